@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
@@ -14,11 +15,21 @@ import (
 	"why-backend/internal/api"
 	"why-backend/internal/api/middleware"
 	"why-backend/internal/config"
+	"why-backend/internal/events"
+	"why-backend/internal/moderation"
+	"why-backend/internal/outbox"
+	"why-backend/internal/realtime"
+	"why-backend/internal/search"
 	"why-backend/internal/storage"
+	"why-backend/internal/stream"
 	"why-backend/internal/telemetry"
+	"why-backend/internal/webhooks"
 )
 
 func main() {
+	workerMode := flag.Bool("worker", false, "Run background jobs only, without the HTTP server")
+	flag.Parse()
+
 	ctx := context.Background()
 
 	// Load configuration
@@ -51,6 +62,21 @@ func main() {
 	}
 	defer db.Close()
 
+	if flag.Arg(0) == "reindex" {
+		runReindex(ctx, db, cfg, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "set-role" {
+		runSetRole(ctx, db, flag.Args()[1:])
+		return
+	}
+
+	worker := newJobWorker(db, cfg)
+	if *workerMode {
+		runWorker(ctx, worker)
+		return
+	}
+
 	// Initialize MinIO
 	minioClient, err := storage.InitMinIO(ctx, cfg.MinIO)
 	if err != nil {
@@ -58,8 +84,69 @@ func main() {
 		log.Fatalf("Failed to initialize MinIO: %v", err)
 	}
 
+	// Start the event bus and the live endpoints; cancelling them closes
+	// open streams and sockets so shutdown does not wait on them
+	eventsCtx, stopEvents := context.WithCancel(ctx)
+	defer stopEvents()
+	bus := events.NewPostgresBus(db, cfg.PostgresURL(), 0)
+	if err := bus.Start(eventsCtx); err != nil {
+		slog.ErrorContext(ctx, "Failed to start event bus", "error", err)
+		log.Fatalf("Failed to start event bus: %v", err)
+	}
+	// Write paths go through the outbox, which the relay publishes to the bus
+	relay, err := outbox.NewRelay(db, cfg.PostgresURL(), bus, outbox.Options{})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to initialize outbox relay", "error", err)
+		log.Fatalf("Failed to initialize outbox relay: %v", err)
+	}
+	if err := relay.Start(eventsCtx); err != nil {
+		slog.ErrorContext(ctx, "Failed to start outbox relay", "error", err)
+		log.Fatalf("Failed to start outbox relay: %v", err)
+	}
+
+	hub := stream.NewHub(bus, stream.Options{})
+	if err := hub.Start(eventsCtx); err != nil {
+		slog.ErrorContext(ctx, "Failed to start event stream", "error", err)
+		log.Fatalf("Failed to start event stream: %v", err)
+	}
+
+	searchIndex, err := openSearchIndex(db, cfg)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to open search index", "error", err)
+		log.Fatalf("Failed to open search index: %v", err)
+	}
+	defer searchIndex.Close()
+	// Postgres keeps its own index current; others follow the write events
+	if cfg.SearchBackend != config.SearchPostgres {
+		search.NewIndexer(db, bus, searchIndex).Start(eventsCtx)
+	}
+
+	sockets := realtime.NewHub(bus, realtime.Options{})
+	sockets.Start(eventsCtx)
+
+	dispatcher := webhooks.NewDispatcher(db, bus, webhooks.Options{
+		AllowPrivateNetworks: cfg.WebhookAllowPrivateNetworks,
+	})
+	dispatcher.Start(eventsCtx)
+
+	// Run jobs in-process unless they are left to dedicated workers
+	runJobs := cfg.JobConcurrency > 0
+	if runJobs {
+		worker.Start(ctx)
+	}
+
+	// Screen new messages and replies
+	filterConfig, err := moderation.LoadConfig(cfg.ContentFilterConfig)
+	if err != nil {
+		log.Fatalf("Failed to load content filter: %v", err)
+	}
+	contentFilter, err := filterConfig.Build(db)
+	if err != nil {
+		log.Fatalf("Failed to load content filter: %v", err)
+	}
+
 	// Create router
-	router := api.NewRouter(db, minioClient, cfg)
+	router := api.NewRouter(db, minioClient, outbox.NewWriter(), searchIndex, hub, sockets, contentFilter, cfg)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -82,6 +169,7 @@ func main() {
 	<-quit
 
 	slog.InfoContext(ctx, "Shutting down server...")
+	stopEvents()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -92,5 +180,10 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Requests are done enqueueing; let running jobs finish
+	if runJobs {
+		stopJobs(context.Background(), worker)
+	}
+
 	slog.InfoContext(ctx, "Server exited")
 }
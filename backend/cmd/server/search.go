@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"log/slog"
+	"time"
+
+	"why-backend/internal/config"
+	"why-backend/internal/search"
+)
+
+// openSearchIndex opens the configured search backend
+func openSearchIndex(db *sql.DB, cfg *config.Config) (search.Index, error) {
+	if cfg.SearchBackend == config.SearchBleve {
+		return search.OpenBleve(cfg.SearchIndexPath)
+	}
+	return search.NewPostgres(db), nil
+}
+
+// runReindex rebuilds the search index from the database: the reindex
+// command. The server must not have the index open meanwhile.
+func runReindex(ctx context.Context, db *sql.DB, cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("reindex", flag.ExitOnError)
+	batchSize := flags.Int("batch-size", search.DefaultBatchSize, "Rows to load and index at a time")
+	flags.Parse(args)
+
+	if cfg.SearchBackend != config.SearchBleve {
+		slog.InfoContext(ctx, "Postgres search is kept current by the database; nothing to reindex")
+		return
+	}
+
+	slog.InfoContext(ctx, "Rebuilding search index", "path", cfg.SearchIndexPath, "batch_size", *batchSize)
+	start := time.Now()
+	total, err := search.RebuildBleve(ctx, db, cfg.SearchIndexPath, *batchSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to rebuild search index", "error", err, "indexed", total)
+		log.Fatalf("Failed to rebuild search index: %v", err)
+	}
+	slog.InfoContext(ctx, "Rebuilt search index", "documents", total, "duration", time.Since(start))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"slices"
+
+	"why-backend/internal/models"
+)
+
+// runSetRole gives a user a role: the set-role command. This is how the first
+// moderators and admins are appointed; the change is written to the
+// moderation audit log without an actor.
+func runSetRole(ctx context.Context, db *sql.DB, args []string) {
+	if len(args) != 2 {
+		log.Fatalf("Usage: server set-role <email> <%s|%s|%s>", models.RoleUser, models.RoleModerator, models.RoleAdmin)
+	}
+	email, role := args[0], args[1]
+	if !slices.Contains([]string{models.RoleUser, models.RoleModerator, models.RoleAdmin}, role) {
+		log.Fatalf("Unknown role %q", role)
+	}
+
+	details, err := json.Marshal(map[string]string{"role": role})
+	if err != nil {
+		log.Fatalf("Failed to encode audit details: %v", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRowContext(ctx,
+		`UPDATE users SET role = $2, updated_at = NOW() WHERE email = $1 RETURNING id`,
+		email, role,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		log.Fatalf("No user with email %s", email)
+	} else if err != nil {
+		log.Fatalf("Failed to set role: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO moderation_audit_log (action, target_type, target_id, details) VALUES ($1, $2, $3, $4)`,
+		models.AuditUserRoleSet, models.ReportTargetUser, userID, details,
+	); err != nil {
+		log.Fatalf("Failed to write audit log: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Fatalf("Failed to commit role change: %v", err)
+	}
+	slog.InfoContext(ctx, "Role set", "user_id", userID, "email", email, "role", role)
+}
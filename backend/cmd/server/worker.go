@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"why-backend/internal/config"
+	"why-backend/internal/jobs"
+)
+
+// jobShutdownTimeout is how long running jobs get to finish on shutdown
+// before they are cancelled and released to another worker
+const jobShutdownTimeout = 30 * time.Second
+
+// newJobWorker returns a worker with every job handler registered
+func newJobWorker(db *sql.DB, cfg *config.Config) *jobs.Worker {
+	worker := jobs.NewWorker(db, jobs.Options{Concurrency: cfg.JobConcurrency})
+	// Register job handlers here, e.g.
+	// jobs.Handle(worker, "email.send", sendEmail)
+	return worker
+}
+
+// runWorker runs jobs until interrupted: the --worker mode
+func runWorker(ctx context.Context, worker *jobs.Worker) {
+	slog.InfoContext(ctx, "Starting job worker")
+	worker.Start(ctx)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.InfoContext(ctx, "Shutting down job worker...")
+	stopJobs(ctx, worker)
+	slog.InfoContext(ctx, "Job worker exited")
+}
+
+// stopJobs waits for running jobs, up to jobShutdownTimeout
+func stopJobs(ctx context.Context, worker *jobs.Worker) {
+	ctx, cancel := context.WithTimeout(ctx, jobShutdownTimeout)
+	defer cancel()
+
+	if err := worker.Shutdown(ctx); err != nil {
+		slog.WarnContext(ctx, "Running jobs were interrupted and will be retried", "error", err)
+	}
+}
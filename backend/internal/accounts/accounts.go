@@ -0,0 +1,161 @@
+// Package accounts tells whether a user may use the API: active, suspended
+// until a given time, or banned.
+//
+// Tokens stay valid for a day, so every authenticated request checks the
+// account's standing. Cache keeps standings for a short while to spare the
+// database; a suspension or ban made on another server takes effect there
+// within the cache's TTL.
+package accounts
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"why-backend/internal/models"
+)
+
+// DefaultCacheTTL is how long Cache keeps a standing
+const DefaultCacheTTL = 30 * time.Second
+
+// maxCacheEntries bounds the cache; past it, the oldest entry is dropped
+const maxCacheEntries = 10000
+
+// ErrUnknownUser is returned for a user that does not exist
+var ErrUnknownUser = errors.New("unknown user")
+
+// Standing is whether a user may use the API and, when not, why and until
+// when. Until is nil for active and banned users.
+type Standing struct {
+	State  string     `json:"state"`
+	Reason string     `json:"reason,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+// Active reports whether the user may use the API
+func (s Standing) Active() bool {
+	return s.State == models.AccountActive
+}
+
+// Checker looks up users' standing
+type Checker interface {
+	Standing(ctx context.Context, userID string) (Standing, error)
+}
+
+// record is the account state as stored; its standing depends on the time
+type record struct {
+	banned           bool
+	banReason        string
+	suspendedUntil   sql.NullTime
+	suspensionReason string
+}
+
+func (r record) standing(now time.Time) Standing {
+	switch {
+	case r.banned:
+		return Standing{State: models.AccountBanned, Reason: r.banReason}
+	case r.suspendedUntil.Valid && r.suspendedUntil.Time.After(now):
+		until := r.suspendedUntil.Time
+		return Standing{State: models.AccountSuspended, Reason: r.suspensionReason, Until: &until}
+	}
+	return Standing{State: models.AccountActive}
+}
+
+func load(ctx context.Context, db *sql.DB, userID string) (record, error) {
+	var r record
+	err := db.QueryRowContext(ctx,
+		`SELECT banned_at IS NOT NULL, COALESCE(ban_reason, ''), suspended_until, COALESCE(suspension_reason, '')
+		 FROM users WHERE id = $1`,
+		userID,
+	).Scan(&r.banned, &r.banReason, &r.suspendedUntil, &r.suspensionReason)
+	if err == sql.ErrNoRows {
+		return r, ErrUnknownUser
+	}
+	return r, err
+}
+
+type cacheEntry struct {
+	userID  string
+	record  record
+	expires time.Time
+}
+
+// Cache is a Checker that reads standings from the database and keeps them
+// for ttl
+type Cache struct {
+	db  *sql.DB
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order holds the entries oldest first; every entry lives for ttl, so
+	// that is also the order they expire in
+	order *list.List
+}
+
+func NewCache(db *sql.DB, ttl time.Duration) *Cache {
+	return &Cache{db: db, ttl: ttl, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// Standing returns userID's standing, from the cache when it is fresh
+func (c *Cache) Standing(ctx context.Context, userID string) (Standing, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	var entry cacheEntry
+	elem, ok := c.entries[userID]
+	if ok {
+		entry = *elem.Value.(*cacheEntry)
+	}
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.record.standing(now), nil
+	}
+
+	return c.Refresh(ctx, userID)
+}
+
+// Refresh reads userID's standing from the database and caches it. Logins
+// and token refreshes use it so they never act on a stale standing.
+func (c *Cache) Refresh(ctx context.Context, userID string) (Standing, error) {
+	r, err := load(ctx, c.db, userID)
+	if err != nil {
+		return Standing{}, err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	if elem, ok := c.entries[userID]; ok {
+		c.remove(elem)
+	}
+	// Drop expired entries from the front, and the oldest live one if still full
+	for front := c.order.Front(); front != nil; front = c.order.Front() {
+		if now.Before(front.Value.(*cacheEntry).expires) && len(c.entries) < maxCacheEntries {
+			break
+		}
+		c.remove(front)
+	}
+	c.entries[userID] = c.order.PushBack(&cacheEntry{userID: userID, record: r, expires: now.Add(c.ttl)})
+	c.mu.Unlock()
+
+	return r.standing(now), nil
+}
+
+// Forget drops userID's cached standing, so a change made on this server
+// applies to their next request
+func (c *Cache) Forget(userID string) {
+	c.mu.Lock()
+	if elem, ok := c.entries[userID]; ok {
+		c.remove(elem)
+	}
+	c.mu.Unlock()
+}
+
+// remove drops an entry; c.mu must be held
+func (c *Cache) remove(elem *list.Element) {
+	delete(c.entries, elem.Value.(*cacheEntry).userID)
+	c.order.Remove(elem)
+}
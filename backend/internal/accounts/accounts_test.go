@@ -0,0 +1,186 @@
+package accounts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+)
+
+var standingColumns = []string{"banned", "ban_reason", "suspended_until", "suspension_reason"}
+
+func expectLoad(mock sqlmock.Sqlmock, userID string, rows *sqlmock.Rows) {
+	mock.ExpectQuery("SELECT banned_at IS NOT NULL, COALESCE\\(ban_reason, ''\\), suspended_until, COALESCE\\(suspension_reason, ''\\) FROM users WHERE id = \\$1").
+		WithArgs(userID).
+		WillReturnRows(rows)
+}
+
+func TestRecordStanding(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		record record
+		want   Standing
+	}{
+		{"active", record{}, Standing{State: models.AccountActive}},
+		{
+			"suspended",
+			record{suspendedUntil: sql.NullTime{Time: later, Valid: true}, suspensionReason: "spam"},
+			Standing{State: models.AccountSuspended, Reason: "spam", Until: &later},
+		},
+		{
+			"suspension over",
+			record{suspendedUntil: sql.NullTime{Time: now.Add(-time.Hour), Valid: true}, suspensionReason: "spam"},
+			Standing{State: models.AccountActive},
+		},
+		{
+			"banned while suspended",
+			record{banned: true, banReason: "abuse", suspendedUntil: sql.NullTime{Time: later, Valid: true}},
+			Standing{State: models.AccountBanned, Reason: "abuse"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.record.standing(now)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want.State == models.AccountActive, got.Active())
+		})
+	}
+}
+
+func TestCache_Standing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	cache := NewCache(db, time.Minute)
+	ctx := context.Background()
+
+	until := time.Now().Add(time.Hour)
+	expectLoad(mock, "user-1", sqlmock.NewRows(standingColumns).AddRow(false, "", until, "spam"))
+
+	// Read once, then answered from the cache
+	for range 3 {
+		standing, err := cache.Standing(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, models.AccountSuspended, standing.State)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Forgetting the user, as a moderator lifting the suspension does, reads
+	// the new standing
+	expectLoad(mock, "user-1", sqlmock.NewRows(standingColumns).AddRow(false, "", nil, ""))
+	cache.Forget("user-1")
+
+	standing, err := cache.Standing(ctx, "user-1")
+	require.NoError(t, err)
+	assert.True(t, standing.Active())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCache_Standing_Expires(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	cache := NewCache(db, time.Millisecond)
+	ctx := context.Background()
+
+	expectLoad(mock, "user-1", sqlmock.NewRows(standingColumns).AddRow(false, "", nil, ""))
+	expectLoad(mock, "user-1", sqlmock.NewRows(standingColumns).AddRow(true, "abuse", nil, ""))
+
+	standing, err := cache.Standing(ctx, "user-1")
+	require.NoError(t, err)
+	assert.True(t, standing.Active())
+
+	time.Sleep(5 * time.Millisecond)
+
+	standing, err = cache.Standing(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, Standing{State: models.AccountBanned, Reason: "abuse"}, standing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCache_Refresh_Bounded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	cache := NewCache(db, time.Minute)
+
+	// A full cache of live entries, user-0 the oldest
+	now := time.Now()
+	for i := range maxCacheEntries {
+		fill(cache, fmt.Sprintf("user-%d", i), now.Add(time.Duration(i+1)*time.Second))
+	}
+
+	expectLoad(mock, "newcomer", sqlmock.NewRows(standingColumns).AddRow(false, "", nil, ""))
+	_, err = cache.Refresh(context.Background(), "newcomer")
+	require.NoError(t, err)
+
+	assert.Len(t, cache.entries, maxCacheEntries)
+	assert.Contains(t, cache.entries, "newcomer")
+	assert.NotContains(t, cache.entries, "user-0")
+
+	// Refreshing a cached user replaces its entry and evicts nothing
+	expectLoad(mock, "user-1", sqlmock.NewRows(standingColumns).AddRow(false, "", nil, ""))
+	_, err = cache.Refresh(context.Background(), "user-1")
+	require.NoError(t, err)
+
+	assert.Len(t, cache.entries, maxCacheEntries)
+	assert.Contains(t, cache.entries, "user-2")
+	assert.Equal(t, maxCacheEntries, cache.order.Len())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCache_Refresh_DropsExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	cache := NewCache(db, time.Minute)
+
+	now := time.Now()
+	fill(cache, "gone-1", now.Add(-time.Second))
+	fill(cache, "gone-2", now.Add(-time.Millisecond))
+	fill(cache, "live", now.Add(time.Second))
+
+	expectLoad(mock, "newcomer", sqlmock.NewRows(standingColumns).AddRow(false, "", nil, ""))
+	_, err = cache.Refresh(context.Background(), "newcomer")
+	require.NoError(t, err)
+
+	assert.Len(t, cache.entries, 2)
+	assert.Contains(t, cache.entries, "live")
+	assert.Equal(t, "newcomer", cache.order.Back().Value.(*cacheEntry).userID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// fill caches an active standing for userID that expires at expires
+func fill(cache *Cache, userID string, expires time.Time) {
+	cache.entries[userID] = cache.order.PushBack(&cacheEntry{userID: userID, expires: expires})
+}
+
+func TestCache_Refresh_UnknownUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	cache := NewCache(db, time.Minute)
+
+	mock.ExpectQuery("FROM users WHERE id = \\$1").
+		WithArgs("user-1").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = cache.Refresh(context.Background(), "user-1")
+	assert.ErrorIs(t, err, ErrUnknownUser)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
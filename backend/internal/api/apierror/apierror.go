@@ -0,0 +1,191 @@
+// Package apierror is the one error type handlers and middleware fail
+// requests with, and its rendering as RFC 7807 application/problem+json.
+//
+// Handlers call Abort with an *Error; the error middleware writes it once
+// the handler returns:
+//
+//	{
+//	  "type": "about:blank",
+//	  "title": "Not Found",
+//	  "status": 404,
+//	  "detail": "message not found",
+//	  "code": "not_found",
+//	  "instance": "/api/v1/messages/123",
+//	  "trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"
+//	}
+//
+// Clients branch on code, which is stable; detail is for people and may
+// change.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"why-backend/internal/models"
+)
+
+// ContentType is the media type of error responses
+const ContentType = "application/problem+json"
+
+// Codes. Each status has a general code; some failures clients handle
+// specially have their own.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInvalidToken       = "invalid_token"
+	CodeForbidden          = "forbidden"
+	CodeAccountSuspended   = "account_suspended"
+	CodeAccountBanned      = "account_banned"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeConflict           = "conflict"
+	CodeEmailTaken         = "email_taken"
+	CodeHandleTaken        = "handle_taken"
+	CodeRequestTooLarge    = "request_too_large"
+	CodeContentRejected    = "content_rejected"
+	CodeInternal           = "internal_error"
+)
+
+// Error is a failed request: its status, code and detail, and for invalid
+// requests what is wrong with each field
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []models.FieldError
+	// Extensions are added to the problem as members of their own
+	Extensions map[string]any
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Detail
+}
+
+// With returns e with an extension member
+func (e *Error) With(key string, value any) *Error {
+	ext := make(map[string]any, len(e.Extensions)+1)
+	for k, v := range e.Extensions {
+		ext[k] = v
+	}
+	ext[key] = value
+	copied := *e
+	copied.Extensions = ext
+	return &copied
+}
+
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+// Validation is a request with invalid fields
+func Validation(fields []models.FieldError) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Detail: "invalid request", Fields: fields}
+}
+
+func Unauthorized(detail string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(detail string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func Conflict(detail string) *Error {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+// Internal is a failure that is not the client's; detail says what failed,
+// never why
+func Internal(detail string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, detail)
+}
+
+// Abort ends the request with err, which the error middleware writes
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Respond writes the last error of the request as a problem, unless a
+// response was already written. It is the error middleware's work after the
+// handlers have run.
+func Respond(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+	Write(c, c.Errors.Last().Err)
+}
+
+// Write writes err as a problem. Errors other than *Error are internal.
+func Write(c *gin.Context, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal("internal error")
+	}
+	p := problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(e.Status),
+		Status:   e.Status,
+		Detail:   e.Detail,
+		Code:     e.Code,
+		Instance: c.Request.URL.Path,
+		Errors:   e.Fields,
+	}
+	if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+		p.TraceID = sc.TraceID().String()
+	}
+
+	body, err := p.marshal(e.Extensions)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(e.Status, ContentType, body)
+	c.Abort()
+}
+
+// problem is the RFC 7807 body
+type problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Code     string              `json:"code"`
+	Instance string              `json:"instance,omitempty"`
+	TraceID  string              `json:"trace_id,omitempty"`
+	Errors   []models.FieldError `json:"errors,omitempty"`
+}
+
+// marshal encodes p with the extension members alongside its own
+func (p problem) marshal(extensions map[string]any) ([]byte, error) {
+	body, err := json.Marshal(p)
+	if err != nil || len(extensions) == 0 {
+		return body, err
+	}
+	members := make(map[string]any, len(extensions))
+	for k, v := range extensions {
+		members[k] = v
+	}
+	var own map[string]json.RawMessage
+	if err := json.Unmarshal(body, &own); err != nil {
+		return nil, err
+	}
+	for k, v := range own {
+		members[k] = v
+	}
+	return json.Marshal(members)
+}
@@ -0,0 +1,105 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"why-backend/internal/models"
+)
+
+func newContext() (*httptest.ResponseRecorder, *gin.Context) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/messages", nil)
+	return w, c
+}
+
+func TestWrite(t *testing.T) {
+	w, c := newContext()
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(c.Request.Context(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	c.Request = c.Request.WithContext(ctx)
+
+	Write(c, Validation([]models.FieldError{{Field: "content", Message: "is required"}}))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "invalid request",
+		"code": "validation_failed",
+		"instance": "/api/v1/messages",
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"errors": [{"field": "content", "message": "is required"}]
+	}`, w.Body.String())
+	assert.True(t, c.IsAborted())
+}
+
+func TestWrite_Extensions(t *testing.T) {
+	w, c := newContext()
+	err := New(http.StatusUnprocessableEntity, CodeContentRejected, "message rejected").With("reason", "matched slurs list")
+
+	Write(c, err)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "content_rejected", body["code"])
+	assert.Equal(t, "matched slurs list", body["reason"])
+	// With copies rather than changing err
+	assert.Len(t, err.With("other", 1).Extensions, 2)
+	assert.Len(t, err.Extensions, 1)
+}
+
+func TestWrite_OtherErrorsAreInternal(t *testing.T) {
+	w, c := newContext()
+
+	Write(c, errors.New("pq: connection refused"))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"internal_error"`)
+	assert.NotContains(t, w.Body.String(), "connection refused")
+}
+
+func TestRespond(t *testing.T) {
+	t.Run("last error", func(t *testing.T) {
+		w, c := newContext()
+		Abort(c, BadRequest("invalid sort"))
+		Abort(c, NotFound("message not found"))
+
+		Respond(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"detail":"message not found"`)
+	})
+
+	t.Run("already written", func(t *testing.T) {
+		w, c := newContext()
+		c.String(http.StatusAccepted, "ok")
+		Abort(c, Internal("failed to notify"))
+
+		Respond(c)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "ok", w.Body.String())
+	})
+
+	t.Run("no error", func(t *testing.T) {
+		w, c := newContext()
+
+		Respond(c)
+
+		assert.False(t, c.Writer.Written())
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
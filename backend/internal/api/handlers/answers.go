@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+)
+
+// replyReadableBy is an SQL condition that holds when viewer can see reply r
+// in thread m: the thread is readable, the reply is not hidden, filtered or by
+// a banned user, and viewer has no block with either author
+func replyReadableBy(viewer string) string {
+	return readableBy(viewer) + ` AND NOT ` + blockedBetween("m.user_id", viewer) +
+		` AND r.hidden_at IS NULL AND ` + notBanned("r.user_id") + ` AND ` + unfiltered("r", viewer) +
+		` AND NOT ` + blockedBetween("r.user_id", viewer)
+}
+
+// AcceptReply marks a reply as the accepted answer to its message.
+// Only the message author may accept; accepting another reply replaces the previous one.
+func (h *MessageHandler) AcceptReply(c *gin.Context) {
+	ctx, span := messageTracer.Start(c.Request.Context(), "AcceptReply")
+	defer span.End()
+
+	messageID := c.Param("id")
+	replyID := c.Param("reply_id")
+	userID, _ := c.Get("user_id")
+
+	span.SetAttributes(
+		attribute.String("message.id", messageID),
+		attribute.String("reply.id", replyID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if !h.authorizeMessageAuthor(c, messageID, userID.(string)) {
+		return
+	}
+
+	var message models.Message
+	err := scanMessage(h.db.QueryRowContext(ctx,
+		`UPDATE messages m SET accepted_reply_id = $1, updated_at = NOW()
+		 WHERE m.id = $2 AND EXISTS (
+		   SELECT 1 FROM replies r JOIN messages m ON m.id = r.message_id
+		   WHERE r.id = $1 AND r.message_id = $2 AND `+replyReadableBy("$3")+`)
+		 RETURNING `+messageColumns,
+		replyID, messageID, userID,
+	), &message)
+
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("reply not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to accept reply", "error", err, "message_id", messageID, "reply_id", replyID)
+		apierror.Abort(c, apierror.Internal("failed to accept reply"))
+		return
+	}
+
+	slog.InfoContext(ctx, "Reply accepted", "message_id", messageID, "reply_id", replyID, "user_id", userID)
+
+	// The answer is accepted either way; a failed notification is only logged
+	var replyAuthorID string
+	err = h.db.QueryRowContext(ctx, `SELECT user_id FROM replies WHERE id = $1`, replyID).Scan(&replyAuthorID)
+	if err == nil {
+		err = notify(ctx, h.db, notificationEvent{
+			Type:      models.NotificationAcceptedAnswer,
+			ActorID:   userID.(string),
+			MessageID: messageID,
+			ReplyID:   sql.NullString{String: replyID, Valid: true},
+		}, replyAuthorID)
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.WarnContext(ctx, "Failed to notify accepted answer", "error", err, "reply_id", replyID)
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// UnacceptReply clears the accepted answer if it is the given reply
+func (h *MessageHandler) UnacceptReply(c *gin.Context) {
+	ctx, span := messageTracer.Start(c.Request.Context(), "UnacceptReply")
+	defer span.End()
+
+	messageID := c.Param("id")
+	replyID := c.Param("reply_id")
+	userID, _ := c.Get("user_id")
+
+	span.SetAttributes(
+		attribute.String("message.id", messageID),
+		attribute.String("reply.id", replyID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if !h.authorizeMessageAuthor(c, messageID, userID.(string)) {
+		return
+	}
+
+	var message models.Message
+	err := scanMessage(h.db.QueryRowContext(ctx,
+		`UPDATE messages m SET accepted_reply_id = NULL, updated_at = NOW()
+		 WHERE m.id = $1 AND m.accepted_reply_id = $2
+		 RETURNING `+messageColumns,
+		messageID, replyID,
+	), &message)
+
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("reply is not the accepted answer"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to unaccept reply", "error", err, "message_id", messageID, "reply_id", replyID)
+		apierror.Abort(c, apierror.Internal("failed to unaccept reply"))
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// authorizeMessageAuthor writes a 404 or 403 response and returns false unless
+// userID is the author of the message
+func (h *MessageHandler) authorizeMessageAuthor(c *gin.Context, messageID, userID string) bool {
+	ctx := c.Request.Context()
+
+	var authorID string
+	err := h.db.QueryRowContext(ctx,
+		`SELECT user_id FROM messages WHERE id = $1`,
+		messageID,
+	).Scan(&authorID)
+
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("message not found"))
+		return false
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Failed to get message author", "error", err, "message_id", messageID)
+		apierror.Abort(c, apierror.Internal("failed to get message"))
+		return false
+	}
+
+	if authorID != userID {
+		apierror.Abort(c, apierror.Forbidden("only the message author can do this"))
+		return false
+	}
+
+	return true
+}
+
+// VoteReply records the caller's up (1) or down (-1) vote on a reply, or clears it (0),
+// and returns the reply's new score
+func (h *MessageHandler) VoteReply(c *gin.Context) {
+	ctx, span := messageTracer.Start(c.Request.Context(), "VoteReply")
+	defer span.End()
+
+	replyID := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	span.SetAttributes(
+		attribute.String("reply.id", replyID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	var req models.VoteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	value := *req.Value
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to vote"))
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the reply so concurrent votes recompute the score one at a time. A
+	// reply the caller cannot see is reported as missing.
+	var replyAuthorID, messageID string
+	err = tx.QueryRowContext(ctx,
+		`SELECT r.user_id, r.message_id FROM replies r JOIN messages m ON m.id = r.message_id
+		 WHERE r.id = $1 AND `+replyReadableBy("$2")+`
+		 FOR UPDATE OF r`,
+		replyID, userID,
+	).Scan(&replyAuthorID, &messageID)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("reply not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to lock reply", "error", err, "reply_id", replyID)
+		apierror.Abort(c, apierror.Internal("failed to vote"))
+		return
+	}
+
+	if value == 0 {
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM reply_votes WHERE reply_id = $1 AND user_id = $2`,
+			replyID, userID,
+		)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO reply_votes (reply_id, user_id, value)
+			 VALUES ($1, $2, $3)
+			 ON CONFLICT (reply_id, user_id) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()`,
+			replyID, userID, value,
+		)
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to record vote", "error", err, "reply_id", replyID, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to vote"))
+		return
+	}
+
+	// Upvotes are the reactions a reply author hears about
+	if value == 1 {
+		err = notify(ctx, tx, notificationEvent{
+			Type:      models.NotificationReaction,
+			ActorID:   userID.(string),
+			MessageID: messageID,
+			ReplyID:   sql.NullString{String: replyID, Valid: true},
+		}, replyAuthorID)
+		if err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to notify vote", "error", err, "reply_id", replyID)
+			apierror.Abort(c, apierror.Internal("failed to vote"))
+			return
+		}
+	}
+
+	var score int
+	err = tx.QueryRowContext(ctx,
+		`UPDATE replies SET score = (SELECT COALESCE(SUM(value), 0) FROM reply_votes WHERE reply_id = $1)
+		 WHERE id = $1
+		 RETURNING score`,
+		replyID,
+	).Scan(&score)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to update reply score", "error", err, "reply_id", replyID)
+		apierror.Abort(c, apierror.Internal("failed to vote"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to commit vote", "error", err, "reply_id", replyID)
+		apierror.Abort(c, apierror.Internal("failed to vote"))
+		return
+	}
+
+	span.SetAttributes(attribute.Int("reply.score", score))
+
+	c.JSON(http.StatusOK, models.VoteResponse{
+		ReplyID: replyID,
+		Score:   score,
+		Vote:    value,
+	})
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/content"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+	"why-backend/internal/testutil"
+)
+
+func TestMessageHandler_AcceptReply_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id").
+		WithArgs("msg-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-123"))
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"}).
+		AddRow("msg-123", "user-123", "Why?", pq.StringArray{}, "reply-1", pq.StringArray{}, `[]`, now, now, "public")
+	mock.ExpectQuery("UPDATE messages m SET accepted_reply_id").
+		WithArgs("reply-1", "msg-123", "user-123").
+		WillReturnRows(rows)
+	mock.ExpectQuery("SELECT user_id FROM replies WHERE id").
+		WithArgs("reply-1").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-456"))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-123", models.NotificationAcceptedAnswer, "msg-123", "reply-1", pq.Array([]string{"user-456"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/msg-123/replies/reply-1/accept", nil)
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}, {Key: "reply_id", Value: "reply-1"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.AcceptReply)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Message
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.NotNil(t, response.AcceptedReplyID)
+	assert.Equal(t, "reply-1", *response.AcceptedReplyID)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_AcceptReply_NotAuthor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id").
+		WithArgs("msg-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("someone-else"))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/msg-123/replies/reply-1/accept", nil)
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}, {Key: "reply_id", Value: "reply-1"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.AcceptReply)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_AcceptReply_ReplyNotInThread(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id").
+		WithArgs("msg-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-123"))
+	mock.ExpectQuery("UPDATE messages m SET accepted_reply_id").
+		WithArgs("reply-other", "msg-123", "user-123").
+		WillReturnError(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/msg-123/replies/reply-other/accept", nil)
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}, {Key: "reply_id", Value: "reply-other"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.AcceptReply)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "reply not found")
+}
+
+func TestMessageHandler_AcceptReply_Unreadable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id").
+		WithArgs("msg-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-123"))
+	// A hidden reply, or one by a user blocked either way, cannot be accepted
+	mock.ExpectQuery("UPDATE messages m SET accepted_reply_id .* r.hidden_at IS NULL .* user_blocks").
+		WithArgs("reply-blocked", "msg-123", "user-123").
+		WillReturnError(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/msg-123/replies/reply-blocked/accept", nil)
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}, {Key: "reply_id", Value: "reply-blocked"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.AcceptReply)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_VoteReply_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT r.user_id, r.message_id FROM replies r .* FOR UPDATE OF r").
+		WithArgs("reply-1", "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "message_id"}).AddRow("user-456", "msg-123"))
+	mock.ExpectExec("INSERT INTO reply_votes").
+		WithArgs("reply-1", "user-123", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-123", models.NotificationReaction, "msg-123", "reply-1", pq.Array([]string{"user-456"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE replies SET score").
+		WithArgs("reply-1").
+		WillReturnRows(sqlmock.NewRows([]string{"score"}).AddRow(3))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/replies/reply-1/vote", bytes.NewBufferString(`{"value":1}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "reply-1"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.VoteReply)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.VoteResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Equal(t, 3, response.Score)
+	assert.Equal(t, 1, response.Vote)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_VoteReply_Clear(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT r.user_id, r.message_id FROM replies r").
+		WithArgs("reply-1", "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "message_id"}).AddRow("user-456", "msg-123"))
+	mock.ExpectExec("DELETE FROM reply_votes").
+		WithArgs("reply-1", "user-123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE replies SET score").
+		WithArgs("reply-1").
+		WillReturnRows(sqlmock.NewRows([]string{"score"}).AddRow(0))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/replies/reply-1/vote", bytes.NewBufferString(`{"value":0}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "reply-1"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.VoteReply)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_VoteReply_InvalidValue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	for _, body := range []string{`{"value":2}`, `{}`} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("PUT", "/replies/reply-1/vote", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: "reply-1"}}
+		c.Set("user_id", "user-123")
+
+		testutil.Serve(t, c, handler.VoteReply)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestMessageHandler_VoteReply_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT r.user_id, r.message_id FROM replies r").
+		WithArgs("nonexistent", "user-123").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/replies/nonexistent/vote", bytes.NewBufferString(`{"value":-1}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "nonexistent"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.VoteReply)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_VoteReply_Unreadable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	// The reply exists but its thread is followers-only or its author blocked
+	// the voter, so the lock finds nothing and no vote or notification is made
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT r.user_id, r.message_id FROM replies r JOIN messages m .*m.visibility .* user_blocks").
+		WithArgs("reply-1", "user-123").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/replies/reply-1/vote", bytes.NewBufferString(`{"value":1}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "reply-1"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.VoteReply)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "reply not found")
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_ListReplies_SortByScore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	messageID := "msg-123"
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "mentions", "score", "accepted", "created_at", "updated_at"}).
+		AddRow("reply-2", messageID, "user-2", "Accepted answer", pq.StringArray{}, `[]`, 1, true, now, now).
+		AddRow("reply-1", messageID, "user-1", "Popular answer", pq.StringArray{}, `[]`, 5, false, now, now)
+
+	mock.ExpectQuery("FROM replies r .+ ORDER BY accepted DESC, r.score DESC").
+		WithArgs(messageID).
+		WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages/"+messageID+"/replies?sort=score", nil)
+	c.Params = gin.Params{{Key: "id", Value: messageID}}
+
+	testutil.Serve(t, c, handler.ListReplies)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []models.Reply
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Len(t, response, 2)
+	assert.True(t, response[0].Accepted)
+	assert.Equal(t, 5, response[1].Score)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_ListReplies_InvalidSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages/msg-123/replies?sort=random", nil)
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}}
+
+	testutil.Serve(t, c, handler.ListReplies)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMessageHandler_ListMessages_Unanswered(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"}).
+		AddRow("msg-1", "user-1", "Why is the sky blue?", pq.StringArray{}, nil, pq.StringArray{}, `[]`, now, now, "public")
+
+	mock.ExpectQuery("FROM messages m WHERE m.accepted_reply_id IS NULL AND m.visibility = 'public' AND m.hidden_at IS NULL " +
+		"AND NOT EXISTS \\(SELECT 1 FROM users bu WHERE bu.id = m.user_id AND bu.banned_at IS NOT NULL\\) AND m.filter_action IS NULL ORDER BY m.created_at DESC").
+		WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages?filter=unanswered", nil)
+
+	testutil.Serve(t, c, handler.ListMessages)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []models.Message
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Len(t, response, 1)
+	assert.Nil(t, response[0].AcceptedReplyID)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_ListMessages_InvalidFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages?filter=bogus", nil)
+
+	testutil.Serve(t, c, handler.ListMessages)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"log/slog"
 	"net/http"
@@ -8,22 +9,27 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/accounts"
+	"why-backend/internal/api/apierror"
 	"why-backend/internal/auth"
 	"why-backend/internal/config"
+	"why-backend/internal/content"
 	"why-backend/internal/models"
 )
 
 var authTracer = otel.Tracer("why-backend/handlers/auth")
 
 type AuthHandler struct {
-	db     *sql.DB
-	config *config.Config
+	db       *sql.DB
+	config   *config.Config
+	accounts *accounts.Cache
 }
 
-func NewAuthHandler(db *sql.DB, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(db *sql.DB, cfg *config.Config, accountCache *accounts.Cache) *AuthHandler {
 	return &AuthHandler{
-		db:     db,
-		config: cfg,
+		db:       db,
+		config:   cfg,
+		accounts: accountCache,
 	}
 }
 
@@ -33,35 +39,41 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 	defer span.End()
 
 	var req models.SignupRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		span.RecordError(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	span.SetAttributes(attribute.String("user.email", req.Email))
 
+	if req.Handle != "" && !content.IsValidHandle(req.Handle) {
+		apierror.Abort(c, apierror.BadRequest("handle must be 1-30 letters, digits or underscores"))
+		return
+	}
+
 	// Hash password
 	passwordHash, err := auth.HashPassword(req.Password)
 	if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to hash password", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+		apierror.Abort(c, apierror.Internal("failed to create user"))
 		return
 	}
 
 	// Create user
 	var user models.User
 	err = h.db.QueryRowContext(ctx,
-		`INSERT INTO users (email, password_hash) VALUES ($1, $2)
-		 RETURNING id, email, created_at, updated_at`,
-		req.Email, passwordHash,
-	).Scan(&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+		`INSERT INTO users (email, password_hash, handle) VALUES ($1, $2, NULLIF($3, ''))
+		 RETURNING id, email, handle, role, created_at, updated_at`,
+		req.Email, passwordHash, req.Handle,
+	).Scan(&user.ID, &user.Email, &user.Handle, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 
-	if err != nil {
+	if isUniqueViolation(err, "idx_users_handle") {
+		apierror.Abort(c, apierror.New(http.StatusConflict, apierror.CodeHandleTaken, "handle already taken"))
+		return
+	} else if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to create user", "error", err, "email", req.Email)
-		c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+		apierror.Abort(c, apierror.New(http.StatusConflict, apierror.CodeEmailTaken, "email already exists"))
 		return
 	}
 
@@ -70,7 +82,7 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 	if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to generate token", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create token"})
+		apierror.Abort(c, apierror.Internal("failed to create token"))
 		return
 	}
 
@@ -89,9 +101,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	defer span.End()
 
 	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		span.RecordError(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -100,18 +110,18 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	// Get user by email
 	var user models.User
 	err := h.db.QueryRowContext(ctx,
-		`SELECT id, email, password_hash, created_at, updated_at FROM users WHERE email = $1`,
+		`SELECT id, email, handle, role, password_hash, created_at, updated_at FROM users WHERE email = $1`,
 		req.Email,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Email, &user.Handle, &user.Role, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		span.SetAttributes(attribute.Bool("auth.failed", true))
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+		apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "invalid email or password"))
 		return
 	} else if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Database error during login", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		apierror.Abort(c, apierror.Internal("login failed"))
 		return
 	}
 
@@ -119,7 +129,12 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
 		span.SetAttributes(attribute.Bool("auth.failed", true))
 		slog.WarnContext(ctx, "Failed login attempt", "email", req.Email)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+		apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "invalid email or password"))
+		return
+	}
+
+	// Suspended and banned users are told why only once their password is right
+	if !h.checkStanding(ctx, c, user.ID) {
 		return
 	}
 
@@ -128,7 +143,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to generate token", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create token"})
+		apierror.Abort(c, apierror.Internal("failed to create token"))
 		return
 	}
 
@@ -143,3 +158,63 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		User:  user,
 	})
 }
+
+// RefreshToken issues the authenticated user a new token. The account's
+// standing is read afresh, so suspended and banned users cannot extend their
+// sessions.
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	ctx, span := authTracer.Start(c.Request.Context(), "RefreshToken")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	var user models.User
+	err := h.db.QueryRowContext(ctx,
+		`SELECT id, email, handle, role, created_at, updated_at FROM users WHERE id = $1`,
+		userID,
+	).Scan(&user.ID, &user.Email, &user.Handle, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "invalid or expired token"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get user", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to refresh token"))
+		return
+	}
+
+	if !h.checkStanding(ctx, c, user.ID) {
+		return
+	}
+
+	token, err := auth.GenerateToken(user.ID, user.Email, h.config.JWTSecret)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to generate token", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to create token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		Token: token,
+		User:  user,
+	})
+}
+
+// checkStanding reads userID's account standing from the database and
+// writes a 403 saying why when the account is suspended or banned
+func (h *AuthHandler) checkStanding(ctx context.Context, c *gin.Context, userID string) bool {
+	standing, err := h.accounts.Refresh(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to check account standing", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to check account"))
+		return false
+	}
+	if !standing.Active() {
+		slog.WarnContext(ctx, "Sign-in refused", "user_id", userID, "state", standing.State)
+		apierror.Abort(c, apierror.New(http.StatusForbidden, "account_"+standing.State, "account "+standing.State).With("account", standing))
+		return false
+	}
+	return true
+}
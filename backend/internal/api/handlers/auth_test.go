@@ -11,8 +11,11 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"why-backend/internal/accounts"
+	"why-backend/internal/api/apierror"
 	"why-backend/internal/auth"
 	"why-backend/internal/models"
 	"why-backend/internal/testutil"
@@ -24,7 +27,7 @@ func TestAuthHandler_Signup_Success(t *testing.T) {
 	defer db.Close()
 
 	cfg := testutil.GetTestConfig()
-	handler := NewAuthHandler(db, cfg)
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
 
 	// Setup request
 	signupReq := models.SignupRequest{
@@ -35,11 +38,11 @@ func TestAuthHandler_Signup_Success(t *testing.T) {
 
 	// Mock database response
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "email", "created_at", "updated_at"}).
-		AddRow("user-123", signupReq.Email, now, now)
+	rows := sqlmock.NewRows([]string{"id", "email", "handle", "role", "created_at", "updated_at"}).
+		AddRow("user-123", signupReq.Email, nil, models.RoleUser, now, now)
 
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs(signupReq.Email, sqlmock.AnyArg()).
+		WithArgs(signupReq.Email, sqlmock.AnyArg(), "").
 		WillReturnRows(rows)
 
 	// Create request
@@ -49,7 +52,7 @@ func TestAuthHandler_Signup_Success(t *testing.T) {
 	c.Request.Header.Set("Content-Type", "application/json")
 
 	// Execute
-	handler.Signup(c)
+	testutil.Serve(t, c, handler.Signup)
 
 	// Assert
 	assert.Equal(t, http.StatusCreated, w.Code)
@@ -71,7 +74,7 @@ func TestAuthHandler_Signup_InvalidJSON(t *testing.T) {
 	defer db.Close()
 
 	cfg := testutil.GetTestConfig()
-	handler := NewAuthHandler(db, cfg)
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
 
 	// Invalid JSON
 	body := []byte(`{"email": "test@example.com", "password":`)
@@ -81,7 +84,7 @@ func TestAuthHandler_Signup_InvalidJSON(t *testing.T) {
 	c.Request = httptest.NewRequest("POST", "/signup", bytes.NewBuffer(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 
-	handler.Signup(c)
+	testutil.Serve(t, c, handler.Signup)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
@@ -92,7 +95,7 @@ func TestAuthHandler_Signup_ValidationError(t *testing.T) {
 	defer db.Close()
 
 	cfg := testutil.GetTestConfig()
-	handler := NewAuthHandler(db, cfg)
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
 
 	tests := []struct {
 		name    string
@@ -123,7 +126,7 @@ func TestAuthHandler_Signup_ValidationError(t *testing.T) {
 			c.Request = httptest.NewRequest("POST", "/signup", bytes.NewBuffer(body))
 			c.Request.Header.Set("Content-Type", "application/json")
 
-			handler.Signup(c)
+			testutil.Serve(t, c, handler.Signup)
 
 			assert.Equal(t, http.StatusBadRequest, w.Code)
 		})
@@ -136,7 +139,7 @@ func TestAuthHandler_Signup_DuplicateEmail(t *testing.T) {
 	defer db.Close()
 
 	cfg := testutil.GetTestConfig()
-	handler := NewAuthHandler(db, cfg)
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
 
 	signupReq := models.SignupRequest{
 		Email:    "existing@example.com",
@@ -146,7 +149,7 @@ func TestAuthHandler_Signup_DuplicateEmail(t *testing.T) {
 
 	// Mock duplicate email error
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs(signupReq.Email, sqlmock.AnyArg()).
+		WithArgs(signupReq.Email, sqlmock.AnyArg(), "").
 		WillReturnError(sql.ErrConnDone) // Simulating conflict
 
 	w := httptest.NewRecorder()
@@ -154,18 +157,73 @@ func TestAuthHandler_Signup_DuplicateEmail(t *testing.T) {
 	c.Request = httptest.NewRequest("POST", "/signup", bytes.NewBuffer(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 
-	handler.Signup(c)
+	testutil.Serve(t, c, handler.Signup)
 
 	assert.Equal(t, http.StatusConflict, w.Code)
 }
 
+func TestAuthHandler_Signup_InvalidHandle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	signupReq := models.SignupRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+		Handle:   "not a handle",
+	}
+	body, _ := json.Marshal(signupReq)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/signup", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	testutil.Serve(t, c, handler.Signup)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAuthHandler_Signup_HandleTaken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	signupReq := models.SignupRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+		Handle:   "alice",
+	}
+	body, _ := json.Marshal(signupReq)
+
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs(signupReq.Email, sqlmock.AnyArg(), "alice").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_users_handle"})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/signup", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	testutil.Serve(t, c, handler.Signup)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "handle already taken")
+}
+
 func TestAuthHandler_Login_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db, mock := testutil.SetupTestDB(t)
 	defer db.Close()
 
 	cfg := testutil.GetTestConfig()
-	handler := NewAuthHandler(db, cfg)
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
 
 	password := "password123"
 	passwordHash, _ := auth.HashPassword(password)
@@ -178,19 +236,20 @@ func TestAuthHandler_Login_Success(t *testing.T) {
 
 	// Mock database response
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "created_at", "updated_at"}).
-		AddRow("user-123", loginReq.Email, passwordHash, now, now)
+	rows := sqlmock.NewRows([]string{"id", "email", "handle", "role", "password_hash", "created_at", "updated_at"}).
+		AddRow("user-123", loginReq.Email, nil, models.RoleUser, passwordHash, now, now)
 
-	mock.ExpectQuery("SELECT id, email, password_hash, created_at, updated_at FROM users WHERE email").
+	mock.ExpectQuery("SELECT id, email, handle, role, password_hash, created_at, updated_at FROM users WHERE email").
 		WithArgs(loginReq.Email).
 		WillReturnRows(rows)
+	expectStanding(mock, "user-123", false, nil)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request = httptest.NewRequest("POST", "/login", bytes.NewBuffer(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 
-	handler.Login(c)
+	testutil.Serve(t, c, handler.Login)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
@@ -210,7 +269,7 @@ func TestAuthHandler_Login_UserNotFound(t *testing.T) {
 	defer db.Close()
 
 	cfg := testutil.GetTestConfig()
-	handler := NewAuthHandler(db, cfg)
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
 
 	loginReq := models.LoginRequest{
 		Email:    "nonexistent@example.com",
@@ -219,7 +278,7 @@ func TestAuthHandler_Login_UserNotFound(t *testing.T) {
 	body, _ := json.Marshal(loginReq)
 
 	// Mock user not found
-	mock.ExpectQuery("SELECT id, email, password_hash, created_at, updated_at FROM users WHERE email").
+	mock.ExpectQuery("SELECT id, email, handle, role, password_hash, created_at, updated_at FROM users WHERE email").
 		WithArgs(loginReq.Email).
 		WillReturnError(sql.ErrNoRows)
 
@@ -228,13 +287,14 @@ func TestAuthHandler_Login_UserNotFound(t *testing.T) {
 	c.Request = httptest.NewRequest("POST", "/login", bytes.NewBuffer(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 
-	handler.Login(c)
+	testutil.Serve(t, c, handler.Login)
 
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 
 	var response map[string]string
 	json.Unmarshal(w.Body.Bytes(), &response)
-	assert.Equal(t, "invalid email or password", response["error"])
+	assert.Equal(t, apierror.CodeInvalidCredentials, response["code"])
+	assert.Equal(t, "invalid email or password", response["detail"])
 }
 
 func TestAuthHandler_Login_WrongPassword(t *testing.T) {
@@ -243,7 +303,7 @@ func TestAuthHandler_Login_WrongPassword(t *testing.T) {
 	defer db.Close()
 
 	cfg := testutil.GetTestConfig()
-	handler := NewAuthHandler(db, cfg)
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
 
 	correctPassword := "correctpassword"
 	passwordHash, _ := auth.HashPassword(correctPassword)
@@ -256,10 +316,10 @@ func TestAuthHandler_Login_WrongPassword(t *testing.T) {
 
 	// Mock database response with correct hash
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "created_at", "updated_at"}).
-		AddRow("user-123", loginReq.Email, passwordHash, now, now)
+	rows := sqlmock.NewRows([]string{"id", "email", "handle", "role", "password_hash", "created_at", "updated_at"}).
+		AddRow("user-123", loginReq.Email, nil, models.RoleUser, passwordHash, now, now)
 
-	mock.ExpectQuery("SELECT id, email, password_hash, created_at, updated_at FROM users WHERE email").
+	mock.ExpectQuery("SELECT id, email, handle, role, password_hash, created_at, updated_at FROM users WHERE email").
 		WithArgs(loginReq.Email).
 		WillReturnRows(rows)
 
@@ -268,13 +328,14 @@ func TestAuthHandler_Login_WrongPassword(t *testing.T) {
 	c.Request = httptest.NewRequest("POST", "/login", bytes.NewBuffer(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 
-	handler.Login(c)
+	testutil.Serve(t, c, handler.Login)
 
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 
 	var response map[string]string
 	json.Unmarshal(w.Body.Bytes(), &response)
-	assert.Equal(t, "invalid email or password", response["error"])
+	assert.Equal(t, apierror.CodeInvalidCredentials, response["code"])
+	assert.Equal(t, "invalid email or password", response["detail"])
 }
 
 func TestAuthHandler_Login_InvalidJSON(t *testing.T) {
@@ -283,7 +344,7 @@ func TestAuthHandler_Login_InvalidJSON(t *testing.T) {
 	defer db.Close()
 
 	cfg := testutil.GetTestConfig()
-	handler := NewAuthHandler(db, cfg)
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
 
 	body := []byte(`{"email": "test@example.com"`)
 
@@ -292,7 +353,7 @@ func TestAuthHandler_Login_InvalidJSON(t *testing.T) {
 	c.Request = httptest.NewRequest("POST", "/login", bytes.NewBuffer(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 
-	handler.Login(c)
+	testutil.Serve(t, c, handler.Login)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
@@ -303,7 +364,7 @@ func TestAuthHandler_Login_DatabaseError(t *testing.T) {
 	defer db.Close()
 
 	cfg := testutil.GetTestConfig()
-	handler := NewAuthHandler(db, cfg)
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
 
 	loginReq := models.LoginRequest{
 		Email:    "test@example.com",
@@ -312,7 +373,7 @@ func TestAuthHandler_Login_DatabaseError(t *testing.T) {
 	body, _ := json.Marshal(loginReq)
 
 	// Mock database error
-	mock.ExpectQuery("SELECT id, email, password_hash, created_at, updated_at FROM users WHERE email").
+	mock.ExpectQuery("SELECT id, email, handle, role, password_hash, created_at, updated_at FROM users WHERE email").
 		WithArgs(loginReq.Email).
 		WillReturnError(sql.ErrConnDone)
 
@@ -321,7 +382,152 @@ func TestAuthHandler_Login_DatabaseError(t *testing.T) {
 	c.Request = httptest.NewRequest("POST", "/login", bytes.NewBuffer(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 
-	handler.Login(c)
+	testutil.Serve(t, c, handler.Login)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+// expectStanding expects userID's account standing to be read
+func expectStanding(mock sqlmock.Sqlmock, userID string, banned bool, suspendedUntil *time.Time) {
+	var banReason, suspensionReason string
+	if banned {
+		banReason = "abuse"
+	}
+	if suspendedUntil != nil {
+		suspensionReason = "spam"
+	}
+	mock.ExpectQuery("SELECT banned_at IS NOT NULL, COALESCE\\(ban_reason, ''\\), suspended_until, COALESCE\\(suspension_reason, ''\\) FROM users WHERE id = \\$1").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned", "ban_reason", "suspended_until", "suspension_reason"}).
+			AddRow(banned, banReason, suspendedUntil, suspensionReason))
+}
+
+func TestAuthHandler_Login_Suspended(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	passwordHash, _ := auth.HashPassword("password123")
+	now := time.Now()
+	until := now.Add(72 * time.Hour)
+	mock.ExpectQuery("SELECT id, email, handle, role, password_hash, created_at, updated_at FROM users WHERE email").
+		WithArgs("test@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "handle", "role", "password_hash", "created_at", "updated_at"}).
+			AddRow("user-123", "test@example.com", nil, models.RoleUser, passwordHash, now, now))
+	expectStanding(mock, "user-123", false, &until)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"email":"test@example.com","password":"password123"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	testutil.Serve(t, c, handler.Login)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	assert.Equal(t, apierror.ContentType, w.Header().Get("Content-Type"))
+
+	var response struct {
+		Code    string            `json:"code"`
+		Detail  string            `json:"detail"`
+		Account accounts.Standing `json:"account"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, apierror.CodeAccountSuspended, response.Code)
+	assert.Equal(t, "account suspended", response.Detail)
+	assert.Equal(t, "spam", response.Account.Reason)
+	require.NotNil(t, response.Account.Until)
+	assert.WithinDuration(t, until, *response.Account.Until, time.Second)
+	assert.NotContains(t, w.Body.String(), "token")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthHandler_Login_SuspensionOver(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	passwordHash, _ := auth.HashPassword("password123")
+	now := time.Now()
+	ended := now.Add(-time.Hour)
+	mock.ExpectQuery("SELECT id, email, handle, role, password_hash, created_at, updated_at FROM users WHERE email").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "handle", "role", "password_hash", "created_at", "updated_at"}).
+			AddRow("user-123", "test@example.com", nil, models.RoleUser, passwordHash, now, now))
+	expectStanding(mock, "user-123", false, &ended)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"email":"test@example.com","password":"password123"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	testutil.Serve(t, c, handler.Login)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthHandler_RefreshToken_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, email, handle, role, created_at, updated_at FROM users WHERE id = \\$1").
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "handle", "role", "created_at", "updated_at"}).
+			AddRow("user-123", "test@example.com", nil, models.RoleUser, now, now))
+	expectStanding(mock, "user-123", false, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/refresh", nil)
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.RefreshToken)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.AuthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	claims, err := auth.ValidateToken(response.Token, cfg.JWTSecret)
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", claims.UserID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthHandler_RefreshToken_Banned(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cfg := testutil.GetTestConfig()
+	handler := NewAuthHandler(db, cfg, accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, email, handle, role, created_at, updated_at FROM users WHERE id = \\$1").
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "handle", "role", "created_at", "updated_at"}).
+			AddRow("user-123", "test@example.com", nil, models.RoleUser, now, now))
+	expectStanding(mock, "user-123", true, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/refresh", nil)
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.RefreshToken)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "account banned")
+	assert.NotContains(t, w.Body.String(), "token")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+)
+
+var blockTracer = otel.Tracer("why-backend/handlers/blocks")
+
+// blockedBetween is an SQL condition that holds when either user has blocked
+// the other. The arguments are columns or parameters.
+func blockedBetween(a, b string) string {
+	return `EXISTS (SELECT 1 FROM user_blocks b
+		 WHERE (b.blocker_id = ` + a + ` AND b.blocked_id = ` + b + `)
+		    OR (b.blocker_id = ` + b + ` AND b.blocked_id = ` + a + `))`
+}
+
+// visibleTo is an SQL condition that holds when viewer should see content by
+// author: neither has blocked the other and viewer has not muted author
+func visibleTo(author, viewer string) string {
+	return `NOT ` + blockedBetween(author, viewer) + `
+		 AND NOT EXISTS (SELECT 1 FROM user_mutes mu WHERE mu.muter_id = ` + viewer + ` AND mu.muted_id = ` + author + `)`
+}
+
+// isBlocked reports whether blockerID has blocked blockedID
+func isBlocked(ctx context.Context, db dbtx, blockerID, blockedID string) (bool, error) {
+	var blocked bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2)`,
+		blockerID, blockedID,
+	).Scan(&blocked)
+	return blocked, err
+}
+
+// relation is a user-to-user list the authenticated user manages
+type relation struct {
+	name   string // for spans, logs and errors
+	table  string
+	owner  string // column holding the authenticated user
+	target string // column holding the other user
+}
+
+var (
+	blockRelation = relation{name: "block", table: "user_blocks", owner: "blocker_id", target: "blocked_id"}
+	muteRelation  = relation{name: "mute", table: "user_mutes", owner: "muter_id", target: "muted_id"}
+)
+
+type BlockHandler struct {
+	db *sql.DB
+}
+
+func NewBlockHandler(db *sql.DB) *BlockHandler {
+	return &BlockHandler{db: db}
+}
+
+// Block stops a user replying to, mentioning or following the authenticated
+// user or seeing their content, and hides the user's content from them.
+// Follows between the two are removed. Blocking twice is not an error.
+func (h *BlockHandler) Block(c *gin.Context) {
+	h.add(c, blockRelation, func(ctx context.Context, tx *sql.Tx, userID, targetID string) error {
+		rows, err := tx.QueryContext(ctx,
+			`DELETE FROM follows
+			 WHERE (follower_id = $1 AND followee_id = $2) OR (follower_id = $2 AND followee_id = $1)
+			 RETURNING follower_id, followee_id`,
+			userID, targetID,
+		)
+		if err != nil {
+			return err
+		}
+		var removed [][2]string
+		for rows.Next() {
+			var f [2]string
+			if err := rows.Scan(&f[0], &f[1]); err != nil {
+				rows.Close()
+				return err
+			}
+			removed = append(removed, f)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, f := range removed {
+			if err := adjustFollowCounts(ctx, tx, f[0], f[1], -1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Unblock removes a block. Unblocking a user not blocked is not an error.
+func (h *BlockHandler) Unblock(c *gin.Context) {
+	h.remove(c, blockRelation)
+}
+
+// ListBlocks returns the users the authenticated user has blocked, most
+// recent first, with cursor pagination
+func (h *BlockHandler) ListBlocks(c *gin.Context) {
+	h.list(c, blockRelation)
+}
+
+// Mute hides a user's content from the authenticated user without them
+// knowing. Muting twice is not an error.
+func (h *BlockHandler) Mute(c *gin.Context) {
+	h.add(c, muteRelation, nil)
+}
+
+// Unmute removes a mute. Unmuting a user not muted is not an error.
+func (h *BlockHandler) Unmute(c *gin.Context) {
+	h.remove(c, muteRelation)
+}
+
+// ListMutes returns the users the authenticated user has muted, most recent
+// first, with cursor pagination
+func (h *BlockHandler) ListMutes(c *gin.Context) {
+	h.list(c, muteRelation)
+}
+
+// add records the relation to the user in :id and, when it is new, runs
+// then in the same transaction
+func (h *BlockHandler) add(c *gin.Context, rel relation, then func(ctx context.Context, tx *sql.Tx, userID, targetID string) error) {
+	ctx, span := blockTracer.Start(c.Request.Context(), "Add"+rel.name)
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	targetID := c.Param("id")
+	span.SetAttributes(
+		attribute.String("user.id", userID.(string)),
+		attribute.String("target.id", targetID),
+	)
+
+	if _, err := uuid.Parse(targetID); err != nil {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+	if targetID == userID.(string) {
+		apierror.Abort(c, apierror.BadRequest("cannot "+rel.name+" yourself"))
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to "+rel.name+" user"))
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO `+rel.table+` (`+rel.owner+`, `+rel.target+`)
+		 SELECT $1, id FROM users WHERE id = $2
+		 ON CONFLICT DO NOTHING`,
+		userID, targetID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to "+rel.name+" user", "error", err, "user_id", userID, "target_id", targetID)
+		apierror.Abort(c, apierror.Internal("failed to "+rel.name+" user"))
+		return
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, targetID).Scan(&exists); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to check user", "error", err, "target_id", targetID)
+			apierror.Abort(c, apierror.Internal("failed to "+rel.name+" user"))
+			return
+		}
+		if !exists {
+			apierror.Abort(c, apierror.NotFound("user not found"))
+			return
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if then != nil {
+		err = then(ctx, tx, userID.(string), targetID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to "+rel.name+" user", "error", err, "user_id", userID, "target_id", targetID)
+		apierror.Abort(c, apierror.Internal("failed to "+rel.name+" user"))
+		return
+	}
+
+	slog.InfoContext(ctx, "User "+rel.name+" added", "user_id", userID, "target_id", targetID)
+	c.Status(http.StatusNoContent)
+}
+
+// remove deletes the relation to the user in :id
+func (h *BlockHandler) remove(c *gin.Context, rel relation) {
+	ctx, span := blockTracer.Start(c.Request.Context(), "Remove"+rel.name)
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	targetID := c.Param("id")
+	span.SetAttributes(
+		attribute.String("user.id", userID.(string)),
+		attribute.String("target.id", targetID),
+	)
+
+	if _, err := uuid.Parse(targetID); err != nil {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+
+	_, err := h.db.ExecContext(ctx,
+		`DELETE FROM `+rel.table+` WHERE `+rel.owner+` = $1 AND `+rel.target+` = $2`,
+		userID, targetID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to remove "+rel.name, "error", err, "user_id", userID, "target_id", targetID)
+		apierror.Abort(c, apierror.Internal("failed to un"+rel.name+" user"))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// list pages through the authenticated user's relations
+func (h *BlockHandler) list(c *gin.Context, rel relation) {
+	ctx, span := blockTracer.Start(c.Request.Context(), "List"+rel.name+"s")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	query := `SELECT u.id, u.handle, r.created_at
+		 FROM ` + rel.table + ` r
+		 JOIN users u ON u.id = r.` + rel.target + `
+		 WHERE r.` + rel.owner + ` = $1`
+	args := []any{userID, page.Limit + 1}
+	if page.Cursor != nil {
+		query += ` AND (r.created_at, r.` + rel.target + `) < ($3, $4)`
+		args = append(args, page.Cursor.CreatedAt, page.Cursor.ID)
+	}
+	query += ` ORDER BY r.created_at DESC, r.` + rel.target + ` DESC LIMIT $2`
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list "+rel.name+"s", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to list "+rel.name+"s"))
+		return
+	}
+	defer rows.Close()
+
+	result := models.RelatedUserPage{Users: []models.RelatedUser{}}
+	for rows.Next() {
+		var u models.RelatedUser
+		if err := rows.Scan(&u.ID, &u.Handle, &u.CreatedAt); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan "+rel.name, "error", err)
+			continue
+		}
+		result.Users = append(result.Users, u)
+	}
+
+	if len(result.Users) > page.Limit {
+		result.Users = result.Users[:page.Limit]
+		last := result.Users[page.Limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	span.SetAttributes(attribute.Int("users.count", len(result.Users)))
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/content"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+	"why-backend/internal/testutil"
+)
+
+func TestBlockHandler_Block(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewBlockHandler(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO user_blocks \\(blocker_id, blocked_id\\) SELECT \\$1, id FROM users WHERE id = \\$2 ON CONFLICT DO NOTHING").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// They followed each other; both follows go
+	mock.ExpectQuery("DELETE FROM follows WHERE \\(follower_id = \\$1 AND followee_id = \\$2\\) OR \\(follower_id = \\$2 AND followee_id = \\$1\\) RETURNING follower_id, followee_id").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"follower_id", "followee_id"}).
+			AddRow(testFollowerID, testFolloweeID).
+			AddRow(testFolloweeID, testFollowerID))
+	mock.ExpectExec("UPDATE users u SET").
+		WithArgs(testFollowerID, testFolloweeID, -1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE users u SET").
+		WithArgs(testFolloweeID, testFollowerID, -1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	_, c := newFollowRequest("PUT", "/me/blocks/"+testFolloweeID, testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Block)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlockHandler_Block_AlreadyBlocked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewBlockHandler(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO user_blocks").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM users WHERE id = \\$1\\)").
+		WithArgs(testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, c := newFollowRequest("PUT", "/me/blocks/"+testFolloweeID, testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Block)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlockHandler_Block_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewBlockHandler(db)
+
+	w, c := newFollowRequest("PUT", "/me/blocks/"+testFollowerID, testFollowerID, testFollowerID)
+	testutil.Serve(t, c, handler.Block)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, c = newFollowRequest("PUT", "/me/blocks/bogus", testFollowerID, "bogus")
+	testutil.Serve(t, c, handler.Block)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlockHandler_Mute_UserNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewBlockHandler(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO user_mutes \\(muter_id, muted_id\\)").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	w, c := newFollowRequest("PUT", "/me/mutes/"+testFolloweeID, testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Mute)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlockHandler_Mute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewBlockHandler(db)
+
+	// Muting leaves follows alone
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO user_mutes").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, c := newFollowRequest("PUT", "/me/mutes/"+testFolloweeID, testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Mute)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlockHandler_Unblock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewBlockHandler(db)
+
+	mock.ExpectExec("DELETE FROM user_blocks WHERE blocker_id = \\$1 AND blocked_id = \\$2").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, c := newFollowRequest("DELETE", "/me/blocks/"+testFolloweeID, testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Unblock)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlockHandler_ListBlocks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewBlockHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT u.id, u.handle, r.created_at FROM user_blocks r JOIN users u ON u.id = r.blocked_id "+
+		"WHERE r.blocker_id = \\$1 ORDER BY r.created_at DESC, r.blocked_id DESC LIMIT \\$2").
+		WithArgs(testFollowerID, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle", "created_at"}).
+			AddRow(testFolloweeID, "bob", now).
+			AddRow("3c2b1a00-0000-4000-8000-000000000003", nil, now.Add(-time.Hour)))
+
+	w, c := newFollowRequest("GET", "/me/blocks?limit=1", testFollowerID, "")
+	testutil.Serve(t, c, handler.ListBlocks)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.RelatedUserPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Users, 1)
+	assert.Equal(t, "bob", *response.Users[0].Handle)
+
+	cursor, err := decodeCursor(response.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, testFolloweeID, cursor.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlockHandler_ListMutes_Cursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewBlockHandler(db)
+
+	cursorTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM user_mutes r JOIN users u ON u.id = r.muted_id WHERE r.muter_id = \\$1 "+
+		"AND \\(r.created_at, r.muted_id\\) < \\(\\$3, \\$4\\)").
+		WithArgs(testFollowerID, 21, cursorTime, testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle", "created_at"}))
+
+	w, c := newFollowRequest("GET", "/me/mutes?cursor="+encodeCursor(cursorTime, testFolloweeID), testFollowerID, "")
+	testutil.Serve(t, c, handler.ListMutes)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"users":[]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_ListMessages_HidesBlockedAndMuted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("FROM messages m WHERE m.accepted_reply_id IS NULL AND m.visibility <> 'unlisted' AND .+ " +
+		"AND NOT EXISTS \\(SELECT 1 FROM user_blocks b .+\\) " +
+		"AND NOT EXISTS \\(SELECT 1 FROM user_mutes mu WHERE mu.muter_id = \\$1 AND mu.muted_id = m.user_id\\) ORDER BY").
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages?filter=unanswered", nil)
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.ListMessages)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_ListReplies_HidesBlockedAndMuted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("WHERE r.message_id = \\$1 AND .+ AND NOT EXISTS \\(SELECT 1 FROM user_blocks b .+user_mutes mu WHERE mu.muter_id = \\$2 AND mu.muted_id = r.user_id\\) AND \\(r.filter_action IS NULL OR r.user_id = \\$2::uuid\\) ORDER BY").
+		WithArgs("msg-123", "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages/msg-123/replies", nil)
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.ListReplies)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_GetMessage_Blocked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("FROM messages m WHERE m.id = \\$1 AND .+ AND NOT EXISTS \\(SELECT 1 FROM user_blocks b").
+		WithArgs("msg-123", "user-123").
+		WillReturnError(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages/msg-123", nil)
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.GetMessage)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_CreateReply_Blocked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.user_id, m.visibility FROM messages m WHERE m.id = \\$1 AND .+ FOR SHARE").
+		WithArgs("msg-123", "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "visibility"}).AddRow("user-456", "public"))
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM user_blocks").
+		WithArgs("user-456", "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/msg-123/replies", bytes.NewBufferString(`{"content":"hello"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.CreateReply)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
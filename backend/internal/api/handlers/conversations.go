@@ -0,0 +1,647 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/content"
+	"why-backend/internal/models"
+)
+
+var conversationTracer = otel.Tracer("why-backend/handlers/conversations")
+
+// maxConversationParticipants caps group size, the creator included
+const maxConversationParticipants = 10
+
+// unreadDirectMessage is an SQL condition on direct_messages dm that holds
+// when participant p has not read it. Their own messages and those of users
+// blocked either way do not count.
+var unreadDirectMessage = `dm.sender_id <> p.user_id
+	 AND (p.last_read_at IS NULL OR (dm.created_at, dm.id) > (p.last_read_at, p.last_read_message_id))
+	 AND NOT ` + blockedBetween("dm.sender_id", "p.user_id")
+
+// conversationColumns selects a conversation from conversations c as seen by
+// participant p
+var conversationColumns = `c.id, c.last_message_at, c.created_at,
+	(SELECT COUNT(*) FROM direct_messages dm WHERE dm.conversation_id = c.id AND ` + unreadDirectMessage + `) AS unread_count`
+
+const directMessageColumns = `dm.id, dm.conversation_id, dm.sender_id, dm.content, dm.media_urls, dm.created_at`
+
+// isParticipant reports whether userID takes part in conversationID
+func isParticipant(ctx context.Context, db dbtx, conversationID, userID string) (bool, error) {
+	var ok bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)`,
+		conversationID, userID,
+	).Scan(&ok)
+	return ok, err
+}
+
+type ConversationHandler struct {
+	db     *sql.DB
+	limits content.Limits
+}
+
+func NewConversationHandler(db *sql.DB, limits content.Limits) *ConversationHandler {
+	return &ConversationHandler{db: db, limits: limits}
+}
+
+// CreateConversation starts a conversation between the authenticated user and
+// participant_ids. A one-to-one conversation is only created once per pair;
+// asking again returns the existing one with 200.
+func (h *ConversationHandler) CreateConversation(c *gin.Context) {
+	ctx, span := conversationTracer.Start(c.Request.Context(), "CreateConversation")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	var req models.CreateConversationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	seen := map[string]bool{userID.(string): true}
+	var others []string
+	for _, id := range req.ParticipantIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			apierror.Abort(c, apierror.BadRequest("invalid participant id"))
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		apierror.Abort(c, apierror.BadRequest("a conversation needs another participant"))
+		return
+	}
+	if len(others)+1 > maxConversationParticipants {
+		apierror.Abort(c, apierror.BadRequest("too many participants"))
+		return
+	}
+	span.SetAttributes(attribute.Int("conversation.participants", len(others)+1))
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to create conversation"))
+		return
+	}
+	defer tx.Rollback()
+
+	var found int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE id = ANY($1)`, pq.Array(others)).Scan(&found); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check participants", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to create conversation"))
+		return
+	}
+	if found != len(others) {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+
+	var blocked bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM unnest($2::uuid[]) AS o(id) WHERE `+blockedBetween("o.id", "$1::uuid")+`)`,
+		userID, pq.Array(others),
+	).Scan(&blocked)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check blocks", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to create conversation"))
+		return
+	}
+	if blocked {
+		apierror.Abort(c, apierror.Forbidden("you cannot message one of these users"))
+		return
+	}
+
+	var directKey sql.NullString
+	if len(others) == 1 {
+		pair := []string{userID.(string), others[0]}
+		sort.Strings(pair)
+		directKey = sql.NullString{String: pair[0] + ":" + pair[1], Valid: true}
+	}
+
+	status := http.StatusCreated
+	var conversationID string
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO conversations (created_by, direct_key)
+		 VALUES ($1, $2)
+		 ON CONFLICT (direct_key) DO NOTHING
+		 RETURNING id`,
+		userID, directKey,
+	).Scan(&conversationID)
+	if err == sql.ErrNoRows {
+		// The pair already has a conversation
+		status = http.StatusOK
+		err = tx.QueryRowContext(ctx, `SELECT id FROM conversations WHERE direct_key = $1`, directKey).Scan(&conversationID)
+	} else if err == nil {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO conversation_participants (conversation_id, user_id)
+			 SELECT $1, unnest($2::uuid[])`,
+			conversationID, pq.Array(append(others, userID.(string))),
+		)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to create conversation", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to create conversation"))
+		return
+	}
+
+	conversation, err := h.getConversation(ctx, conversationID, userID.(string))
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to load conversation", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to create conversation"))
+		return
+	}
+
+	span.SetAttributes(attribute.String("conversation.id", conversationID))
+	if status == http.StatusCreated {
+		slog.InfoContext(ctx, "Conversation created", "conversation_id", conversationID, "user_id", userID)
+	}
+	c.JSON(status, conversation)
+}
+
+// ListConversations returns the authenticated user's conversations, most
+// recently active first, with cursor pagination
+func (h *ConversationHandler) ListConversations(c *gin.Context) {
+	ctx, span := conversationTracer.Start(c.Request.Context(), "ListConversations")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	query := `SELECT ` + conversationColumns + `
+		 FROM conversation_participants p
+		 JOIN conversations c ON c.id = p.conversation_id
+		 WHERE p.user_id = $1`
+	args := []any{userID, page.Limit + 1}
+	if page.Cursor != nil {
+		query += ` AND (c.last_message_at, c.id) < ($3, $4)`
+		args = append(args, page.Cursor.CreatedAt, page.Cursor.ID)
+	}
+	query += ` ORDER BY c.last_message_at DESC, c.id DESC LIMIT $2`
+
+	conversations, err := h.queryConversations(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list conversations", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to list conversations"))
+		return
+	}
+
+	result := models.ConversationPage{Conversations: conversations}
+	if len(result.Conversations) > page.Limit {
+		result.Conversations = result.Conversations[:page.Limit]
+		last := result.Conversations[page.Limit-1]
+		result.NextCursor = encodeCursor(last.LastMessageAt, last.ID)
+	}
+
+	if err := h.attachParticipants(ctx, result.Conversations); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to load participants", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to list conversations"))
+		return
+	}
+
+	span.SetAttributes(attribute.Int("conversations.count", len(result.Conversations)))
+	c.JSON(http.StatusOK, result)
+}
+
+// GetConversation returns a conversation with its participants' read receipts
+func (h *ConversationHandler) GetConversation(c *gin.Context) {
+	ctx, span := conversationTracer.Start(c.Request.Context(), "GetConversation")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	conversationID := c.Param("id")
+	span.SetAttributes(
+		attribute.String("user.id", userID.(string)),
+		attribute.String("conversation.id", conversationID),
+	)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return
+	}
+
+	conversation, err := h.getConversation(ctx, conversationID, userID.(string))
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get conversation", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to get conversation"))
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// UnreadCount returns the number of unread messages across the authenticated
+// user's conversations
+func (h *ConversationHandler) UnreadCount(c *gin.Context) {
+	ctx, span := conversationTracer.Start(c.Request.Context(), "UnreadCount")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	var count int
+	err := h.db.QueryRowContext(ctx,
+		`SELECT COUNT(*)
+		 FROM conversation_participants p
+		 JOIN direct_messages dm ON dm.conversation_id = p.conversation_id
+		 WHERE p.user_id = $1 AND `+unreadDirectMessage,
+		userID,
+	).Scan(&count)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to count unread messages", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to count messages"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
+
+// SendMessage posts a message to a conversation. Nobody can send to a
+// conversation with someone they blocked or who blocked them.
+func (h *ConversationHandler) SendMessage(c *gin.Context) {
+	ctx, span := conversationTracer.Start(c.Request.Context(), "SendMessage")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	conversationID := c.Param("id")
+	span.SetAttributes(
+		attribute.String("user.id", userID.(string)),
+		attribute.String("conversation.id", conversationID),
+	)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return
+	}
+
+	var req models.SendDirectMessageRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to send message"))
+		return
+	}
+	defer tx.Rollback()
+
+	ok, err := isParticipant(ctx, tx, conversationID, userID.(string))
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check participant", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to send message"))
+		return
+	}
+	if !ok {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return
+	}
+
+	var blocked bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM conversation_participants p
+		 WHERE p.conversation_id = $1 AND p.user_id <> $2 AND `+blockedBetween("p.user_id", "$2")+`)`,
+		conversationID, userID,
+	).Scan(&blocked)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check blocks", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to send message"))
+		return
+	}
+	if blocked {
+		apierror.Abort(c, apierror.Forbidden("you cannot send messages to this conversation"))
+		return
+	}
+
+	valid, err := validatePost(ctx, c, tx, post{
+		UserID: userID.(string), ConversationID: conversationID, Text: &req.Content,
+		MaxLength: h.limits.MessageLength, MediaURLs: req.MediaURLs, MaxAttachments: h.limits.Attachments,
+	})
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check media uploads", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to send message"))
+		return
+	}
+	if !valid {
+		return
+	}
+
+	var message models.DirectMessage
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO direct_messages (conversation_id, sender_id, content, media_urls)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING id, conversation_id, sender_id, content, media_urls, created_at`,
+		conversationID, userID, req.Content, pq.Array(req.MediaURLs),
+	).Scan(&message.ID, &message.ConversationID, &message.SenderID, &message.Content, &message.MediaURLs, &message.CreatedAt)
+	if err == nil {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE conversations SET last_message_at = GREATEST(last_message_at, $2) WHERE id = $1`,
+			conversationID, message.CreatedAt,
+		)
+	}
+	if err == nil {
+		// Senders have read their own message
+		_, err = tx.ExecContext(ctx,
+			`UPDATE conversation_participants SET last_read_message_id = $3, last_read_at = $4
+			 WHERE conversation_id = $1 AND user_id = $2`,
+			conversationID, userID, message.ID, message.CreatedAt,
+		)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to send message", "error", err, "conversation_id", conversationID, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to send message"))
+		return
+	}
+
+	span.SetAttributes(attribute.String("direct_message.id", message.ID))
+	c.JSON(http.StatusCreated, message)
+}
+
+// ListMessages returns a conversation's messages, newest first, with cursor
+// pagination. Messages from users blocked either way are left out.
+func (h *ConversationHandler) ListMessages(c *gin.Context) {
+	ctx, span := conversationTracer.Start(c.Request.Context(), "ListMessages")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	conversationID := c.Param("id")
+	span.SetAttributes(
+		attribute.String("user.id", userID.(string)),
+		attribute.String("conversation.id", conversationID),
+	)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return
+	}
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	ok, err := isParticipant(ctx, h.db, conversationID, userID.(string))
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check participant", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to list messages"))
+		return
+	}
+	if !ok {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return
+	}
+
+	query := `SELECT ` + directMessageColumns + `
+		 FROM direct_messages dm
+		 WHERE dm.conversation_id = $1 AND NOT ` + blockedBetween("dm.sender_id", "$3")
+	args := []any{conversationID, page.Limit + 1, userID}
+	if page.Cursor != nil {
+		query += ` AND (dm.created_at, dm.id) < ($4, $5)`
+		args = append(args, page.Cursor.CreatedAt, page.Cursor.ID)
+	}
+	query += ` ORDER BY dm.created_at DESC, dm.id DESC LIMIT $2`
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list messages", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to list messages"))
+		return
+	}
+	defer rows.Close()
+
+	result := models.DirectMessagePage{Messages: []models.DirectMessage{}}
+	for rows.Next() {
+		var m models.DirectMessage
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.Content, &m.MediaURLs, &m.CreatedAt); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan message", "error", err)
+			continue
+		}
+		result.Messages = append(result.Messages, m)
+	}
+
+	if len(result.Messages) > page.Limit {
+		result.Messages = result.Messages[:page.Limit]
+		last := result.Messages[page.Limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	span.SetAttributes(attribute.Int("messages.count", len(result.Messages)))
+	c.JSON(http.StatusOK, result)
+}
+
+// MarkRead moves the authenticated user's read receipt forward to message_id,
+// or to the newest message when the body is empty. It never moves back.
+func (h *ConversationHandler) MarkRead(c *gin.Context) {
+	ctx, span := conversationTracer.Start(c.Request.Context(), "MarkRead")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	conversationID := c.Param("id")
+	span.SetAttributes(
+		attribute.String("user.id", userID.(string)),
+		attribute.String("conversation.id", conversationID),
+	)
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return
+	}
+
+	var req models.MarkConversationReadRequest
+	if c.Request.ContentLength != 0 {
+		if !bindJSON(c, &req) {
+			return
+		}
+	}
+	if req.MessageID != "" {
+		if _, err := uuid.Parse(req.MessageID); err != nil {
+			apierror.Abort(c, apierror.NotFound("message not found"))
+			return
+		}
+	}
+
+	ok, err := isParticipant(ctx, h.db, conversationID, userID.(string))
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check participant", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to mark conversation read"))
+		return
+	}
+	if !ok {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return
+	}
+
+	target := `SELECT id, created_at FROM direct_messages WHERE conversation_id = $1`
+	args := []any{conversationID, userID}
+	if req.MessageID != "" {
+		target += ` AND id = $3`
+		args = append(args, req.MessageID)
+	}
+	target += ` ORDER BY created_at DESC, id DESC LIMIT 1`
+
+	result, err := h.db.ExecContext(ctx,
+		`UPDATE conversation_participants p
+		 SET last_read_message_id = dm.id, last_read_at = dm.created_at
+		 FROM (`+target+`) dm
+		 WHERE p.conversation_id = $1 AND p.user_id = $2
+		   AND (p.last_read_at IS NULL OR (dm.created_at, dm.id) > (p.last_read_at, p.last_read_message_id))`,
+		args...,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to mark conversation read", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to mark conversation read"))
+		return
+	}
+
+	// Nothing moved: either already read that far or no such message
+	if n, _ := result.RowsAffected(); n == 0 && req.MessageID != "" {
+		var exists bool
+		err := h.db.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM direct_messages WHERE id = $1 AND conversation_id = $2)`,
+			req.MessageID, conversationID,
+		).Scan(&exists)
+		if err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to check message", "error", err, "message_id", req.MessageID)
+			apierror.Abort(c, apierror.Internal("failed to mark conversation read"))
+			return
+		}
+		if !exists {
+			apierror.Abort(c, apierror.NotFound("message not found"))
+			return
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getConversation loads a conversation as seen by userID, or sql.ErrNoRows if
+// they do not take part in it
+func (h *ConversationHandler) getConversation(ctx context.Context, conversationID, userID string) (models.Conversation, error) {
+	conversations, err := h.queryConversations(ctx,
+		`SELECT `+conversationColumns+`
+		 FROM conversation_participants p
+		 JOIN conversations c ON c.id = p.conversation_id
+		 WHERE p.conversation_id = $1 AND p.user_id = $2`,
+		conversationID, userID,
+	)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	if len(conversations) == 0 {
+		return models.Conversation{}, sql.ErrNoRows
+	}
+	if err := h.attachParticipants(ctx, conversations); err != nil {
+		return models.Conversation{}, err
+	}
+	return conversations[0], nil
+}
+
+// queryConversations runs a query selecting conversationColumns
+func (h *ConversationHandler) queryConversations(ctx context.Context, query string, args ...any) ([]models.Conversation, error) {
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []models.Conversation{}
+	for rows.Next() {
+		var conv models.Conversation
+		if err := rows.Scan(&conv.ID, &conv.LastMessageAt, &conv.CreatedAt, &conv.UnreadCount); err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conv)
+	}
+	return conversations, rows.Err()
+}
+
+// attachParticipants fills in the participants of each conversation
+func (h *ConversationHandler) attachParticipants(ctx context.Context, conversations []models.Conversation) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(conversations))
+	index := make(map[string]int, len(conversations))
+	for i, conv := range conversations {
+		ids[i] = conv.ID
+		index[conv.ID] = i
+		conversations[i].Participants = []models.ConversationParticipant{}
+	}
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT p.conversation_id, p.user_id, u.handle, p.last_read_message_id, p.last_read_at
+		 FROM conversation_participants p
+		 JOIN users u ON u.id = p.user_id
+		 WHERE p.conversation_id = ANY($1)
+		 ORDER BY p.joined_at, p.user_id`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var conversationID string
+		var p models.ConversationParticipant
+		if err := rows.Scan(&conversationID, &p.UserID, &p.Handle, &p.LastReadMessageID, &p.LastReadAt); err != nil {
+			return err
+		}
+		if i, ok := index[conversationID]; ok {
+			conversations[i].Participants = append(conversations[i].Participants, p)
+		}
+	}
+	return rows.Err()
+}
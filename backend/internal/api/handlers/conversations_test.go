@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/content"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+const (
+	testConversationID  = "4d3c2b1a-0000-4000-8000-000000000001"
+	testDirectMessageID = "4d3c2b1a-0000-4000-8000-000000000101"
+)
+
+var (
+	conversationRowColumns  = []string{"id", "last_message_at", "created_at", "unread_count"}
+	participantRowColumns   = []string{"conversation_id", "user_id", "handle", "last_read_message_id", "last_read_at"}
+	directMessageRowColumns = []string{"id", "conversation_id", "sender_id", "content", "media_urls", "created_at"}
+)
+
+func newConversationRequest(method, target, userID, id, body string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	if body != "" {
+		c.Request = httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+	} else {
+		c.Request = httptest.NewRequest(method, target, nil)
+	}
+	if id != "" {
+		c.Params = gin.Params{{Key: "id", Value: id}}
+	}
+	c.Set("user_id", userID)
+	return w, c
+}
+
+func expectConversationLoad(mock sqlmock.Sqlmock, now time.Time) {
+	mock.ExpectQuery("SELECT c.id, c.last_message_at, c.created_at, .+ AS unread_count FROM conversation_participants p "+
+		"JOIN conversations c ON c.id = p.conversation_id WHERE p.conversation_id = \\$1 AND p.user_id = \\$2").
+		WithArgs(testConversationID, testFollowerID).
+		WillReturnRows(sqlmock.NewRows(conversationRowColumns).AddRow(testConversationID, now, now, 0))
+	mock.ExpectQuery("SELECT p.conversation_id, p.user_id, u.handle, p.last_read_message_id, p.last_read_at " +
+		"FROM conversation_participants p JOIN users u ON u.id = p.user_id WHERE p.conversation_id = ANY\\(\\$1\\)").
+		WithArgs(pq.Array([]string{testConversationID})).
+		WillReturnRows(sqlmock.NewRows(participantRowColumns).
+			AddRow(testConversationID, testFolloweeID, "bob", nil, nil).
+			AddRow(testConversationID, testFollowerID, "alice", nil, nil))
+}
+
+func TestConversationHandler_CreateConversation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users WHERE id = ANY\\(\\$1\\)").
+		WithArgs(pq.Array([]string{testFolloweeID})).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM unnest\\(\\$2::uuid\\[\\]\\) AS o\\(id\\) WHERE EXISTS \\(SELECT 1 FROM user_blocks b").
+		WithArgs(testFollowerID, pq.Array([]string{testFolloweeID})).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("INSERT INTO conversations \\(created_by, direct_key\\) VALUES \\(\\$1, \\$2\\) ON CONFLICT \\(direct_key\\) DO NOTHING RETURNING id").
+		WithArgs(testFollowerID, testFollowerID+":"+testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testConversationID))
+	mock.ExpectExec("INSERT INTO conversation_participants \\(conversation_id, user_id\\) SELECT \\$1, unnest\\(\\$2::uuid\\[\\]\\)").
+		WithArgs(testConversationID, pq.Array([]string{testFolloweeID, testFollowerID})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	expectConversationLoad(mock, now)
+
+	// Duplicates and the creator themselves are dropped
+	w, c := newConversationRequest("POST", "/conversations", testFollowerID, "",
+		`{"participant_ids":["`+testFolloweeID+`","`+testFolloweeID+`","`+testFollowerID+`"]}`)
+	testutil.Serve(t, c, handler.CreateConversation)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.Conversation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, testConversationID, response.ID)
+	require.Len(t, response.Participants, 2)
+	assert.Equal(t, "bob", *response.Participants[0].Handle)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_CreateConversation_ExistingPair(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT EXISTS").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("INSERT INTO conversations").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT id FROM conversations WHERE direct_key = \\$1").
+		WithArgs(testFollowerID + ":" + testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testConversationID))
+	mock.ExpectCommit()
+	expectConversationLoad(mock, now)
+
+	w, c := newConversationRequest("POST", "/conversations", testFollowerID, "", `{"participant_ids":["`+testFolloweeID+`"]}`)
+	testutil.Serve(t, c, handler.CreateConversation)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_CreateConversation_Blocked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT EXISTS").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	w, c := newConversationRequest("POST", "/conversations", testFollowerID, "", `{"participant_ids":["`+testFolloweeID+`"]}`)
+	testutil.Serve(t, c, handler.CreateConversation)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_CreateConversation_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no participants", `{"participant_ids":[]}`},
+		{"only yourself", `{"participant_ids":["` + testFollowerID + `"]}`},
+		{"invalid id", `{"participant_ids":["bogus"]}`},
+		{"too many", `{"participant_ids":["3c2b1a00-0000-4000-8000-000000000010","3c2b1a00-0000-4000-8000-000000000011",` +
+			`"3c2b1a00-0000-4000-8000-000000000012","3c2b1a00-0000-4000-8000-000000000013","3c2b1a00-0000-4000-8000-000000000014",` +
+			`"3c2b1a00-0000-4000-8000-000000000015","3c2b1a00-0000-4000-8000-000000000016","3c2b1a00-0000-4000-8000-000000000017",` +
+			`"3c2b1a00-0000-4000-8000-000000000018","3c2b1a00-0000-4000-8000-000000000019"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, c := newConversationRequest("POST", "/conversations", testFollowerID, "", tt.body)
+			testutil.Serve(t, c, handler.CreateConversation)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_ListConversations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+	now := time.Now()
+	otherID := "4d3c2b1a-0000-4000-8000-000000000002"
+
+	mock.ExpectQuery("FROM conversation_participants p JOIN conversations c ON c.id = p.conversation_id WHERE p.user_id = \\$1 "+
+		"ORDER BY c.last_message_at DESC, c.id DESC LIMIT \\$2").
+		WithArgs(testFollowerID, 2).
+		WillReturnRows(sqlmock.NewRows(conversationRowColumns).
+			AddRow(testConversationID, now, now, 3).
+			AddRow(otherID, now.Add(-time.Hour), now.Add(-time.Hour), 0))
+	mock.ExpectQuery("FROM conversation_participants p JOIN users u ON u.id = p.user_id WHERE p.conversation_id = ANY\\(\\$1\\)").
+		WithArgs(pq.Array([]string{testConversationID})).
+		WillReturnRows(sqlmock.NewRows(participantRowColumns).
+			AddRow(testConversationID, testFollowerID, "alice", testDirectMessageID, now.Add(-time.Minute)))
+
+	w, c := newConversationRequest("GET", "/conversations?limit=1", testFollowerID, "", "")
+	testutil.Serve(t, c, handler.ListConversations)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ConversationPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Conversations, 1)
+	assert.Equal(t, 3, response.Conversations[0].UnreadCount)
+	require.Len(t, response.Conversations[0].Participants, 1)
+	assert.Equal(t, testDirectMessageID, *response.Conversations[0].Participants[0].LastReadMessageID)
+
+	cursor, err := decodeCursor(response.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, testConversationID, cursor.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_GetConversation_NotParticipant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+
+	mock.ExpectQuery("WHERE p.conversation_id = \\$1 AND p.user_id = \\$2").
+		WithArgs(testConversationID, testFollowerID).
+		WillReturnRows(sqlmock.NewRows(conversationRowColumns))
+
+	w, c := newConversationRequest("GET", "/conversations/"+testConversationID, testFollowerID, testConversationID, "")
+	testutil.Serve(t, c, handler.GetConversation)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_UnreadCount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM conversation_participants p JOIN direct_messages dm ON dm.conversation_id = p.conversation_id " +
+		"WHERE p.user_id = \\$1 AND dm.sender_id <> p.user_id AND \\(p.last_read_at IS NULL OR \\(dm.created_at, dm.id\\) > \\(p.last_read_at, p.last_read_message_id\\)\\)").
+		WithArgs(testFollowerID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	w, c := newConversationRequest("GET", "/conversations/unread-count", testFollowerID, "", "")
+	testutil.Serve(t, c, handler.UnreadCount)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"unread_count":4}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_SendMessage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+	now := time.Now()
+	mediaURL := "/api/v1/conversations/" + testConversationID + "/media/4d3c2b1a-0000-4000-8000-000000000201.png"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM conversation_participants WHERE conversation_id = \\$1 AND user_id = \\$2\\)").
+		WithArgs(testConversationID, testFollowerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM conversation_participants p WHERE p.conversation_id = \\$1 AND p.user_id <> \\$2 AND EXISTS \\(SELECT 1 FROM user_blocks b").
+		WithArgs(testConversationID, testFollowerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT url FROM media_uploads WHERE url = ANY\\(\\$1\\) AND user_id = \\$2").
+		WithArgs(pq.Array([]string{mediaURL}), testFollowerID, testConversationID).
+		WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow(mediaURL))
+	mock.ExpectQuery("INSERT INTO direct_messages \\(conversation_id, sender_id, content, media_urls\\)").
+		WithArgs(testConversationID, testFollowerID, "hi", pq.Array([]string{mediaURL})).
+		WillReturnRows(sqlmock.NewRows(directMessageRowColumns).
+			AddRow(testDirectMessageID, testConversationID, testFollowerID, "hi", pq.StringArray{mediaURL}, now))
+	mock.ExpectExec("UPDATE conversations SET last_message_at = GREATEST\\(last_message_at, \\$2\\) WHERE id = \\$1").
+		WithArgs(testConversationID, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE conversation_participants SET last_read_message_id = \\$3, last_read_at = \\$4").
+		WithArgs(testConversationID, testFollowerID, testDirectMessageID, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, c := newConversationRequest("POST", "/conversations/"+testConversationID+"/messages", testFollowerID, testConversationID,
+		`{"content":"hi","media_urls":["`+mediaURL+`"]}`)
+	testutil.Serve(t, c, handler.SendMessage)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.DirectMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, testDirectMessageID, response.ID)
+	assert.Equal(t, []string{mediaURL}, []string(response.MediaURLs))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_SendMessage_Blocked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM conversation_participants").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM conversation_participants p").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	w, c := newConversationRequest("POST", "/conversations/"+testConversationID+"/messages", testFollowerID, testConversationID, `{"content":"hi"}`)
+	testutil.Serve(t, c, handler.SendMessage)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_SendMessage_NotParticipant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM conversation_participants").
+		WithArgs(testConversationID, testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	w, c := newConversationRequest("POST", "/conversations/"+testConversationID+"/messages", testFolloweeID, testConversationID, `{"content":"hi"}`)
+	testutil.Serve(t, c, handler.SendMessage)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_ListMessages_Cursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+	cursorTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM conversation_participants").
+		WithArgs(testConversationID, testFollowerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT dm.id, dm.conversation_id, dm.sender_id, dm.content, dm.media_urls, dm.created_at FROM direct_messages dm "+
+		"WHERE dm.conversation_id = \\$1 AND NOT EXISTS \\(SELECT 1 FROM user_blocks b .+\\) "+
+		"AND \\(dm.created_at, dm.id\\) < \\(\\$4, \\$5\\) ORDER BY dm.created_at DESC, dm.id DESC LIMIT \\$2").
+		WithArgs(testConversationID, 21, testFollowerID, cursorTime, testDirectMessageID).
+		WillReturnRows(sqlmock.NewRows(directMessageRowColumns).
+			AddRow("4d3c2b1a-0000-4000-8000-000000000100", testConversationID, testFolloweeID, "older", pq.StringArray{}, cursorTime.Add(-time.Minute)))
+
+	w, c := newConversationRequest("GET", "/conversations/"+testConversationID+"/messages?cursor="+encodeCursor(cursorTime, testDirectMessageID),
+		testFollowerID, testConversationID, "")
+	testutil.Serve(t, c, handler.ListMessages)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.DirectMessagePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Messages, 1)
+	assert.Equal(t, "older", response.Messages[0].Content)
+	assert.Empty(t, response.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_MarkRead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM conversation_participants").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("UPDATE conversation_participants p SET last_read_message_id = dm.id, last_read_at = dm.created_at "+
+		"FROM \\(SELECT id, created_at FROM direct_messages WHERE conversation_id = \\$1 ORDER BY created_at DESC, id DESC LIMIT 1\\) dm "+
+		"WHERE p.conversation_id = \\$1 AND p.user_id = \\$2 AND \\(p.last_read_at IS NULL OR").
+		WithArgs(testConversationID, testFollowerID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, c := newConversationRequest("POST", "/conversations/"+testConversationID+"/read", testFollowerID, testConversationID, "")
+	testutil.Serve(t, c, handler.MarkRead)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationHandler_MarkRead_UnknownMessage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewConversationHandler(db, content.DefaultLimits)
+
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM conversation_participants").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("WHERE conversation_id = \\$1 AND id = \\$3 ORDER BY").
+		WithArgs(testConversationID, testFollowerID, testDirectMessageID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM direct_messages WHERE id = \\$1 AND conversation_id = \\$2\\)").
+		WithArgs(testDirectMessageID, testConversationID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	w, c := newConversationRequest("POST", "/conversations/"+testConversationID+"/read", testFollowerID, testConversationID,
+		`{"message_id":"`+testDirectMessageID+`"}`)
+	testutil.Serve(t, c, handler.MarkRead)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+)
+
+// unfiltered is an SQL condition that holds when the message or reply alias
+// was not held or shadow-hidden by the content filter, or is viewer's own;
+// viewer is a uuid parameter, or empty for anyone
+func unfiltered(alias, viewer string) string {
+	if viewer == "" {
+		return alias + `.filter_action IS NULL`
+	}
+	return `(` + alias + `.filter_action IS NULL OR ` + alias + `.user_id = ` + viewer + `::uuid)`
+}
+
+// checkContent runs the content filter on new or edited content. It fails
+// the request and returns false when the content is rejected or cannot be
+// checked, failure being the message of the latter.
+func (h *MessageHandler) checkContent(ctx context.Context, c *gin.Context, content moderation.Content, failure string) (moderation.Decision, bool) {
+	span := trace.SpanFromContext(ctx)
+	decision, err := h.filter.Check(ctx, content)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to filter content", "error", err, "user_id", content.UserID)
+		apierror.Abort(c, apierror.Internal(failure))
+		return decision, false
+	}
+	if decision.Action == moderation.Allow {
+		return decision, true
+	}
+
+	span.SetAttributes(
+		attribute.String("filter.action", decision.Action),
+		attribute.String("filter.name", decision.Filter),
+	)
+	slog.InfoContext(ctx, "Content filtered", "user_id", content.UserID, "kind", content.Kind,
+		"action", decision.Action, "filter", decision.Filter, "reason", decision.Reason)
+	if decision.Action == moderation.Reject {
+		apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, apierror.CodeContentRejected, content.Kind+" rejected").
+			With("reason", decision.Reason))
+		return decision, false
+	}
+	return decision, true
+}
+
+// markFiltered records the content filter's decision on a new or edited
+// message or reply, targetType being the report target. A hold is filed as a report with
+// no reporter, for moderators to approve by dismissing it or to act on.
+func markFiltered(ctx context.Context, db dbtx, targetType, targetID, userID string, decision moderation.Decision) error {
+	table := "messages"
+	if targetType == models.ReportTargetReply {
+		table = "replies"
+	}
+	_, err := db.ExecContext(ctx, `UPDATE `+table+` SET filter_action = $2 WHERE id = $1`, targetID, decision.Action)
+	if err != nil || decision.Action != moderation.Hold {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, reason, details)
+		 VALUES (NULL, $1, $2, $3, $4, $5)`,
+		targetType, targetID, userID, decision.Category, "Held by the "+decision.Filter+" filter: "+decision.Reason,
+	)
+	return err
+}
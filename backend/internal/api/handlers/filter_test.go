@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/accounts"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/content"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+	"why-backend/internal/testutil"
+)
+
+// decisionFilter decides the same for all content
+type decisionFilter moderation.Decision
+
+func (f decisionFilter) Check(ctx context.Context, content moderation.Content) (moderation.Decision, error) {
+	return moderation.Decision(f), nil
+}
+
+func newCreateMessageRequest(content string) (*httptest.ResponseRecorder, *gin.Context) {
+	body, _ := json.Marshal(models.CreateMessageRequest{Content: content})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+	return w, c
+}
+
+func expectInsertMessage(mock sqlmock.Sqlmock, content string) {
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO messages").
+		WithArgs("user-123", content, sqlmock.AnyArg(), sqlmock.AnyArg(), models.VisibilityPublic).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "mentions", "created_at", "updated_at", "visibility"}).
+			AddRow(testMessageID, "user-123", content, pq.Array([]string{}), nil, `[]`, now, now, models.VisibilityPublic))
+}
+
+func TestMessageHandler_CreateMessage_Rejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, decisionFilter{Action: moderation.Reject, Filter: "slurs", Reason: "matched slurs list"}, content.DefaultLimits)
+
+	w, c := newCreateMessageRequest("something awful")
+
+	testutil.Serve(t, c, handler.CreateMessage)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, apierror.CodeContentRejected, response["code"])
+	assert.Equal(t, "message rejected", response["detail"])
+	assert.Equal(t, "matched slurs list", response["reason"])
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_CreateMessage_Held(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, decisionFilter{
+		Action: moderation.Hold, Filter: "links", Reason: "7 links, at most 5 allowed", Category: moderation.CategorySpam,
+	}, content.DefaultLimits)
+
+	content := "buy now @alice"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, handle FROM users WHERE lower\\(handle\\) = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle"}).AddRow("user-456", "alice"))
+	expectInsertMessage(mock, content)
+	mock.ExpectExec("UPDATE messages SET filter_action = \\$2 WHERE id = \\$1").
+		WithArgs(testMessageID, moderation.Hold).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO reports \\(reporter_id, target_type, target_id, target_user_id, reason, details\\) VALUES \\(NULL").
+		WithArgs(models.ReportTargetMessage, testMessageID, "user-123", moderation.CategorySpam,
+			"Held by the links filter: 7 links, at most 5 allowed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, c := newCreateMessageRequest(content)
+
+	testutil.Serve(t, c, handler.CreateMessage)
+
+	// No mention notifications and no events until a moderator approves it
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var response models.Message
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.PendingReview)
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_CreateMessage_ShadowHidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, decisionFilter{Action: moderation.ShadowHide, Filter: "spam", Reason: "spam probability 0.995"}, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	expectInsertMessage(mock, "cheap pills")
+	mock.ExpectExec("UPDATE messages SET filter_action = \\$2 WHERE id = \\$1").
+		WithArgs(testMessageID, moderation.ShadowHide).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, c := newCreateMessageRequest("cheap pills")
+
+	testutil.Serve(t, c, handler.CreateMessage)
+
+	// The author cannot tell
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "pending_review")
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_CreateReply_Held(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, decisionFilter{Action: moderation.Hold, Filter: "words[0]", Reason: "matched words[0] list", Category: "hate"}, content.DefaultLimits)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.user_id, m.visibility FROM messages m WHERE m.id = \\$1 AND .+ FOR SHARE").
+		WithArgs(testMessageID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "visibility"}).AddRow("user-123", models.VisibilityPublic))
+	mock.ExpectQuery("INSERT INTO replies").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "mentions", "score", "created_at", "updated_at"}).
+			AddRow("reply-123", testMessageID, "user-123", "hmm", pq.Array([]string{}), `[]`, 0, now, now))
+	mock.ExpectExec("UPDATE replies SET filter_action = \\$2 WHERE id = \\$1").
+		WithArgs("reply-123", moderation.Hold).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO reports").
+		WithArgs(models.ReportTargetReply, "reply-123", "user-123", "hate", "Held by the words[0] filter: matched words[0] list").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/"+testMessageID+"/replies", bytes.NewBufferString(`{"content":"hmm"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: testMessageID}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.CreateReply)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"pending_review":true`)
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func newUpdateMessageRequest(content string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PATCH", "/messages/"+testMessageID, bytes.NewBufferString(`{"content":"`+content+`"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: testMessageID}}
+	c.Set("user_id", "user-123")
+	return w, c
+}
+
+func expectMessageAuthor(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id").
+		WithArgs(testMessageID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-123"))
+}
+
+func TestMessageHandler_UpdateMessage_Rejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, decisionFilter{Action: moderation.Reject, Filter: "slurs", Reason: "matched slurs list"}, content.DefaultLimits)
+
+	// Clean when posted, edited into something awful: nothing is saved
+	expectMessageAuthor(mock)
+
+	w, c := newUpdateMessageRequest("something awful")
+
+	testutil.Serve(t, c, handler.UpdateMessage)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, apierror.CodeContentRejected, response["code"])
+	assert.Equal(t, "matched slurs list", response["reason"])
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_UpdateMessage_Held(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, decisionFilter{
+		Action: moderation.Hold, Filter: "links", Reason: "7 links, at most 5 allowed", Category: moderation.CategorySpam,
+	}, content.DefaultLimits)
+
+	now := time.Now()
+	expectMessageAuthor(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE messages m SET content").
+		WillReturnRows(sqlmock.NewRows(updatedMessageColumns).
+			AddRow(testMessageID, "user-123", "buy now", pq.Array([]string{}), nil, pq.Array([]string{}), `[]`, now, now, models.VisibilityPublic, false))
+	mock.ExpectExec("DELETE FROM message_tags WHERE message_id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE messages SET filter_action = \\$2 WHERE id = \\$1").
+		WithArgs(testMessageID, moderation.Hold).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO reports").
+		WithArgs(models.ReportTargetMessage, testMessageID, "user-123", moderation.CategorySpam, "Held by the links filter: 7 links, at most 5 allowed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, c := newUpdateMessageRequest("buy now")
+
+	testutil.Serve(t, c, handler.UpdateMessage)
+
+	// Held until a moderator approves it, and not announced
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"pending_review":true`)
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ResolveReport_ApproveHeld(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewModerationHandler(db, bus, accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM reports WHERE id = \\$1 FOR UPDATE").
+		WithArgs(testReportID).
+		WillReturnRows(sqlmock.NewRows(resolveRowColumns).
+			AddRow(testReportID, nil, models.ReportTargetMessage, testMessageID, "user-456", "other", models.ReportOpen, nil, testMessageID))
+	mock.ExpectQuery("UPDATE messages m SET filter_action = NULL WHERE m.id = \\$1 AND m.filter_action = 'hold' RETURNING m.id").
+		WithArgs(testMessageID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"}).
+			AddRow(testMessageID, "user-456", "fine after all", pq.Array([]string{}), nil, pq.Array([]string{}), `[]`, now, now, models.VisibilityPublic))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").
+		WithArgs("mod-1", models.AuditContentApproved, testReportID, models.ReportTargetMessage, testMessageID, []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE reports SET status = 'resolved'").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns).
+			AddRow(testReportID, nil, models.ReportTargetMessage, testMessageID, "user-456", "other", "Held by the words[0] filter: matched words[0] list",
+				models.ReportResolved, "mod-1", now, "mod-1", now, models.ModerationDismiss, nil, now))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	// Nobody filed it, so nobody is told the outcome
+	mock.ExpectCommit()
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/resolve", `{"action":"dismiss"}`)
+
+	testutil.Serve(t, c, handler.ResolveReport)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response models.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Nil(t, response.ReporterID)
+
+	// The message is announced as if just posted
+	published := bus.Published()
+	require.Len(t, published, 1)
+	assert.Equal(t, events.MessageCreated, published[0].Type)
+	assert.Equal(t, testMessageID, published[0].MessageID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ResolveReport_DismissSpamTrainsHam(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM reports WHERE id = \\$1 FOR UPDATE").
+		WithArgs(testReportID).
+		WillReturnRows(sqlmock.NewRows(resolveRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetReply, "reply-1", "user-456", "spam", models.ReportOpen, nil, testMessageID))
+	mock.ExpectQuery("SELECT content FROM replies WHERE id = \\$1").
+		WithArgs("reply-1").
+		WillReturnRows(sqlmock.NewRows([]string{"content"}).AddRow("Try the new release"))
+	mock.ExpectExec("INSERT INTO spam_tokens").
+		WithArgs(pq.Array([]string{"try", "the", "new", "release"}), 0, 1).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("UPDATE spam_documents").
+		WithArgs("ham").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE reports SET status = 'resolved'").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetReply, "reply-1", "user-456", "spam", nil,
+				models.ReportResolved, "mod-1", now, "mod-1", now, models.ModerationDismiss, nil, now))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-123", models.NotificationReportResolved, nil, nil, testReportID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/resolve", `{"action":"dismiss"}`)
+
+	testutil.Serve(t, c, handler.ResolveReport)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+)
+
+var followTracer = otel.Tracer("why-backend/handlers/follows")
+
+type FollowHandler struct {
+	db *sql.DB
+}
+
+func NewFollowHandler(db *sql.DB) *FollowHandler {
+	return &FollowHandler{db: db}
+}
+
+// adjustFollowCounts moves the following count of followerID and the
+// follower count of followeeID by delta. Both rows are locked in id order so
+// two users following each other at once cannot deadlock.
+func adjustFollowCounts(ctx context.Context, tx *sql.Tx, followerID, followeeID string, delta int) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE users u SET
+			following_count = u.following_count + CASE WHEN u.id = $1 THEN $3 ELSE 0 END,
+			follower_count = u.follower_count + CASE WHEN u.id = $2 THEN $3 ELSE 0 END
+		 FROM (SELECT id FROM users WHERE id IN ($1, $2) ORDER BY id FOR UPDATE) l
+		 WHERE u.id = l.id`,
+		followerID, followeeID, delta,
+	)
+	return err
+}
+
+// Follow makes the authenticated user follow another. Following someone
+// already followed is not an error; following across a block is forbidden.
+func (h *FollowHandler) Follow(c *gin.Context) {
+	ctx, span := followTracer.Start(c.Request.Context(), "Follow")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	followeeID := c.Param("id")
+	span.SetAttributes(
+		attribute.String("user.id", userID.(string)),
+		attribute.String("followee.id", followeeID),
+	)
+
+	if _, err := uuid.Parse(followeeID); err != nil {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+	if followeeID == userID.(string) {
+		apierror.Abort(c, apierror.BadRequest("cannot follow yourself"))
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to follow user"))
+		return
+	}
+	defer tx.Rollback()
+
+	var blocked bool
+	if err := tx.QueryRowContext(ctx, `SELECT `+blockedBetween("$1::uuid", "$2::uuid"), userID, followeeID).Scan(&blocked); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check block", "error", err, "user_id", userID, "followee_id", followeeID)
+		apierror.Abort(c, apierror.Internal("failed to follow user"))
+		return
+	}
+	if blocked {
+		apierror.Abort(c, apierror.Forbidden("you cannot follow this user"))
+		return
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO follows (follower_id, followee_id)
+		 SELECT $1, id FROM users WHERE id = $2
+		 ON CONFLICT DO NOTHING`,
+		userID, followeeID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to follow user", "error", err, "user_id", userID, "followee_id", followeeID)
+		apierror.Abort(c, apierror.Internal("failed to follow user"))
+		return
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, followeeID).Scan(&exists); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to check user", "error", err, "followee_id", followeeID)
+			apierror.Abort(c, apierror.Internal("failed to follow user"))
+			return
+		}
+		if !exists {
+			apierror.Abort(c, apierror.NotFound("user not found"))
+			return
+		}
+		// Already following
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if err := adjustFollowCounts(ctx, tx, userID.(string), followeeID, 1); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to update follow counts", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to follow user"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to commit transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to follow user"))
+		return
+	}
+
+	slog.InfoContext(ctx, "User followed", "user_id", userID, "followee_id", followeeID)
+	c.Status(http.StatusNoContent)
+}
+
+// Unfollow stops the authenticated user following another. Unfollowing
+// someone not followed is not an error.
+func (h *FollowHandler) Unfollow(c *gin.Context) {
+	ctx, span := followTracer.Start(c.Request.Context(), "Unfollow")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	followeeID := c.Param("id")
+	span.SetAttributes(
+		attribute.String("user.id", userID.(string)),
+		attribute.String("followee.id", followeeID),
+	)
+
+	if _, err := uuid.Parse(followeeID); err != nil {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to unfollow user"))
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2`,
+		userID, followeeID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to unfollow user", "error", err, "user_id", userID, "followee_id", followeeID)
+		apierror.Abort(c, apierror.Internal("failed to unfollow user"))
+		return
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		if err := adjustFollowCounts(ctx, tx, userID.(string), followeeID, -1); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to update follow counts", "error", err, "user_id", userID)
+			apierror.Abort(c, apierror.Internal("failed to unfollow user"))
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to commit transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to unfollow user"))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListFollowers returns the users following a user, most recent first, with
+// cursor pagination
+func (h *FollowHandler) ListFollowers(c *gin.Context) {
+	h.listFollows(c, "ListFollowers", "follower_count", "follower_id", "followee_id")
+}
+
+// ListFollowing returns the users a user follows, most recent first, with
+// cursor pagination
+func (h *FollowHandler) ListFollowing(c *gin.Context) {
+	h.listFollows(c, "ListFollowing", "following_count", "followee_id", "follower_id")
+}
+
+// listFollows lists the listed column of follows where the user is in the
+// other column, with the matching count from users as the total
+func (h *FollowHandler) listFollows(c *gin.Context, name, countColumn, listed, other string) {
+	ctx, span := followTracer.Start(c.Request.Context(), name)
+	defer span.End()
+
+	userID := c.Param("id")
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	if _, err := uuid.Parse(userID); err != nil {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	result := models.FollowPage{Users: []models.FollowUser{}}
+	err = h.db.QueryRowContext(ctx, `SELECT `+countColumn+` FROM users WHERE id = $1`, userID).Scan(&result.Total)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get user", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to list users"))
+		return
+	}
+
+	query := `SELECT u.id, u.handle, f.created_at
+		 FROM follows f
+		 JOIN users u ON u.id = f.` + listed + `
+		 WHERE f.` + other + ` = $1`
+	args := []any{userID, page.Limit + 1}
+	if page.Cursor != nil {
+		query += ` AND (f.created_at, f.` + listed + `) < ($3, $4)`
+		args = append(args, page.Cursor.CreatedAt, page.Cursor.ID)
+	}
+	query += ` ORDER BY f.created_at DESC, f.` + listed + ` DESC LIMIT $2`
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list follows", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to list users"))
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.FollowUser
+		if err := rows.Scan(&u.ID, &u.Handle, &u.FollowedAt); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan follow", "error", err)
+			continue
+		}
+		result.Users = append(result.Users, u)
+	}
+
+	if len(result.Users) > page.Limit {
+		result.Users = result.Users[:page.Limit]
+		last := result.Users[page.Limit-1]
+		result.NextCursor = encodeCursor(last.FollowedAt, last.ID)
+	}
+
+	span.SetAttributes(attribute.Int("users.count", len(result.Users)))
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+const (
+	testFollowerID = "3c2b1a00-0000-4000-8000-000000000001"
+	testFolloweeID = "3c2b1a00-0000-4000-8000-000000000002"
+)
+
+func newFollowRequest(method, target, userID, id string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, nil)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	if userID != "" {
+		c.Set("user_id", userID)
+	}
+	return w, c
+}
+
+func expectNoBlock(mock sqlmock.Sqlmock, a, b string) {
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM user_blocks b").
+		WithArgs(a, b).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+}
+
+func TestFollowHandler_Follow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	mock.ExpectBegin()
+	expectNoBlock(mock, testFollowerID, testFolloweeID)
+	mock.ExpectExec("INSERT INTO follows \\(follower_id, followee_id\\) SELECT \\$1, id FROM users WHERE id = \\$2 ON CONFLICT DO NOTHING").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users u SET.+FROM \\(SELECT id FROM users WHERE id IN \\(\\$1, \\$2\\) ORDER BY id FOR UPDATE\\) l").
+		WithArgs(testFollowerID, testFolloweeID, 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	_, c := newFollowRequest("PUT", "/users/"+testFolloweeID+"/follow", testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Follow)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowHandler_Follow_AlreadyFollowing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	mock.ExpectBegin()
+	expectNoBlock(mock, testFollowerID, testFolloweeID)
+	mock.ExpectExec("INSERT INTO follows").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM users WHERE id = \\$1\\)").
+		WithArgs(testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, c := newFollowRequest("PUT", "/users/"+testFolloweeID+"/follow", testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Follow)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowHandler_Follow_UserNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	mock.ExpectBegin()
+	expectNoBlock(mock, testFollowerID, testFolloweeID)
+	mock.ExpectExec("INSERT INTO follows").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	w, c := newFollowRequest("PUT", "/users/"+testFolloweeID+"/follow", testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Follow)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowHandler_Follow_Blocked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM user_blocks b").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	w, c := newFollowRequest("PUT", "/users/"+testFolloweeID+"/follow", testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Follow)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowHandler_Follow_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	w, c := newFollowRequest("PUT", "/users/"+testFollowerID+"/follow", testFollowerID, testFollowerID)
+	testutil.Serve(t, c, handler.Follow)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, c = newFollowRequest("PUT", "/users/bogus/follow", testFollowerID, "bogus")
+	testutil.Serve(t, c, handler.Follow)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowHandler_Unfollow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM follows WHERE follower_id = \\$1 AND followee_id = \\$2").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users u SET").
+		WithArgs(testFollowerID, testFolloweeID, -1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	_, c := newFollowRequest("DELETE", "/users/"+testFolloweeID+"/follow", testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Unfollow)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowHandler_Unfollow_NotFollowing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM follows").
+		WithArgs(testFollowerID, testFolloweeID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	_, c := newFollowRequest("DELETE", "/users/"+testFolloweeID+"/follow", testFollowerID, testFolloweeID)
+	testutil.Serve(t, c, handler.Unfollow)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowHandler_ListFollowers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT follower_count FROM users WHERE id = \\$1").
+		WithArgs(testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"follower_count"}).AddRow(3))
+	mock.ExpectQuery("FROM follows f JOIN users u ON u.id = f.follower_id WHERE f.followee_id = \\$1 "+
+		"ORDER BY f.created_at DESC, f.follower_id DESC LIMIT \\$2").
+		WithArgs(testFolloweeID, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle", "created_at"}).
+			AddRow("3c2b1a00-0000-4000-8000-000000000003", "carol", now).
+			AddRow(testFollowerID, nil, now.Add(-time.Hour)).
+			AddRow("3c2b1a00-0000-4000-8000-000000000004", "dave", now.Add(-2*time.Hour)))
+
+	w, c := newFollowRequest("GET", "/users/"+testFolloweeID+"/followers?limit=2", "", testFolloweeID)
+	testutil.Serve(t, c, handler.ListFollowers)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.FollowPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.Total)
+	require.Len(t, response.Users, 2)
+	assert.Equal(t, "carol", *response.Users[0].Handle)
+	assert.Nil(t, response.Users[1].Handle)
+	require.NotEmpty(t, response.NextCursor)
+
+	cursor, err := decodeCursor(response.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, testFollowerID, cursor.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowHandler_ListFollowing_Cursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	cursorTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT following_count FROM users WHERE id = \\$1").
+		WithArgs(testFollowerID).
+		WillReturnRows(sqlmock.NewRows([]string{"following_count"}).AddRow(5))
+	mock.ExpectQuery("JOIN users u ON u.id = f.followee_id WHERE f.follower_id = \\$1 "+
+		"AND \\(f.created_at, f.followee_id\\) < \\(\\$3, \\$4\\)").
+		WithArgs(testFollowerID, 21, cursorTime, testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle", "created_at"}))
+
+	w, c := newFollowRequest("GET", "/users/"+testFollowerID+"/following?cursor="+encodeCursor(cursorTime, testFolloweeID), "", testFollowerID)
+	testutil.Serve(t, c, handler.ListFollowing)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"users":[],"total":5}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowHandler_ListFollowers_UserNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewFollowHandler(db)
+
+	mock.ExpectQuery("SELECT follower_count FROM users").
+		WithArgs(testFolloweeID).
+		WillReturnRows(sqlmock.NewRows([]string{"follower_count"}))
+
+	w, c := newFollowRequest("GET", "/users/"+testFolloweeID+"/followers", "", testFolloweeID)
+	testutil.Serve(t, c, handler.ListFollowers)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,16 +1,20 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"path/filepath"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
 	"why-backend/internal/config"
 	"why-backend/internal/storage"
 )
@@ -18,18 +22,27 @@ import (
 var mediaTracer = otel.Tracer("why-backend/handlers/media")
 
 type MediaHandler struct {
+	db     *sql.DB
 	minio  *minio.Client
 	config *config.Config
 }
 
-func NewMediaHandler(minio *minio.Client, cfg *config.Config) *MediaHandler {
+func NewMediaHandler(db *sql.DB, minio *minio.Client, cfg *config.Config) *MediaHandler {
 	return &MediaHandler{
+		db:     db,
 		minio:  minio,
 		config: cfg,
 	}
 }
 
-// UploadMedia handles file uploads to MinIO
+// conversationObject is where media attached to a conversation is stored
+func conversationObject(conversationID, name string) string {
+	return "conversations/" + conversationID + "/" + name
+}
+
+// UploadMedia handles file uploads to MinIO. With a conversation_id form
+// field the file is attached to that conversation: only its participants can
+// fetch it, through GetConversationMedia.
 func (h *MediaHandler) UploadMedia(c *gin.Context) {
 	ctx, span := mediaTracer.Start(c.Request.Context(), "UploadMedia")
 	defer span.End()
@@ -37,7 +50,7 @@ func (h *MediaHandler) UploadMedia(c *gin.Context) {
 	file, err := c.FormFile("file")
 	if err != nil {
 		span.RecordError(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		apierror.Abort(c, apierror.BadRequest("file is required"))
 		return
 	}
 
@@ -46,12 +59,20 @@ func (h *MediaHandler) UploadMedia(c *gin.Context) {
 		attribute.Int64("file.size", file.Size),
 	)
 
+	conversationID := c.PostForm("conversation_id")
+	if conversationID != "" {
+		span.SetAttributes(attribute.String("conversation.id", conversationID))
+		if !h.canAccessConversation(c, conversationID) {
+			return
+		}
+	}
+
 	// Open uploaded file
 	src, err := file.Open()
 	if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to open uploaded file", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+		apierror.Abort(c, apierror.Internal("failed to read file"))
 		return
 	}
 	defer src.Close()
@@ -60,13 +81,32 @@ func (h *MediaHandler) UploadMedia(c *gin.Context) {
 	ext := filepath.Ext(file.Filename)
 	objectName := fmt.Sprintf("%s%s", uuid.New().String(), ext)
 	contentType := storage.GetContentType(file.Filename)
+	if conversationID != "" {
+		objectName = conversationObject(conversationID, objectName)
+	}
 
 	// Upload to MinIO
 	url, err := storage.UploadFile(ctx, h.minio, h.config.MinIO.BucketName, objectName, src, file.Size, contentType)
 	if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to upload file to MinIO", "error", err, "filename", file.Filename)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload file"})
+		apierror.Abort(c, apierror.Internal("failed to upload file"))
+		return
+	}
+
+	if conversationID != "" {
+		url = "/api/v1/conversations/" + conversationID + "/media/" + filepath.Base(objectName)
+	}
+
+	// Only files recorded here may be attached to messages
+	userID, _ := c.Get("user_id")
+	if _, err := h.db.ExecContext(ctx,
+		`INSERT INTO media_uploads (url, user_id, conversation_id) VALUES ($1, $2, NULLIF($3, '')::uuid)`,
+		url, userID, conversationID,
+	); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to record upload", "error", err, "url", url)
+		apierror.Abort(c, apierror.Internal("failed to upload file"))
 		return
 	}
 
@@ -75,3 +115,66 @@ func (h *MediaHandler) UploadMedia(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"url": url})
 }
+
+// GetConversationMedia serves a file attached to a conversation to its
+// participants
+func (h *MediaHandler) GetConversationMedia(c *gin.Context) {
+	ctx, span := mediaTracer.Start(c.Request.Context(), "GetConversationMedia")
+	defer span.End()
+
+	conversationID := c.Param("id")
+	name := c.Param("name")
+	span.SetAttributes(
+		attribute.String("conversation.id", conversationID),
+		attribute.String("file.name", name),
+	)
+
+	// Names are the <uuid><ext> given out by UploadMedia
+	if _, err := uuid.Parse(strings.TrimSuffix(name, filepath.Ext(name))); err != nil {
+		apierror.Abort(c, apierror.NotFound("file not found"))
+		return
+	}
+	if !h.canAccessConversation(c, conversationID) {
+		return
+	}
+
+	object, info, err := storage.OpenFile(ctx, h.minio, h.config.MinIO.BucketName, conversationObject(conversationID, name))
+	if errors.Is(err, storage.ErrNotFound) {
+		apierror.Abort(c, apierror.NotFound("file not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to open file", "error", err, "conversation_id", conversationID, "name", name)
+		apierror.Abort(c, apierror.Internal("failed to get file"))
+		return
+	}
+	defer object.Close()
+
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, object, map[string]string{
+		"Cache-Control": "private, max-age=3600",
+	})
+}
+
+// canAccessConversation reports whether the authenticated user takes part in
+// conversationID, failing the request with a 404 or 500 when not
+func (h *MediaHandler) canAccessConversation(c *gin.Context, conversationID string) bool {
+	ctx := c.Request.Context()
+	userID, _ := c.Get("user_id")
+
+	if _, err := uuid.Parse(conversationID); err != nil {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return false
+	}
+
+	ok, err := isParticipant(ctx, h.db, conversationID, userID.(string))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to check participant", "error", err, "conversation_id", conversationID)
+		apierror.Abort(c, apierror.Internal("failed to check conversation"))
+		return false
+	}
+	if !ok {
+		apierror.Abort(c, apierror.NotFound("conversation not found"))
+		return false
+	}
+	return true
+}
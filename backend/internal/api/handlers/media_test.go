@@ -7,8 +7,10 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"why-backend/internal/testutil"
 )
 
@@ -17,14 +19,14 @@ func TestMediaHandler_UploadMedia_MissingFile(t *testing.T) {
 	cfg := testutil.GetTestConfig()
 
 	// Create handler with nil minio client (won't be called for this test)
-	handler := NewMediaHandler(nil, cfg)
+	handler := NewMediaHandler(nil, nil, cfg)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request = httptest.NewRequest("POST", "/media", nil)
 	c.Request.Header.Set("Content-Type", "multipart/form-data")
 
-	handler.UploadMedia(c)
+	testutil.Serve(t, c, handler.UploadMedia)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
@@ -33,18 +35,88 @@ func TestMediaHandler_UploadMedia_InvalidFormData(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	cfg := testutil.GetTestConfig()
 
-	handler := NewMediaHandler(nil, cfg)
+	handler := NewMediaHandler(nil, nil, cfg)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request = httptest.NewRequest("POST", "/media", nil)
 	c.Request.Header.Set("Content-Type", "application/json") // Wrong content type
 
-	handler.UploadMedia(c)
+	testutil.Serve(t, c, handler.UploadMedia)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestMediaHandler_UploadMedia_ConversationNotParticipant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testutil.GetTestConfig()
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	// Refused before MinIO is reached
+	handler := NewMediaHandler(db, nil, cfg)
+
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM conversation_participants WHERE conversation_id = \\$1 AND user_id = \\$2\\)").
+		WithArgs(testConversationID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	require.NoError(t, writer.WriteField("conversation_id", testConversationID))
+	part, err := writer.CreateFormFile("file", "photo.png")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("png"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/media", body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UploadMedia)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMediaHandler_GetConversationMedia_Denied(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testutil.GetTestConfig()
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMediaHandler(db, nil, cfg)
+
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM conversation_participants").
+		WithArgs(testConversationID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"not a participant", "4d3c2b1a-0000-4000-8000-000000000201.png"},
+		{"not an uploaded name", "..secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/conversations/"+testConversationID+"/media/"+tt.file, nil)
+			c.Params = gin.Params{{Key: "id", Value: testConversationID}, {Key: "name", Value: tt.file}}
+			c.Set("user_id", "user-123")
+
+			testutil.Serve(t, c, handler.GetConversationMedia)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func createMultipartFormData(t *testing.T, fieldName, fileName string, fileContent []byte) (*bytes.Buffer, string) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
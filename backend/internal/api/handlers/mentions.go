@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"why-backend/internal/content"
+	"why-backend/internal/models"
+)
+
+// resolveMentions matches the @handles in text against registered users.
+// Handles that do not belong to anyone are left as plain text.
+func resolveMentions(ctx context.Context, tx *sql.Tx, text string) (models.Mentions, error) {
+	mentions := models.Mentions{}
+
+	spans := content.ExtractMentions(text)
+	if len(spans) == 0 {
+		return mentions, nil
+	}
+
+	var handles []string
+	seen := make(map[string]bool)
+	for _, span := range spans {
+		if !seen[span.Handle] {
+			seen[span.Handle] = true
+			handles = append(handles, span.Handle)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, handle FROM users WHERE lower(handle) = ANY($1)`,
+		pq.Array(handles),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type user struct{ id, handle string }
+	users := make(map[string]user)
+	for rows.Next() {
+		var u user
+		if err := rows.Scan(&u.id, &u.handle); err != nil {
+			return nil, err
+		}
+		users[content.NormalizeHandle(u.handle)] = u
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, span := range spans {
+		if u, ok := users[span.Handle]; ok {
+			mentions = append(mentions, models.Mention{
+				UserID: u.id,
+				Handle: u.handle,
+				Start:  span.Start,
+				End:    span.End,
+			})
+		}
+	}
+
+	return mentions, nil
+}
+
+// notifyMentions creates one mention notification per mentioned user.
+// Re-notifying for the same message or reply is a no-op, so it is safe to
+// call again after an edit. Being mentioned in a message lets a user read
+// it, but being mentioned in a reply does not, so a reply's mentions only
+// notify users who can read its thread.
+func notifyMentions(ctx context.Context, tx *sql.Tx, actorID, messageID string, replyID sql.NullString, mentions models.Mentions) error {
+	userIDs := make([]string, 0, len(mentions))
+	for _, m := range mentions {
+		userIDs = append(userIDs, m.UserID)
+	}
+
+	if replyID.Valid && len(userIDs) > 0 {
+		var err error
+		userIDs, err = threadReaders(ctx, tx, messageID, userIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return notify(ctx, tx, notificationEvent{
+		Type:      models.NotificationMention,
+		ActorID:   actorID,
+		MessageID: messageID,
+		ReplyID:   replyID,
+	}, userIDs...)
+}
+
+// threadReaders returns those of userIDs who can read message messageID and
+// have no block with its author
+func threadReaders(ctx context.Context, tx *sql.Tx, messageID string, userIDs []string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT u.id FROM messages m CROSS JOIN unnest($2::uuid[]) AS u(id)
+		 WHERE m.id = $1 AND `+readableBy("u.id")+` AND NOT `+blockedBetween("m.user_id", "u.id"),
+		messageID, pq.Array(userIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readers []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		readers = append(readers, id)
+	}
+	return readers, rows.Err()
+}
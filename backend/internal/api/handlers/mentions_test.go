@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/content"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+	"why-backend/internal/testutil"
+)
+
+func TestMessageHandler_CreateReply_WithMentions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	messageID := "msg-123"
+	text := "@Alice and @bob, see @nobody and @me"
+	body, _ := json.Marshal(models.CreateReplyRequest{Content: text})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.user_id, m.visibility FROM messages m WHERE m.id").
+		WithArgs(messageID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "visibility"}).AddRow("user-123", "public"))
+	mock.ExpectQuery("SELECT id, handle FROM users WHERE lower\\(handle\\) = ANY").
+		WithArgs(pq.Array([]string{"alice", "bob", "nobody", "me"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle"}).
+			AddRow("user-alice", "Alice").
+			AddRow("user-bob", "bob").
+			AddRow("user-123", "me"))
+
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO replies").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "mentions", "score", "created_at", "updated_at"}).
+			AddRow("reply-123", messageID, "user-123", text, pq.StringArray{},
+				`[{"user_id":"user-alice","handle":"Alice","start":0,"end":6},{"user_id":"user-bob","handle":"bob","start":11,"end":15},{"user_id":"user-123","handle":"me","start":33,"end":36}]`,
+				0, now, now))
+
+	// Replying to and mentioning yourself notifies nobody but the others
+	mock.ExpectQuery("SELECT u.id FROM messages m CROSS JOIN unnest\\(\\$2::uuid\\[\\]\\) AS u\\(id\\) WHERE m.id = \\$1 AND m.hidden_at IS NULL").
+		WithArgs(messageID, pq.Array([]string{"user-alice", "user-bob", "user-123"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-alice").AddRow("user-bob").AddRow("user-123"))
+	mock.ExpectExec("INSERT INTO notifications .+NOT EXISTS \\(SELECT 1 FROM user_blocks").
+		WithArgs("user-123", models.NotificationMention, messageID, "reply-123", pq.Array([]string{"user-alice", "user-bob"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/"+messageID+"/replies", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: messageID}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.CreateReply)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.Reply
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Len(t, response.Mentions, 3)
+	assert.Equal(t, models.Mention{UserID: "user-alice", Handle: "Alice", Start: 0, End: 6}, response.Mentions[0])
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_CreateReply_MentionOnFollowersOnlyThread(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	messageID := "msg-123"
+	text := "@alice @carol what do you think?"
+	body, _ := json.Marshal(models.CreateReplyRequest{Content: text})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.user_id, m.visibility FROM messages m WHERE m.id").
+		WithArgs(messageID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "visibility"}).AddRow("user-123", models.VisibilityFollowers))
+	mock.ExpectQuery("SELECT id, handle FROM users WHERE lower\\(handle\\) = ANY").
+		WithArgs(pq.Array([]string{"alice", "carol"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle"}).
+			AddRow("user-alice", "alice").
+			AddRow("user-carol", "carol"))
+
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO replies").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "mentions", "score", "created_at", "updated_at"}).
+			AddRow("reply-123", messageID, "user-123", text, pq.StringArray{},
+				`[{"user_id":"user-alice","handle":"alice","start":0,"end":6},{"user_id":"user-carol","handle":"carol","start":7,"end":13}]`,
+				0, now, now))
+
+	// Only alice follows the author, so only she can read the thread and
+	// hear about the mention; carol is mentioned but not notified
+	mock.ExpectQuery("SELECT u.id FROM messages m CROSS JOIN unnest.+m.visibility <> 'followers'.+follows f WHERE f.follower_id = u.id::uuid.+user_blocks").
+		WithArgs(messageID, pq.Array([]string{"user-alice", "user-carol"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-alice"))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-123", models.NotificationMention, messageID, "reply-123", pq.Array([]string{"user-alice"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/"+messageID+"/replies", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: messageID}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.CreateReply)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestResolveMentions_DropsUnknownHandles(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, handle FROM users").
+		WithArgs(pq.Array([]string{"ghost", "alice"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle"}).AddRow("user-alice", "alice"))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+
+	mentions, err := resolveMentions(context.Background(), tx, "@ghost @alice @ALICE")
+	require.NoError(t, err)
+	assert.Equal(t, models.Mentions{
+		{UserID: "user-alice", Handle: "alice", Start: 7, End: 13},
+		{UserID: "user-alice", Handle: "alice", Start: 14, End: 20},
+	}, mentions)
+}
+
+func TestResolveMentions_NoMentionsSkipsQuery(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+
+	mentions, err := resolveMentions(context.Background(), tx, "nobody here, email me at bob@example.com")
+	require.NoError(t, err)
+	assert.Empty(t, mentions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,28 +1,95 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/content"
+	"why-backend/internal/events"
 	"why-backend/internal/models"
+	"why-backend/internal/moderation"
 )
 
 var messageTracer = otel.Tracer("why-backend/handlers/messages")
 
+// messageColumns selects a models.Message from the messages table aliased as m
+const messageColumns = `m.id, m.user_id, m.content, m.media_urls, m.accepted_reply_id,
+	ARRAY(SELECT mt.tag FROM message_tags mt WHERE mt.message_id = m.id ORDER BY mt.tag) AS tags,
+	m.mentions, m.created_at, m.updated_at, m.visibility`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMessage scans a row selected with messageColumns, and then into
+// extra, if given, any columns selected after them
+func scanMessage(row rowScanner, message *models.Message, extra ...any) error {
+	return row.Scan(append([]any{&message.ID, &message.UserID, &message.Content, &message.MediaURLs, &message.AcceptedReplyID,
+		&message.Tags, &message.Mentions, &message.CreatedAt, &message.UpdatedAt, &message.Visibility}, extra...)...)
+}
+
+// readableBy is an SQL condition that holds when the message m may be read
+// by viewer, a uuid parameter, or by anyone when viewer is empty.
+// Followers-only messages are readable by the author, their followers and
+// the users mentioned in them; hidden messages and those of banned users by
+// no one; messages the content filter held or shadow-hid by their author
+// alone.
+func readableBy(viewer string) string {
+	if viewer == "" {
+		return `m.hidden_at IS NULL AND m.visibility <> '` + models.VisibilityFollowers + `' AND ` + notBanned("m.user_id") +
+			` AND ` + unfiltered("m", "")
+	}
+	filtered := unfiltered("m", viewer)
+	viewer += `::uuid`
+	return `m.hidden_at IS NULL AND (m.visibility <> '` + models.VisibilityFollowers + `'
+		 OR m.user_id = ` + viewer + `
+		 OR EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = ` + viewer + ` AND f.followee_id = m.user_id)
+		 OR m.mentions @> jsonb_build_array(jsonb_build_object('user_id', ` + viewer + `::text)))
+		 AND ` + notBanned("m.user_id") + ` AND ` + filtered
+}
+
+// notBanned is an SQL condition that holds when the user in column is not
+// banned. Banned users' messages and replies are left out of every read.
+func notBanned(column string) string {
+	return `NOT EXISTS (SELECT 1 FROM users bu WHERE bu.id = ` + column + ` AND bu.banned_at IS NOT NULL)`
+}
+
 type MessageHandler struct {
-	db *sql.DB
+	db        *sql.DB
+	publisher events.Publisher
+	filter    moderation.Filter
+	limits    content.Limits
+}
+
+func NewMessageHandler(db *sql.DB, publisher events.Publisher, filter moderation.Filter, limits content.Limits) *MessageHandler {
+	return &MessageHandler{db: db, publisher: publisher, filter: filter, limits: limits}
 }
 
-func NewMessageHandler(db *sql.DB) *MessageHandler {
-	return &MessageHandler{db: db}
+// validate checks new or edited content against the limits, responding when
+// it is invalid or cannot be checked
+func (h *MessageHandler) validate(ctx context.Context, c *gin.Context, p post, failure string) bool {
+	valid, err := validatePost(ctx, c, h.db, p)
+	if err != nil {
+		trace.SpanFromContext(ctx).RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check media uploads", "error", err, "user_id", p.UserID)
+		apierror.Abort(c, apierror.Internal(failure))
+	}
+	return valid
 }
 
-// CreateMessage creates a new message
+// CreateMessage creates a new message once the content filter lets it
+// through. Rejected messages get a 422; held ones are stored, pending review,
+// with a 202, and shadow-hidden ones as if nothing happened. Neither is
+// announced to anyone.
 func (h *MessageHandler) CreateMessage(c *gin.Context) {
 	ctx, span := messageTracer.Start(c.Request.Context(), "CreateMessage")
 	defer span.End()
@@ -31,59 +98,146 @@ func (h *MessageHandler) CreateMessage(c *gin.Context) {
 	span.SetAttributes(attribute.String("user.id", userID.(string)))
 
 	var req models.CreateMessageRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = models.VisibilityPublic
+	}
+	if !h.validate(ctx, c, post{
+		UserID: userID.(string), Text: &req.Content, MaxLength: h.limits.MessageLength,
+		MediaURLs: req.MediaURLs, MaxAttachments: h.limits.Attachments,
+	}, "failed to create message") {
+		return
+	}
+
+	decision, ok := h.checkContent(ctx, c, moderation.Content{UserID: userID.(string), Kind: moderation.KindMessage, Text: req.Content}, "failed to create message")
+	if !ok {
+		return
+	}
+	filtered := decision.Action != moderation.Allow
+
+	tags := content.ExtractHashtags(req.Content)
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
 		span.RecordError(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to create message"))
 		return
 	}
+	defer tx.Rollback()
 
 	var message models.Message
-	err := h.db.QueryRowContext(ctx,
-		`INSERT INTO messages (user_id, content, media_urls)
-		 VALUES ($1, $2, $3)
-		 RETURNING id, user_id, content, media_urls, created_at, updated_at`,
-		userID, req.Content, pq.Array(req.MediaURLs),
-	).Scan(&message.ID, &message.UserID, &message.Content, &message.MediaURLs, &message.CreatedAt, &message.UpdatedAt)
-
+	mentions, err := resolveMentions(ctx, tx, req.Content)
+	if err == nil {
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO messages (user_id, content, media_urls, mentions, visibility)
+			 VALUES ($1, $2, $3, $4, $5)
+			 RETURNING id, user_id, content, media_urls, accepted_reply_id, mentions, created_at, updated_at, visibility`,
+			userID, req.Content, pq.Array(req.MediaURLs), mentions, req.Visibility,
+		).Scan(&message.ID, &message.UserID, &message.Content, &message.MediaURLs, &message.AcceptedReplyID, &message.Mentions, &message.CreatedAt, &message.UpdatedAt, &message.Visibility)
+	}
+	if err == nil {
+		err = insertMessageTags(ctx, tx, message.ID, tags)
+	}
+	if err == nil && filtered {
+		err = markFiltered(ctx, tx, models.ReportTargetMessage, message.ID, message.UserID, decision)
+	}
+	if err == nil && !filtered {
+		err = notifyMentions(ctx, tx, message.UserID, message.ID, sql.NullString{}, mentions)
+	}
+	message.Tags = append(pq.StringArray{}, tags...)
+	// Events are seen by every stream, webhook and search index subscriber
+	if err == nil && !filtered && message.Visibility == models.VisibilityPublic {
+		err = h.publisher.Publish(ctx, tx, events.MessageCreated, message.ID, message)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to create message", "error", err, "user_id", userID)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create message"})
+		apierror.Abort(c, apierror.Internal("failed to create message"))
 		return
 	}
 
 	span.SetAttributes(
 		attribute.String("message.id", message.ID),
+		attribute.String("message.visibility", message.Visibility),
 		attribute.Int("media_urls.count", len(req.MediaURLs)),
+		attribute.Int("tags.count", len(tags)),
+		attribute.Int("mentions.count", len(mentions)),
 	)
 	slog.InfoContext(ctx, "Message created", "message_id", message.ID, "user_id", userID)
 
+	if decision.Action == moderation.Hold {
+		message.PendingReview = true
+		c.JSON(http.StatusAccepted, message)
+		return
+	}
 	c.JSON(http.StatusCreated, message)
 }
 
-// ListMessages returns paginated messages
+// ListMessages returns paginated messages.
+// ?filter=unanswered restricts the list to messages without an accepted reply.
+// Unlisted messages are left out, and followers-only ones are listed only for
+// viewers who may read them. Signed-in viewers do not see messages from users
+// they blocked or muted or who blocked them.
 func (h *MessageHandler) ListMessages(c *gin.Context) {
 	ctx, span := messageTracer.Start(c.Request.Context(), "ListMessages")
 	defer span.End()
 
+	filter := c.Query("filter")
+	span.SetAttributes(attribute.String("messages.filter", filter))
+
+	var conditions []string
+	var args []any
+	switch filter {
+	case "":
+	case "unanswered":
+		conditions = append(conditions, "m.accepted_reply_id IS NULL")
+	default:
+		apierror.Abort(c, apierror.BadRequest("invalid filter"))
+		return
+	}
+
+	if viewerID := c.GetString("user_id"); viewerID != "" {
+		args = append(args, viewerID)
+		conditions = append(conditions,
+			`m.visibility <> '`+models.VisibilityUnlisted+`'`,
+			readableBy("$1"),
+			visibleTo("m.user_id", "$1"),
+		)
+	} else {
+		conditions = append(conditions, `m.visibility = '`+models.VisibilityPublic+`'`, `m.hidden_at IS NULL`, notBanned("m.user_id"), unfiltered("m", ""))
+	}
+
+	var where string
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
 	rows, err := h.db.QueryContext(ctx,
-		`SELECT id, user_id, content, media_urls, created_at, updated_at
-		 FROM messages
-		 ORDER BY created_at DESC
+		`SELECT `+messageColumns+`
+		 FROM messages m
+		 `+where+`
+		 ORDER BY m.created_at DESC
 		 LIMIT 50`,
+		args...,
 	)
 	if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to list messages", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list messages"})
+		apierror.Abort(c, apierror.Internal("failed to list messages"))
 		return
 	}
 	defer rows.Close()
 
-	var messages []models.Message
+	messages := []models.Message{}
 	for rows.Next() {
 		var msg models.Message
-		if err := rows.Scan(&msg.ID, &msg.UserID, &msg.Content, &msg.MediaURLs, &msg.CreatedAt, &msg.UpdatedAt); err != nil {
+		if err := scanMessage(rows, &msg); err != nil {
 			span.RecordError(err)
 			slog.ErrorContext(ctx, "Failed to scan message", "error", err)
 			continue
@@ -95,7 +249,9 @@ func (h *MessageHandler) ListMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, messages)
 }
 
-// GetMessage returns a single message with its replies
+// GetMessage returns a single message with its replies. A message is not
+// found when the viewer may not read it, or for a signed-in viewer when its
+// author and the viewer are blocked either way.
 func (h *MessageHandler) GetMessage(c *gin.Context) {
 	ctx, span := messageTracer.Start(c.Request.Context(), "GetMessage")
 	defer span.End()
@@ -103,27 +259,159 @@ func (h *MessageHandler) GetMessage(c *gin.Context) {
 	messageID := c.Param("id")
 	span.SetAttributes(attribute.String("message.id", messageID))
 
+	query := `SELECT ` + messageColumns + `
+		 FROM messages m WHERE m.id = $1`
+	args := []any{messageID}
+	if viewerID := c.GetString("user_id"); viewerID != "" {
+		query += ` AND ` + readableBy("$2") + ` AND NOT ` + blockedBetween("m.user_id", "$2")
+		args = append(args, viewerID)
+	} else {
+		query += ` AND ` + readableBy("")
+	}
+
 	var message models.Message
-	err := h.db.QueryRowContext(ctx,
-		`SELECT id, user_id, content, media_urls, created_at, updated_at
-		 FROM messages WHERE id = $1`,
-		messageID,
-	).Scan(&message.ID, &message.UserID, &message.Content, &message.MediaURLs, &message.CreatedAt, &message.UpdatedAt)
+	err := scanMessage(h.db.QueryRowContext(ctx, query, args...), &message)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		apierror.Abort(c, apierror.NotFound("message not found"))
 		return
 	} else if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to get message", "error", err, "message_id", messageID)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get message"})
+		apierror.Abort(c, apierror.Internal("failed to get message"))
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// UpdateMessage edits the content and (optionally) media of a message and
+// re-derives its hashtags. Only the author may edit. The edit goes through
+// the content filter as a new message does: a rejected edit is not saved,
+// and a held one is answered with a 202.
+func (h *MessageHandler) UpdateMessage(c *gin.Context) {
+	ctx, span := messageTracer.Start(c.Request.Context(), "UpdateMessage")
+	defer span.End()
+
+	messageID := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	span.SetAttributes(
+		attribute.String("message.id", messageID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	var req models.UpdateMessageRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if !h.authorizeMessageAuthor(c, messageID, userID.(string)) {
+		return
+	}
+	if !h.validate(ctx, c, post{
+		UserID: userID.(string), Text: &req.Content, MaxLength: h.limits.MessageLength,
+		MediaURLs: req.MediaURLs, MaxAttachments: h.limits.Attachments,
+	}, "failed to update message") {
+		return
+	}
+
+	decision, ok := h.checkContent(ctx, c, moderation.Content{
+		ID: messageID, UserID: userID.(string), Kind: moderation.KindMessage, Text: req.Content,
+	}, "failed to update message")
+	if !ok {
+		return
+	}
+	filtered := decision.Action != moderation.Allow
+
+	tags := content.ExtractHashtags(req.Content)
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to update message"))
+		return
+	}
+	defer tx.Rollback()
+
+	// A nil media_urls leaves the existing attachments in place. A message
+	// hidden by a moderator or held or shadow-hidden by the content filter
+	// stays out of sight: neither mentions nor events are sent for it.
+	var (
+		message models.Message
+		hidden  bool
+	)
+	mentions, err := resolveMentions(ctx, tx, req.Content)
+	if err == nil {
+		err = scanMessage(tx.QueryRowContext(ctx,
+			`UPDATE messages m SET content = $1, media_urls = COALESCE($2, m.media_urls), mentions = $3, updated_at = NOW()
+			 WHERE m.id = $4
+			 RETURNING `+messageColumns+`, m.hidden_at IS NOT NULL OR m.filter_action IS NOT NULL`,
+			req.Content, pq.Array(req.MediaURLs), mentions, messageID,
+		), &message, &hidden)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `DELETE FROM message_tags WHERE message_id = $1`, messageID)
+	}
+	if err == nil {
+		err = insertMessageTags(ctx, tx, messageID, tags)
+	}
+	if err == nil && filtered {
+		err = markFiltered(ctx, tx, models.ReportTargetMessage, messageID, message.UserID, decision)
+	}
+	if err == nil && !filtered && !hidden {
+		err = notifyMentions(ctx, tx, message.UserID, messageID, sql.NullString{}, mentions)
+	}
+	message.Tags = append(pq.StringArray{}, tags...)
+	if err == nil && !filtered && !hidden && message.Visibility == models.VisibilityPublic {
+		err = h.publisher.Publish(ctx, tx, events.MessageUpdated, messageID, message)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("message not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to update message", "error", err, "message_id", messageID)
+		apierror.Abort(c, apierror.Internal("failed to update message"))
 		return
 	}
 
+	span.SetAttributes(attribute.Int("tags.count", len(tags)))
+	slog.InfoContext(ctx, "Message updated", "message_id", messageID, "user_id", userID)
+
+	if decision.Action == moderation.Hold {
+		message.PendingReview = true
+		c.JSON(http.StatusAccepted, message)
+		return
+	}
 	c.JSON(http.StatusOK, message)
 }
 
-// CreateReply creates a reply to a message
+// insertMessageTags records the tags of a message, creating any new tags
+func insertMessageTags(ctx context.Context, tx *sql.Tx, messageID string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT DO NOTHING`,
+		pq.Array(tags),
+	); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO message_tags (message_id, tag) SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`,
+		messageID, pq.Array(tags),
+	)
+	return err
+}
+
+// CreateReply creates a reply to a message, filtered like a message
 func (h *MessageHandler) CreateReply(c *gin.Context) {
 	ctx, span := messageTracer.Start(c.Request.Context(), "CreateReply")
 	defer span.End()
@@ -137,60 +425,167 @@ func (h *MessageHandler) CreateReply(c *gin.Context) {
 	)
 
 	var req models.CreateReplyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !h.validate(ctx, c, post{
+		UserID: userID.(string), Text: &req.Content, MaxLength: h.limits.ReplyLength,
+		MediaURLs: req.MediaURLs, MaxAttachments: h.limits.Attachments,
+	}, "failed to create reply") {
+		return
+	}
+
+	decision, ok := h.checkContent(ctx, c, moderation.Content{UserID: userID.(string), Kind: moderation.KindReply, Text: req.Content}, "failed to create reply")
+	if !ok {
+		return
+	}
+	filtered := decision.Action != moderation.Allow
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
 		span.RecordError(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to create reply"))
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the parent so it cannot be deleted mid-reply, and find whom to notify
+	var messageAuthorID, visibility string
+	err = tx.QueryRowContext(ctx,
+		`SELECT m.user_id, m.visibility FROM messages m WHERE m.id = $1 AND `+readableBy("$2")+` FOR SHARE`,
+		messageID, userID,
+	).Scan(&messageAuthorID, &visibility)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("message not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get message", "error", err, "message_id", messageID)
+		apierror.Abort(c, apierror.Internal("failed to create reply"))
 		return
 	}
 
-	var reply models.Reply
-	err := h.db.QueryRowContext(ctx,
-		`INSERT INTO replies (message_id, user_id, content, media_urls)
-		 VALUES ($1, $2, $3, $4)
-		 RETURNING id, message_id, user_id, content, media_urls, created_at, updated_at`,
-		messageID, userID, req.Content, pq.Array(req.MediaURLs),
-	).Scan(&reply.ID, &reply.MessageID, &reply.UserID, &reply.Content, &reply.MediaURLs, &reply.CreatedAt, &reply.UpdatedAt)
+	if messageAuthorID != userID.(string) {
+		blocked, err := isBlocked(ctx, tx, messageAuthorID, userID.(string))
+		if err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to check block", "error", err, "message_id", messageID)
+			apierror.Abort(c, apierror.Internal("failed to create reply"))
+			return
+		}
+		if blocked {
+			apierror.Abort(c, apierror.Forbidden("you cannot reply to this message"))
+			return
+		}
+	}
 
+	var reply models.Reply
+	mentions, err := resolveMentions(ctx, tx, req.Content)
+	if err == nil {
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO replies (message_id, user_id, content, media_urls, mentions)
+			 VALUES ($1, $2, $3, $4, $5)
+			 RETURNING id, message_id, user_id, content, media_urls, mentions, score, created_at, updated_at`,
+			messageID, userID, req.Content, pq.Array(req.MediaURLs), mentions,
+		).Scan(&reply.ID, &reply.MessageID, &reply.UserID, &reply.Content, &reply.MediaURLs, &reply.Mentions, &reply.Score, &reply.CreatedAt, &reply.UpdatedAt)
+	}
+	replyRef := sql.NullString{String: reply.ID, Valid: true}
+	if err == nil && filtered {
+		err = markFiltered(ctx, tx, models.ReportTargetReply, reply.ID, reply.UserID, decision)
+	}
+	if err == nil && !filtered {
+		err = notify(ctx, tx, notificationEvent{
+			Type:      models.NotificationReply,
+			ActorID:   reply.UserID,
+			MessageID: messageID,
+			ReplyID:   replyRef,
+		}, messageAuthorID)
+	}
+	if err == nil && !filtered {
+		err = notifyMentions(ctx, tx, reply.UserID, messageID, replyRef, mentions)
+	}
+	// Replies share their message's visibility
+	if err == nil && !filtered && visibility == models.VisibilityPublic {
+		err = h.publisher.Publish(ctx, tx, events.ReplyCreated, messageID, reply)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to create reply", "error", err, "message_id", messageID, "user_id", userID)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create reply"})
+		apierror.Abort(c, apierror.Internal("failed to create reply"))
 		return
 	}
 
 	span.SetAttributes(attribute.String("reply.id", reply.ID))
 	slog.InfoContext(ctx, "Reply created", "reply_id", reply.ID, "message_id", messageID, "user_id", userID)
 
+	if decision.Action == moderation.Hold {
+		reply.PendingReview = true
+		c.JSON(http.StatusAccepted, reply)
+		return
+	}
 	c.JSON(http.StatusCreated, reply)
 }
 
-// ListReplies returns all replies for a message
+// ListReplies returns all replies for a message, if the viewer may read it.
+// ?sort=score orders by vote score with the accepted answer first.
+// Signed-in viewers do not see replies from users they blocked or muted or
+// who blocked them.
 func (h *MessageHandler) ListReplies(c *gin.Context) {
 	ctx, span := messageTracer.Start(c.Request.Context(), "ListReplies")
 	defer span.End()
 
 	messageID := c.Param("id")
-	span.SetAttributes(attribute.String("message.id", messageID))
+	sort := c.DefaultQuery("sort", "created_at")
+	span.SetAttributes(
+		attribute.String("message.id", messageID),
+		attribute.String("replies.sort", sort),
+	)
+
+	var orderBy string
+	switch sort {
+	case "created_at":
+		orderBy = "r.created_at ASC"
+	case "score":
+		orderBy = "accepted DESC, r.score DESC, r.created_at ASC"
+	default:
+		apierror.Abort(c, apierror.BadRequest("invalid sort"))
+		return
+	}
+
+	where := `r.message_id = $1 AND r.hidden_at IS NULL AND ` + notBanned("r.user_id")
+	args := []any{messageID}
+	if viewerID := c.GetString("user_id"); viewerID != "" {
+		where += ` AND ` + readableBy("$2") + ` AND ` + visibleTo("r.user_id", "$2") + ` AND ` + unfiltered("r", "$2")
+		args = append(args, viewerID)
+	} else {
+		where += ` AND ` + readableBy("") + ` AND ` + unfiltered("r", "")
+	}
 
 	rows, err := h.db.QueryContext(ctx,
-		`SELECT id, message_id, user_id, content, media_urls, created_at, updated_at
-		 FROM replies
-		 WHERE message_id = $1
-		 ORDER BY created_at ASC`,
-		messageID,
+		`SELECT r.id, r.message_id, r.user_id, r.content, r.media_urls, r.mentions, r.score,
+		        COALESCE(r.id = m.accepted_reply_id, false) AS accepted, r.created_at, r.updated_at
+		 FROM replies r
+		 JOIN messages m ON m.id = r.message_id
+		 WHERE `+where+`
+		 ORDER BY `+orderBy,
+		args...,
 	)
 	if err != nil {
 		span.RecordError(err)
 		slog.ErrorContext(ctx, "Failed to list replies", "error", err, "message_id", messageID)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list replies"})
+		apierror.Abort(c, apierror.Internal("failed to list replies"))
 		return
 	}
 	defer rows.Close()
 
-	var replies []models.Reply
+	replies := []models.Reply{}
 	for rows.Next() {
 		var reply models.Reply
-		if err := rows.Scan(&reply.ID, &reply.MessageID, &reply.UserID, &reply.Content, &reply.MediaURLs, &reply.CreatedAt, &reply.UpdatedAt); err != nil {
+		if err := rows.Scan(&reply.ID, &reply.MessageID, &reply.UserID, &reply.Content, &reply.MediaURLs, &reply.Mentions, &reply.Score, &reply.Accepted, &reply.CreatedAt, &reply.UpdatedAt); err != nil {
 			span.RecordError(err)
 			slog.ErrorContext(ctx, "Failed to scan reply", "error", err)
 			continue
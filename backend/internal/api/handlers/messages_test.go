@@ -14,7 +14,10 @@ import (
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"why-backend/internal/content"
+	"why-backend/internal/events"
 	"why-backend/internal/models"
+	"why-backend/internal/moderation"
 	"why-backend/internal/testutil"
 )
 
@@ -23,7 +26,8 @@ func TestMessageHandler_CreateMessage_Success(t *testing.T) {
 	db, mock := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, moderation.Chain{}, content.DefaultLimits)
 
 	createReq := models.CreateMessageRequest{
 		Content:   "Test message content",
@@ -33,12 +37,17 @@ func TestMessageHandler_CreateMessage_Success(t *testing.T) {
 
 	// Mock database response
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "created_at", "updated_at"}).
-		AddRow("msg-123", "user-123", createReq.Content, pq.Array(createReq.MediaURLs), now, now)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "mentions", "created_at", "updated_at", "visibility"}).
+		AddRow("msg-123", "user-123", createReq.Content, pq.Array(createReq.MediaURLs), nil, `[]`, now, now, "public")
 
+	mock.ExpectQuery("SELECT url FROM media_uploads").
+		WithArgs(pq.Array(createReq.MediaURLs), "user-123", "").
+		WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow("https://example.com/image1.jpg"))
+	mock.ExpectBegin()
 	mock.ExpectQuery("INSERT INTO messages").
-		WithArgs("user-123", createReq.Content, sqlmock.AnyArg()).
+		WithArgs("user-123", createReq.Content, sqlmock.AnyArg(), sqlmock.AnyArg(), models.VisibilityPublic).
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -46,7 +55,7 @@ func TestMessageHandler_CreateMessage_Success(t *testing.T) {
 	c.Request.Header.Set("Content-Type", "application/json")
 	c.Set("user_id", "user-123") // Simulate auth middleware
 
-	handler.CreateMessage(c)
+	testutil.Serve(t, c, handler.CreateMessage)
 
 	assert.Equal(t, http.StatusCreated, w.Code)
 
@@ -56,6 +65,11 @@ func TestMessageHandler_CreateMessage_Success(t *testing.T) {
 	assert.Equal(t, "msg-123", response.ID)
 	assert.Equal(t, createReq.Content, response.Content)
 
+	published := bus.Published()
+	require.Len(t, published, 1)
+	assert.Equal(t, events.MessageCreated, published[0].Type)
+	assert.Equal(t, "msg-123", published[0].MessageID)
+
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
@@ -65,7 +79,7 @@ func TestMessageHandler_CreateMessage_InvalidJSON(t *testing.T) {
 	db, _ := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
 
 	body := []byte(`{"content":`)
 
@@ -75,7 +89,7 @@ func TestMessageHandler_CreateMessage_InvalidJSON(t *testing.T) {
 	c.Request.Header.Set("Content-Type", "application/json")
 	c.Set("user_id", "user-123")
 
-	handler.CreateMessage(c)
+	testutil.Serve(t, c, handler.CreateMessage)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
@@ -85,7 +99,7 @@ func TestMessageHandler_CreateMessage_MissingContent(t *testing.T) {
 	db, _ := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
 
 	createReq := models.CreateMessageRequest{
 		Content:   "", // Empty content should fail validation
@@ -99,32 +113,197 @@ func TestMessageHandler_CreateMessage_MissingContent(t *testing.T) {
 	c.Request.Header.Set("Content-Type", "application/json")
 	c.Set("user_id", "user-123")
 
-	handler.CreateMessage(c)
+	testutil.Serve(t, c, handler.CreateMessage)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestMessageHandler_CreateMessage_WithHashtags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	body := []byte(`{"content":"Why does #Go have no generics? #golang #go"}`)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "mentions", "created_at", "updated_at", "visibility"}).
+		AddRow("msg-123", "user-123", "Why does #Go have no generics? #golang #go", pq.StringArray{}, nil, `[]`, now, now, "public")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO messages").WillReturnRows(rows)
+	mock.ExpectExec("INSERT INTO tags").
+		WithArgs(pq.Array([]string{"go", "golang"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO message_tags").
+		WithArgs("msg-123", pq.Array([]string{"go", "golang"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.CreateMessage)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.Message
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "golang"}, []string(response.Tags))
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+// updatedMessageColumns are those an edit returns: the message, and whether
+// it is hidden or filtered
+var updatedMessageColumns = []string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility", "hidden"}
+
+func TestMessageHandler_UpdateMessage_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, moderation.Chain{}, content.DefaultLimits)
+
+	body := []byte(`{"content":"Edited #physics"}`)
+
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id").
+		WithArgs("msg-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-123"))
+
+	now := time.Now()
+	rows := sqlmock.NewRows(updatedMessageColumns).
+		AddRow("msg-123", "user-123", "Edited #physics", pq.StringArray{"url1"}, nil, pq.StringArray{"old"}, `[]`, now, now, "public", false)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE messages m SET content").
+		WithArgs("Edited #physics", sqlmock.AnyArg(), sqlmock.AnyArg(), "msg-123").
+		WillReturnRows(rows)
+	mock.ExpectExec("DELETE FROM message_tags WHERE message_id").
+		WithArgs("msg-123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO tags").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO message_tags").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PATCH", "/messages/msg-123", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UpdateMessage)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Message
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Equal(t, "Edited #physics", response.Content)
+	assert.Equal(t, []string{"physics"}, []string(response.Tags))
+
+	published := bus.Published()
+	require.Len(t, published, 1)
+	assert.Equal(t, events.MessageUpdated, published[0].Type)
+	assert.Equal(t, "msg-123", published[0].MessageID)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_UpdateMessage_Hidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id").
+		WithArgs("msg-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-123"))
+
+	// Hidden by a moderator, or held or shadow-hidden by the content filter
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE messages m SET content .+ RETURNING .+, m.hidden_at IS NOT NULL OR m.filter_action IS NOT NULL").
+		WillReturnRows(sqlmock.NewRows(updatedMessageColumns).
+			AddRow("msg-123", "user-123", "Edited", pq.StringArray{}, nil, pq.StringArray{}, `[]`, now, now, "public", true))
+	mock.ExpectExec("DELETE FROM message_tags WHERE message_id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PATCH", "/messages/msg-123", bytes.NewBufferString(`{"content":"Edited"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UpdateMessage)
+
+	// Saved for its author, but its new content goes to no subscriber
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, bus.Published())
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMessageHandler_UpdateMessage_NotAuthor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id").
+		WithArgs("msg-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("someone-else"))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PATCH", "/messages/msg-123", bytes.NewBufferString(`{"content":"hijacked"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UpdateMessage)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
 func TestMessageHandler_ListMessages_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db, mock := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
 
 	// Mock database response with multiple messages
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "created_at", "updated_at"}).
-		AddRow("msg-1", "user-1", "First message", pq.StringArray{}, now, now).
-		AddRow("msg-2", "user-2", "Second message", pq.StringArray{"url1"}, now, now)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"}).
+		AddRow("msg-1", "user-1", "First message", pq.StringArray{}, nil, pq.StringArray{}, `[]`, now, now, "public").
+		AddRow("msg-2", "user-2", "Second message", pq.StringArray{"url1"}, nil, pq.StringArray{}, `[]`, now, now, "public")
 
-	mock.ExpectQuery("SELECT id, user_id, content, media_urls, created_at, updated_at FROM messages").
+	mock.ExpectQuery("SELECT m.id, m.user_id, .+ FROM messages m").
 		WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request = httptest.NewRequest("GET", "/messages", nil)
 
-	handler.ListMessages(c)
+	testutil.Serve(t, c, handler.ListMessages)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
@@ -144,26 +323,24 @@ func TestMessageHandler_ListMessages_Empty(t *testing.T) {
 	db, mock := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
 
 	// Mock empty result
-	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "created_at", "updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"})
 
-	mock.ExpectQuery("SELECT id, user_id, content, media_urls, created_at, updated_at FROM messages").
+	mock.ExpectQuery("SELECT m.id, m.user_id, .+ FROM messages m").
 		WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request = httptest.NewRequest("GET", "/messages", nil)
 
-	handler.ListMessages(c)
+	testutil.Serve(t, c, handler.ListMessages)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var response []models.Message
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	require.NoError(t, err)
-	assert.Nil(t, response) // Empty array should be nil
+	// An empty array, as the OpenAPI document says, not null
+	assert.JSONEq(t, `[]`, w.Body.String())
 }
 
 func TestMessageHandler_GetMessage_Success(t *testing.T) {
@@ -171,14 +348,14 @@ func TestMessageHandler_GetMessage_Success(t *testing.T) {
 	db, mock := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
 
 	messageID := "msg-123"
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "created_at", "updated_at"}).
-		AddRow(messageID, "user-123", "Test message", pq.StringArray{}, now, now)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"}).
+		AddRow(messageID, "user-123", "Test message", pq.StringArray{}, nil, pq.StringArray{}, `[]`, now, now, "public")
 
-	mock.ExpectQuery("SELECT id, user_id, content, media_urls, created_at, updated_at FROM messages WHERE id").
+	mock.ExpectQuery("SELECT m.id, m.user_id, .+ FROM messages m WHERE m.id").
 		WithArgs(messageID).
 		WillReturnRows(rows)
 
@@ -187,7 +364,7 @@ func TestMessageHandler_GetMessage_Success(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/messages/"+messageID, nil)
 	c.Params = gin.Params{{Key: "id", Value: messageID}}
 
-	handler.GetMessage(c)
+	testutil.Serve(t, c, handler.GetMessage)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
@@ -205,11 +382,11 @@ func TestMessageHandler_GetMessage_NotFound(t *testing.T) {
 	db, mock := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
 
 	messageID := "nonexistent"
 
-	mock.ExpectQuery("SELECT id, user_id, content, media_urls, created_at, updated_at FROM messages WHERE id").
+	mock.ExpectQuery("SELECT m.id, m.user_id, .+ FROM messages m WHERE m.id").
 		WithArgs(messageID).
 		WillReturnError(sql.ErrNoRows)
 
@@ -218,7 +395,7 @@ func TestMessageHandler_GetMessage_NotFound(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/messages/"+messageID, nil)
 	c.Params = gin.Params{{Key: "id", Value: messageID}}
 
-	handler.GetMessage(c)
+	testutil.Serve(t, c, handler.GetMessage)
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
@@ -228,7 +405,8 @@ func TestMessageHandler_CreateReply_Success(t *testing.T) {
 	db, mock := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, moderation.Chain{}, content.DefaultLimits)
 
 	messageID := "msg-123"
 	createReq := models.CreateReplyRequest{
@@ -239,12 +417,23 @@ func TestMessageHandler_CreateReply_Success(t *testing.T) {
 
 	// Mock database response
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "created_at", "updated_at"}).
-		AddRow("reply-123", messageID, "user-123", createReq.Content, pq.Array(createReq.MediaURLs), now, now)
-
+	rows := sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "mentions", "score", "created_at", "updated_at"}).
+		AddRow("reply-123", messageID, "user-123", createReq.Content, pq.Array(createReq.MediaURLs), `[]`, 0, now, now)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.user_id, m.visibility FROM messages m WHERE m.id = \\$1 AND .+ FOR SHARE").
+		WithArgs(messageID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "visibility"}).AddRow("user-456", "public"))
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM user_blocks WHERE blocker_id = \\$1 AND blocked_id = \\$2\\)").
+		WithArgs("user-456", "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 	mock.ExpectQuery("INSERT INTO replies").
-		WithArgs(messageID, "user-123", createReq.Content, sqlmock.AnyArg()).
+		WithArgs(messageID, "user-123", createReq.Content, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(rows)
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-123", models.NotificationReply, messageID, "reply-123", pq.Array([]string{"user-456"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -253,7 +442,7 @@ func TestMessageHandler_CreateReply_Success(t *testing.T) {
 	c.Params = gin.Params{{Key: "id", Value: messageID}}
 	c.Set("user_id", "user-123")
 
-	handler.CreateReply(c)
+	testutil.Serve(t, c, handler.CreateReply)
 
 	assert.Equal(t, http.StatusCreated, w.Code)
 
@@ -263,24 +452,57 @@ func TestMessageHandler_CreateReply_Success(t *testing.T) {
 	assert.Equal(t, "reply-123", response.ID)
 	assert.Equal(t, messageID, response.MessageID)
 
+	published := bus.Published()
+	require.Len(t, published, 1)
+	assert.Equal(t, events.ReplyCreated, published[0].Type)
+	assert.Equal(t, messageID, published[0].MessageID)
+
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
+func TestMessageHandler_CreateReply_MessageNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.user_id, m.visibility FROM messages m WHERE m.id").
+		WithArgs("nonexistent", "user-123").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/nonexistent/replies", bytes.NewBufferString(`{"content":"hello"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "nonexistent"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.CreateReply)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
 func TestMessageHandler_ListReplies_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db, mock := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
 
 	messageID := "msg-123"
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "created_at", "updated_at"}).
-		AddRow("reply-1", messageID, "user-1", "First reply", pq.StringArray{}, now, now).
-		AddRow("reply-2", messageID, "user-2", "Second reply", pq.StringArray{}, now, now)
+	rows := sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "mentions", "score", "accepted", "created_at", "updated_at"}).
+		AddRow("reply-1", messageID, "user-1", "First reply", pq.StringArray{}, `[]`, 0, false, now, now).
+		AddRow("reply-2", messageID, "user-2", "Second reply", pq.StringArray{}, `[]`, 0, false, now, now)
 
-	mock.ExpectQuery("SELECT id, message_id, user_id, content, media_urls, created_at, updated_at FROM replies WHERE message_id").
+	mock.ExpectQuery("SELECT r.id, r.message_id, r.user_id, r.content, r.media_urls, r.mentions, r.score, .+ FROM replies r .+ WHERE r.message_id").
 		WithArgs(messageID).
 		WillReturnRows(rows)
 
@@ -289,7 +511,7 @@ func TestMessageHandler_ListReplies_Success(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/messages/"+messageID+"/replies", nil)
 	c.Params = gin.Params{{Key: "id", Value: messageID}}
 
-	handler.ListReplies(c)
+	testutil.Serve(t, c, handler.ListReplies)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
@@ -309,12 +531,12 @@ func TestMessageHandler_ListReplies_Empty(t *testing.T) {
 	db, mock := testutil.SetupTestDB(t)
 	defer db.Close()
 
-	handler := NewMessageHandler(db)
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
 
 	messageID := "msg-123"
-	rows := sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "created_at", "updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "mentions", "score", "accepted", "created_at", "updated_at"})
 
-	mock.ExpectQuery("SELECT id, message_id, user_id, content, media_urls, created_at, updated_at FROM replies WHERE message_id").
+	mock.ExpectQuery("SELECT r.id, r.message_id, r.user_id, r.content, r.media_urls, r.mentions, r.score, .+ FROM replies r .+ WHERE r.message_id").
 		WithArgs(messageID).
 		WillReturnRows(rows)
 
@@ -323,12 +545,9 @@ func TestMessageHandler_ListReplies_Empty(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/messages/"+messageID+"/replies", nil)
 	c.Params = gin.Params{{Key: "id", Value: messageID}}
 
-	handler.ListReplies(c)
+	testutil.Serve(t, c, handler.ListReplies)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var response []models.Reply
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	require.NoError(t, err)
-	assert.Nil(t, response)
+	assert.JSONEq(t, `[]`, w.Body.String())
 }
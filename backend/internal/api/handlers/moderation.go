@@ -0,0 +1,566 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/accounts"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+)
+
+var moderationTracer = otel.Tracer("why-backend/handlers/moderation")
+
+// audit records a moderation step. actorID is empty for steps taken from the
+// command line, and reportID when the step is not about a report.
+func audit(ctx context.Context, db dbtx, actorID, action, reportID, targetType, targetID string, details any) error {
+	if details == nil {
+		details = struct{}{}
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO moderation_audit_log (actor_id, action, report_id, target_type, target_id, details)
+		 VALUES (NULLIF($1, '')::uuid, $2, NULLIF($3, '')::uuid, $4, $5, $6)`,
+		actorID, action, reportID, targetType, targetID, data,
+	)
+	return err
+}
+
+// notifyModeration sends userID a moderation notice about a report. Notices
+// have no actor and are delivered regardless of preferences, blocks and mutes.
+func notifyModeration(ctx context.Context, db dbtx, notificationType, userID, reportID string, messageID, replyID sql.NullString) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO notifications (user_id, type, message_id, reply_id, report_id)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		userID, notificationType, messageID, replyID, reportID,
+	)
+	return err
+}
+
+type ModerationHandler struct {
+	db        *sql.DB
+	publisher events.Publisher
+	accounts  *accounts.Cache
+}
+
+func NewModerationHandler(db *sql.DB, publisher events.Publisher, accountCache *accounts.Cache) *ModerationHandler {
+	return &ModerationHandler{db: db, publisher: publisher, accounts: accountCache}
+}
+
+// ListReports returns the report queue: ?status=open (the default) or
+// claimed reports oldest first, or resolved reports newest first, with
+// cursor pagination
+func (h *ModerationHandler) ListReports(c *gin.Context) {
+	ctx, span := moderationTracer.Start(c.Request.Context(), "ListReports")
+	defer span.End()
+
+	status := c.DefaultQuery("status", models.ReportOpen)
+	span.SetAttributes(attribute.String("reports.status", status))
+
+	order, after := "ASC", ">"
+	switch status {
+	case models.ReportOpen, models.ReportClaimed:
+	case models.ReportResolved:
+		order, after = "DESC", "<"
+	default:
+		apierror.Abort(c, apierror.BadRequest("invalid status"))
+		return
+	}
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	query := `SELECT ` + reportColumns + ` FROM reports WHERE status = $1`
+	args := []any{status, page.Limit + 1}
+	if page.Cursor != nil {
+		query += ` AND (created_at, id) ` + after + ` ($3, $4)`
+		args = append(args, page.Cursor.CreatedAt, page.Cursor.ID)
+	}
+	query += ` ORDER BY created_at ` + order + `, id ` + order + ` LIMIT $2`
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list reports", "error", err, "status", status)
+		apierror.Abort(c, apierror.Internal("failed to list reports"))
+		return
+	}
+	defer rows.Close()
+
+	result := models.ReportPage{Reports: []models.Report{}}
+	for rows.Next() {
+		var report models.Report
+		if err := scanReport(rows, &report); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan report", "error", err)
+			continue
+		}
+		result.Reports = append(result.Reports, report)
+	}
+
+	if len(result.Reports) > page.Limit {
+		result.Reports = result.Reports[:page.Limit]
+		last := result.Reports[page.Limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	span.SetAttributes(attribute.Int("reports.count", len(result.Reports)))
+	c.JSON(http.StatusOK, result)
+}
+
+// GetReport returns a report
+func (h *ModerationHandler) GetReport(c *gin.Context) {
+	ctx, span := moderationTracer.Start(c.Request.Context(), "GetReport")
+	defer span.End()
+
+	reportID := c.Param("id")
+	span.SetAttributes(attribute.String("report.id", reportID))
+
+	if _, err := uuid.Parse(reportID); err != nil {
+		apierror.Abort(c, apierror.NotFound("report not found"))
+		return
+	}
+
+	var report models.Report
+	err := scanReport(h.db.QueryRowContext(ctx, `SELECT `+reportColumns+` FROM reports WHERE id = $1`, reportID), &report)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("report not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get report", "error", err, "report_id", reportID)
+		apierror.Abort(c, apierror.Internal("failed to get report"))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ClaimReport assigns an open report to the authenticated moderator, so
+// others leave it alone. Claiming a report one already holds is not an error.
+func (h *ModerationHandler) ClaimReport(c *gin.Context) {
+	h.updateClaim(c, "ClaimReport", models.AuditReportClaimed,
+		`UPDATE reports SET status = '`+models.ReportClaimed+`', claimed_by = $2, claimed_at = COALESCE(claimed_at, NOW())
+		 WHERE id = $1 AND (status = '`+models.ReportOpen+`' OR (status = '`+models.ReportClaimed+`' AND claimed_by = $2))
+		 RETURNING `+reportColumns)
+}
+
+// ReleaseReport puts a report the authenticated moderator claimed back in
+// the open queue
+func (h *ModerationHandler) ReleaseReport(c *gin.Context) {
+	h.updateClaim(c, "ReleaseReport", models.AuditReportReleased,
+		`UPDATE reports SET status = '`+models.ReportOpen+`', claimed_by = NULL, claimed_at = NULL
+		 WHERE id = $1 AND status = '`+models.ReportClaimed+`' AND claimed_by = $2
+		 RETURNING `+reportColumns)
+}
+
+// updateClaim runs a claim change, query, taking the report id and moderator
+// id, and records it in the audit log
+func (h *ModerationHandler) updateClaim(c *gin.Context, name, action, query string) {
+	ctx, span := moderationTracer.Start(c.Request.Context(), name)
+	defer span.End()
+
+	reportID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("report.id", reportID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(reportID); err != nil {
+		apierror.Abort(c, apierror.NotFound("report not found"))
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to update report"))
+		return
+	}
+	defer tx.Rollback()
+
+	var report models.Report
+	err = scanReport(tx.QueryRowContext(ctx, query, reportID, userID), &report)
+	if err == sql.ErrNoRows {
+		h.reportConflict(c, reportID, userID.(string))
+		return
+	}
+	if err == nil {
+		err = audit(ctx, tx, userID.(string), action, report.ID, report.TargetType, report.TargetID, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to update report", "error", err, "report_id", reportID)
+		apierror.Abort(c, apierror.Internal("failed to update report"))
+		return
+	}
+
+	slog.InfoContext(ctx, "Report updated", "report_id", reportID, "action", action, "user_id", userID)
+	c.JSON(http.StatusOK, report)
+}
+
+// reportConflict explains why the authenticated moderator cannot change a
+// report: it does not exist, is resolved or is someone else's
+func (h *ModerationHandler) reportConflict(c *gin.Context, reportID, userID string) {
+	ctx := c.Request.Context()
+
+	var status string
+	var claimedBy sql.NullString
+	err := h.db.QueryRowContext(ctx, `SELECT status, claimed_by FROM reports WHERE id = $1`, reportID).Scan(&status, &claimedBy)
+	switch {
+	case err == sql.ErrNoRows:
+		apierror.Abort(c, apierror.NotFound("report not found"))
+	case err != nil:
+		slog.ErrorContext(ctx, "Failed to get report", "error", err, "report_id", reportID)
+		apierror.Abort(c, apierror.Internal("failed to update report"))
+	case status == models.ReportResolved:
+		apierror.Abort(c, apierror.Conflict("report is already resolved"))
+	case status == models.ReportClaimed && claimedBy.String != userID:
+		apierror.Abort(c, apierror.Conflict("report is claimed by another moderator"))
+	default:
+		apierror.Abort(c, apierror.Conflict("report is not claimed"))
+	}
+}
+
+// ResolveReport closes an open report, or one the authenticated moderator
+// claimed, with an action on its target: dismiss, hide the message or reply,
+// warn its author or suspend them. The reporter is told the outcome, and
+// every step is audited. Dismissing a hold by the content filter approves the
+// content, and resolving a spam report trains the spam classifier.
+func (h *ModerationHandler) ResolveReport(c *gin.Context) {
+	ctx, span := moderationTracer.Start(c.Request.Context(), "ResolveReport")
+	defer span.End()
+
+	reportID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("report.id", reportID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(reportID); err != nil {
+		apierror.Abort(c, apierror.NotFound("report not found"))
+		return
+	}
+
+	var req models.ResolveReportRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	span.SetAttributes(attribute.String("report.action", req.Action))
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to resolve report"))
+		return
+	}
+	defer tx.Rollback()
+
+	// threadID is the message the target belongs to, for message and reply
+	// targets that still exist
+	var report models.Report
+	var threadID sql.NullString
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, reporter_id, target_type, target_id, target_user_id, reason, status, claimed_by,
+			CASE target_type
+				WHEN '`+models.ReportTargetMessage+`' THEN (SELECT m.id FROM messages m WHERE m.id = target_id)
+				WHEN '`+models.ReportTargetReply+`' THEN (SELECT r.message_id FROM replies r WHERE r.id = target_id)
+			END
+		 FROM reports WHERE id = $1
+		 FOR UPDATE`,
+		reportID,
+	).Scan(&report.ID, &report.ReporterID, &report.TargetType, &report.TargetID, &report.TargetUserID,
+		&report.Reason, &report.Status, &report.ClaimedBy, &threadID)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("report not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get report", "error", err, "report_id", reportID)
+		apierror.Abort(c, apierror.Internal("failed to resolve report"))
+		return
+	}
+
+	switch {
+	case report.Status == models.ReportResolved:
+		apierror.Abort(c, apierror.Conflict("report is already resolved"))
+		return
+	case report.ClaimedBy != nil && *report.ClaimedBy != userID.(string):
+		apierror.Abort(c, apierror.Conflict("report is claimed by another moderator"))
+		return
+	case req.Action == models.ModerationHide && report.TargetType == models.ReportTargetUser:
+		apierror.Abort(c, apierror.BadRequest("only messages and replies can be hidden"))
+		return
+	}
+
+	err = h.applyAction(ctx, tx, userID.(string), report, threadID, req)
+	// Content the filter held is approved by dismissing its report
+	if err == nil && report.ReporterID == nil && req.Action == models.ModerationDismiss && threadID.Valid {
+		err = h.approve(ctx, tx, userID.(string), report)
+	}
+	if err == nil && report.Reason == moderation.CategorySpam && report.TargetType != models.ReportTargetUser && threadID.Valid {
+		err = learn(ctx, tx, report, req.Action)
+	}
+	if err == nil {
+		err = scanReport(tx.QueryRowContext(ctx,
+			`UPDATE reports SET status = '`+models.ReportResolved+`', action = $2, resolution_note = NULLIF($3, ''),
+				resolved_by = $4, resolved_at = NOW(), claimed_by = COALESCE(claimed_by, $4), claimed_at = COALESCE(claimed_at, NOW())
+			 WHERE id = $1
+			 RETURNING `+reportColumns,
+			reportID, req.Action, req.Note, userID,
+		), &report)
+	}
+	if err == nil {
+		err = audit(ctx, tx, userID.(string), models.AuditReportResolved, report.ID, report.TargetType, report.TargetID,
+			map[string]string{"action": req.Action})
+	}
+	if err == nil && report.ReporterID != nil {
+		err = notifyModeration(ctx, tx, models.NotificationReportResolved, *report.ReporterID, report.ID,
+			sql.NullString{}, sql.NullString{})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to resolve report", "error", err, "report_id", reportID)
+		apierror.Abort(c, apierror.Internal("failed to resolve report"))
+		return
+	}
+	if req.Action == models.ModerationSuspend {
+		h.accounts.Forget(report.TargetUserID)
+	}
+
+	slog.InfoContext(ctx, "Report resolved", "report_id", reportID, "action", req.Action, "user_id", userID)
+	c.JSON(http.StatusOK, report)
+}
+
+// applyAction carries out a resolution's action on the report's target and
+// audits it. Content that is already gone is left as is.
+func (h *ModerationHandler) applyAction(ctx context.Context, tx *sql.Tx, moderatorID string, report models.Report, threadID sql.NullString, req models.ResolveReportRequest) error {
+	switch req.Action {
+	case models.ModerationHide:
+		if !threadID.Valid {
+			return nil
+		}
+		var visibility string
+		eventType := events.MessageDeleted
+		query := `UPDATE messages m SET hidden_at = COALESCE(m.hidden_at, NOW()) WHERE m.id = $1 RETURNING m.visibility`
+		if report.TargetType == models.ReportTargetReply {
+			eventType = events.ReplyDeleted
+			query = `UPDATE replies r SET hidden_at = COALESCE(r.hidden_at, NOW()) FROM messages m
+				 WHERE r.id = $1 AND m.id = r.message_id RETURNING m.visibility`
+		}
+		if err := tx.QueryRowContext(ctx, query, report.TargetID).Scan(&visibility); err != nil {
+			return err
+		}
+		// Hidden content disappears from live clients and the search index as
+		// if deleted; only public threads were ever sent to them
+		if visibility == models.VisibilityPublic {
+			if err := h.publisher.Publish(ctx, tx, eventType, threadID.String, map[string]string{"id": report.TargetID}); err != nil {
+				return err
+			}
+		}
+		return audit(ctx, tx, moderatorID, models.AuditContentHidden, report.ID, report.TargetType, report.TargetID, nil)
+
+	case models.ModerationWarn:
+		var messageID, replyID sql.NullString
+		switch report.TargetType {
+		case models.ReportTargetMessage:
+			messageID = threadID
+		case models.ReportTargetReply:
+			messageID = threadID
+			replyID = sql.NullString{String: report.TargetID, Valid: threadID.Valid}
+		}
+		if err := notifyModeration(ctx, tx, models.NotificationModerationWarning, report.TargetUserID, report.ID, messageID, replyID); err != nil {
+			return err
+		}
+		return audit(ctx, tx, moderatorID, models.AuditUserWarned, report.ID, models.ReportTargetUser, report.TargetUserID, nil)
+
+	case models.ModerationSuspend:
+		reason := req.Note
+		if reason == "" {
+			reason = report.Reason
+		}
+		// A suspension never shortens one already in force
+		var until sql.NullTime
+		err := tx.QueryRowContext(ctx,
+			`UPDATE users SET suspended_until = GREATEST(suspended_until, NOW() + make_interval(days => $2)),
+				suspension_reason = $3, updated_at = NOW()
+			 WHERE id = $1
+			 RETURNING suspended_until`,
+			report.TargetUserID, req.DurationDays, reason,
+		).Scan(&until)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, tx, moderatorID, models.AuditUserSuspended, report.ID, models.ReportTargetUser, report.TargetUserID,
+			map[string]any{"duration_days": req.DurationDays, "until": until.Time, "reason": reason})
+	}
+	return nil
+}
+
+// approve releases content the content filter held, as if it had just been
+// posted: mentioned users and the thread's author are notified, and public
+// content is announced
+func (h *ModerationHandler) approve(ctx context.Context, tx *sql.Tx, moderatorID string, report models.Report) error {
+	if report.TargetType == models.ReportTargetMessage {
+		var message models.Message
+		err := scanMessage(tx.QueryRowContext(ctx,
+			`UPDATE messages m SET filter_action = NULL
+			 WHERE m.id = $1 AND m.filter_action = '`+moderation.Hold+`'
+			 RETURNING `+messageColumns,
+			report.TargetID,
+		), &message)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err == nil {
+			err = notifyMentions(ctx, tx, message.UserID, message.ID, sql.NullString{}, message.Mentions)
+		}
+		if err == nil && message.Visibility == models.VisibilityPublic {
+			err = h.publisher.Publish(ctx, tx, events.MessageCreated, message.ID, message)
+		}
+		if err != nil {
+			return err
+		}
+		return audit(ctx, tx, moderatorID, models.AuditContentApproved, report.ID, report.TargetType, report.TargetID, nil)
+	}
+
+	var reply models.Reply
+	var messageAuthorID, visibility string
+	err := tx.QueryRowContext(ctx,
+		`UPDATE replies r SET filter_action = NULL FROM messages m
+		 WHERE r.id = $1 AND r.filter_action = '`+moderation.Hold+`' AND m.id = r.message_id
+		 RETURNING r.id, r.message_id, r.user_id, r.content, r.media_urls, r.mentions, r.score,
+			COALESCE(r.id = m.accepted_reply_id, false), r.created_at, r.updated_at, m.user_id, m.visibility`,
+		report.TargetID,
+	).Scan(&reply.ID, &reply.MessageID, &reply.UserID, &reply.Content, &reply.MediaURLs, &reply.Mentions, &reply.Score,
+		&reply.Accepted, &reply.CreatedAt, &reply.UpdatedAt, &messageAuthorID, &visibility)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	replyRef := sql.NullString{String: reply.ID, Valid: true}
+	if err == nil {
+		err = notify(ctx, tx, notificationEvent{
+			Type:      models.NotificationReply,
+			ActorID:   reply.UserID,
+			MessageID: reply.MessageID,
+			ReplyID:   replyRef,
+		}, messageAuthorID)
+	}
+	if err == nil {
+		err = notifyMentions(ctx, tx, reply.UserID, reply.MessageID, replyRef, reply.Mentions)
+	}
+	if err == nil && visibility == models.VisibilityPublic {
+		err = h.publisher.Publish(ctx, tx, events.ReplyCreated, reply.MessageID, reply)
+	}
+	if err != nil {
+		return err
+	}
+	return audit(ctx, tx, moderatorID, models.AuditContentApproved, report.ID, report.TargetType, report.TargetID, nil)
+}
+
+// learn trains the spam classifier on the message or reply a spam report is
+// about: dismissing the report says it is not spam, any other action that it
+// is
+func learn(ctx context.Context, tx *sql.Tx, report models.Report, action string) error {
+	query := `SELECT content FROM messages WHERE id = $1`
+	if report.TargetType == models.ReportTargetReply {
+		query = `SELECT content FROM replies WHERE id = $1`
+	}
+	var text string
+	if err := tx.QueryRowContext(ctx, query, report.TargetID).Scan(&text); err != nil {
+		return err
+	}
+	return moderation.Train(ctx, tx, text, action != models.ModerationDismiss)
+}
+
+// ListAuditLog returns moderation steps, newest first, with cursor
+// pagination. ?report_id= and ?target_id= narrow it to one report or target.
+func (h *ModerationHandler) ListAuditLog(c *gin.Context) {
+	ctx, span := moderationTracer.Start(c.Request.Context(), "ListAuditLog")
+	defer span.End()
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	query := `SELECT id, actor_id, action, report_id, target_type, target_id, details, created_at
+		 FROM moderation_audit_log WHERE TRUE`
+	args := []any{page.Limit + 1}
+	for _, filter := range []string{"report_id", "target_id"} {
+		value := c.Query(filter)
+		if value == "" {
+			continue
+		}
+		if _, err := uuid.Parse(value); err != nil {
+			apierror.Abort(c, apierror.BadRequest("invalid "+filter))
+			return
+		}
+		args = append(args, value)
+		query += ` AND ` + filter + ` = $` + strconv.Itoa(len(args))
+	}
+	if page.Cursor != nil {
+		args = append(args, page.Cursor.CreatedAt, page.Cursor.ID)
+		query += ` AND (created_at, id) < ($` + strconv.Itoa(len(args)-1) + `, $` + strconv.Itoa(len(args)) + `)`
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT $1`
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list audit log", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to list audit log"))
+		return
+	}
+	defer rows.Close()
+
+	result := models.AuditLogPage{Entries: []models.AuditEntry{}}
+	for rows.Next() {
+		var e models.AuditEntry
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.ReportID, &e.TargetType, &e.TargetID, &details, &e.CreatedAt); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan audit entry", "error", err)
+			continue
+		}
+		e.Details = details
+		result.Entries = append(result.Entries, e)
+	}
+
+	if len(result.Entries) > page.Limit {
+		result.Entries = result.Entries[:page.Limit]
+		last := result.Entries[page.Limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	span.SetAttributes(attribute.Int("entries.count", len(result.Entries)))
+	c.JSON(http.StatusOK, result)
+}
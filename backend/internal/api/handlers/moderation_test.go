@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/accounts"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+var resolveRowColumns = []string{"id", "reporter_id", "target_type", "target_id", "target_user_id", "reason", "status", "claimed_by", "thread_id"}
+
+func newModerationRequest(method, path, body string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: testReportID}}
+	c.Set("user_id", "mod-1")
+	return w, c
+}
+
+func TestModerationHandler_ClaimReport_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE reports SET status = 'claimed', claimed_by = \\$2").
+		WithArgs(testReportID, "mod-1").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetMessage, testMessageID, "user-456", "spam", nil,
+				models.ReportClaimed, "mod-1", now, nil, nil, nil, nil, now))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").
+		WithArgs("mod-1", models.AuditReportClaimed, testReportID, models.ReportTargetMessage, testMessageID, []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/claim", "")
+
+	testutil.Serve(t, c, handler.ClaimReport)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ReportClaimed, response.Status)
+	require.NotNil(t, response.ClaimedBy)
+	assert.Equal(t, "mod-1", *response.ClaimedBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ClaimReport_ClaimedByAnother(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE reports SET status = 'claimed'").
+		WithArgs(testReportID, "mod-1").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns))
+	mock.ExpectQuery("SELECT status, claimed_by FROM reports WHERE id = \\$1").
+		WithArgs(testReportID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "claimed_by"}).AddRow(models.ReportClaimed, "mod-2"))
+	mock.ExpectRollback()
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/claim", "")
+
+	testutil.Serve(t, c, handler.ClaimReport)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "report is claimed by another moderator")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ResolveReport_Hide(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewModerationHandler(db, bus, accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, reporter_id, target_type, .+ FROM reports WHERE id = \\$1 FOR UPDATE").
+		WithArgs(testReportID).
+		WillReturnRows(sqlmock.NewRows(resolveRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetMessage, testMessageID, "user-456", "spam", models.ReportClaimed, "mod-1", testMessageID))
+	mock.ExpectQuery("UPDATE messages m SET hidden_at = COALESCE\\(m.hidden_at, NOW\\(\\)\\) WHERE m.id = \\$1").
+		WithArgs(testMessageID).
+		WillReturnRows(sqlmock.NewRows([]string{"visibility"}).AddRow(models.VisibilityPublic))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").
+		WithArgs("mod-1", models.AuditContentHidden, testReportID, models.ReportTargetMessage, testMessageID, []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Hiding spam teaches the spam classifier
+	mock.ExpectQuery("SELECT content FROM messages WHERE id = \\$1").
+		WithArgs(testMessageID).
+		WillReturnRows(sqlmock.NewRows([]string{"content"}).AddRow("Cheap pills here"))
+	mock.ExpectExec("INSERT INTO spam_tokens").
+		WithArgs(pq.Array([]string{"cheap", "pills", "here"}), 1, 0).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE spam_documents SET count = count \\+ 1 WHERE class = \\$1").
+		WithArgs("spam").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE reports SET status = 'resolved'").
+		WithArgs(testReportID, models.ModerationHide, "spam bot", "mod-1").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetMessage, testMessageID, "user-456", "spam", nil,
+				models.ReportResolved, "mod-1", now, "mod-1", now, models.ModerationHide, "spam bot", now))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").
+		WithArgs("mod-1", models.AuditReportResolved, testReportID, models.ReportTargetMessage, testMessageID, []byte(`{"action":"hide"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications \\(user_id, type, message_id, reply_id, report_id\\)").
+		WithArgs("user-123", models.NotificationReportResolved, nil, nil, testReportID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/resolve", `{"action":"hide","note":"spam bot"}`)
+
+	testutil.Serve(t, c, handler.ResolveReport)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Live clients and the search index drop the message as if deleted
+	published := bus.Published()
+	require.Len(t, published, 1)
+	assert.Equal(t, events.MessageDeleted, published[0].Type)
+	assert.JSONEq(t, `{"id":"`+testMessageID+`"}`, string(published[0].Data))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ResolveReport_WarnReplyAuthor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewModerationHandler(db, bus, accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM reports WHERE id = \\$1 FOR UPDATE").
+		WithArgs(testReportID).
+		WillReturnRows(sqlmock.NewRows(resolveRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetReply, "reply-1", "user-456", "harassment", models.ReportOpen, nil, testMessageID))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-456", models.NotificationModerationWarning, testMessageID, "reply-1", testReportID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").
+		WithArgs("mod-1", models.AuditUserWarned, testReportID, models.ReportTargetUser, "user-456", []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE reports SET status = 'resolved'").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetReply, "reply-1", "user-456", "harassment", nil,
+				models.ReportResolved, "mod-1", now, "mod-1", now, models.ModerationWarn, nil, now))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("user-123", models.NotificationReportResolved, nil, nil, testReportID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/resolve", `{"action":"warn"}`)
+
+	testutil.Serve(t, c, handler.ResolveReport)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ResolveReport_Suspend(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	now := time.Now()
+	until := now.Add(7 * 24 * time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM reports WHERE id = \\$1 FOR UPDATE").
+		WithArgs(testReportID).
+		WillReturnRows(sqlmock.NewRows(resolveRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetUser, "user-456", "user-456", "harassment", models.ReportOpen, nil, nil))
+	// Without a note the report's reason is given
+	mock.ExpectQuery("UPDATE users SET suspended_until = GREATEST\\(suspended_until, NOW\\(\\) \\+ make_interval\\(days => \\$2\\)\\)").
+		WithArgs("user-456", 7, "harassment").
+		WillReturnRows(sqlmock.NewRows([]string{"suspended_until"}).AddRow(until))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").
+		WithArgs("mod-1", models.AuditUserSuspended, testReportID, models.ReportTargetUser, "user-456", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE reports SET status = 'resolved'").
+		WithArgs(testReportID, models.ModerationSuspend, "", "mod-1").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetUser, "user-456", "user-456", "harassment", nil,
+				models.ReportResolved, "mod-1", now, "mod-1", now, models.ModerationSuspend, nil, now))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/resolve", `{"action":"suspend","duration_days":7}`)
+
+	testutil.Serve(t, c, handler.ResolveReport)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ResolveReport_SuspendNeedsDuration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/resolve", `{"action":"suspend"}`)
+
+	testutil.Serve(t, c, handler.ResolveReport)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ResolveReport_HideUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM reports WHERE id = \\$1 FOR UPDATE").
+		WithArgs(testReportID).
+		WillReturnRows(sqlmock.NewRows(resolveRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetUser, "user-456", "user-456", "spam", models.ReportOpen, nil, nil))
+	mock.ExpectRollback()
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/resolve", `{"action":"hide"}`)
+
+	testutil.Serve(t, c, handler.ResolveReport)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "only messages and replies can be hidden")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ResolveReport_AlreadyResolved(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM reports WHERE id = \\$1 FOR UPDATE").
+		WithArgs(testReportID).
+		WillReturnRows(sqlmock.NewRows(resolveRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetMessage, testMessageID, "user-456", "spam", models.ReportResolved, "mod-2", testMessageID))
+	mock.ExpectRollback()
+
+	w, c := newModerationRequest("POST", "/admin/reports/"+testReportID+"/resolve", `{"action":"dismiss"}`)
+
+	testutil.Serve(t, c, handler.ResolveReport)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ListReports_InvalidStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	w, c := newModerationRequest("GET", "/admin/reports?status=pending", "")
+
+	testutil.Serve(t, c, handler.ListReports)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ListAuditLog_ByReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	now := time.Now()
+	mock.ExpectQuery("FROM moderation_audit_log WHERE TRUE AND report_id = \\$2 ORDER BY created_at DESC, id DESC LIMIT \\$1").
+		WithArgs(defaultPageSize+1, testReportID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "action", "report_id", "target_type", "target_id", "details", "created_at"}).
+			AddRow("entry-2", "mod-1", models.AuditReportResolved, testReportID, models.ReportTargetMessage, testMessageID, []byte(`{"action":"hide"}`), now).
+			AddRow("entry-1", "mod-1", models.AuditReportClaimed, testReportID, models.ReportTargetMessage, testMessageID, []byte(`{}`), now.Add(-time.Minute)))
+
+	w, c := newModerationRequest("GET", "/admin/audit-log?report_id="+testReportID, "")
+
+	testutil.Serve(t, c, handler.ListAuditLog)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.AuditLogPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Entries, 2)
+	assert.Equal(t, models.AuditReportResolved, response.Entries[0].Action)
+	assert.JSONEq(t, `{"action":"hide"}`, string(response.Entries[0].Details))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_ListAuditLog_InvalidFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	w, c := newModerationRequest("GET", "/admin/audit-log?target_id=nope", "")
+
+	testutil.Serve(t, c, handler.ListAuditLog)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid target_id")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+)
+
+var notificationTracer = otel.Tracer("why-backend/handlers/notifications")
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// notificationEvent describes what happened; notify fans it out to recipients
+type notificationEvent struct {
+	Type      string
+	ActorID   string
+	MessageID string
+	ReplyID   sql.NullString
+}
+
+// notify records event for each recipient. The actor is never notified about
+// their own action, and recipients who blocked or muted the actor or disabled
+// the notification type are skipped. Duplicate notifications for the same event
+// are ignored.
+func notify(ctx context.Context, db dbtx, event notificationEvent, recipients ...string) error {
+	var userIDs []string
+	seen := map[string]bool{event.ActorID: true}
+	for _, id := range recipients {
+		if !seen[id] {
+			seen[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO notifications (user_id, actor_id, type, message_id, reply_id)
+		 SELECT u.id, $1, $2, $3, $4
+		 FROM unnest($5::uuid[]) AS u(id)
+		 WHERE NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = u.id AND b.blocked_id = $1)
+		   AND NOT EXISTS (SELECT 1 FROM user_mutes mu WHERE mu.muter_id = u.id AND mu.muted_id = $1)
+		   AND NOT EXISTS (SELECT 1 FROM notification_preferences p WHERE p.user_id = u.id AND p.type = $2 AND NOT p.enabled)
+		 ON CONFLICT DO NOTHING`,
+		event.ActorID, event.Type, event.MessageID, event.ReplyID, pq.Array(userIDs),
+	)
+	return err
+}
+
+// visibleNotification hides notifications from actors the recipient has
+// since blocked or muted, or who have since blocked them
+var visibleNotification = visibleTo("notifications.actor_id", "notifications.user_id")
+
+type NotificationHandler struct {
+	db *sql.DB
+}
+
+func NewNotificationHandler(db *sql.DB) *NotificationHandler {
+	return &NotificationHandler{db: db}
+}
+
+// ListNotifications returns the authenticated user's notifications, newest
+// first, with cursor pagination and the total unread count.
+// ?unread=true returns only unread notifications.
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	ctx, span := notificationTracer.Start(c.Request.Context(), "ListNotifications")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	query := `SELECT id, type, actor_id, message_id, reply_id, report_id, read_at, created_at
+		 FROM notifications
+		 WHERE user_id = $1 AND ` + visibleNotification
+	args := []any{userID, page.Limit + 1}
+	if c.Query("unread") == "true" {
+		query += ` AND read_at IS NULL`
+	}
+	if page.Cursor != nil {
+		query += ` AND (created_at, id) < ($3, $4)`
+		args = append(args, page.Cursor.CreatedAt, page.Cursor.ID)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT $2`
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list notifications", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to list notifications"))
+		return
+	}
+	defer rows.Close()
+
+	result := models.NotificationPage{Notifications: []models.Notification{}}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.Type, &n.ActorID, &n.MessageID, &n.ReplyID, &n.ReportID, &n.ReadAt, &n.CreatedAt); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan notification", "error", err)
+			continue
+		}
+		result.Notifications = append(result.Notifications, n)
+	}
+
+	if len(result.Notifications) > page.Limit {
+		result.Notifications = result.Notifications[:page.Limit]
+		last := result.Notifications[page.Limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	err = h.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL AND `+visibleNotification,
+		userID,
+	).Scan(&result.UnreadCount)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to count unread notifications", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to list notifications"))
+		return
+	}
+
+	span.SetAttributes(attribute.Int("notifications.count", len(result.Notifications)))
+	c.JSON(http.StatusOK, result)
+}
+
+// UnreadCount returns the number of unread notifications, in total and by type
+func (h *NotificationHandler) UnreadCount(c *gin.Context) {
+	ctx, span := notificationTracer.Start(c.Request.Context(), "UnreadCount")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT type, COUNT(*) FROM notifications
+		 WHERE user_id = $1 AND read_at IS NULL AND `+visibleNotification+`
+		 GROUP BY type`,
+		userID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to count unread notifications", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to count notifications"))
+		return
+	}
+	defer rows.Close()
+
+	counts := models.UnreadCounts{ByType: map[string]int{}}
+	for rows.Next() {
+		var notificationType string
+		var count int
+		if err := rows.Scan(&notificationType, &count); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan unread count", "error", err)
+			continue
+		}
+		counts.ByType[notificationType] = count
+		counts.Total += count
+	}
+
+	c.JSON(http.StatusOK, counts)
+}
+
+// MarkRead marks one of the authenticated user's notifications as read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	ctx, span := notificationTracer.Start(c.Request.Context(), "MarkRead")
+	defer span.End()
+
+	notificationID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("notification.id", notificationID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	var n models.Notification
+	err := h.db.QueryRowContext(ctx,
+		`UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+		 WHERE id = $1 AND user_id = $2
+		 RETURNING id, type, actor_id, message_id, reply_id, report_id, read_at, created_at`,
+		notificationID, userID,
+	).Scan(&n.ID, &n.Type, &n.ActorID, &n.MessageID, &n.ReplyID, &n.ReportID, &n.ReadAt, &n.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("notification not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to mark notification read", "error", err, "notification_id", notificationID)
+		apierror.Abort(c, apierror.Internal("failed to mark notification read"))
+		return
+	}
+
+	c.JSON(http.StatusOK, n)
+}
+
+// MarkAllRead marks every unread notification of the authenticated user as read
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	ctx, span := notificationTracer.Start(c.Request.Context(), "MarkAllRead")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	result, err := h.db.ExecContext(ctx,
+		`UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to mark notifications read", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to mark notifications read"))
+		return
+	}
+
+	updated, _ := result.RowsAffected()
+	span.SetAttributes(attribute.Int64("notifications.updated", updated))
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// GetPreferences returns whether each notification type is enabled
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	ctx, span := notificationTracer.Start(c.Request.Context(), "GetPreferences")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	prefs, err := h.loadPreferences(ctx, userID.(string))
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get notification preferences", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to get notification preferences"))
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences enables or disables notification types. Types missing
+// from the request body are left unchanged.
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	ctx, span := notificationTracer.Start(c.Request.Context(), "UpdatePreferences")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	var req models.NotificationPreferences
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	var types []string
+	var enabled []bool
+	for notificationType, on := range req {
+		if !slices.Contains(models.NotificationTypes, notificationType) {
+			apierror.Abort(c, apierror.BadRequest("unknown notification type: "+notificationType))
+			return
+		}
+		types = append(types, notificationType)
+		enabled = append(enabled, on)
+	}
+
+	if len(types) > 0 {
+		_, err := h.db.ExecContext(ctx,
+			`INSERT INTO notification_preferences (user_id, type, enabled)
+			 SELECT $1, t.type, t.enabled FROM unnest($2::text[], $3::boolean[]) AS t(type, enabled)
+			 ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()`,
+			userID, pq.Array(types), pq.Array(enabled),
+		)
+		if err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to update notification preferences", "error", err, "user_id", userID)
+			apierror.Abort(c, apierror.Internal("failed to update notification preferences"))
+			return
+		}
+	}
+
+	prefs, err := h.loadPreferences(ctx, userID.(string))
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get notification preferences", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to get notification preferences"))
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// loadPreferences returns every notification type, defaulting to enabled
+func (h *NotificationHandler) loadPreferences(ctx context.Context, userID string) (models.NotificationPreferences, error) {
+	prefs := models.NotificationPreferences{}
+	for _, notificationType := range models.NotificationTypes {
+		prefs[notificationType] = true
+	}
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT type, enabled FROM notification_preferences WHERE user_id = $1`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var notificationType string
+		var enabled bool
+		if err := rows.Scan(&notificationType, &enabled); err != nil {
+			return nil, err
+		}
+		if _, known := prefs[notificationType]; known {
+			prefs[notificationType] = enabled
+		}
+	}
+
+	return prefs, rows.Err()
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+var notificationColumns = []string{"id", "type", "actor_id", "message_id", "reply_id", "report_id", "read_at", "created_at"}
+
+func TestNotificationHandler_ListNotifications(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewNotificationHandler(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows(notificationColumns).
+		AddRow("5f0c6a1e-7d2b-4a8e-9c3f-000000000003", models.NotificationReply, "user-2", "msg-1", "reply-1", nil, nil, now).
+		AddRow("5f0c6a1e-7d2b-4a8e-9c3f-000000000002", models.NotificationMention, "user-3", "msg-1", nil, nil, now, now.Add(-time.Minute)).
+		AddRow("5f0c6a1e-7d2b-4a8e-9c3f-000000000001", models.NotificationReaction, "user-4", "msg-1", "reply-1", nil, nil, now.Add(-2*time.Minute))
+
+	mock.ExpectQuery("SELECT id, type, actor_id, message_id, reply_id, report_id, read_at, created_at FROM notifications WHERE user_id = \\$1 "+
+		"AND NOT EXISTS \\(SELECT 1 FROM user_blocks b .+user_mutes mu WHERE mu.muter_id = notifications.user_id AND mu.muted_id = notifications.actor_id\\) ORDER BY").
+		WithArgs("user-123", 3).
+		WillReturnRows(rows)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM notifications WHERE user_id = \\$1 AND read_at IS NULL AND NOT EXISTS .+user_mutes").
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/me/notifications?limit=2", nil)
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.ListNotifications)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.NotificationPage
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Len(t, response.Notifications, 2)
+	assert.Equal(t, models.NotificationReply, response.Notifications[0].Type)
+	assert.Nil(t, response.Notifications[1].ReplyID)
+	assert.Equal(t, 5, response.UnreadCount)
+	assert.NotEmpty(t, response.NextCursor)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestNotificationHandler_ListNotifications_UnreadWithCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewNotificationHandler(db)
+
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	lastID := "5f0c6a1e-7d2b-4a8e-9c3f-000000000002"
+
+	mock.ExpectQuery("WHERE user_id = \\$1 AND .+ AND read_at IS NULL AND \\(created_at, id\\) < \\(\\$3, \\$4\\)").
+		WithArgs("user-123", defaultPageSize+1, createdAt, lastID).
+		WillReturnRows(sqlmock.NewRows(notificationColumns))
+	mock.ExpectQuery("SELECT COUNT").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/me/notifications?unread=true&cursor="+encodeCursor(createdAt, lastID), nil)
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.ListNotifications)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"notifications":[],"unread_count":0}`, w.Body.String())
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestNotificationHandler_UnreadCount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewNotificationHandler(db)
+
+	mock.ExpectQuery("SELECT type, COUNT\\(\\*\\) FROM notifications").
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "count"}).
+			AddRow(models.NotificationReply, 3).
+			AddRow(models.NotificationMention, 1))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/me/notifications/unread-count", nil)
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UnreadCount)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"total":4,"by_type":{"reply":3,"mention":1}}`, w.Body.String())
+}
+
+func TestNotificationHandler_MarkRead_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewNotificationHandler(db)
+
+	// Another user's notification is indistinguishable from a missing one
+	mock.ExpectQuery("UPDATE notifications SET read_at").
+		WithArgs("notif-1", "user-123").
+		WillReturnError(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/me/notifications/notif-1/read", nil)
+	c.Params = gin.Params{{Key: "id", Value: "notif-1"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.MarkRead)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestNotificationHandler_MarkAllRead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewNotificationHandler(db)
+
+	mock.ExpectExec("UPDATE notifications SET read_at = NOW\\(\\) WHERE user_id = \\$1 AND read_at IS NULL").
+		WithArgs("user-123").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/me/notifications/read-all", nil)
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.MarkAllRead)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"updated":4}`, w.Body.String())
+}
+
+func TestNotificationHandler_UpdatePreferences(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewNotificationHandler(db)
+
+	mock.ExpectExec("INSERT INTO notification_preferences").
+		WithArgs("user-123", pq.Array([]string{"reaction"}), pq.Array([]bool{false})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT type, enabled FROM notification_preferences").
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "enabled"}).AddRow("reaction", false))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/me/notification-preferences", bytes.NewBufferString(`{"reaction":false}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UpdatePreferences)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"reply":true,"mention":true,"reaction":false,"accepted_answer":true}`, w.Body.String())
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestNotificationHandler_UpdatePreferences_UnknownType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewNotificationHandler(db)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/me/notification-preferences", bytes.NewBufferString(`{"newsletter":true}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UpdatePreferences)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNotify_SkipsActorAndDuplicates(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO notifications .+user_blocks.+user_mutes.+notification_preferences").
+		WithArgs("user-1", models.NotificationReply, "msg-1", sql.NullString{}, pq.Array([]string{"user-2"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := notify(context.Background(), db, notificationEvent{
+		Type:      models.NotificationReply,
+		ActorID:   "user-1",
+		MessageID: "msg-1",
+	}, "user-1", "user-2", "user-2")
+	require.NoError(t, err)
+
+	// Only the actor: nothing to insert
+	err = notify(context.Background(), db, notificationEvent{Type: models.NotificationReply, ActorID: "user-1", MessageID: "msg-1"}, "user-1")
+	require.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor is a keyset position: the (created_at, id) of the last row on the previous page
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+type pageParams struct {
+	Limit  int
+	Cursor *pageCursor
+}
+
+// parsePageParams reads ?limit= and ?cursor= from the request
+func parsePageParams(c *gin.Context) (pageParams, error) {
+	limit, err := parseLimit(c)
+	params := pageParams{Limit: limit}
+	if err != nil {
+		return params, err
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil {
+			return params, err
+		}
+		params.Cursor = cursor
+	}
+
+	return params, nil
+}
+
+// parseLimit reads ?limit=, defaulting to defaultPageSize and capped at maxPageSize
+func parseLimit(c *gin.Context) (int, error) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return defaultPageSize, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return defaultPageSize, errors.New("invalid limit")
+	}
+	return min(limit, maxPageSize), nil
+}
+
+// encodeCursor returns an opaque cursor pointing after the given row
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "," + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	ts, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &pageCursor{CreatedAt: createdAt, ID: id}, nil
+}
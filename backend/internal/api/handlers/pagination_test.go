@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	id := "5f0c6a1e-7d2b-4a8e-9c3f-1b2d3e4f5a6b"
+
+	cursor, err := decodeCursor(encodeCursor(createdAt, id))
+	require.NoError(t, err)
+	assert.True(t, createdAt.Equal(cursor.CreatedAt))
+	assert.Equal(t, id, cursor.ID)
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, raw := range []string{"!!!", "bm8tY29tbWE", encodeCursor(time.Now(), "not-a-uuid")} {
+		_, err := decodeCursor(raw)
+		assert.ErrorIs(t, err, errInvalidCursor, raw)
+	}
+}
+
+func TestParsePageParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantErr   bool
+	}{
+		{name: "defaults", query: "", wantLimit: defaultPageSize},
+		{name: "explicit limit", query: "?limit=5", wantLimit: 5},
+		{name: "limit is capped", query: "?limit=1000", wantLimit: maxPageSize},
+		{name: "zero limit", query: "?limit=0", wantErr: true},
+		{name: "non-numeric limit", query: "?limit=ten", wantErr: true},
+		{name: "bad cursor", query: "?cursor=garbage", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/"+tt.query, nil)
+
+			params, err := parsePageParams(c)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLimit, params.Limit)
+			assert.Nil(t, params.Cursor)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+)
+
+var reportTracer = otel.Tracer("why-backend/handlers/reports")
+
+// reportColumns selects a models.Report from the reports table
+const reportColumns = `id, reporter_id, target_type, target_id, target_user_id, reason, details, status,
+	claimed_by, claimed_at, resolved_by, resolved_at, action, resolution_note, created_at`
+
+// scanReport scans a row selected with reportColumns
+func scanReport(row rowScanner, report *models.Report) error {
+	return row.Scan(&report.ID, &report.ReporterID, &report.TargetType, &report.TargetID, &report.TargetUserID,
+		&report.Reason, &report.Details, &report.Status, &report.ClaimedBy, &report.ClaimedAt,
+		&report.ResolvedBy, &report.ResolvedAt, &report.Action, &report.ResolutionNote, &report.CreatedAt)
+}
+
+// reportTargetAuthor finds the user behind each report target type: the
+// reported user, or the author of the reported message or reply
+var reportTargetAuthor = map[string]string{
+	models.ReportTargetMessage: `SELECT user_id FROM messages WHERE id = $1`,
+	models.ReportTargetReply:   `SELECT user_id FROM replies WHERE id = $1`,
+	models.ReportTargetUser:    `SELECT id FROM users WHERE id = $1`,
+}
+
+type ReportHandler struct {
+	db *sql.DB
+}
+
+func NewReportHandler(db *sql.DB) *ReportHandler {
+	return &ReportHandler{db: db}
+}
+
+// CreateReport files a report about a message, reply or user for the
+// moderators. A user has one pending report per target at most.
+func (h *ReportHandler) CreateReport(c *gin.Context) {
+	ctx, span := reportTracer.Start(c.Request.Context(), "CreateReport")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	var req models.CreateReportRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("report.target_type", req.TargetType),
+		attribute.String("report.target_id", req.TargetID),
+		attribute.String("report.reason", req.Reason),
+	)
+
+	var targetUserID string
+	err := h.db.QueryRowContext(ctx, reportTargetAuthor[req.TargetType], req.TargetID).Scan(&targetUserID)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound(req.TargetType+" not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get report target", "error", err, "target_type", req.TargetType, "target_id", req.TargetID)
+		apierror.Abort(c, apierror.Internal("failed to create report"))
+		return
+	}
+	if targetUserID == userID.(string) {
+		apierror.Abort(c, apierror.BadRequest("you cannot report yourself"))
+		return
+	}
+
+	var report models.Report
+	err = scanReport(h.db.QueryRowContext(ctx,
+		`INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, reason, details)
+		 VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		 RETURNING `+reportColumns,
+		userID, req.TargetType, req.TargetID, targetUserID, req.Reason, req.Details,
+	), &report)
+	if isUniqueViolation(err, "idx_reports_pending") {
+		apierror.Abort(c, apierror.Conflict("you have already reported this "+req.TargetType))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to create report", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to create report"))
+		return
+	}
+
+	span.SetAttributes(attribute.String("report.id", report.ID))
+	slog.InfoContext(ctx, "Report created", "report_id", report.ID, "user_id", userID,
+		"target_type", report.TargetType, "target_id", report.TargetID)
+
+	c.JSON(http.StatusCreated, reporterView(report))
+}
+
+// ListMyReports returns the authenticated user's reports and their outcome,
+// newest first, with cursor pagination
+func (h *ReportHandler) ListMyReports(c *gin.Context) {
+	ctx, span := reportTracer.Start(c.Request.Context(), "ListMyReports")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	query := `SELECT ` + reportColumns + ` FROM reports WHERE reporter_id = $1`
+	args := []any{userID, page.Limit + 1}
+	if page.Cursor != nil {
+		query += ` AND (created_at, id) < ($3, $4)`
+		args = append(args, page.Cursor.CreatedAt, page.Cursor.ID)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT $2`
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list reports", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to list reports"))
+		return
+	}
+	defer rows.Close()
+
+	result := models.ReportPage{Reports: []models.Report{}}
+	for rows.Next() {
+		var report models.Report
+		if err := scanReport(rows, &report); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan report", "error", err)
+			continue
+		}
+		result.Reports = append(result.Reports, reporterView(report))
+	}
+
+	if len(result.Reports) > page.Limit {
+		result.Reports = result.Reports[:page.Limit]
+		last := result.Reports[page.Limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	span.SetAttributes(attribute.Int("reports.count", len(result.Reports)))
+	c.JSON(http.StatusOK, result)
+}
+
+// GetMyReport returns one of the authenticated user's reports
+func (h *ReportHandler) GetMyReport(c *gin.Context) {
+	ctx, span := reportTracer.Start(c.Request.Context(), "GetMyReport")
+	defer span.End()
+
+	reportID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("report.id", reportID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(reportID); err != nil {
+		apierror.Abort(c, apierror.NotFound("report not found"))
+		return
+	}
+
+	var report models.Report
+	err := scanReport(h.db.QueryRowContext(ctx,
+		`SELECT `+reportColumns+` FROM reports WHERE id = $1 AND reporter_id = $2`,
+		reportID, userID,
+	), &report)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("report not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get report", "error", err, "report_id", reportID)
+		apierror.Abort(c, apierror.Internal("failed to get report"))
+		return
+	}
+
+	c.JSON(http.StatusOK, reporterView(report))
+}
+
+// reporterView leaves out which moderators handled a report and their notes
+func reporterView(report models.Report) models.Report {
+	report.ClaimedBy = nil
+	report.ClaimedAt = nil
+	report.ResolvedBy = nil
+	report.ResolutionNote = nil
+	return report
+}
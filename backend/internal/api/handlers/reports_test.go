@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+const (
+	testReportID  = "00000000-0000-0000-0000-0000000000a1"
+	testMessageID = "00000000-0000-0000-0000-0000000000b1"
+)
+
+var reportRowColumns = []string{"id", "reporter_id", "target_type", "target_id", "target_user_id", "reason", "details", "status",
+	"claimed_by", "claimed_at", "resolved_by", "resolved_at", "action", "resolution_note", "created_at"}
+
+func newReportRequest(body string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/reports", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+	return w, c
+}
+
+func TestReportHandler_CreateReport_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewReportHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id = \\$1").
+		WithArgs(testMessageID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-456"))
+	mock.ExpectQuery("INSERT INTO reports \\(reporter_id, target_type, target_id, target_user_id, reason, details\\)").
+		WithArgs("user-123", models.ReportTargetMessage, testMessageID, "user-456", "spam", "Buy now links").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetMessage, testMessageID, "user-456", "spam", "Buy now links",
+				models.ReportOpen, nil, nil, nil, nil, nil, nil, now))
+
+	w, c := newReportRequest(`{"target_type":"message","target_id":"` + testMessageID + `","reason":"spam","details":"Buy now links"}`)
+
+	testutil.Serve(t, c, handler.CreateReport)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, testReportID, response.ID)
+	assert.Equal(t, models.ReportOpen, response.Status)
+	assert.Equal(t, "user-456", response.TargetUserID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportHandler_CreateReport_Self(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewReportHandler(db)
+
+	mock.ExpectQuery("SELECT user_id FROM messages WHERE id = \\$1").
+		WithArgs(testMessageID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("user-123"))
+
+	w, c := newReportRequest(`{"target_type":"message","target_id":"` + testMessageID + `","reason":"spam"}`)
+
+	testutil.Serve(t, c, handler.CreateReport)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "you cannot report yourself")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportHandler_CreateReport_AlreadyReported(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewReportHandler(db)
+
+	mock.ExpectQuery("SELECT id FROM users WHERE id = \\$1").
+		WithArgs(testMessageID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testMessageID))
+	mock.ExpectQuery("INSERT INTO reports").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_reports_pending"})
+
+	w, c := newReportRequest(`{"target_type":"user","target_id":"` + testMessageID + `","reason":"harassment"}`)
+
+	testutil.Serve(t, c, handler.CreateReport)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "you have already reported this user")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportHandler_CreateReport_TargetNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewReportHandler(db)
+
+	mock.ExpectQuery("SELECT user_id FROM replies WHERE id = \\$1").
+		WithArgs(testMessageID).
+		WillReturnError(sql.ErrNoRows)
+
+	w, c := newReportRequest(`{"target_type":"reply","target_id":"` + testMessageID + `","reason":"hate"}`)
+
+	testutil.Serve(t, c, handler.CreateReport)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "reply not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportHandler_CreateReport_InvalidReason(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewReportHandler(db)
+
+	w, c := newReportRequest(`{"target_type":"message","target_id":"` + testMessageID + `","reason":"boring"}`)
+
+	testutil.Serve(t, c, handler.CreateReport)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportHandler_GetMyReport_HidesModerators(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewReportHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("FROM reports WHERE id = \\$1 AND reporter_id = \\$2").
+		WithArgs(testReportID, "user-123").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns).
+			AddRow(testReportID, "user-123", models.ReportTargetMessage, testMessageID, "user-456", "spam", nil,
+				models.ReportResolved, "mod-1", now, "mod-1", now, models.ModerationHide, "clear spam", now))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/me/reports/"+testReportID, nil)
+	c.Params = gin.Params{{Key: "id", Value: testReportID}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.GetMyReport)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ModerationHide, response["action"])
+	assert.NotContains(t, response, "claimed_by")
+	assert.NotContains(t, response, "resolved_by")
+	assert.NotContains(t, response, "resolution_note")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/content"
+	"why-backend/internal/models"
+	"why-backend/internal/search"
+)
+
+var searchTracer = otel.Tracer("why-backend/handlers/search")
+
+// parseSearchTime accepts an RFC 3339 timestamp or a date, which is the
+// start of that day (UTC)
+func parseSearchTime(raw string) (t time.Time, date bool, err error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, false, nil
+	}
+	t, err = time.Parse(time.DateOnly, raw)
+	return t, true, err
+}
+
+type SearchHandler struct {
+	db    *sql.DB
+	index search.Index
+}
+
+func NewSearchHandler(db *sql.DB, index search.Index) *SearchHandler {
+	return &SearchHandler{db: db, index: index}
+}
+
+// Search finds messages and replies matching ?q=, best matches first, with
+// highlighted snippets and cursor pagination. See search.ParseQuery for the
+// query syntax. Filters: ?type=message|reply, ?author= (@handle or user id),
+// ?tag= (the message's tags, or a reply's parent message's), and ?from= /
+// ?to= (RFC 3339 timestamps or dates, both inclusive).
+func (h *SearchHandler) Search(c *gin.Context) {
+	ctx, span := searchTracer.Start(c.Request.Context(), "Search")
+	defer span.End()
+
+	q := search.Query{Text: c.Query("q"), Cursor: c.Query("cursor")}
+	span.SetAttributes(attribute.String("search.query", q.Text))
+
+	limit, err := parseLimit(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	q.Limit = limit
+
+	q.Type = c.Query("type")
+	if q.Type != "" && q.Type != models.SearchResultMessage && q.Type != models.SearchResultReply {
+		apierror.Abort(c, apierror.BadRequest("type must be message or reply"))
+		return
+	}
+
+	if raw := c.Query("tag"); raw != "" {
+		q.Tag = content.NormalizeTag(raw)
+		if q.Tag == "" {
+			apierror.Abort(c, apierror.BadRequest("invalid tag"))
+			return
+		}
+	}
+	if raw := c.Query("from"); raw != "" {
+		q.From, _, err = parseSearchTime(raw)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("invalid from"))
+			return
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		to, date, err := parseSearchTime(raw)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("invalid to"))
+			return
+		}
+		if date {
+			// Through the end of that day
+			q.Before = to.AddDate(0, 0, 1)
+		} else {
+			// Timestamps are stored to the microsecond
+			q.Before = to.Truncate(time.Microsecond).Add(time.Microsecond)
+		}
+	}
+
+	if author := c.Query("author"); author != "" {
+		if _, err := uuid.Parse(author); err == nil {
+			q.AuthorID = author
+		} else {
+			err := h.db.QueryRowContext(ctx,
+				`SELECT id FROM users WHERE lower(handle) = lower($1)`,
+				strings.TrimPrefix(author, "@"),
+			).Scan(&q.AuthorID)
+			if err == sql.ErrNoRows {
+				// Nobody by that handle, so nothing they wrote matches
+				c.JSON(http.StatusOK, models.SearchPage{Results: []models.SearchResult{}})
+				return
+			}
+			if err != nil {
+				span.RecordError(err)
+				slog.ErrorContext(ctx, "Failed to look up search author", "error", err)
+				apierror.Abort(c, apierror.Internal("failed to search"))
+				return
+			}
+		}
+	}
+
+	result, err := h.index.Search(ctx, q)
+	if search.BadQuery(err) {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to search", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to search"))
+		return
+	}
+
+	result.Results, err = h.dropBanned(ctx, result.Results)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to filter search results", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to search"))
+		return
+	}
+
+	span.SetAttributes(attribute.Int("search.results", len(result.Results)))
+	c.JSON(http.StatusOK, result)
+}
+
+// dropBanned leaves out results written by banned users or in their
+// threads. The index is not told about bans, so a page may come back short;
+// its cursor still leads to the next one.
+func (h *SearchHandler) dropBanned(ctx context.Context, results []models.SearchResult) ([]models.SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	messageIDs := make([]string, len(results))
+	userIDs := make([]string, len(results))
+	for i, r := range results {
+		messageIDs[i] = r.MessageID
+		userIDs[i] = r.UserID
+	}
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT m.id FROM messages m JOIN users u ON u.id = m.user_id WHERE m.id = ANY($1) AND u.banned_at IS NOT NULL
+		 UNION ALL
+		 SELECT u.id FROM users u WHERE u.id = ANY($2) AND u.banned_at IS NOT NULL`,
+		pq.Array(messageIDs), pq.Array(userIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	banned := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		banned[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(banned) == 0 {
+		return results, nil
+	}
+
+	kept := results[:0]
+	for _, r := range results {
+		if !banned[r.MessageID] && !banned[r.UserID] {
+			kept = append(kept, r)
+		}
+	}
+	return kept, nil
+}
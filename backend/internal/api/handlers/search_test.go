@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+	"why-backend/internal/search"
+	"why-backend/internal/testutil"
+)
+
+var searchColumns = []string{"kind", "id", "message_id", "user_id", "ts_headline", "rank", "created_at"}
+
+func TestSearchHandler_Search(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewSearchHandler(db, search.NewPostgres(db))
+
+	now := time.Now()
+	mock.ExpectQuery("WITH q AS \\(SELECT to_tsquery\\('english', \\$1\\) AS query\\).+FROM messages m, q WHERE m.search_vector @@ q.query AND m.visibility = 'public' AND m.hidden_at IS NULL AND m.filter_action IS NULL UNION ALL .+FROM replies r, q WHERE r.search_vector @@ q.query AND r.hidden_at IS NULL AND r.filter_action IS NULL AND EXISTS .+\\) u ORDER BY u.rank DESC, u.id DESC LIMIT \\$2.+ts_headline").
+		WithArgs("(connection <-> pool) & leak:*", 3).
+		WillReturnRows(sqlmock.NewRows(searchColumns).
+			AddRow("message", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "user-1",
+				"the <mark>connection</mark> <mark>pool</mark> <mark>leaks</mark>", 0.5, now).
+			AddRow("reply", "5f0c6a1e-7d2b-4a8e-9c3f-000000000002", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "user-2",
+				"<mark>connection</mark> <mark>pool</mark> &lt;script&gt; <mark>leaking</mark>", 0.25, now).
+			AddRow("reply", "5f0c6a1e-7d2b-4a8e-9c3f-000000000001", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "user-3",
+				"<mark>leak</mark>", 0.1, now))
+	expectBannedSearchResults(mock)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", `/search?q="connection+pool"+leak*&limit=2`, nil)
+
+	testutil.Serve(t, c, handler.Search)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.SearchPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, models.SearchResultMessage, response.Results[0].Type)
+	assert.Equal(t, models.SearchResultReply, response.Results[1].Type)
+	assert.Equal(t, "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", response.Results[1].MessageID)
+	assert.Contains(t, response.Results[0].Snippet, "<mark>pool</mark>")
+	assert.NotEmpty(t, response.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectBannedSearchResults expects the search results' authors and threads
+// to be checked for bans, finding banned
+func expectBannedSearchResults(mock sqlmock.Sqlmock, banned ...string) {
+	rows := sqlmock.NewRows([]string{"id"})
+	for _, id := range banned {
+		rows.AddRow(id)
+	}
+	mock.ExpectQuery("SELECT m.id FROM messages m JOIN users u ON u.id = m.user_id WHERE m.id = ANY\\(\\$1\\) AND u.banned_at IS NOT NULL " +
+		"UNION ALL SELECT u.id FROM users u WHERE u.id = ANY\\(\\$2\\) AND u.banned_at IS NOT NULL").
+		WillReturnRows(rows)
+}
+
+func TestSearchHandler_Search_DropsBanned(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewSearchHandler(db, search.NewPostgres(db))
+
+	now := time.Now()
+	mock.ExpectQuery("WITH q AS").
+		WillReturnRows(sqlmock.NewRows(searchColumns).
+			AddRow("message", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "user-1",
+				"<mark>leak</mark>", 0.5, now).
+			AddRow("reply", "5f0c6a1e-7d2b-4a8e-9c3f-000000000002", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "user-2",
+				"<mark>leak</mark>", 0.25, now).
+			AddRow("reply", "5f0c6a1e-7d2b-4a8e-9c3f-000000000001", "5f0c6a1e-7d2b-4a8e-9c3f-000000000004", "user-3",
+				"<mark>leak</mark>", 0.1, now))
+	// user-1 is banned: their message and the reply in their thread go
+	expectBannedSearchResults(mock, "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "user-1")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/search?q=leak", nil)
+
+	testutil.Serve(t, c, handler.Search)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.SearchPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, "5f0c6a1e-7d2b-4a8e-9c3f-000000000001", response.Results[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchHandler_Search_Filters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewSearchHandler(db, search.NewPostgres(db))
+
+	mock.ExpectQuery("SELECT id FROM users WHERE lower\\(handle\\) = lower\\(\\$1\\)").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-1"))
+	mock.ExpectQuery("FROM replies r, q WHERE r.search_vector @@ q.query "+
+		"AND r.hidden_at IS NULL AND r.filter_action IS NULL AND EXISTS \\(SELECT 1 FROM messages pm WHERE pm.id = r.message_id AND pm.visibility = 'public' AND pm.hidden_at IS NULL AND pm.filter_action IS NULL\\) "+
+		"AND r.user_id = \\$3 "+
+		"AND EXISTS \\(SELECT 1 FROM message_tags mt WHERE mt.message_id = r.message_id AND mt.tag = \\$4\\) "+
+		"AND r.created_at >= \\$5 AND r.created_at < \\$6\\) u ORDER BY").
+		WithArgs("postgres", 21, "user-1", "databases",
+			time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows(searchColumns))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET",
+		"/search?q=postgres&type=reply&author=@alice&tag=%23Databases&from=2024-01-01&to=2024-01-31", nil)
+
+	testutil.Serve(t, c, handler.Search)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"results":[]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchHandler_Search_UnknownAuthor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewSearchHandler(db, search.NewPostgres(db))
+
+	mock.ExpectQuery("SELECT id FROM users").
+		WithArgs("nobody").
+		WillReturnError(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/search?q=postgres&author=nobody", nil)
+
+	testutil.Serve(t, c, handler.Search)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"results":[]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchHandler_Search_InvalidParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewSearchHandler(db, search.NewPostgres(db))
+
+	for _, target := range []string{
+		"/search",
+		"/search?q=-draft",
+		"/search?q=postgres&type=user",
+		"/search?q=postgres&from=yesterday",
+		"/search?q=postgres&cursor=bogus",
+		"/search?q=postgres&limit=0",
+	} {
+		t.Run(target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", target, nil)
+
+			testutil.Serve(t, c, handler.Search)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
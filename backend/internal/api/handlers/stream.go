@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/events"
+	"why-backend/internal/stream"
+)
+
+var streamTracer = otel.Tracer("why-backend/handlers/stream")
+
+const (
+	// heartbeatInterval keeps idle connections open through proxies
+	heartbeatInterval = 15 * time.Second
+	// reconnectDelay is the EventSource retry delay sent to clients, in milliseconds
+	reconnectDelay = 3000
+)
+
+type StreamHandler struct {
+	hub       *stream.Hub
+	heartbeat time.Duration
+}
+
+func NewStreamHandler(hub *stream.Hub) *StreamHandler {
+	return &StreamHandler{hub: hub, heartbeat: heartbeatInterval}
+}
+
+// Stream sends new messages and replies as Server-Sent Events.
+// ?message_id= restricts the stream to one thread. A reconnecting client
+// resumes after its Last-Event-ID header (or ?last_event_id=); if the replay
+// buffer no longer reaches back that far a "reset" event tells it to refetch.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	ctx, span := streamTracer.Start(c.Request.Context(), "Stream")
+	defer span.End()
+
+	messageID := c.Query("message_id")
+	if messageID != "" {
+		if _, err := uuid.Parse(messageID); err != nil {
+			apierror.Abort(c, apierror.BadRequest("invalid message_id"))
+			return
+		}
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var after int64
+	if lastEventID != "" {
+		var err error
+		after, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || after < 0 {
+			apierror.Abort(c, apierror.BadRequest("invalid Last-Event-ID"))
+			return
+		}
+	}
+
+	span.SetAttributes(
+		attribute.String("message.id", messageID),
+		attribute.Int64("stream.last_event_id", after),
+	)
+
+	sub, replay, complete := h.hub.Subscribe(messageID, after)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable nginx response buffering
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay)
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		writeStreamEvent(w, event)
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	sent := len(replay)
+	for {
+		select {
+		case <-ctx.Done():
+			span.SetAttributes(attribute.Int("stream.events_sent", sent))
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Shutting down or fell behind; the client reconnects and resumes
+				span.SetAttributes(attribute.Int("stream.events_sent", sent))
+				return
+			}
+			writeStreamEvent(w, event)
+			w.Flush()
+			sent++
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			w.Flush()
+		}
+	}
+}
+
+// writeStreamEvent writes event in SSE framing. Data is compact JSON, which
+// never contains a newline.
+func writeStreamEvent(w io.Writer, event events.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
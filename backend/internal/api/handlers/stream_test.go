@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/events"
+	"why-backend/internal/stream"
+	"why-backend/internal/testutil"
+)
+
+const streamThreadID = "5f0c6a1e-7d2b-4a8e-9c3f-00000000000a"
+
+// startTestHub returns a started hub whose replay buffer holds three events,
+// two of them in streamThreadID
+func startTestHub(t *testing.T) *stream.Hub {
+	bus := events.NewMemoryBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	require.NoError(t, bus.Publish(ctx, nil, events.MessageCreated, streamThreadID, map[string]string{"id": "msg-a"}))
+	require.NoError(t, bus.Publish(ctx, nil, events.MessageCreated, "5f0c6a1e-7d2b-4a8e-9c3f-00000000000b", map[string]string{"id": "msg-b"}))
+	require.NoError(t, bus.Publish(ctx, nil, events.ReplyCreated, streamThreadID, map[string]string{"id": "reply-1"}))
+
+	hub := stream.NewHub(bus, stream.Options{})
+	require.NoError(t, hub.Start(ctx))
+	return hub
+}
+
+// serveStream runs the handler on a request whose client has already gone
+// away, so it returns after writing the headers and the replay
+func serveStream(t *testing.T, handler *StreamHandler, target, lastEventID string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Request = httptest.NewRequest("GET", target, nil).WithContext(ctx)
+	if lastEventID != "" {
+		c.Request.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	testutil.Serve(t, c, handler.Stream)
+	return w
+}
+
+func TestStreamHandler_Stream_ReplaysThreadAfterLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewStreamHandler(startTestHub(t))
+
+	w := serveStream(t, handler, "/stream?message_id="+streamThreadID, "1")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "retry: 3000\n\n"+
+		"id: 3\nevent: reply.created\ndata: {\"id\":\"reply-1\"}\n\n", w.Body.String())
+}
+
+func TestStreamHandler_Stream_NoReplayWithoutLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewStreamHandler(startTestHub(t))
+
+	w := serveStream(t, handler, "/stream", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "retry: 3000\n\n", w.Body.String())
+}
+
+func TestStreamHandler_Stream_InvalidLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewStreamHandler(startTestHub(t))
+
+	w := serveStream(t, handler, "/stream", "abc")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/accounts"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+)
+
+// account is a user's role and state, locked for an account action
+type account struct {
+	role      string
+	suspended bool
+	banned    bool
+}
+
+// lockAccount reads and locks userID's account
+func lockAccount(ctx context.Context, tx *sql.Tx, userID string) (account, error) {
+	var a account
+	err := tx.QueryRowContext(ctx,
+		`SELECT role, COALESCE(suspended_until > NOW(), false), banned_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE`,
+		userID,
+	).Scan(&a.role, &a.suspended, &a.banned)
+	return a, err
+}
+
+// SuspendUser suspends a user for duration_days, replacing any suspension in
+// force. Only admins can suspend moderators and admins.
+func (h *ModerationHandler) SuspendUser(c *gin.Context) {
+	ctx, span := moderationTracer.Start(c.Request.Context(), "SuspendUser")
+	defer span.End()
+
+	targetID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("target.id", targetID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(targetID); err != nil {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+	if targetID == userID.(string) {
+		apierror.Abort(c, apierror.BadRequest("you cannot suspend yourself"))
+		return
+	}
+
+	var req models.SuspendUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to suspend user"))
+		return
+	}
+	defer tx.Rollback()
+
+	target, err := lockAccount(ctx, tx, targetID)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get user", "error", err, "user_id", targetID)
+		apierror.Abort(c, apierror.Internal("failed to suspend user"))
+		return
+	}
+	if target.role != models.RoleUser && c.GetString("role") != models.RoleAdmin {
+		apierror.Abort(c, apierror.Forbidden("only admins can suspend moderators and admins"))
+		return
+	}
+
+	var until time.Time
+	err = tx.QueryRowContext(ctx,
+		`UPDATE users SET suspended_until = NOW() + make_interval(days => $2), suspension_reason = $3, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING suspended_until`,
+		targetID, req.DurationDays, req.Reason,
+	).Scan(&until)
+	if err == nil {
+		err = audit(ctx, tx, userID.(string), models.AuditUserSuspended, "", models.ReportTargetUser, targetID,
+			map[string]any{"duration_days": req.DurationDays, "until": until, "reason": req.Reason})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to suspend user", "error", err, "user_id", targetID)
+		apierror.Abort(c, apierror.Internal("failed to suspend user"))
+		return
+	}
+	h.accounts.Forget(targetID)
+
+	slog.InfoContext(ctx, "User suspended", "user_id", targetID, "until", until, "moderator_id", userID)
+	c.JSON(http.StatusOK, accounts.Standing{State: models.AccountSuspended, Reason: req.Reason, Until: &until})
+}
+
+// LiftSuspension ends a user's suspension early. Lifting a suspension that
+// is not in force is not an error.
+func (h *ModerationHandler) LiftSuspension(c *gin.Context) {
+	h.liftAccountState(c, "LiftSuspension", models.AuditUserUnsuspended,
+		func(a account) bool { return a.suspended },
+		`UPDATE users SET suspended_until = NULL, suspension_reason = NULL, updated_at = NOW() WHERE id = $1`)
+}
+
+// BanUser bans a user until the ban is lifted. Their tokens stop working and
+// their messages and replies are hidden from everyone. Banning a banned user
+// only updates the reason.
+func (h *ModerationHandler) BanUser(c *gin.Context) {
+	ctx, span := moderationTracer.Start(c.Request.Context(), "BanUser")
+	defer span.End()
+
+	targetID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("target.id", targetID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(targetID); err != nil {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+	if targetID == userID.(string) {
+		apierror.Abort(c, apierror.BadRequest("you cannot ban yourself"))
+		return
+	}
+
+	var req models.BanUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to ban user"))
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = lockAccount(ctx, tx, targetID)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE users SET banned_at = COALESCE(banned_at, NOW()), ban_reason = $2, updated_at = NOW() WHERE id = $1`,
+			targetID, req.Reason,
+		)
+	}
+	if err == nil {
+		err = audit(ctx, tx, userID.(string), models.AuditUserBanned, "", models.ReportTargetUser, targetID,
+			map[string]string{"reason": req.Reason})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to ban user", "error", err, "user_id", targetID)
+		apierror.Abort(c, apierror.Internal("failed to ban user"))
+		return
+	}
+	h.accounts.Forget(targetID)
+
+	slog.InfoContext(ctx, "User banned", "user_id", targetID, "admin_id", userID)
+	c.JSON(http.StatusOK, accounts.Standing{State: models.AccountBanned, Reason: req.Reason})
+}
+
+// UnbanUser lifts a user's ban; their content shows again. Unbanning a user
+// who is not banned is not an error.
+func (h *ModerationHandler) UnbanUser(c *gin.Context) {
+	h.liftAccountState(c, "UnbanUser", models.AuditUserUnbanned,
+		func(a account) bool { return a.banned },
+		`UPDATE users SET banned_at = NULL, ban_reason = NULL, updated_at = NOW() WHERE id = $1`)
+}
+
+// liftAccountState runs query, taking the user id, when inForce holds for
+// the user's account, and records it in the audit log
+func (h *ModerationHandler) liftAccountState(c *gin.Context, name, action string, inForce func(account) bool, query string) {
+	ctx, span := moderationTracer.Start(c.Request.Context(), name)
+	defer span.End()
+
+	targetID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("target.id", targetID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(targetID); err != nil {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to update user"))
+		return
+	}
+	defer tx.Rollback()
+
+	target, err := lockAccount(ctx, tx, targetID)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+	if err == nil && !inForce(target) {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, query, targetID)
+	}
+	if err == nil {
+		err = audit(ctx, tx, userID.(string), action, "", models.ReportTargetUser, targetID, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to update user", "error", err, "user_id", targetID, "action", action)
+		apierror.Abort(c, apierror.Internal("failed to update user"))
+		return
+	}
+	h.accounts.Forget(targetID)
+
+	slog.InfoContext(ctx, "Account state lifted", "user_id", targetID, "action", action, "moderator_id", userID)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/accounts"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+const testTargetUserID = "00000000-0000-0000-0000-0000000000c1"
+
+func expectLockAccount(mock sqlmock.Sqlmock, role string, suspended, banned bool) {
+	mock.ExpectQuery("SELECT role, COALESCE\\(suspended_until > NOW\\(\\), false\\), banned_at IS NOT NULL FROM users WHERE id = \\$1 FOR UPDATE").
+		WithArgs(testTargetUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "suspended", "banned"}).AddRow(role, suspended, banned))
+}
+
+func newAccountRequest(method, path, body, role string) (*gin.Context, func() (int, string)) {
+	w, c := newModerationRequest(method, path, body)
+	c.Params = gin.Params{{Key: "id", Value: testTargetUserID}}
+	c.Set("role", role)
+	return c, func() (int, string) { return c.Writer.Status(), w.Body.String() }
+}
+
+func TestModerationHandler_SuspendUser_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	until := time.Now().Add(3 * 24 * time.Hour)
+	mock.ExpectBegin()
+	expectLockAccount(mock, models.RoleUser, false, false)
+	mock.ExpectQuery("UPDATE users SET suspended_until = NOW\\(\\) \\+ make_interval\\(days => \\$2\\), suspension_reason = \\$3").
+		WithArgs(testTargetUserID, 3, "repeated spam").
+		WillReturnRows(sqlmock.NewRows([]string{"suspended_until"}).AddRow(until))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").
+		WithArgs("mod-1", models.AuditUserSuspended, "", models.ReportTargetUser, testTargetUserID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	c, result := newAccountRequest("PUT", "/admin/users/"+testTargetUserID+"/suspension",
+		`{"reason":"repeated spam","duration_days":3}`, models.RoleModerator)
+
+	testutil.Serve(t, c, handler.SuspendUser)
+
+	code, body := result()
+	assert.Equal(t, http.StatusOK, code)
+
+	var response accounts.Standing
+	require.NoError(t, json.Unmarshal([]byte(body), &response))
+	assert.Equal(t, models.AccountSuspended, response.State)
+	assert.Equal(t, "repeated spam", response.Reason)
+	require.NotNil(t, response.Until)
+	assert.WithinDuration(t, until, *response.Until, time.Second)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_SuspendUser_ModeratorByModerator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, models.RoleModerator, false, false)
+	mock.ExpectRollback()
+
+	c, result := newAccountRequest("PUT", "/admin/users/"+testTargetUserID+"/suspension",
+		`{"reason":"abuse","duration_days":3}`, models.RoleModerator)
+
+	testutil.Serve(t, c, handler.SuspendUser)
+
+	code, body := result()
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "only admins can suspend moderators and admins")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_SuspendUser_Self(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	c, result := newAccountRequest("PUT", "/admin/users/"+testTargetUserID+"/suspension",
+		`{"reason":"test","duration_days":1}`, models.RoleAdmin)
+	c.Set("user_id", testTargetUserID)
+
+	testutil.Serve(t, c, handler.SuspendUser)
+
+	code, _ := result()
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_SuspendUser_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM users WHERE id = \\$1 FOR UPDATE").
+		WithArgs(testTargetUserID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	c, result := newAccountRequest("PUT", "/admin/users/"+testTargetUserID+"/suspension",
+		`{"reason":"spam","duration_days":1}`, models.RoleModerator)
+
+	testutil.Serve(t, c, handler.SuspendUser)
+
+	code, _ := result()
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_LiftSuspension(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("suspended", func(t *testing.T) {
+		db, mock := testutil.SetupTestDB(t)
+		defer db.Close()
+
+		handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+		mock.ExpectBegin()
+		expectLockAccount(mock, models.RoleUser, true, false)
+		mock.ExpectExec("UPDATE users SET suspended_until = NULL, suspension_reason = NULL").
+			WithArgs(testTargetUserID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO moderation_audit_log").
+			WithArgs("mod-1", models.AuditUserUnsuspended, "", models.ReportTargetUser, testTargetUserID, []byte(`{}`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		c, result := newAccountRequest("DELETE", "/admin/users/"+testTargetUserID+"/suspension", "", models.RoleModerator)
+
+		testutil.Serve(t, c, handler.LiftSuspension)
+
+		code, _ := result()
+		assert.Equal(t, http.StatusNoContent, code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not suspended", func(t *testing.T) {
+		db, mock := testutil.SetupTestDB(t)
+		defer db.Close()
+
+		handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+		// Nothing to change or audit
+		mock.ExpectBegin()
+		expectLockAccount(mock, models.RoleUser, false, false)
+		mock.ExpectRollback()
+
+		c, result := newAccountRequest("DELETE", "/admin/users/"+testTargetUserID+"/suspension", "", models.RoleModerator)
+
+		testutil.Serve(t, c, handler.LiftSuspension)
+
+		code, _ := result()
+		assert.Equal(t, http.StatusNoContent, code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestModerationHandler_BanUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	cache := accounts.NewCache(db, accounts.DefaultCacheTTL)
+	handler := NewModerationHandler(db, events.NewMemoryBus(), cache)
+
+	// The target's standing is cached as active before the ban
+	mock.ExpectQuery("SELECT banned_at IS NOT NULL").
+		WithArgs(testTargetUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned", "ban_reason", "suspended_until", "suspension_reason"}).AddRow(false, "", nil, ""))
+	standing, err := cache.Standing(context.Background(), testTargetUserID)
+	require.NoError(t, err)
+	require.True(t, standing.Active())
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, models.RoleUser, false, false)
+	mock.ExpectExec("UPDATE users SET banned_at = COALESCE\\(banned_at, NOW\\(\\)\\), ban_reason = \\$2").
+		WithArgs(testTargetUserID, "threats").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_audit_log").
+		WithArgs("mod-1", models.AuditUserBanned, "", models.ReportTargetUser, testTargetUserID, []byte(`{"reason":"threats"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx, result := newAccountRequest("PUT", "/admin/users/"+testTargetUserID+"/ban", `{"reason":"threats"}`, models.RoleAdmin)
+
+	handler.BanUser(ctx)
+
+	code, body := result()
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"state":"banned","reason":"threats"}`, body)
+
+	// The ban applies to the user's next request on this server
+	mock.ExpectQuery("SELECT banned_at IS NOT NULL").
+		WithArgs(testTargetUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned", "ban_reason", "suspended_until", "suspension_reason"}).AddRow(true, "threats", nil, ""))
+	standing, err = cache.Standing(context.Background(), testTargetUserID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AccountBanned, standing.State)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationHandler_BanUser_MissingReason(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewModerationHandler(db, events.NewMemoryBus(), accounts.NewCache(db, accounts.DefaultCacheTTL))
+
+	c, result := newAccountRequest("PUT", "/admin/users/"+testTargetUserID+"/ban", `{}`, models.RoleAdmin)
+
+	testutil.Serve(t, c, handler.BanUser)
+
+	code, _ := result()
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
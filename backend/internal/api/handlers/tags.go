@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/content"
+	"why-backend/internal/models"
+)
+
+var tagTracer = otel.Tracer("why-backend/handlers/tags")
+
+// maxTagSuggestions caps the autocomplete list returned by ListTags
+const maxTagSuggestions = 10
+
+type TagHandler struct {
+	db *sql.DB
+}
+
+func NewTagHandler(db *sql.DB) *TagHandler {
+	return &TagHandler{db: db}
+}
+
+// listedUnderTag is an SQL condition that holds when message m is listed on
+// tag pages and counted in tag suggestions
+func listedUnderTag() string {
+	return `m.visibility = '` + models.VisibilityPublic + `' AND m.hidden_at IS NULL AND ` + notBanned("m.user_id") + ` AND ` + unfiltered("m", "")
+}
+
+// ListTags returns the most used tags starting with ?prefix=, for autocomplete.
+// Only tags on messages a tag page lists are suggested, so hashtags used only
+// in followers-only, hidden or filtered messages stay private.
+func (h *TagHandler) ListTags(c *gin.Context) {
+	ctx, span := tagTracer.Start(c.Request.Context(), "ListTags")
+	defer span.End()
+
+	prefix := strings.ToLower(strings.TrimPrefix(c.Query("prefix"), "#"))
+	span.SetAttributes(attribute.String("tags.prefix", prefix))
+
+	// '_' is valid in tags but is a LIKE wildcard
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT t.name, COUNT(m.id)
+		 FROM tags t
+		 JOIN message_tags mt ON mt.tag = t.name
+		 JOIN messages m ON m.id = mt.message_id
+		 WHERE t.name LIKE $1 AND `+listedUnderTag()+`
+		 GROUP BY t.name
+		 ORDER BY COUNT(m.id) DESC, t.name ASC
+		 LIMIT $2`,
+		pattern, maxTagSuggestions,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list tags", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to list tags"))
+		return
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.Name, &tag.MessageCount); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan tag", "error", err)
+			continue
+		}
+		tags = append(tags, tag)
+	}
+
+	span.SetAttributes(attribute.Int("tags.count", len(tags)))
+	c.JSON(http.StatusOK, tags)
+}
+
+// ListTagMessages returns public messages carrying a tag, newest first, with
+// cursor pagination
+func (h *TagHandler) ListTagMessages(c *gin.Context) {
+	ctx, span := tagTracer.Start(c.Request.Context(), "ListTagMessages")
+	defer span.End()
+
+	tag := content.NormalizeTag(c.Param("tag"))
+	if tag == "" {
+		apierror.Abort(c, apierror.BadRequest("invalid tag"))
+		return
+	}
+	span.SetAttributes(attribute.String("tag", tag))
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	query := `SELECT ` + messageColumns + `
+		 FROM messages m
+		 JOIN message_tags t ON t.message_id = m.id
+		 WHERE t.tag = $1 AND ` + listedUnderTag()
+	args := []any{tag}
+	if page.Cursor != nil {
+		query += ` AND (m.created_at, m.id) < ($3, $4)`
+		args = append(args, page.Limit+1, page.Cursor.CreatedAt, page.Cursor.ID)
+	} else {
+		args = append(args, page.Limit+1)
+	}
+	query += ` ORDER BY m.created_at DESC, m.id DESC LIMIT $2`
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list tag messages", "error", err, "tag", tag)
+		apierror.Abort(c, apierror.Internal("failed to list messages"))
+		return
+	}
+	defer rows.Close()
+
+	result := models.MessagePage{Messages: []models.Message{}}
+	for rows.Next() {
+		var msg models.Message
+		if err := scanMessage(rows, &msg); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan message", "error", err)
+			continue
+		}
+		result.Messages = append(result.Messages, msg)
+	}
+
+	if len(result.Messages) > page.Limit {
+		result.Messages = result.Messages[:page.Limit]
+		last := result.Messages[page.Limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	span.SetAttributes(attribute.Int("messages.count", len(result.Messages)))
+	c.JSON(http.StatusOK, result)
+}
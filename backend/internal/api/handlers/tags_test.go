@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+// listTagsQuery matches the suggestions query: only messages a tag page lists
+// are joined, so a tag used on none of them is not returned at all
+const listTagsQuery = "SELECT t.name, COUNT\\(m.id\\) FROM tags t JOIN message_tags mt ON mt.tag = t.name JOIN messages m ON m.id = mt.message_id " +
+	"WHERE t.name LIKE \\$1 AND m.visibility = 'public' AND m.hidden_at IS NULL .* AND m.filter_action IS NULL GROUP BY t.name"
+
+func TestTagHandler_ListTags_Prefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewTagHandler(db)
+
+	rows := sqlmock.NewRows([]string{"name", "count"}).
+		AddRow("web_dev", 7).
+		AddRow("web_3", 2)
+
+	// '#' is stripped, case folded and '_' escaped for LIKE
+	mock.ExpectQuery(listTagsQuery).
+		WithArgs(`web\_%`, maxTagSuggestions).
+		WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/tags?prefix=%23Web_", nil)
+
+	testutil.Serve(t, c, handler.ListTags)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []models.Tag
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Len(t, response, 2)
+	assert.Equal(t, "web_dev", response[0].Name)
+	assert.Equal(t, 7, response[0].MessageCount)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestTagHandler_ListTags_OmitsUnlistedTags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewTagHandler(db)
+
+	// #launch_plans is used only on a followers-only message, so the inner
+	// joins leave it out and only the public #launch comes back
+	mock.ExpectQuery(listTagsQuery).
+		WithArgs(`launch%`, maxTagSuggestions).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count"}).AddRow("launch", 1))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/tags?prefix=launch", nil)
+
+	testutil.Serve(t, c, handler.ListTags)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []models.Tag
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Tag{{Name: "launch", MessageCount: 1}}, response)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestTagHandler_ListTagMessages_Paginates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewTagHandler(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"}).
+		AddRow("5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "user-1", "Third #go", pq.StringArray{}, nil, pq.StringArray{"go"}, `[]`, now, now, "public").
+		AddRow("5f0c6a1e-7d2b-4a8e-9c3f-000000000002", "user-1", "Second #go", pq.StringArray{}, nil, pq.StringArray{"go"}, `[]`, now.Add(-time.Minute), now, "public").
+		AddRow("5f0c6a1e-7d2b-4a8e-9c3f-000000000001", "user-1", "First #go", pq.StringArray{}, nil, pq.StringArray{"go"}, `[]`, now.Add(-2*time.Minute), now, "public")
+
+	// limit+1 rows are requested to detect a following page
+	mock.ExpectQuery("FROM messages m JOIN message_tags t ON t.message_id = m.id WHERE t.tag = \\$1 AND m.visibility = 'public' AND m.hidden_at IS NULL "+
+		"AND NOT EXISTS \\(SELECT 1 FROM users bu WHERE bu.id = m.user_id AND bu.banned_at IS NOT NULL\\) AND m.filter_action IS NULL ORDER BY").
+		WithArgs("go", 3).
+		WillReturnRows(rows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/tags/Go/messages?limit=2", nil)
+	c.Params = gin.Params{{Key: "tag", Value: "Go"}}
+
+	testutil.Serve(t, c, handler.ListTagMessages)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.MessagePage
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Len(t, response.Messages, 2)
+	assert.Equal(t, []string{"go"}, []string(response.Messages[0].Tags))
+	require.NotEmpty(t, response.NextCursor)
+
+	cursor, err := decodeCursor(response.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, "5f0c6a1e-7d2b-4a8e-9c3f-000000000002", cursor.ID)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestTagHandler_ListTagMessages_WithCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewTagHandler(db)
+
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	lastID := "5f0c6a1e-7d2b-4a8e-9c3f-000000000002"
+
+	mock.ExpectQuery("WHERE t.tag = \\$1 AND m.visibility = 'public' AND m.hidden_at IS NULL AND NOT EXISTS .+\\) AND m.filter_action IS NULL AND \\(m.created_at, m.id\\) < \\(\\$3, \\$4\\)").
+		WithArgs("go", defaultPageSize+1, createdAt, lastID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"}))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/tags/go/messages?cursor="+encodeCursor(createdAt, lastID), nil)
+	c.Params = gin.Params{{Key: "tag", Value: "go"}}
+
+	testutil.Serve(t, c, handler.ListTagMessages)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"messages":[]}`, w.Body.String())
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestTagHandler_ListTagMessages_InvalidTag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewTagHandler(db)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/tags/not-a-tag/messages", nil)
+	c.Params = gin.Params{{Key: "tag", Value: "not-a-tag"}}
+
+	testutil.Serve(t, c, handler.ListTagMessages)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+	"why-backend/internal/timeline"
+)
+
+var timelineTracer = otel.Tracer("why-backend/handlers/timeline")
+
+type TimelineHandler struct {
+	db     *sql.DB
+	reader timeline.Reader
+}
+
+func NewTimelineHandler(db *sql.DB, reader timeline.Reader) *TimelineHandler {
+	return &TimelineHandler{db: db, reader: reader}
+}
+
+// Home returns messages from the users the authenticated user follows,
+// newest first, with cursor pagination
+func (h *TimelineHandler) Home(c *gin.Context) {
+	ctx, span := timelineTracer.Start(c.Request.Context(), "Home")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	var before *timeline.Entry
+	if page.Cursor != nil {
+		before = &timeline.Entry{MessageID: page.Cursor.ID, CreatedAt: page.Cursor.CreatedAt}
+	}
+
+	entries, err := h.reader.Home(ctx, userID.(string), before, page.Limit+1)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to read home timeline", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to get timeline"))
+		return
+	}
+
+	result := models.MessagePage{Messages: []models.Message{}}
+	if len(entries) > page.Limit {
+		entries = entries[:page.Limit]
+		last := entries[page.Limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.MessageID)
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.MessageID
+	}
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT `+messageColumns+` FROM messages m WHERE m.id = ANY($1) AND m.hidden_at IS NULL AND `+notBanned("m.user_id")+` AND `+visibleTo("m.user_id", "$2")+` AND `+unfiltered("m", "$2"),
+		pq.Array(ids), userID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to load timeline messages", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to get timeline"))
+		return
+	}
+	defer rows.Close()
+
+	byID := make(map[string]models.Message, len(ids))
+	for rows.Next() {
+		var msg models.Message
+		if err := scanMessage(rows, &msg); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan message", "error", err)
+			continue
+		}
+		byID[msg.ID] = msg
+	}
+
+	// Keep the timeline's order; messages deleted or hidden since it was read
+	// or by muted users are skipped
+	for _, e := range entries {
+		if msg, ok := byID[e.MessageID]; ok {
+			result.Messages = append(result.Messages, msg)
+		}
+	}
+
+	span.SetAttributes(attribute.Int("messages.count", len(result.Messages)))
+	c.JSON(http.StatusOK, result)
+}
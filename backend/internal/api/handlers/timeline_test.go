@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+	"why-backend/internal/timeline"
+)
+
+// stubTimeline returns fixed entries and records what it was asked for
+type stubTimeline struct {
+	entries []timeline.Entry
+	err     error
+	before  *timeline.Entry
+	limit   int
+}
+
+func (s *stubTimeline) Home(ctx context.Context, userID string, before *timeline.Entry, limit int) ([]timeline.Entry, error) {
+	s.before, s.limit = before, limit
+	return s.entries, s.err
+}
+
+func newTimelineRequest(target string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", target, nil)
+	c.Set("user_id", "user-123")
+	return w, c
+}
+
+func TestTimelineHandler_Home(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	ids := []string{
+		"5f0c6a1e-7d2b-4a8e-9c3f-000000000003",
+		"5f0c6a1e-7d2b-4a8e-9c3f-000000000002",
+		"5f0c6a1e-7d2b-4a8e-9c3f-000000000001",
+	}
+	reader := &stubTimeline{entries: []timeline.Entry{
+		{MessageID: ids[0], CreatedAt: now},
+		{MessageID: ids[1], CreatedAt: now.Add(-time.Minute)},
+		{MessageID: ids[2], CreatedAt: now.Add(-2 * time.Minute)},
+	}}
+	handler := NewTimelineHandler(db, reader)
+
+	// Rows come back in any order; the second message has been deleted
+	mock.ExpectQuery("FROM messages m WHERE m.id = ANY\\(\\$1\\) AND m.hidden_at IS NULL AND NOT EXISTS .+user_mutes").
+		WithArgs(pq.Array(ids[:2]), "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"}).
+			AddRow(ids[0], "user-1", "First", pq.StringArray{}, nil, pq.StringArray{}, `[]`, now, now, "public"))
+
+	w, c := newTimelineRequest("/timeline/home?limit=2")
+	testutil.Serve(t, c, handler.Home)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, reader.limit)
+	assert.Nil(t, reader.before)
+
+	var response models.MessagePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Messages, 1)
+	assert.Equal(t, ids[0], response.Messages[0].ID)
+	require.NotEmpty(t, response.NextCursor)
+
+	cursor, err := decodeCursor(response.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, ids[1], cursor.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTimelineHandler_Home_Cursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	reader := &stubTimeline{}
+	handler := NewTimelineHandler(db, reader)
+
+	cursorTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	w, c := newTimelineRequest("/timeline/home?cursor=" + encodeCursor(cursorTime, "5f0c6a1e-7d2b-4a8e-9c3f-000000000002"))
+	testutil.Serve(t, c, handler.Home)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"messages":[]}`, w.Body.String())
+	require.NotNil(t, reader.before)
+	assert.Equal(t, "5f0c6a1e-7d2b-4a8e-9c3f-000000000002", reader.before.MessageID)
+	assert.True(t, reader.before.CreatedAt.Equal(cursorTime))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTimelineHandler_Home_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewTimelineHandler(db, &stubTimeline{err: errors.New("boom")})
+
+	w, c := newTimelineRequest("/timeline/home?cursor=bogus")
+	testutil.Serve(t, c, handler.Home)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, c = newTimelineRequest("/timeline/home")
+	testutil.Serve(t, c, handler.Home)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
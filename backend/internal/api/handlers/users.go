@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/content"
+	"why-backend/internal/models"
+)
+
+var userTracer = otel.Tracer("why-backend/handlers/users")
+
+type UserHandler struct {
+	db *sql.DB
+}
+
+func NewUserHandler(db *sql.DB) *UserHandler {
+	return &UserHandler{db: db}
+}
+
+// GetMe returns the authenticated user
+func (h *UserHandler) GetMe(c *gin.Context) {
+	ctx, span := userTracer.Start(c.Request.Context(), "GetMe")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	var user models.User
+	err := h.db.QueryRowContext(ctx,
+		`SELECT id, email, handle, role, created_at, updated_at FROM users WHERE id = $1`,
+		userID,
+	).Scan(&user.ID, &user.Email, &user.Handle, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get user", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to get user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// GetUser returns a user's public profile with follower and following counts
+func (h *UserHandler) GetUser(c *gin.Context) {
+	ctx, span := userTracer.Start(c.Request.Context(), "GetUser")
+	defer span.End()
+
+	userID := c.Param("id")
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	if _, err := uuid.Parse(userID); err != nil {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	}
+
+	var profile models.Profile
+	err := h.db.QueryRowContext(ctx,
+		`SELECT id, handle, follower_count, following_count, created_at FROM users WHERE id = $1`,
+		userID,
+	).Scan(&profile.ID, &profile.Handle, &profile.FollowerCount, &profile.FollowingCount, &profile.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get user", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to get user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// UpdateHandle sets the handle other users @mention the authenticated user by
+func (h *UserHandler) UpdateHandle(c *gin.Context) {
+	ctx, span := userTracer.Start(c.Request.Context(), "UpdateHandle")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	var req models.UpdateHandleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if !content.IsValidHandle(req.Handle) {
+		apierror.Abort(c, apierror.BadRequest("handle must be 1-30 letters, digits or underscores"))
+		return
+	}
+
+	var user models.User
+	err := h.db.QueryRowContext(ctx,
+		`UPDATE users SET handle = $1, updated_at = NOW() WHERE id = $2
+		 RETURNING id, email, handle, role, created_at, updated_at`,
+		req.Handle, userID,
+	).Scan(&user.ID, &user.Email, &user.Handle, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+
+	if isUniqueViolation(err, "idx_users_handle") {
+		apierror.Abort(c, apierror.New(http.StatusConflict, apierror.CodeHandleTaken, "handle already taken"))
+		return
+	} else if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("user not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to update handle", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to update handle"))
+		return
+	}
+
+	slog.InfoContext(ctx, "Handle updated", "user_id", userID, "handle", req.Handle)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation on the named index
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+func TestUserHandler_UpdateHandle_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewUserHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE users SET handle").
+		WithArgs("Alice_1", "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "handle", "role", "created_at", "updated_at"}).
+			AddRow("user-123", "alice@example.com", "Alice_1", models.RoleUser, now, now))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/me/handle", bytes.NewBufferString(`{"handle":"Alice_1"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UpdateHandle)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.User
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.NotNil(t, response.Handle)
+	assert.Equal(t, "Alice_1", *response.Handle)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestUserHandler_UpdateHandle_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewUserHandler(db)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/me/handle", bytes.NewBufferString(`{"handle":"not valid!"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UpdateHandle)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUserHandler_UpdateHandle_Taken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewUserHandler(db)
+
+	mock.ExpectQuery("UPDATE users SET handle").
+		WithArgs("alice", "user-123").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_users_handle"})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/me/handle", bytes.NewBufferString(`{"handle":"alice"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.UpdateHandle)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "handle already taken")
+}
+
+func TestUserHandler_GetMe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewUserHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, email, handle, role, created_at, updated_at FROM users WHERE id").
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "handle", "role", "created_at", "updated_at"}).
+			AddRow("user-123", "alice@example.com", nil, models.RoleUser, now, now))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/me", nil)
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.GetMe)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"handle":null`)
+}
+
+func TestUserHandler_GetUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewUserHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, handle, follower_count, following_count, created_at FROM users WHERE id = \\$1").
+		WithArgs("3c2b1a00-0000-4000-8000-000000000001").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "handle", "follower_count", "following_count", "created_at"}).
+			AddRow("3c2b1a00-0000-4000-8000-000000000001", "alice", 12, 3, now))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/users/3c2b1a00-0000-4000-8000-000000000001", nil)
+	c.Params = gin.Params{{Key: "id", Value: "3c2b1a00-0000-4000-8000-000000000001"}}
+
+	testutil.Serve(t, c, handler.GetUser)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"follower_count":12`)
+	assert.Contains(t, w.Body.String(), `"following_count":3`)
+	assert.NotContains(t, w.Body.String(), "email")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/trace"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/content"
+	"why-backend/internal/models"
+)
+
+// fieldErrors collects what is wrong with a request, field by field
+type fieldErrors []models.FieldError
+
+func (e *fieldErrors) add(field, format string, args ...any) {
+	*e = append(*e, models.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// respond fails the request with the errors
+func (e fieldErrors) respond(c *gin.Context) {
+	apierror.Abort(c, apierror.Validation(e))
+}
+
+// bindJSON binds the request body into req, a pointer to a request struct.
+// It fails the request and returns false when the body is malformed, over
+// the size limit middleware.BodyLimit set, or fails validation, naming each invalid field as the client sent it rather than
+// passing on the decoder's or validator's messages.
+func bindJSON(c *gin.Context, req any) bool {
+	err := c.ShouldBindJSON(req)
+	if err == nil {
+		return true
+	}
+	trace.SpanFromContext(c.Request.Context()).RecordError(err)
+
+	var (
+		invalid   validator.ValidationErrors
+		typeError *json.UnmarshalTypeError
+		tooLarge  *http.MaxBytesError
+		errs      fieldErrors
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge,
+			fmt.Sprintf("request body must be at most %d bytes", tooLarge.Limit)))
+		return false
+	case errors.As(err, &invalid):
+		t := reflect.TypeOf(req).Elem()
+		for _, fe := range invalid {
+			errs.add(jsonName(t, fe.StructField()), "%s", validationMessage(fe))
+		}
+	case errors.As(err, &typeError):
+		errs.add(typeError.Field, "must be %s", jsonType(typeError.Type))
+	case errors.Is(err, io.EOF):
+		apierror.Abort(c, apierror.BadRequest("request body is required"))
+		return false
+	default:
+		apierror.Abort(c, apierror.BadRequest("request body is not valid JSON"))
+		return false
+	}
+	errs.respond(c)
+	return false
+}
+
+// jsonType names the JSON type a Go type decodes from
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// jsonName is the JSON name of field in struct type t
+func jsonName(t reflect.Type, field string) string {
+	f, ok := t.FieldByName(field)
+	if !ok {
+		return field
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field
+	}
+	return name
+}
+
+// validationMessage says which rule a field broke
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "email":
+		return "must be an email address"
+	case "url":
+		return "must be a URL"
+	case "uuid":
+		return "must be a UUID"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min", "max":
+		kind := fe.Kind()
+		if kind == reflect.Ptr {
+			kind = fe.Type().Elem().Kind()
+		}
+		bound := "at least"
+		if fe.Tag() == "max" {
+			bound = "at most"
+		}
+		switch kind {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters", bound, fe.Param())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("must have %s %s items", bound, fe.Param())
+		}
+		return fmt.Sprintf("must be %s %s", bound, fe.Param())
+	}
+	return "is invalid"
+}
+
+// post is the content of a new or edited message, reply or direct message.
+// Text is normalized in place.
+type post struct {
+	UserID string
+	// ConversationID is set for direct messages, which may also attach media
+	// uploaded to their conversation
+	ConversationID string
+	Text           *string
+	MaxLength      int
+	MediaURLs      []string
+	MaxAttachments int
+}
+
+// validatePost checks a post against its limits and that its media was
+// uploaded by its author. It fails the request and returns false when the
+// post is invalid; it returns an error, without failing the request, when the
+// uploads cannot be checked.
+func validatePost(ctx context.Context, c *gin.Context, db dbtx, p post) (bool, error) {
+	var errs fieldErrors
+	*p.Text = content.Normalize(*p.Text)
+	if content.IsBlank(*p.Text) {
+		errs.add("content", "must not be blank")
+	} else if content.Length(*p.Text) > p.MaxLength {
+		errs.add("content", "must be at most %d characters", p.MaxLength)
+	}
+
+	if len(p.MediaURLs) > p.MaxAttachments {
+		errs.add("media_urls", "must have at most %d items", p.MaxAttachments)
+	} else if len(p.MediaURLs) > 0 {
+		uploaded, err := uploadedBy(ctx, db, p.UserID, p.ConversationID, p.MediaURLs)
+		if err != nil {
+			return false, err
+		}
+		for i, url := range p.MediaURLs {
+			if !uploaded[url] {
+				errs.add(fmt.Sprintf("media_urls[%d]", i), "must be a file you uploaded")
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		errs.respond(c)
+		return false, nil
+	}
+	return true, nil
+}
+
+// uploadedBy returns which of urls userID uploaded, outside any conversation
+// or to conversationID
+func uploadedBy(ctx context.Context, db dbtx, userID, conversationID string, urls []string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT url FROM media_uploads
+		 WHERE url = ANY($1) AND user_id = $2 AND (conversation_id IS NULL OR conversation_id = NULLIF($3, '')::uuid)`,
+		pq.Array(urls), userID, conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uploaded := make(map[string]bool, len(urls))
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		uploaded[url] = true
+	}
+	return uploaded, rows.Err()
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/content"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+	"why-backend/internal/testutil"
+)
+
+func newValidationRequest(path, body string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+	c.Params = gin.Params{{Key: "id", Value: testMessageID}}
+	return w, c
+}
+
+func fieldErrorsOf(t *testing.T, w *httptest.ResponseRecorder) []models.FieldError {
+	var response struct {
+		Code   string              `json:"code"`
+		Errors []models.FieldError `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, apierror.CodeValidationFailed, response.Code)
+	return response.Errors
+}
+
+func TestMessageHandler_CreateMessage_FieldErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limits := content.Limits{MessageLength: 5, ReplyLength: 3, Attachments: 2}
+
+	tests := []struct {
+		name     string
+		body     string
+		expected []models.FieldError
+	}{
+		{
+			name: "missing content and bad visibility",
+			body: `{"visibility":"secret"}`,
+			expected: []models.FieldError{
+				{Field: "content", Message: "is required"},
+				{Field: "visibility", Message: "must be one of: public, unlisted, followers"},
+			},
+		},
+		{
+			name:     "whitespace only",
+			body:     `{"content":" \u200b\n\t"}`,
+			expected: []models.FieldError{{Field: "content", Message: "must not be blank"}},
+		},
+		{
+			name:     "too long",
+			body:     `{"content":"hello!"}`,
+			expected: []models.FieldError{{Field: "content", Message: "must be at most 5 characters"}},
+		},
+		{
+			name: "too many attachments",
+			body: `{"content":"hello","media_urls":["a","b","c"]}`,
+			expected: []models.FieldError{
+				{Field: "media_urls", Message: "must have at most 2 items"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.SetupTestDB(t)
+			defer db.Close()
+
+			handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, limits)
+			w, c := newValidationRequest("/messages", tt.body)
+
+			testutil.Serve(t, c, handler.CreateMessage)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, tt.expected, fieldErrorsOf(t, w))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMessageHandler_CreateMessage_NormalizesContent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	// Five characters once the accent composes, and one emoji of two code points
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.Limits{MessageLength: 5, ReplyLength: 5, Attachments: 1})
+
+	mock.ExpectBegin()
+	expectInsertMessage(mock, "caf\u00e9\U0001F44B\U0001F3FD")
+	mock.ExpectCommit()
+
+	w, c := newValidationRequest("/messages", `{"content":"  cafe\u0301\ud83d\udc4b\ud83c\udffd  "}`)
+
+	testutil.Serve(t, c, handler.CreateMessage)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_CreateReply_MediaNotUploaded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+	mine := "http://localhost:9000/why-media/mine.png"
+	other := "https://elsewhere.example/tracker.gif"
+
+	mock.ExpectQuery("SELECT url FROM media_uploads WHERE url = ANY\\(\\$1\\) AND user_id = \\$2").
+		WithArgs(pq.Array([]string{other, mine}), "user-123", "").
+		WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow(mine))
+
+	w, c := newValidationRequest("/messages/"+testMessageID+"/replies", `{"content":"look","media_urls":["`+other+`","`+mine+`"]}`)
+
+	testutil.Serve(t, c, handler.CreateReply)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []models.FieldError{{Field: "media_urls[0]", Message: "must be a file you uploaded"}}, fieldErrorsOf(t, w))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBindJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedCode   string
+		expectedFields []models.FieldError
+	}{
+		{
+			name:           "validation failures name the JSON fields",
+			body:           `{"email":"not-an-email","password":"short"}`,
+			expectedCode:   apierror.CodeValidationFailed,
+			expectedFields: []models.FieldError{{Field: "email", Message: "must be an email address"}, {Field: "password", Message: "must be at least 8 characters"}},
+		},
+		{
+			name:           "wrong type",
+			body:           `{"email":5,"password":"password123"}`,
+			expectedCode:   apierror.CodeValidationFailed,
+			expectedFields: []models.FieldError{{Field: "email", Message: "must be a string"}},
+		},
+		{
+			name:         "malformed",
+			body:         `{"email":`,
+			expectedCode: apierror.CodeInvalidRequest,
+		},
+		{
+			name:         "empty",
+			body:         ``,
+			expectedCode: apierror.CodeInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, c := newValidationRequest("/signup", tt.body)
+
+			var req models.SignupRequest
+			testutil.Serve(t, c, func(c *gin.Context) {
+				assert.False(t, bindJSON(c, &req))
+			})
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var response struct {
+				Code   string              `json:"code"`
+				Detail string              `json:"detail"`
+				Errors []models.FieldError `json:"errors"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedCode, response.Code)
+			assert.Equal(t, tt.expectedFields, response.Errors)
+			assert.NotContains(t, response.Detail, "SignupRequest")
+		})
+	}
+}
+
+func TestBindJSON_TooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w, c := newValidationRequest("/signup", `{"email":"someone@example.com","password":"password123"}`)
+	// As middleware.BodyLimit leaves it
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 16)
+
+	var req models.SignupRequest
+	testutil.Serve(t, c, func(c *gin.Context) {
+		assert.False(t, bindJSON(c, &req))
+	})
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, apierror.CodeRequestTooLarge, response["code"])
+	assert.Equal(t, "request body must be at most 16 bytes", response["detail"])
+}
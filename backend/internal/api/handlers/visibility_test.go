@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/content"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+	"why-backend/internal/testutil"
+)
+
+func TestMessageHandler_CreateMessage_FollowersOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, moderation.Chain{}, content.DefaultLimits)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO messages \\(user_id, content, media_urls, mentions, visibility\\)").
+		WithArgs("user-123", "Only for my followers", sqlmock.AnyArg(), sqlmock.AnyArg(), models.VisibilityFollowers).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "mentions", "created_at", "updated_at", "visibility"}).
+			AddRow("msg-123", "user-123", "Only for my followers", pq.StringArray{}, nil, `[]`, now, now, models.VisibilityFollowers))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages", bytes.NewBufferString(`{"content":"Only for my followers","visibility":"followers"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.CreateMessage)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.Message
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.VisibilityFollowers, response.Visibility)
+
+	// Nothing that fans out to the public: stream, webhooks, search
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_CreateMessage_InvalidVisibility(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages", bytes.NewBufferString(`{"content":"hello","visibility":"secret"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.CreateMessage)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_ListMessages_AnonymousSeesPublicOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("FROM messages m WHERE m.visibility = 'public' AND m.hidden_at IS NULL " +
+		"AND NOT EXISTS \\(SELECT 1 FROM users bu WHERE bu.id = m.user_id AND bu.banned_at IS NOT NULL\\) AND m.filter_action IS NULL ORDER BY").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages", nil)
+
+	testutil.Serve(t, c, handler.ListMessages)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_ListMessages_ViewerSeesFollowedAndMentioned(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectQuery("FROM messages m WHERE m.visibility <> 'unlisted' " +
+		"AND m.hidden_at IS NULL AND \\(m.visibility <> 'followers' OR m.user_id = \\$1::uuid " +
+		"OR EXISTS \\(SELECT 1 FROM follows f WHERE f.follower_id = \\$1::uuid AND f.followee_id = m.user_id\\) " +
+		"OR m.mentions @> jsonb_build_array\\(jsonb_build_object\\('user_id', \\$1::uuid::text\\)\\)\\)").
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages", nil)
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.ListMessages)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_GetMessage_AnonymousFollowersOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewMessageHandler(db, events.NewMemoryBus(), moderation.Chain{}, content.DefaultLimits)
+
+	// Unlisted messages stay reachable by link, followers-only ones do not
+	mock.ExpectQuery("FROM messages m WHERE m.id = \\$1 AND m.hidden_at IS NULL AND m.visibility <> 'followers'").
+		WithArgs("msg-123").
+		WillReturnError(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/messages/msg-123", nil)
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}}
+
+	testutil.Serve(t, c, handler.GetMessage)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageHandler_CreateReply_NotReadable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := events.NewMemoryBus()
+	handler := NewMessageHandler(db, bus, moderation.Chain{}, content.DefaultLimits)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.user_id, m.visibility FROM messages m WHERE m.id = \\$1 AND m.hidden_at IS NULL AND \\(m.visibility <> 'followers' OR .+\\) FOR SHARE").
+		WithArgs("msg-123", "user-123").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/messages/msg-123/replies", bytes.NewBufferString(`{"content":"hello"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "msg-123"}}
+	c.Set("user_id", "user-123")
+
+	testutil.Serve(t, c, handler.CreateReply)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+	"why-backend/internal/webhooks"
+)
+
+var webhookTracer = otel.Tracer("why-backend/handlers/webhooks")
+
+const webhookColumns = `id, user_id, url, event_types, active, failure_count, disabled_at, created_at, updated_at`
+
+type WebhookHandler struct {
+	db *sql.DB
+}
+
+func NewWebhookHandler(db *sql.DB) *WebhookHandler {
+	return &WebhookHandler{db: db}
+}
+
+func scanWebhook(row rowScanner, w *models.Webhook) error {
+	return row.Scan(&w.ID, &w.UserID, &w.URL, &w.EventTypes, &w.Active, &w.FailureCount,
+		&w.DisabledAt, &w.CreatedAt, &w.UpdatedAt)
+}
+
+// validateWebhook returns a client error for an unusable URL or event type.
+// A nil URL is left unchecked.
+func validateWebhook(rawURL *string, eventTypes []string) string {
+	if rawURL != nil {
+		u, err := url.Parse(*rawURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return "url must be an http or https URL"
+		}
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(webhooks.EventTypes, eventType) {
+			return "unknown event type: " + eventType
+		}
+	}
+	return ""
+}
+
+// CreateWebhook registers an endpoint for the authenticated user. The
+// response carries the signing secret, which is not shown again.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	ctx, span := webhookTracer.Start(c.Request.Context(), "CreateWebhook")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	var req models.CreateWebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if msg := validateWebhook(&req.URL, req.EventTypes); msg != "" {
+		apierror.Abort(c, apierror.BadRequest(msg))
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to generate webhook secret", "error", err)
+		apierror.Abort(c, apierror.Internal("failed to create webhook"))
+		return
+	}
+
+	var webhook models.Webhook
+	err = scanWebhook(h.db.QueryRowContext(ctx,
+		`INSERT INTO webhooks (user_id, url, secret, event_types)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING `+webhookColumns,
+		userID, req.URL, secret, pq.Array(req.EventTypes),
+	), &webhook)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to create webhook", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to create webhook"))
+		return
+	}
+	webhook.Secret = secret
+
+	slog.InfoContext(ctx, "Webhook created", "webhook_id", webhook.ID, "user_id", userID)
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// ListWebhooks returns the authenticated user's webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	ctx, span := webhookTracer.Start(c.Request.Context(), "ListWebhooks")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE user_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list webhooks", "error", err, "user_id", userID)
+		apierror.Abort(c, apierror.Internal("failed to list webhooks"))
+		return
+	}
+	defer rows.Close()
+
+	result := []models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan webhook", "error", err)
+			continue
+		}
+		result = append(result, webhook)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetWebhook returns one of the authenticated user's webhooks
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	ctx, span := webhookTracer.Start(c.Request.Context(), "GetWebhook")
+	defer span.End()
+
+	webhookID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("webhook.id", webhookID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		apierror.Abort(c, apierror.NotFound("webhook not found"))
+		return
+	}
+
+	var webhook models.Webhook
+	err := scanWebhook(h.db.QueryRowContext(ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1 AND user_id = $2`,
+		webhookID, userID,
+	), &webhook)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("webhook not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to get webhook", "error", err, "webhook_id", webhookID)
+		apierror.Abort(c, apierror.Internal("failed to get webhook"))
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook changes a webhook's URL, event types or active flag.
+// Reactivating a webhook clears its failure count.
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	ctx, span := webhookTracer.Start(c.Request.Context(), "UpdateWebhook")
+	defer span.End()
+
+	webhookID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("webhook.id", webhookID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		apierror.Abort(c, apierror.NotFound("webhook not found"))
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if msg := validateWebhook(req.URL, req.EventTypes); msg != "" {
+		apierror.Abort(c, apierror.BadRequest(msg))
+		return
+	}
+
+	var eventTypes any
+	if req.EventTypes != nil {
+		eventTypes = pq.Array(req.EventTypes)
+	}
+
+	var webhook models.Webhook
+	err := scanWebhook(h.db.QueryRowContext(ctx,
+		`UPDATE webhooks SET
+			url = COALESCE($3, url),
+			event_types = COALESCE($4, event_types),
+			failure_count = CASE WHEN $5 AND NOT active THEN 0 ELSE failure_count END,
+			disabled_at = CASE WHEN $5 THEN NULL ELSE disabled_at END,
+			active = COALESCE($5, active),
+			updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2
+		 RETURNING `+webhookColumns,
+		webhookID, userID, req.URL, eventTypes, req.Active,
+	), &webhook)
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.NotFound("webhook not found"))
+		return
+	} else if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to update webhook", "error", err, "webhook_id", webhookID)
+		apierror.Abort(c, apierror.Internal("failed to update webhook"))
+		return
+	}
+
+	slog.InfoContext(ctx, "Webhook updated", "webhook_id", webhookID, "user_id", userID)
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	ctx, span := webhookTracer.Start(c.Request.Context(), "DeleteWebhook")
+	defer span.End()
+
+	webhookID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("webhook.id", webhookID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		apierror.Abort(c, apierror.NotFound("webhook not found"))
+		return
+	}
+
+	result, err := h.db.ExecContext(ctx,
+		`DELETE FROM webhooks WHERE id = $1 AND user_id = $2`,
+		webhookID, userID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to delete webhook", "error", err, "webhook_id", webhookID)
+		apierror.Abort(c, apierror.Internal("failed to delete webhook"))
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		apierror.Abort(c, apierror.NotFound("webhook not found"))
+		return
+	}
+
+	slog.InfoContext(ctx, "Webhook deleted", "webhook_id", webhookID, "user_id", userID)
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries returns a webhook's delivery log, newest first, with cursor
+// pagination. ?status= filters by pending, succeeded or failed.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	ctx, span := webhookTracer.Start(c.Request.Context(), "ListDeliveries")
+	defer span.End()
+
+	webhookID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(
+		attribute.String("webhook.id", webhookID),
+		attribute.String("user.id", userID.(string)),
+	)
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		apierror.Abort(c, apierror.NotFound("webhook not found"))
+		return
+	}
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != webhooks.StatusPending && status != webhooks.StatusSucceeded && status != webhooks.StatusFailed {
+		apierror.Abort(c, apierror.BadRequest("invalid status"))
+		return
+	}
+
+	var exists bool
+	err = h.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1 AND user_id = $2)`,
+		webhookID, userID,
+	).Scan(&exists)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to check webhook", "error", err, "webhook_id", webhookID)
+		apierror.Abort(c, apierror.Internal("failed to list deliveries"))
+		return
+	}
+	if !exists {
+		apierror.Abort(c, apierror.NotFound("webhook not found"))
+		return
+	}
+
+	query := `SELECT id, event_id, event_type, status, attempts, last_response_code, last_error,
+		 next_attempt_at, created_at, updated_at
+		 FROM webhook_deliveries
+		 WHERE webhook_id = $1`
+	args := []any{webhookID, page.Limit + 1}
+	if page.Cursor != nil {
+		query += ` AND (created_at, id) < ($3, $4)`
+		args = append(args, page.Cursor.CreatedAt, page.Cursor.ID)
+	}
+	if status != "" {
+		args = append(args, status)
+		query += ` AND status = $` + strconv.Itoa(len(args))
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT $2`
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to list deliveries", "error", err, "webhook_id", webhookID)
+		apierror.Abort(c, apierror.Internal("failed to list deliveries"))
+		return
+	}
+	defer rows.Close()
+
+	result := models.WebhookDeliveryPage{Deliveries: []models.WebhookDelivery{}}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.LastResponseCode,
+			&d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Failed to scan delivery", "error", err)
+			continue
+		}
+		result.Deliveries = append(result.Deliveries, d)
+	}
+
+	if len(result.Deliveries) > page.Limit {
+		result.Deliveries = result.Deliveries[:page.Limit]
+		last := result.Deliveries[page.Limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+const testWebhookID = "7a1d2c3e-4b5f-4a6b-8c7d-000000000001"
+
+var webhookRowColumns = []string{"id", "user_id", "url", "event_types", "active", "failure_count", "disabled_at", "created_at", "updated_at"}
+
+func newWebhookRequest(method, target, body string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "user-123")
+	return w, c
+}
+
+func TestWebhookHandler_CreateWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewWebhookHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO webhooks \\(user_id, url, secret, event_types\\)").
+		WithArgs("user-123", "https://example.com/hook", sqlmock.AnyArg(), pq.Array([]string{events.ReplyCreated})).
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(testWebhookID, "user-123", "https://example.com/hook", "{reply.created}", true, 0, nil, now, now))
+
+	w, c := newWebhookRequest("POST", "/webhooks", `{"url":"https://example.com/hook","event_types":["reply.created"]}`)
+	testutil.Serve(t, c, handler.CreateWebhook)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, testWebhookID, response.ID)
+	assert.Equal(t, []string{events.ReplyCreated}, []string(response.EventTypes))
+	assert.True(t, strings.HasPrefix(response.Secret, "whsec_"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookHandler_CreateWebhook_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewWebhookHandler(db)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing url", `{"event_types":["reply.created"]}`},
+		{"no event types", `{"url":"https://example.com/hook","event_types":[]}`},
+		{"unknown event type", `{"url":"https://example.com/hook","event_types":["user.deleted"]}`},
+		{"not http", `{"url":"ftp://example.com/hook","event_types":["reply.created"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, c := newWebhookRequest("POST", "/webhooks", tt.body)
+			testutil.Serve(t, c, handler.CreateWebhook)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookHandler_ListWebhooks_OmitsSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewWebhookHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, user_id, url, event_types, .+ FROM webhooks WHERE user_id = \\$1").
+		WithArgs("user-123").
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(testWebhookID, "user-123", "https://example.com/hook", "{message.created,reply.created}", false, 20, now, now, now))
+
+	w, c := newWebhookRequest("GET", "/webhooks", "")
+	testutil.Serve(t, c, handler.ListWebhooks)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret")
+
+	var response []models.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response, 1)
+	assert.False(t, response[0].Active)
+	assert.NotNil(t, response[0].DisabledAt)
+	assert.Len(t, response[0].EventTypes, 2)
+}
+
+func TestWebhookHandler_UpdateWebhook_Reactivate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewWebhookHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE webhooks SET .+ WHERE id = \\$1 AND user_id = \\$2").
+		WithArgs(testWebhookID, "user-123", nil, nil, true).
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(testWebhookID, "user-123", "https://example.com/hook", "{reply.created}", true, 0, nil, now, now))
+
+	w, c := newWebhookRequest("PATCH", "/webhooks/"+testWebhookID, `{"active":true}`)
+	c.Params = gin.Params{{Key: "id", Value: testWebhookID}}
+	testutil.Serve(t, c, handler.UpdateWebhook)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Active)
+	assert.Zero(t, response.FailureCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookHandler_DeleteWebhook_NotOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewWebhookHandler(db)
+
+	mock.ExpectExec("DELETE FROM webhooks WHERE id = \\$1 AND user_id = \\$2").
+		WithArgs(testWebhookID, "user-123").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	w, c := newWebhookRequest("DELETE", "/webhooks/"+testWebhookID, "")
+	c.Params = gin.Params{{Key: "id", Value: testWebhookID}}
+	testutil.Serve(t, c, handler.DeleteWebhook)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookHandler_ListDeliveries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewWebhookHandler(db)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM webhooks WHERE id = \\$1 AND user_id = \\$2\\)").
+		WithArgs(testWebhookID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT id, event_id, .+ FROM webhook_deliveries WHERE webhook_id = \\$1 AND status = \\$3 ORDER BY").
+		WithArgs(testWebhookID, 2, "failed").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "event_type", "status", "attempts", "last_response_code", "last_error", "next_attempt_at", "created_at", "updated_at"}).
+			AddRow("7a1d2c3e-4b5f-4a6b-8c7d-000000000003", 12, events.ReplyCreated, "failed", 8, 500, "endpoint responded 500 Internal Server Error", now, now, now).
+			AddRow("7a1d2c3e-4b5f-4a6b-8c7d-000000000002", 11, events.ReplyCreated, "failed", 8, nil, "connection refused", now, now.Add(-time.Minute), now))
+
+	w, c := newWebhookRequest("GET", "/webhooks/"+testWebhookID+"/deliveries?status=failed&limit=1", "")
+	c.Params = gin.Params{{Key: "id", Value: testWebhookID}}
+	testutil.Serve(t, c, handler.ListDeliveries)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.WebhookDeliveryPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Deliveries, 1)
+	assert.Equal(t, 500, *response.Deliveries[0].LastResponseCode)
+	assert.NotEmpty(t, response.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookHandler_ListDeliveries_UnknownWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewWebhookHandler(db)
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(testWebhookID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	w, c := newWebhookRequest("GET", "/webhooks/"+testWebhookID+"/deliveries", "")
+	c.Params = gin.Params{{Key: "id", Value: testWebhookID}}
+	testutil.Serve(t, c, handler.ListDeliveries)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
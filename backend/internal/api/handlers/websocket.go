@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"why-backend/internal/realtime"
+)
+
+var websocketTracer = otel.Tracer("why-backend/handlers/websocket")
+
+const (
+	// maxClientFrameSize bounds frames from clients, which are small commands
+	maxClientFrameSize = 4096
+	writeTimeout       = 10 * time.Second
+	pongTimeout        = 60 * time.Second
+	pingInterval       = pongTimeout * 9 / 10
+)
+
+// Client frame types
+const (
+	clientSubscribe   = "subscribe"
+	clientUnsubscribe = "unsubscribe"
+	clientTyping      = "typing"
+)
+
+// clientFrame is a command sent by a client
+type clientFrame struct {
+	Type      string `json:"type"`
+	MessageID string `json:"message_id"`
+}
+
+type WebSocketHandler struct {
+	db       *sql.DB
+	hub      *realtime.Hub
+	upgrader websocket.Upgrader
+}
+
+// NewWebSocketHandler accepts connections from allowedOrigins, and from
+// clients that send no Origin at all (non-browser clients)
+func NewWebSocketHandler(db *sql.DB, hub *realtime.Hub, allowedOrigins []string) *WebSocketHandler {
+	return &WebSocketHandler{
+		db:  db,
+		hub: hub,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || slices.Contains(allowedOrigins, origin)
+			},
+		},
+	}
+}
+
+// Serve upgrades an authenticated request to a WebSocket. Clients send
+// {"type": "subscribe" | "unsubscribe" | "typing", "message_id": ...} and
+// receive the events, typing signals and presence of the threads they follow.
+// A client too slow to keep up is closed with code 1013 (try again later).
+func (h *WebSocketHandler) Serve(c *gin.Context) {
+	ctx, span := websocketTracer.Start(c.Request.Context(), "WebSocket")
+	defer span.End()
+
+	userID, _ := c.Get("user_id")
+	span.SetAttributes(attribute.String("user.id", userID.(string)))
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already responded
+		span.RecordError(err)
+		return
+	}
+
+	client := h.hub.Connect(userID.(string))
+	go h.writeLoop(conn, client)
+
+	h.readLoop(ctx, conn, client)
+
+	// Presence must be withdrawn even though the request is over
+	client.Close(context.WithoutCancel(ctx))
+}
+
+func (h *WebSocketHandler) readLoop(ctx context.Context, conn *websocket.Conn, client *realtime.Client) {
+	conn.SetReadLimit(maxClientFrameSize)
+	conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+
+	for {
+		var frame clientFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) && !errors.Is(err, websocket.ErrCloseSent) {
+				slog.DebugContext(ctx, "WebSocket read failed", "error", err, "user_id", client.UserID)
+			}
+			return
+		}
+
+		if err := h.handleFrame(ctx, client, frame); err != nil {
+			client.Reply(realtime.Frame{Type: realtime.FrameError, MessageID: frame.MessageID, Error: err.Error()})
+		}
+	}
+}
+
+func (h *WebSocketHandler) handleFrame(ctx context.Context, client *realtime.Client, frame clientFrame) error {
+	if _, err := uuid.Parse(frame.MessageID); err != nil {
+		return errors.New("invalid message_id")
+	}
+
+	switch frame.Type {
+	case clientSubscribe:
+		// Threads the user may not read, as GetMessage decides, are not found:
+		// their typing, presence and replies are not theirs to watch
+		var exists bool
+		err := h.db.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM messages m WHERE m.id = $1 AND `+readableBy("$2")+` AND NOT `+blockedBetween("m.user_id", "$2")+`)`,
+			frame.MessageID, client.UserID,
+		).Scan(&exists)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to check message", "error", err, "message_id", frame.MessageID)
+			return errors.New("failed to subscribe")
+		}
+		if !exists {
+			return errors.New("message not found")
+		}
+		return client.Subscribe(ctx, frame.MessageID)
+	case clientUnsubscribe:
+		return client.Unsubscribe(ctx, frame.MessageID)
+	case clientTyping:
+		return client.Typing(ctx, frame.MessageID)
+	default:
+		return errors.New("unknown frame type")
+	}
+}
+
+// writeLoop is the connection's only writer
+func (h *WebSocketHandler) writeLoop(conn *websocket.Conn, client *realtime.Client) {
+	defer conn.Close()
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case frame := <-client.Send():
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(frame); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		case <-client.Done():
+			code, reason := websocket.CloseGoingAway, ""
+			if client.Slow() {
+				code, reason = websocket.CloseTryAgainLater, "slow consumer"
+			}
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(code, reason), time.Now().Add(writeTimeout))
+			return
+		}
+	}
+}
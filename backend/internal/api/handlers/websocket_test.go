@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/events"
+	"why-backend/internal/realtime"
+	"why-backend/internal/testutil"
+)
+
+// dialTestSocket serves handler as user-123 and connects to it
+func dialTestSocket(t *testing.T, handler *WebSocketHandler) *websocket.Conn {
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) { c.Set("user_id", "user-123") }, handler.Serve)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) realtime.Frame {
+	var frame realtime.Frame
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	require.NoError(t, conn.ReadJSON(&frame))
+	return frame
+}
+
+func TestWebSocketHandler_SubscribeAndReceiveReplies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := events.NewMemoryBus()
+	hub := realtime.NewHub(bus, realtime.Options{})
+	hub.Start(ctx)
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM messages m WHERE m.id = \\$1 AND m.hidden_at IS NULL .+ AND NOT EXISTS \\(SELECT 1 FROM user_blocks b").
+		WithArgs(streamThreadID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	conn := dialTestSocket(t, NewWebSocketHandler(db, hub, nil))
+
+	require.NoError(t, conn.WriteJSON(clientFrame{Type: "subscribe", MessageID: streamThreadID}))
+	assert.Equal(t, realtime.FrameSubscribed, readFrame(t, conn).Type)
+	presence := readFrame(t, conn)
+	assert.Equal(t, realtime.FramePresence, presence.Type)
+	assert.Equal(t, []string{"user-123"}, presence.UserIDs)
+
+	require.NoError(t, bus.Publish(ctx, nil, events.ReplyCreated, streamThreadID, map[string]string{"id": "reply-1"}))
+
+	frame := readFrame(t, conn)
+	assert.Equal(t, events.ReplyCreated, frame.Type)
+	assert.Equal(t, streamThreadID, frame.MessageID)
+	assert.JSONEq(t, `{"id":"reply-1"}`, string(frame.Data))
+}
+
+func TestWebSocketHandler_SubscribeUnreadable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub := realtime.NewHub(events.NewMemoryBus(), realtime.Options{})
+	hub.Start(ctx)
+
+	// A followers-only thread of someone user-123 does not follow, or of
+	// someone who blocked them
+	mock.ExpectQuery("FROM messages m WHERE m.id = \\$1 AND .+m.visibility <> 'followers'.+ AND NOT EXISTS \\(SELECT 1 FROM user_blocks b").
+		WithArgs(streamThreadID, "user-123").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	conn := dialTestSocket(t, NewWebSocketHandler(db, hub, nil))
+
+	require.NoError(t, conn.WriteJSON(clientFrame{Type: "subscribe", MessageID: streamThreadID}))
+	frame := readFrame(t, conn)
+	assert.Equal(t, realtime.FrameError, frame.Type)
+	assert.Equal(t, "message not found", frame.Error)
+
+	// Not subscribed, so not allowed to type either
+	require.NoError(t, conn.WriteJSON(clientFrame{Type: "typing", MessageID: streamThreadID}))
+	assert.Equal(t, "not subscribed to thread", readFrame(t, conn).Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebSocketHandler_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub := realtime.NewHub(events.NewMemoryBus(), realtime.Options{})
+	hub.Start(ctx)
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	conn := dialTestSocket(t, NewWebSocketHandler(db, hub, nil))
+
+	tests := []struct {
+		frame clientFrame
+		error string
+	}{
+		{clientFrame{Type: "subscribe", MessageID: streamThreadID}, "message not found"},
+		{clientFrame{Type: "subscribe", MessageID: "not-a-uuid"}, "invalid message_id"},
+		{clientFrame{Type: "typing", MessageID: streamThreadID}, "not subscribed to thread"},
+		{clientFrame{Type: "dance", MessageID: streamThreadID}, "unknown frame type"},
+	}
+	for _, tt := range tests {
+		require.NoError(t, conn.WriteJSON(tt.frame))
+		frame := readFrame(t, conn)
+		assert.Equal(t, realtime.FrameError, frame.Type)
+		assert.Equal(t, tt.error, frame.Error)
+	}
+}
+
+func TestWebSocketHandler_ClosesOnShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hub := realtime.NewHub(events.NewMemoryBus(), realtime.Options{})
+	hub.Start(ctx)
+
+	conn := dialTestSocket(t, NewWebSocketHandler(db, hub, nil))
+	cancel()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+}
@@ -1,43 +1,118 @@
 package middleware
 
 import (
+	"database/sql"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"why-backend/internal/accounts"
+	"why-backend/internal/api/apierror"
 	"why-backend/internal/auth"
 	"why-backend/internal/config"
 )
 
-// AuthMiddleware validates JWT tokens and adds user info to context
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// AuthMiddleware validates JWT tokens, checks the account may still use the
+// API and adds user info to context
+func AuthMiddleware(cfg *config.Config, checker accounts.Checker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
-			c.Abort()
+			apierror.Abort(c, apierror.Unauthorized("authorization header required"))
 			return
 		}
 
-		// Extract token from "Bearer <token>"
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
-			c.Abort()
+		if !authenticate(c, cfg, checker, authHeader) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		token := parts[1]
-		claims, err := auth.ValidateToken(token, cfg.JWTSecret)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
-			c.Abort()
+// OptionalAuth is AuthMiddleware for public routes that tailor results to
+// the viewer: requests without an Authorization header proceed anonymously,
+// but a malformed header or an invalid or expired token is still rejected
+// with 401 rather than silently served the anonymous view, and a suspended or
+// banned account with 403.
+func OptionalAuth(cfg *config.Config, checker accounts.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" && !authenticate(c, cfg, checker, authHeader) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Add user info to context
-		c.Set("user_id", claims.UserID)
-		c.Set("email", claims.Email)
+// authenticate validates a "Bearer <token>" header and the account's
+// standing and adds the user info to the context, or fails the request
+func authenticate(c *gin.Context, cfg *config.Config, checker accounts.Checker, authHeader string) bool {
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		apierror.Abort(c, apierror.Unauthorized("invalid authorization header format"))
+		return false
+	}
+
+	claims, err := auth.ValidateToken(parts[1], cfg.JWTSecret)
+	if err != nil {
+		apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "invalid or expired token"))
+		return false
+	}
+
+	ctx := c.Request.Context()
+	standing, err := checker.Standing(ctx, claims.UserID)
+	if err == accounts.ErrUnknownUser {
+		apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "invalid or expired token"))
+		return false
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Failed to check account standing", "error", err, "user_id", claims.UserID)
+		apierror.Abort(c, apierror.Internal("failed to check account"))
+		return false
+	}
+	if !standing.Active() {
+		apierror.Abort(c, apierror.New(http.StatusForbidden, "account_"+standing.State, "account "+standing.State).With("account", standing))
+		return false
+	}
+
+	c.Set("user_id", claims.UserID)
+	c.Set("email", claims.Email)
+	return true
+}
+
+// RequireRole lets through only users with one of roles, and adds the role
+// to the context. Use it after AuthMiddleware. The role is read on every
+// request so that revoking it takes effect at once.
+func RequireRole(db *sql.DB, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userID := c.GetString("user_id")
+
+		var role string
+		err := db.QueryRowContext(ctx, `SELECT role FROM users WHERE id = $1`, userID).Scan(&role)
+		if err != nil && err != sql.ErrNoRows {
+			slog.ErrorContext(ctx, "Failed to get user role", "error", err, "user_id", userID)
+			apierror.Abort(c, apierror.Internal("failed to check permissions"))
+			return
+		}
+		if !slices.Contains(roles, role) {
+			apierror.Abort(c, apierror.Forbidden("insufficient permissions"))
+			return
+		}
+
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+// QueryTokenAuth accepts the access token as ?access_token= for clients that
+// cannot set headers, such as browser WebSockets, by moving it into the
+// Authorization header. Use it only on such routes, before AuthMiddleware.
+func QueryTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("access_token"); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
 		c.Next()
 	}
 }
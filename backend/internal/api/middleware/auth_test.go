@@ -1,16 +1,37 @@
 package middleware
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"why-backend/internal/accounts"
+	"why-backend/internal/api/apierror"
 	"why-backend/internal/auth"
+	"why-backend/internal/models"
 	"why-backend/internal/testutil"
 )
 
+// checkerFunc is an accounts.Checker
+type checkerFunc func(ctx context.Context, userID string) (accounts.Standing, error)
+
+func (f checkerFunc) Standing(ctx context.Context, userID string) (accounts.Standing, error) {
+	return f(ctx, userID)
+}
+
+var activeAccounts = checkerFunc(func(ctx context.Context, userID string) (accounts.Standing, error) {
+	return accounts.Standing{State: models.AccountActive}, nil
+})
+
 func TestAuthMiddleware_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	cfg := testutil.GetTestConfig()
@@ -23,7 +44,8 @@ func TestAuthMiddleware_Success(t *testing.T) {
 
 	// Setup router with middleware
 	router := gin.New()
-	router.Use(AuthMiddleware(cfg))
+	router.Use(Errors())
+	router.Use(AuthMiddleware(cfg, activeAccounts))
 	router.GET("/protected", func(c *gin.Context) {
 		// Check that user info was added to context
 		contextUserID, exists := c.Get("user_id")
@@ -52,7 +74,8 @@ func TestAuthMiddleware_MissingAuthHeader(t *testing.T) {
 	cfg := testutil.GetTestConfig()
 
 	router := gin.New()
-	router.Use(AuthMiddleware(cfg))
+	router.Use(Errors())
+	router.Use(AuthMiddleware(cfg, activeAccounts))
 	router.GET("/protected", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -101,7 +124,8 @@ func TestAuthMiddleware_InvalidHeaderFormat(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			router := gin.New()
-			router.Use(AuthMiddleware(cfg))
+			router.Use(Errors())
+			router.Use(AuthMiddleware(cfg, activeAccounts))
 			router.GET("/protected", func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{"message": "success"})
 			})
@@ -143,7 +167,8 @@ func TestAuthMiddleware_InvalidToken(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			router := gin.New()
-			router.Use(AuthMiddleware(cfg))
+			router.Use(Errors())
+			router.Use(AuthMiddleware(cfg, activeAccounts))
 			router.GET("/protected", func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{"message": "success"})
 			})
@@ -171,7 +196,8 @@ func TestAuthMiddleware_WrongSecret(t *testing.T) {
 	assert.NoError(t, err)
 
 	router := gin.New()
-	router.Use(AuthMiddleware(cfg))
+	router.Use(Errors())
+	router.Use(AuthMiddleware(cfg, activeAccounts))
 	router.GET("/protected", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -220,7 +246,8 @@ func TestAuthMiddleware_CaseSensitiveBearer(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			router := gin.New()
-			router.Use(AuthMiddleware(cfg))
+			router.Use(Errors())
+			router.Use(AuthMiddleware(cfg, activeAccounts))
 			router.GET("/protected", func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{"message": "success"})
 			})
@@ -243,7 +270,8 @@ func TestAuthMiddleware_NextNotCalled(t *testing.T) {
 	handlerCalled := false
 
 	router := gin.New()
-	router.Use(AuthMiddleware(cfg))
+	router.Use(Errors())
+	router.Use(AuthMiddleware(cfg, activeAccounts))
 	router.GET("/protected", func(c *gin.Context) {
 		handlerCalled = true
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
@@ -258,3 +286,212 @@ func TestAuthMiddleware_NextNotCalled(t *testing.T) {
 	assert.False(t, handlerCalled, "Handler should not be called when auth fails")
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+func TestQueryTokenAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testutil.GetTestConfig()
+
+	token, err := auth.GenerateToken("user-123", "test@example.com", cfg.JWTSecret)
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Errors())
+	router.Use(QueryTokenAuth(), AuthMiddleware(cfg, activeAccounts))
+	router.GET("/ws", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("user_id")})
+	})
+
+	tests := []struct {
+		name           string
+		query          string
+		header         string
+		expectedStatus int
+	}{
+		{"token in query", "?access_token=" + token, "", http.StatusOK},
+		{"invalid token in query", "?access_token=invalid", "", http.StatusUnauthorized},
+		{"header takes precedence", "?access_token=" + token, "Bearer invalid", http.StatusUnauthorized},
+		{"no token", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/ws"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testutil.GetTestConfig()
+
+	token, err := auth.GenerateToken("user-123", "test@example.com", cfg.JWTSecret)
+	assert.NoError(t, err)
+
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		UserID: "user-123",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		},
+	}).SignedString([]byte(cfg.JWTSecret))
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Errors())
+	router.Use(OptionalAuth(cfg, activeAccounts))
+	router.GET("/messages", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("user_id"))
+	})
+
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+		expectedUser   string
+	}{
+		{"valid token", "Bearer " + token, http.StatusOK, "user-123"},
+		{"no token", "", http.StatusOK, ""},
+		{"invalid token", "Bearer invalid", http.StatusUnauthorized, ""},
+		{"expired token", "Bearer " + expired, http.StatusUnauthorized, ""},
+		{"malformed header", token, http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/messages", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.expectedUser, w.Body.String())
+			} else {
+				assert.Equal(t, apierror.ContentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		role           string
+		err            error
+		expectedStatus int
+	}{
+		{"moderator", "moderator", nil, http.StatusOK},
+		{"admin", "admin", nil, http.StatusOK},
+		{"user", "user", nil, http.StatusForbidden},
+		{"unknown user", "", sql.ErrNoRows, http.StatusForbidden},
+		{"database error", "", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.SetupTestDB(t)
+			defer db.Close()
+
+			query := mock.ExpectQuery("SELECT role FROM users WHERE id = \\$1").WithArgs("user-123")
+			if tt.err != nil {
+				query.WillReturnError(tt.err)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(tt.role))
+			}
+
+			router := gin.New()
+			router.Use(Errors())
+			router.Use(func(c *gin.Context) { c.Set("user_id", "user-123") })
+			router.Use(RequireRole(db, "moderator", "admin"))
+			router.GET("/admin/reports", func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString("role"))
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/reports", nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.role, w.Body.String())
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAuthMiddleware_AccountStanding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testutil.GetTestConfig()
+
+	token, err := auth.GenerateToken("user-123", "test@example.com", cfg.JWTSecret)
+	assert.NoError(t, err)
+
+	until := time.Now().Add(48 * time.Hour)
+	tests := []struct {
+		name           string
+		standing       accounts.Standing
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"active", accounts.Standing{State: models.AccountActive}, nil, http.StatusOK, ""},
+		{"suspended", accounts.Standing{State: models.AccountSuspended, Reason: "spam", Until: &until}, nil, http.StatusForbidden, apierror.CodeAccountSuspended},
+		{"banned", accounts.Standing{State: models.AccountBanned, Reason: "abuse"}, nil, http.StatusForbidden, apierror.CodeAccountBanned},
+		{"deleted user", accounts.Standing{}, accounts.ErrUnknownUser, http.StatusUnauthorized, apierror.CodeInvalidToken},
+		{"database error", accounts.Standing{}, errors.New("connection refused"), http.StatusInternalServerError, apierror.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := checkerFunc(func(ctx context.Context, userID string) (accounts.Standing, error) {
+				assert.Equal(t, "user-123", userID)
+				return tt.standing, tt.err
+			})
+
+			// Optional authentication refuses the token the same way
+			for _, mw := range []gin.HandlerFunc{AuthMiddleware(cfg, checker), OptionalAuth(cfg, checker)} {
+				router := gin.New()
+				router.Use(Errors())
+				router.Use(mw)
+				router.GET("/messages", func(c *gin.Context) {
+					c.String(http.StatusOK, c.GetString("user_id"))
+				})
+
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/messages", nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, tt.expectedStatus, w.Code)
+				if tt.expectedStatus == http.StatusOK {
+					assert.Equal(t, "user-123", w.Body.String())
+					continue
+				}
+
+				assert.Equal(t, apierror.ContentType, w.Header().Get("Content-Type"))
+				var body struct {
+					Code    string            `json:"code"`
+					Account accounts.Standing `json:"account"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.expectedCode, body.Code)
+				if tt.expectedStatus == http.StatusForbidden {
+					assert.Equal(t, tt.standing.State, body.Account.State)
+					assert.Equal(t, tt.standing.Reason, body.Account.Reason)
+				}
+			}
+		})
+	}
+}
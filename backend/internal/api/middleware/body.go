@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"why-backend/internal/api/apierror"
+)
+
+// BodyLimit bounds request bodies to limit bytes, so that no request is read
+// into memory whole however large it is. A body declaring a larger
+// Content-Length is refused at once with request_too_large; one that turns
+// out larger fails the read, which bindJSON answers the same way. Uploads,
+// sent as multipart/form-data, are left to their handler.
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if mediaType, _, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge,
+				fmt.Sprintf("request body must be at most %d bytes", limit)))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"why-backend/internal/api/apierror"
+)
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		body           string
+		contentType    string
+		unknownLength  bool
+		expectedStatus int
+		expectedRead   string
+	}{
+		{name: "within the limit", body: `{"a":1}`, contentType: "application/json", expectedStatus: http.StatusOK, expectedRead: `{"a":1}`},
+		{name: "declared too large", body: strings.Repeat("x", 17), contentType: "application/json", expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "turns out too large", body: strings.Repeat("x", 17), contentType: "application/json", unknownLength: true, expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "uploads left to their handler", body: strings.Repeat("x", 17), contentType: "multipart/form-data; boundary=x", expectedStatus: http.StatusOK, expectedRead: strings.Repeat("x", 17)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var read string
+			router := gin.New()
+			router.Use(Errors(), BodyLimit(16))
+			router.POST("/", func(c *gin.Context) {
+				body, err := io.ReadAll(c.Request.Body)
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge, "too large"))
+					return
+				}
+				read = string(body)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedRead, read)
+			if tt.expectedStatus != http.StatusOK {
+				assert.Contains(t, w.Body.String(), apierror.CodeRequestTooLarge)
+			}
+		})
+	}
+}
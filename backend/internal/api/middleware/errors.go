@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"why-backend/internal/api/apierror"
+)
+
+// Errors writes the error a handler or middleware failed the request with
+// as application/problem+json. Use it after the tracing middleware, so that
+// problems carry the trace ID.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		apierror.Respond(c)
+	}
+}
+
+// NotFound answers requests for routes that do not exist
+func NotFound(c *gin.Context) {
+	apierror.Write(c, apierror.NotFound("no route for "+c.Request.URL.Path))
+}
+
+// MethodNotAllowed answers requests with a method the route does not serve
+func MethodNotAllowed(c *gin.Context) {
+	apierror.Write(c, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed,
+		c.Request.Method+" is not allowed on "+c.Request.URL.Path))
+}
+
+// Recovered answers a request whose handler panicked
+func Recovered(c *gin.Context, recovered any) {
+	slog.ErrorContext(c.Request.Context(), "Handler panicked", "panic", recovered, "path", c.Request.URL.Path)
+	apierror.Write(c, apierror.Internal("internal error"))
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"why-backend/internal/api/apierror"
+)
+
+func TestErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.CustomRecovery(Recovered), Errors())
+	router.GET("/messages/:id", func(c *gin.Context) {
+		apierror.Abort(c, apierror.NotFound("message not found"))
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"handler error", "/messages/123", http.StatusNotFound, apierror.CodeNotFound},
+		{"panic", "/panic", http.StatusInternalServerError, apierror.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, apierror.ContentType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), `"code":"`+tt.expectedCode+`"`)
+			assert.NotContains(t, w.Body.String(), "boom")
+		})
+	}
+}
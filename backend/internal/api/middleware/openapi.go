@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/api/openapi"
+)
+
+// Validate checks requests against the OpenAPI document before they reach
+// the handlers: unknown fields and values of the wrong type or format fail
+// with validation_failed. Routes the document does not describe pass.
+//
+// In gin's test mode it also checks responses, answering 500 instead of one
+// the document does not describe, so that tests catch handlers drifting
+// from it. Use it after Errors: it writes problems itself to check them.
+func Validate(doc *openapi.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op := doc.Find(c.Request.Method, c.FullPath())
+		if op == nil {
+			c.Next()
+			return
+		}
+
+		if errs := doc.ValidateRequest(op, c.Request); len(errs) > 0 {
+			if errs[0].Field == "" {
+				apierror.Abort(c, apierror.BadRequest("request body "+errs[0].Message))
+			} else {
+				apierror.Abort(c, apierror.Validation(errs))
+			}
+			return
+		}
+
+		if gin.Mode() != gin.TestMode || !buffered(op) {
+			c.Next()
+			return
+		}
+
+		w := &responseBuffer{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		apierror.Respond(c)
+		c.Writer = w.ResponseWriter
+
+		if err := doc.ValidateResponse(op, w.status, w.Header(), w.body.Bytes()); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Response does not match the OpenAPI document",
+				"error", err, "method", c.Request.Method, "path", c.FullPath(), "status", w.status)
+			w.Header().Del("Content-Type")
+			apierror.Write(c, apierror.Internal("response does not match the OpenAPI document: "+err.Error()))
+			return
+		}
+		c.Writer.WriteHeader(w.status)
+		c.Writer.WriteHeaderNow()
+		c.Writer.Write(w.body.Bytes())
+	}
+}
+
+// buffered reports whether op's responses can be held back and checked:
+// streams and protocol switches cannot
+func buffered(op *openapi.Operation) bool {
+	for _, response := range op.Responses {
+		for mediaType := range response.Content {
+			if mediaType == "text/event-stream" {
+				return false
+			}
+		}
+	}
+	_, switches := op.Responses["101"]
+	return !switches
+}
+
+// responseBuffer holds a response back until it has been checked
+type responseBuffer struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *responseBuffer) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *responseBuffer) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *responseBuffer) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *responseBuffer) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *responseBuffer) Status() int {
+	return w.status
+}
+
+func (w *responseBuffer) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *responseBuffer) Written() bool {
+	return w.written
+}
+
+// Flush is a no-op: the response is written once checked
+func (w *responseBuffer) Flush() {}
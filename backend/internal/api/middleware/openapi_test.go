@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/api/openapi"
+	"why-backend/internal/models"
+)
+
+// newValidatedRouter serves a few documented routes behind Validate
+func newValidatedRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Errors(), Validate(openapi.Spec()))
+
+	now := time.Now()
+	router.POST("/api/v1/messages", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		var req models.CreateMessageRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			apierror.Abort(c, apierror.BadRequest("request body is not valid JSON"))
+			return
+		}
+		c.JSON(http.StatusCreated, models.Message{ID: "msg-1", Content: req.Content, Mentions: models.Mentions{}, Visibility: models.VisibilityPublic, CreatedAt: now, UpdatedAt: now})
+	})
+	router.GET("/api/v1/messages", func(c *gin.Context) {
+		c.JSON(http.StatusOK, []models.Message{})
+	})
+	router.GET("/api/v1/messages/:id", func(c *gin.Context) {
+		// Not a Message
+		c.JSON(http.StatusOK, gin.H{"id": 1})
+	})
+	router.PUT("/api/v1/users/:id/follow", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"followed": true})
+	})
+	router.GET("/api/v1/tags/:tag/messages", func(c *gin.Context) {
+		c.JSON(http.StatusOK, models.MessagePage{Messages: []models.Message{}})
+	})
+	router.GET("/api/v1/undocumented", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"anything": true})
+	})
+	return router
+}
+
+func TestValidate_Requests(t *testing.T) {
+	router := newValidatedRouter()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedErrors []models.FieldError
+	}{
+		{"valid", "POST", "/api/v1/messages", `{"content":"hello","media_urls":[]}`, http.StatusCreated, nil},
+		{
+			"unknown field", "POST", "/api/v1/messages", `{"content":"hello","pinned":true}`, http.StatusBadRequest,
+			[]models.FieldError{{Field: "pinned", Message: "is not a known field"}},
+		},
+		{
+			"wrong types", "POST", "/api/v1/messages", `{"content":5,"media_urls":["a",2]}`, http.StatusBadRequest,
+			[]models.FieldError{{Field: "content", Message: "must be a string"}, {Field: "media_urls[1]", Message: "must be a string"}},
+		},
+		{
+			"missing and empty", "POST", "/api/v1/messages", `{"visibility":"secret"}`, http.StatusBadRequest,
+			[]models.FieldError{{Field: "content", Message: "is required"}, {Field: "visibility", Message: "must be one of: public, unlisted, followers"}},
+		},
+		{"malformed body left to the handler", "POST", "/api/v1/messages", `{"content":`, http.StatusBadRequest, nil},
+		{
+			"query enum", "GET", "/api/v1/messages?filter=bogus", "", http.StatusBadRequest,
+			[]models.FieldError{{Field: "filter", Message: "must be one of: unanswered"}},
+		},
+		{
+			"query integer", "GET", "/api/v1/tags/go/messages?limit=ten", "", http.StatusBadRequest,
+			[]models.FieldError{{Field: "limit", Message: "must be an integer"}},
+		},
+		{
+			"query minimum", "GET", "/api/v1/tags/go/messages?limit=0", "", http.StatusBadRequest,
+			[]models.FieldError{{Field: "limit", Message: "must be at least 1"}},
+		},
+		{"valid query", "GET", "/api/v1/tags/go/messages?limit=5&utm_source=x", "", http.StatusOK, nil},
+		{"undocumented route", "GET", "/api/v1/undocumented", "", http.StatusOK, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedErrors != nil {
+				var problem struct {
+					Code   string              `json:"code"`
+					Errors []models.FieldError `json:"errors"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+				assert.Equal(t, apierror.CodeValidationFailed, problem.Code)
+				assert.Equal(t, tt.expectedErrors, problem.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_Responses(t *testing.T) {
+	router := newValidatedRouter()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected string
+	}{
+		{"wrong body", "GET", "/api/v1/messages/msg-1", "id must be a string"},
+		{"undocumented status", "PUT", "/api/v1/users/user-1/follow", "status 200 is not documented"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+			assert.Equal(t, apierror.ContentType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), "response does not match the OpenAPI document")
+			assert.Contains(t, w.Body.String(), tt.expected)
+		})
+	}
+
+	// A documented response goes through untouched
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/messages", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
@@ -0,0 +1,129 @@
+// Package openapi describes the API as an OpenAPI 3.1 document. Schemas are
+// derived from the models types, so they follow the Go structs; operations
+// are listed in routes.go next to the router's own table, and a test fails
+// when the two disagree.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sync"
+)
+
+// Version is the OpenAPI version of the document
+const Version = "3.1.0"
+
+// Document is an OpenAPI document, as much of it as the API uses
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lowercase HTTP methods to operations
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// SecurityRequirement names security schemes; an empty one makes
+// authentication optional
+type SecurityRequirement map[string][]string
+
+var (
+	buildOnce sync.Once
+	document  *Document
+	spec      []byte
+)
+
+// Spec is the document
+func Spec() *Document {
+	buildOnce.Do(func() {
+		document = build()
+		var err error
+		if spec, err = json.Marshal(document); err != nil {
+			panic("openapi: " + err.Error())
+		}
+	})
+	return document
+}
+
+// JSON is the document encoded as JSON
+func JSON() []byte {
+	Spec()
+	return spec
+}
+
+// DocsPage is an HTML page browsing the document served at SpecPath
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// SpecPath is where the router serves the document, and DocsPath the page
+const (
+	SpecPath = "/api/v1/openapi.json"
+	DocsPath = "/api/v1/docs"
+)
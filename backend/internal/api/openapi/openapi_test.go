@@ -0,0 +1,138 @@
+package openapi
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+)
+
+func TestSpec_CoversModels(t *testing.T) {
+	files, err := parser.ParseDir(token.NewFileSet(), "../../models", func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	require.NoError(t, err)
+
+	schemas := Spec().Components.Schemas
+	for _, pkg := range files {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					name := spec.(*ast.TypeSpec).Name.Name
+					if ast.IsExported(name) {
+						assert.Contains(t, schemas, name, "models.%s has no schema", name)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSpec_RefsResolve(t *testing.T) {
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(JSON(), &doc))
+	assert.Equal(t, Version, doc["openapi"])
+
+	schemas := Spec().Components.Schemas
+	refs := regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(string(JSON()), -1)
+	require.NotEmpty(t, refs)
+	for _, ref := range refs {
+		assert.Contains(t, schemas, ref[1])
+	}
+}
+
+func TestSpec_Operations(t *testing.T) {
+	ids := map[string]bool{}
+	for path, item := range Spec().Paths {
+		assert.NotContains(t, path, ":", "path %s uses gin syntax", path)
+		for method, op := range item {
+			assert.False(t, ids[op.OperationID], "operationId %s is not unique", op.OperationID)
+			ids[op.OperationID] = true
+
+			assert.NotEmpty(t, op.Responses, "%s %s", method, path)
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					assert.Contains(t, path, "{"+p.Name+"}", "%s %s", method, path)
+				}
+			}
+		}
+	}
+}
+
+func TestSchemas_Request(t *testing.T) {
+	schema := Spec().Components.Schemas["CreateReportRequest"]
+
+	assert.ElementsMatch(t, []string{"target_type", "target_id", "reason"}, schema.Required)
+	assert.Equal(t, []any{"message", "reply", "user"}, schema.Properties["target_type"].Enum)
+	assert.Equal(t, "uuid", schema.Properties["target_id"].Format)
+	assert.Equal(t, 1, *schema.Properties["target_id"].MinLength)
+	assert.Equal(t, 2000, *schema.Properties["details"].MaxLength)
+}
+
+func TestSchemas_Response(t *testing.T) {
+	schemas := Spec().Components.Schemas
+	message := schemas["Message"]
+
+	// Every field not tagged omitempty is always present
+	assert.Contains(t, message.Required, "accepted_reply_id")
+	assert.NotContains(t, message.Required, "pending_review")
+	assert.Equal(t, Types{"string"}, message.Properties["created_at"].Type)
+	assert.Equal(t, "date-time", message.Properties["created_at"].Format)
+	assert.Equal(t, Types{"string", "null"}, message.Properties["accepted_reply_id"].Type)
+	assert.Equal(t, Types{"array", "null"}, message.Properties["media_urls"].Type)
+	assert.Equal(t, "#/components/schemas/Mentions", message.Properties["mentions"].Ref)
+
+	// accounts.Standing is renamed
+	assert.Contains(t, schemas, "AccountStanding")
+	assert.NotContains(t, schemas, "Standing")
+}
+
+func TestSchemas_OmitEmpty(t *testing.T) {
+	s := &schemas{components: map[string]*Schema{}}
+
+	// The rules only hold for values that are set
+	visibility := s.object(reflect.TypeOf(models.CreateMessageRequest{})).Properties["visibility"]
+	require.Len(t, visibility.AnyOf, 2)
+	assert.Equal(t, []any{"public", "unlisted", "followers"}, visibility.AnyOf[0].Enum)
+	assert.Equal(t, "", visibility.AnyOf[1].Const)
+
+	// A nil pointer is null, which the schema allows anyway
+	url := s.object(reflect.TypeOf(models.UpdateWebhookRequest{})).Properties["url"]
+	assert.Empty(t, url.AnyOf)
+	assert.Equal(t, Types{"string", "null"}, url.Type)
+	assert.Equal(t, "uri", url.Format)
+}
+
+func TestTypes_JSON(t *testing.T) {
+	data, err := json.Marshal(Types{"string"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `"string"`, string(data))
+
+	data, err = json.Marshal(Types{"string", "null"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `["string","null"]`, string(data))
+
+	var types Types
+	require.NoError(t, json.Unmarshal([]byte(`"integer"`), &types))
+	assert.Equal(t, Types{"integer"}, types)
+	require.NoError(t, json.Unmarshal([]byte(`["array","null"]`), &types))
+	assert.Equal(t, Types{"array", "null"}, types)
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	assert.Equal(t, "/api/v1/messages/{id}/replies/{reply_id}/accept", openAPIPath("/api/v1/messages/:id/replies/:reply_id/accept"))
+	assert.Equal(t, "/health", openAPIPath("/health"))
+}
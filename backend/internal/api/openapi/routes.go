@@ -0,0 +1,919 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"why-backend/internal/accounts"
+	"why-backend/internal/models"
+)
+
+// authMode is how an operation authenticates its caller
+type authMode int
+
+const (
+	public authMode = iota
+	// optionalAuth takes a bearer token to tailor results to the viewer
+	optionalAuth
+	bearerAuth
+	// queryTokenAuth also takes the token as ?access_token=, for clients that
+	// cannot set headers: WebSockets, <img> and <video>
+	queryTokenAuth
+)
+
+// operation is a route of api.NewRouter. Path uses gin's :param syntax.
+type operation struct {
+	method, path string
+	id, tag      string
+	summary      string
+	description  string
+	auth         authMode
+	params       []*Parameter
+	// body is a value of the JSON request body's type; an optional one
+	// may be left out
+	body     any
+	optional bool
+	// form is a multipart/form-data request body
+	form *Schema
+	// responses are the successful ones; errors lists the statuses the
+	// operation fails with besides those every operation of its kind does
+	responses []response
+	errors    []int
+}
+
+type response struct {
+	status      int
+	description string
+	contentType string
+	// body is a value of the response's type, or a *Schema
+	body any
+}
+
+func ok(status int, description string, body any) response {
+	return response{status: status, description: description, contentType: "application/json", body: body}
+}
+
+func noContent(description string) response {
+	return response{status: http.StatusNoContent, description: description}
+}
+
+func pathParam(name, description string) *Parameter {
+	return &Parameter{Name: name, In: "path", Description: description, Required: true, Schema: typed("string")}
+}
+
+func query(name, description string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func enum(values ...any) *Schema {
+	return &Schema{Type: Types{"string"}, Enum: values}
+}
+
+func number(n float64) *float64 {
+	return &n
+}
+
+func object(properties map[string]*Schema) *Schema {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return &Schema{Type: Types{"object"}, Properties: properties, Required: required, AdditionalProperties: false}
+}
+
+var (
+	messageID  = pathParam("id", "Message ID")
+	userID     = pathParam("id", "User ID")
+	limit      = query("limit", "Page size; defaults to 20 and is capped at 100", &Schema{Type: Types{"integer"}, Minimum: number(1)})
+	cursor     = query("cursor", "The next_cursor of the previous page", typed("string"))
+	pageParams = []*Parameter{limit, cursor}
+)
+
+func paged(params ...*Parameter) []*Parameter {
+	return append(params, pageParams...)
+}
+
+// operations lists every route of api.NewRouter but the pprof ones, which
+// are off unless ENABLE_PPROF is set
+var operations = []operation{
+	{
+		method: http.MethodGet, path: "/health", id: "health", tag: "meta",
+		summary:   "Check the server is up",
+		responses: []response{ok(http.StatusOK, "The server is up", object(map[string]*Schema{"status": {Type: Types{"string"}, Const: "ok"}}))},
+	},
+	{
+		method: http.MethodGet, path: "/metrics", id: "metrics", tag: "meta",
+		summary:   "Prometheus metrics",
+		responses: []response{{status: http.StatusOK, description: "Metrics in the Prometheus text format", contentType: "text/plain", body: typed("string")}},
+	},
+	{
+		method: http.MethodGet, path: SpecPath, id: "getOpenAPI", tag: "meta",
+		summary:   "This document",
+		responses: []response{ok(http.StatusOK, "The OpenAPI document", &Schema{Type: Types{"object"}})},
+	},
+	{
+		method: http.MethodGet, path: DocsPath, id: "getDocs", tag: "meta",
+		summary:   "Browse this document",
+		responses: []response{{status: http.StatusOK, description: "An HTML page", contentType: "text/html", body: typed("string")}},
+	},
+
+	// Authentication
+	{
+		method: http.MethodPost, path: "/api/v1/signup", id: "signup", tag: "auth",
+		summary:   "Create an account",
+		body:      models.SignupRequest{},
+		responses: []response{ok(http.StatusCreated, "The account and a token for it", models.AuthResponse{})},
+		errors:    []int{http.StatusConflict},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/login", id: "login", tag: "auth",
+		summary:   "Sign in",
+		body:      models.LoginRequest{},
+		responses: []response{ok(http.StatusOK, "The user and a token", models.AuthResponse{})},
+		errors:    []int{http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/refresh", id: "refreshToken", tag: "auth",
+		summary:   "Exchange a token for a fresh one",
+		auth:      bearerAuth,
+		responses: []response{ok(http.StatusOK, "The user and a new token", models.AuthResponse{})},
+	},
+
+	// Messages and replies
+	{
+		method: http.MethodGet, path: "/api/v1/messages", id: "listMessages", tag: "messages",
+		summary:   "List the newest messages",
+		auth:      optionalAuth,
+		params:    []*Parameter{query("filter", "Only messages without an accepted reply", enum("unanswered"))},
+		responses: []response{ok(http.StatusOK, "Up to 50 messages, newest first", []models.Message{})},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/messages", id: "createMessage", tag: "messages",
+		summary: "Post a message",
+		auth:    bearerAuth,
+		body:    models.CreateMessageRequest{},
+		responses: []response{
+			ok(http.StatusCreated, "The message", models.Message{}),
+			ok(http.StatusAccepted, "The message, held for review by the content filter", models.Message{}),
+		},
+		errors: []int{http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/messages/:id", id: "getMessage", tag: "messages",
+		summary:   "Get a message",
+		auth:      optionalAuth,
+		params:    []*Parameter{messageID},
+		responses: []response{ok(http.StatusOK, "The message", models.Message{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPatch, path: "/api/v1/messages/:id", id: "updateMessage", tag: "messages",
+		summary: "Edit a message",
+		auth:    bearerAuth,
+		params:  []*Parameter{messageID},
+		body:    models.UpdateMessageRequest{},
+		responses: []response{
+			ok(http.StatusOK, "The message", models.Message{}),
+			ok(http.StatusAccepted, "The message, held for review by the content filter", models.Message{}),
+		},
+		errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/messages/:id/replies", id: "listReplies", tag: "replies",
+		summary: "List a message's replies",
+		auth:    optionalAuth,
+		params: []*Parameter{
+			messageID,
+			query("sort", "Oldest first, or best scored first", enum("created_at", "score")),
+		},
+		responses: []response{ok(http.StatusOK, "The replies", []models.Reply{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/messages/:id/replies", id: "createReply", tag: "replies",
+		summary: "Reply to a message",
+		auth:    bearerAuth,
+		params:  []*Parameter{messageID},
+		body:    models.CreateReplyRequest{},
+		responses: []response{
+			ok(http.StatusCreated, "The reply", models.Reply{}),
+			ok(http.StatusAccepted, "The reply, held for review by the content filter", models.Reply{}),
+		},
+		errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/messages/:id/replies/:reply_id/accept", id: "acceptReply", tag: "replies",
+		summary:   "Accept a reply as the answer",
+		auth:      bearerAuth,
+		params:    []*Parameter{messageID, pathParam("reply_id", "Reply ID")},
+		responses: []response{ok(http.StatusOK, "The message", models.Message{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/messages/:id/replies/:reply_id/accept", id: "unacceptReply", tag: "replies",
+		summary:   "Withdraw the accepted answer",
+		auth:      bearerAuth,
+		params:    []*Parameter{messageID, pathParam("reply_id", "Reply ID")},
+		responses: []response{ok(http.StatusOK, "The message", models.Message{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/v1/replies/:id/vote", id: "voteReply", tag: "replies",
+		summary:   "Vote on a reply",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Reply ID")},
+		body:      models.VoteRequest{},
+		responses: []response{ok(http.StatusOK, "The reply's new score", models.VoteResponse{})},
+		errors:    []int{http.StatusNotFound},
+	},
+
+	// Tags and search
+	{
+		method: http.MethodGet, path: "/api/v1/tags", id: "listTags", tag: "tags",
+		summary:   "Autocomplete tags",
+		params:    []*Parameter{query("prefix", "Start of the tag, with or without '#'", typed("string"))},
+		responses: []response{ok(http.StatusOK, "The 10 most used matching tags", []models.Tag{})},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/tags/:tag/messages", id: "listTagMessages", tag: "tags",
+		summary:   "List public messages with a tag",
+		params:    paged(pathParam("tag", "Tag name")),
+		responses: []response{ok(http.StatusOK, "A page of messages, newest first", models.MessagePage{})},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/search", id: "search", tag: "search",
+		summary: "Search messages and replies",
+		params: paged(
+			query("q", "Search terms", typed("string")),
+			query("type", "Only messages or only replies", enum(models.SearchResultMessage, models.SearchResultReply)),
+			query("tag", "Only messages with this tag", typed("string")),
+			query("from", "Created at or after: a date or an RFC 3339 time", typed("string")),
+			query("to", "Created at or before: a date, through its end, or an RFC 3339 time", typed("string")),
+			query("author", "Author ID or @handle", typed("string")),
+		),
+		responses: []response{ok(http.StatusOK, "A page of results, best first", models.SearchPage{})},
+	},
+
+	// Users and follows
+	{
+		method: http.MethodGet, path: "/api/v1/me", id: "getMe", tag: "users",
+		summary:   "Get the signed in user",
+		auth:      bearerAuth,
+		responses: []response{ok(http.StatusOK, "The user", models.User{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/v1/me/handle", id: "updateHandle", tag: "users",
+		summary:   "Choose a handle",
+		auth:      bearerAuth,
+		body:      models.UpdateHandleRequest{},
+		responses: []response{ok(http.StatusOK, "The user", models.User{})},
+		errors:    []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/users/:id", id: "getUser", tag: "users",
+		summary:   "Get a user's profile",
+		params:    []*Parameter{userID},
+		responses: []response{ok(http.StatusOK, "The profile", models.Profile{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/users/:id/followers", id: "listFollowers", tag: "follows",
+		summary:   "List a user's followers",
+		params:    paged(userID),
+		responses: []response{ok(http.StatusOK, "A page of followers, newest first", models.FollowPage{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/users/:id/following", id: "listFollowing", tag: "follows",
+		summary:   "List the users a user follows",
+		params:    paged(userID),
+		responses: []response{ok(http.StatusOK, "A page of users, newest first", models.FollowPage{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/v1/users/:id/follow", id: "follow", tag: "follows",
+		summary:   "Follow a user",
+		auth:      bearerAuth,
+		params:    []*Parameter{userID},
+		responses: []response{noContent("Following")},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/users/:id/follow", id: "unfollow", tag: "follows",
+		summary:   "Unfollow a user",
+		auth:      bearerAuth,
+		params:    []*Parameter{userID},
+		responses: []response{noContent("Not following")},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/timeline/home", id: "homeTimeline", tag: "timeline",
+		summary:   "Messages from followed users",
+		auth:      bearerAuth,
+		params:    pageParams,
+		responses: []response{ok(http.StatusOK, "A page of messages, newest first", models.MessagePage{})},
+	},
+
+	// Blocks and mutes
+	{
+		method: http.MethodGet, path: "/api/v1/me/blocks", id: "listBlocks", tag: "blocks",
+		summary:   "List blocked users",
+		auth:      bearerAuth,
+		params:    pageParams,
+		responses: []response{ok(http.StatusOK, "A page of users, newest first", models.RelatedUserPage{})},
+	},
+	{
+		method: http.MethodPut, path: "/api/v1/me/blocks/:id", id: "block", tag: "blocks",
+		summary:     "Block a user",
+		description: "Blocking also removes follows between the two users.",
+		auth:        bearerAuth,
+		params:      []*Parameter{userID},
+		responses:   []response{noContent("Blocked")},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/me/blocks/:id", id: "unblock", tag: "blocks",
+		summary:   "Unblock a user",
+		auth:      bearerAuth,
+		params:    []*Parameter{userID},
+		responses: []response{noContent("Not blocked")},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/me/mutes", id: "listMutes", tag: "blocks",
+		summary:   "List muted users",
+		auth:      bearerAuth,
+		params:    pageParams,
+		responses: []response{ok(http.StatusOK, "A page of users, newest first", models.RelatedUserPage{})},
+	},
+	{
+		method: http.MethodPut, path: "/api/v1/me/mutes/:id", id: "mute", tag: "blocks",
+		summary:   "Mute a user",
+		auth:      bearerAuth,
+		params:    []*Parameter{userID},
+		responses: []response{noContent("Muted")},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/me/mutes/:id", id: "unmute", tag: "blocks",
+		summary:   "Unmute a user",
+		auth:      bearerAuth,
+		params:    []*Parameter{userID},
+		responses: []response{noContent("Not muted")},
+		errors:    []int{http.StatusNotFound},
+	},
+
+	// Conversations
+	{
+		method: http.MethodPost, path: "/api/v1/conversations", id: "createConversation", tag: "conversations",
+		summary: "Start a conversation",
+		auth:    bearerAuth,
+		body:    models.CreateConversationRequest{},
+		responses: []response{
+			ok(http.StatusCreated, "The new conversation", models.Conversation{}),
+			ok(http.StatusOK, "The conversation the same participants already have", models.Conversation{}),
+		},
+		errors: []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/conversations", id: "listConversations", tag: "conversations",
+		summary:   "List conversations",
+		auth:      bearerAuth,
+		params:    pageParams,
+		responses: []response{ok(http.StatusOK, "A page of conversations, most recently active first", models.ConversationPage{})},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/conversations/unread-count", id: "conversationUnreadCount", tag: "conversations",
+		summary:   "Count unread direct messages",
+		auth:      bearerAuth,
+		responses: []response{ok(http.StatusOK, "The count", object(map[string]*Schema{"unread_count": typed("integer")}))},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/conversations/:id", id: "getConversation", tag: "conversations",
+		summary:   "Get a conversation",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Conversation ID")},
+		responses: []response{ok(http.StatusOK, "The conversation", models.Conversation{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/conversations/:id/messages", id: "sendDirectMessage", tag: "conversations",
+		summary:   "Send a direct message",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Conversation ID")},
+		body:      models.SendDirectMessageRequest{},
+		responses: []response{ok(http.StatusCreated, "The message", models.DirectMessage{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/conversations/:id/messages", id: "listDirectMessages", tag: "conversations",
+		summary:   "List a conversation's messages",
+		auth:      bearerAuth,
+		params:    paged(pathParam("id", "Conversation ID")),
+		responses: []response{ok(http.StatusOK, "A page of messages, newest first", models.DirectMessagePage{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/conversations/:id/read", id: "markConversationRead", tag: "conversations",
+		summary:   "Move the read receipt",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Conversation ID")},
+		body:      models.MarkConversationReadRequest{},
+		optional:  true,
+		responses: []response{noContent("Read")},
+		errors:    []int{http.StatusNotFound},
+	},
+
+	// Media
+	{
+		method: http.MethodPost, path: "/api/v1/media", id: "uploadMedia", tag: "media",
+		summary:     "Upload an image or video",
+		description: "Files for a conversation are stored privately and only its participants can get them.",
+		auth:        bearerAuth,
+		form: &Schema{
+			Type: Types{"object"},
+			Properties: map[string]*Schema{
+				"file":            {Type: Types{"string"}, Format: "binary"},
+				"conversation_id": {Type: Types{"string"}, Format: "uuid"},
+			},
+			Required: []string{"file"},
+		},
+		responses: []response{ok(http.StatusOK, "Where the file is", object(map[string]*Schema{"url": typed("string")}))},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/conversations/:id/media/:name", id: "getConversationMedia", tag: "media",
+		summary:   "Get a file sent in a conversation",
+		auth:      queryTokenAuth,
+		params:    []*Parameter{pathParam("id", "Conversation ID"), pathParam("name", "File name")},
+		responses: []response{{status: http.StatusOK, description: "The file", contentType: "*/*", body: &Schema{Type: Types{"string"}, Format: "binary"}}},
+		errors:    []int{http.StatusNotFound},
+	},
+
+	// Notifications
+	{
+		method: http.MethodGet, path: "/api/v1/me/notifications", id: "listNotifications", tag: "notifications",
+		summary:   "List notifications",
+		auth:      bearerAuth,
+		params:    paged(query("unread", "Only unread notifications", enum("true", "false"))),
+		responses: []response{ok(http.StatusOK, "A page of notifications, newest first", models.NotificationPage{})},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/me/notifications/unread-count", id: "notificationUnreadCount", tag: "notifications",
+		summary:   "Count unread notifications",
+		auth:      bearerAuth,
+		responses: []response{ok(http.StatusOK, "The counts", models.UnreadCounts{})},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/me/notifications/read-all", id: "markAllNotificationsRead", tag: "notifications",
+		summary:   "Mark every notification read",
+		auth:      bearerAuth,
+		responses: []response{ok(http.StatusOK, "How many were unread", object(map[string]*Schema{"updated": {Type: Types{"integer"}, Format: "int64"}}))},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/me/notifications/:id/read", id: "markNotificationRead", tag: "notifications",
+		summary:   "Mark a notification read",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Notification ID")},
+		responses: []response{ok(http.StatusOK, "The notification", models.Notification{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/me/notification-preferences", id: "getNotificationPreferences", tag: "notifications",
+		summary:   "Get which notifications are delivered",
+		auth:      bearerAuth,
+		responses: []response{ok(http.StatusOK, "Every configurable type", models.NotificationPreferences{})},
+	},
+	{
+		method: http.MethodPut, path: "/api/v1/me/notification-preferences", id: "updateNotificationPreferences", tag: "notifications",
+		summary:   "Switch notification types on or off",
+		auth:      bearerAuth,
+		body:      models.NotificationPreferences{},
+		responses: []response{ok(http.StatusOK, "Every configurable type", models.NotificationPreferences{})},
+	},
+
+	// Webhooks
+	{
+		method: http.MethodPost, path: "/api/v1/webhooks", id: "createWebhook", tag: "webhooks",
+		summary:   "Register a webhook",
+		auth:      bearerAuth,
+		body:      models.CreateWebhookRequest{},
+		responses: []response{ok(http.StatusCreated, "The webhook, with its signing secret", models.Webhook{})},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/webhooks", id: "listWebhooks", tag: "webhooks",
+		summary:   "List webhooks",
+		auth:      bearerAuth,
+		responses: []response{ok(http.StatusOK, "The webhooks", []models.Webhook{})},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/webhooks/:id", id: "getWebhook", tag: "webhooks",
+		summary:   "Get a webhook",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Webhook ID")},
+		responses: []response{ok(http.StatusOK, "The webhook", models.Webhook{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPatch, path: "/api/v1/webhooks/:id", id: "updateWebhook", tag: "webhooks",
+		summary:   "Change a webhook",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Webhook ID")},
+		body:      models.UpdateWebhookRequest{},
+		responses: []response{ok(http.StatusOK, "The webhook", models.Webhook{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/webhooks/:id", id: "deleteWebhook", tag: "webhooks",
+		summary:   "Delete a webhook",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Webhook ID")},
+		responses: []response{noContent("Deleted")},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/webhooks/:id/deliveries", id: "listWebhookDeliveries", tag: "webhooks",
+		summary: "List a webhook's deliveries",
+		auth:    bearerAuth,
+		params: paged(
+			pathParam("id", "Webhook ID"),
+			query("status", "Only deliveries in this state", enum("pending", "succeeded", "failed")),
+		),
+		responses: []response{ok(http.StatusOK, "A page of deliveries, newest first", models.WebhookDeliveryPage{})},
+		errors:    []int{http.StatusNotFound},
+	},
+
+	// Reports
+	{
+		method: http.MethodPost, path: "/api/v1/reports", id: "createReport", tag: "reports",
+		summary:   "Report a message, reply or user",
+		auth:      bearerAuth,
+		body:      models.CreateReportRequest{},
+		responses: []response{ok(http.StatusCreated, "The report", models.Report{})},
+		errors:    []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/me/reports", id: "listMyReports", tag: "reports",
+		summary:   "List the reports you made",
+		auth:      bearerAuth,
+		params:    pageParams,
+		responses: []response{ok(http.StatusOK, "A page of reports, newest first", models.ReportPage{})},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/me/reports/:id", id: "getMyReport", tag: "reports",
+		summary:   "Get a report you made",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Report ID")},
+		responses: []response{ok(http.StatusOK, "The report", models.Report{})},
+		errors:    []int{http.StatusNotFound},
+	},
+
+	// Moderation
+	{
+		method: http.MethodGet, path: "/api/v1/admin/reports", id: "listReports", tag: "moderation",
+		summary: "List the report queue",
+		auth:    bearerAuth,
+		params: paged(query("status", "Defaults to open; open and claimed reports are oldest first, resolved ones newest first",
+			enum(models.ReportOpen, models.ReportClaimed, models.ReportResolved))),
+		responses: []response{ok(http.StatusOK, "A page of reports", models.ReportPage{})},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/admin/reports/:id", id: "getReport", tag: "moderation",
+		summary:   "Get a report",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Report ID")},
+		responses: []response{ok(http.StatusOK, "The report", models.Report{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/admin/reports/:id/claim", id: "claimReport", tag: "moderation",
+		summary:   "Claim an open report",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Report ID")},
+		responses: []response{ok(http.StatusOK, "The report", models.Report{})},
+		errors:    []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/admin/reports/:id/claim", id: "releaseReport", tag: "moderation",
+		summary:   "Release a report you claimed",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Report ID")},
+		responses: []response{ok(http.StatusOK, "The report", models.Report{})},
+		errors:    []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodPost, path: "/api/v1/admin/reports/:id/resolve", id: "resolveReport", tag: "moderation",
+		summary:   "Resolve a report you claimed",
+		auth:      bearerAuth,
+		params:    []*Parameter{pathParam("id", "Report ID")},
+		body:      models.ResolveReportRequest{},
+		responses: []response{ok(http.StatusOK, "The report", models.Report{})},
+		errors:    []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/admin/audit-log", id: "listAuditLog", tag: "moderation",
+		summary: "List moderation steps",
+		auth:    bearerAuth,
+		params: paged(
+			query("report_id", "Only steps on this report", &Schema{Type: Types{"string"}, Format: "uuid"}),
+			query("target_id", "Only steps on this message, reply or user", &Schema{Type: Types{"string"}, Format: "uuid"}),
+		),
+		responses: []response{ok(http.StatusOK, "A page of entries, newest first", models.AuditLogPage{})},
+	},
+	{
+		method: http.MethodPut, path: "/api/v1/admin/users/:id/suspension", id: "suspendUser", tag: "moderation",
+		summary:   "Suspend a user",
+		auth:      bearerAuth,
+		params:    []*Parameter{userID},
+		body:      models.SuspendUserRequest{},
+		responses: []response{ok(http.StatusOK, "The user's standing", accounts.Standing{})},
+		errors:    []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/admin/users/:id/suspension", id: "liftSuspension", tag: "moderation",
+		summary:   "Lift a suspension",
+		auth:      bearerAuth,
+		params:    []*Parameter{userID},
+		responses: []response{noContent("Not suspended")},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/v1/admin/users/:id/ban", id: "banUser", tag: "moderation",
+		summary:     "Ban a user",
+		description: "Admins only. A banned user's messages and replies are hidden from everyone.",
+		auth:        bearerAuth,
+		params:      []*Parameter{userID},
+		body:        models.BanUserRequest{},
+		responses:   []response{ok(http.StatusOK, "The user's standing", accounts.Standing{})},
+		errors:      []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodDelete, path: "/api/v1/admin/users/:id/ban", id: "unbanUser", tag: "moderation",
+		summary:     "Lift a ban",
+		description: "Admins only.",
+		auth:        bearerAuth,
+		params:      []*Parameter{userID},
+		responses:   []response{noContent("Not banned")},
+		errors:      []int{http.StatusNotFound},
+	},
+
+	// Realtime
+	{
+		method: http.MethodGet, path: "/api/v1/stream", id: "stream", tag: "realtime",
+		summary:     "Stream public events",
+		description: "Server-sent events. A client reconnecting with Last-Event-ID, or ?last_event_id=, gets the events it missed.",
+		params: []*Parameter{
+			query("message_id", "Only events about this message and its replies", typed("string")),
+			query("last_event_id", "Resume after this event", typed("string")),
+			{Name: "Last-Event-ID", In: "header", Description: "Resume after this event", Schema: typed("string")},
+		},
+		responses: []response{{status: http.StatusOK, description: "The event stream", contentType: "text/event-stream", body: typed("string")}},
+	},
+	{
+		method: http.MethodGet, path: "/api/v1/ws", id: "webSocket", tag: "realtime",
+		summary:     "Open a WebSocket",
+		description: "Pushes the signed in user's events; clients subscribe to messages with frames.",
+		auth:        queryTokenAuth,
+		responses:   []response{{status: http.StatusSwitchingProtocols, description: "Switching to the WebSocket protocol"}},
+	},
+}
+
+var tags = []Tag{
+	{Name: "auth", Description: "Accounts and tokens"},
+	{Name: "messages", Description: "Public questions"},
+	{Name: "replies", Description: "Answers, votes and accepted answers"},
+	{Name: "tags", Description: "Hashtags"},
+	{Name: "search", Description: "Full-text search"},
+	{Name: "users", Description: "Profiles and handles"},
+	{Name: "follows", Description: "Followers and following"},
+	{Name: "timeline", Description: "The home timeline"},
+	{Name: "blocks", Description: "Blocked and muted users"},
+	{Name: "conversations", Description: "Private conversations"},
+	{Name: "media", Description: "Images and videos"},
+	{Name: "notifications", Description: "Notifications and their preferences"},
+	{Name: "webhooks", Description: "Event deliveries to your own endpoints"},
+	{Name: "reports", Description: "Reporting content"},
+	{Name: "moderation", Description: "The report queue, suspensions and bans, for moderators and admins"},
+	{Name: "realtime", Description: "Server-sent events and WebSockets"},
+	{Name: "meta", Description: "Health, metrics and this document"},
+}
+
+// errorDescriptions describe the problems an operation answers with
+var errorDescriptions = map[int]string{
+	http.StatusBadRequest:            "The request is malformed or fails validation",
+	http.StatusUnauthorized:          "Authentication is missing or invalid",
+	http.StatusForbidden:             "The account is suspended or banned, or lacks the role",
+	http.StatusNotFound:              "Not found",
+	http.StatusConflict:              "Conflicts with the current state",
+	http.StatusRequestEntityTooLarge: "The request body is too large",
+	http.StatusUnprocessableEntity:   "The content filter rejected the content",
+	http.StatusInternalServerError:   "Internal error",
+}
+
+// build assembles the document
+func build() *Document {
+	s := &schemas{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{reflect.TypeOf(accounts.Standing{}): "AccountStanding"},
+	}
+	// Every models type is a component, even those no operation uses directly
+	for _, v := range modelTypes {
+		s.of(reflect.TypeOf(v))
+	}
+	s.components["Problem"] = problemSchema(s)
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "why API",
+			Version:     "1",
+			Description: "Errors are application/problem+json documents whose code is stable; see the Problem schema.",
+		},
+		Tags:  tags,
+		Paths: map[string]PathItem{},
+		Components: Components{
+			Schemas: s.components,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "The token from signup, login or refresh"},
+				"accessToken": {
+					Type: "apiKey", In: "query", Name: "access_token",
+					Description: "The same token, for clients that cannot set the Authorization header",
+				},
+			},
+		},
+	}
+
+	for _, op := range operations {
+		path := openAPIPath(op.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(op.method)] = op.build(s)
+	}
+	return doc
+}
+
+func (op operation) build(s *schemas) *Operation {
+	o := &Operation{
+		OperationID: op.id,
+		Summary:     op.summary,
+		Description: op.description,
+		Tags:        []string{op.tag},
+		Parameters:  op.params,
+		Responses:   map[string]*Response{},
+	}
+
+	switch {
+	case op.body != nil:
+		o.RequestBody = &RequestBody{
+			Required: !op.optional,
+			Content:  map[string]MediaType{"application/json": {Schema: s.of(reflect.TypeOf(op.body))}},
+		}
+	case op.form != nil:
+		o.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"multipart/form-data": {Schema: op.form}},
+		}
+	}
+
+	switch op.auth {
+	case optionalAuth:
+		o.Security = []SecurityRequirement{{"bearerAuth": {}}, {}}
+	case bearerAuth:
+		o.Security = []SecurityRequirement{{"bearerAuth": {}}}
+	case queryTokenAuth:
+		o.Security = []SecurityRequirement{{"bearerAuth": {}}, {"accessToken": {}}}
+	}
+
+	for _, r := range op.responses {
+		response := &Response{Description: r.description}
+		if r.contentType != "" {
+			schema, ok := r.body.(*Schema)
+			if !ok {
+				schema = s.of(reflect.TypeOf(r.body))
+			}
+			response.Content = map[string]MediaType{r.contentType: {Schema: schema}}
+		}
+		o.Responses[strconv.Itoa(r.status)] = response
+	}
+
+	errors := append([]int{}, op.errors...)
+	if strings.HasPrefix(op.path, "/api/") {
+		errors = append(errors, http.StatusInternalServerError)
+	}
+	if op.auth != public {
+		errors = append(errors, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	if op.body != nil || op.form != nil || hasQuery(op.params) {
+		errors = append(errors, http.StatusBadRequest)
+	}
+	if op.body != nil {
+		errors = append(errors, http.StatusRequestEntityTooLarge)
+	}
+	for _, status := range errors {
+		o.Responses[strconv.Itoa(status)] = &Response{
+			Description: errorDescriptions[status],
+			Content:     map[string]MediaType{"application/problem+json": {Schema: ref("Problem")}},
+		}
+	}
+	return o
+}
+
+func hasQuery(params []*Parameter) bool {
+	for _, p := range params {
+		if p.In == "query" {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIPath turns gin's /users/:id into /users/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// problemSchema describes the application/problem+json body of errors
+func problemSchema(s *schemas) *Schema {
+	return &Schema{
+		Type: Types{"object"},
+		Properties: map[string]*Schema{
+			"type":     typed("string"),
+			"title":    typed("string"),
+			"status":   typed("integer"),
+			"detail":   typed("string"),
+			"code":     {Type: Types{"string"}, Description: "Stable error code, such as validation_failed or handle_taken"},
+			"instance": {Type: Types{"string"}, Description: "The request path"},
+			"trace_id": typed("string"),
+			"errors":   {Type: Types{"array"}, Items: s.of(reflect.TypeOf(models.FieldError{})), Description: "What is wrong with each field, for validation_failed"},
+			"account":  {Ref: ref("AccountStanding").Ref, Description: "The account's standing, for account_suspended and account_banned"},
+			"reason":   {Type: Types{"string"}, Description: "Why the content was rejected, for content_rejected"},
+		},
+		Required: []string{"type", "title", "status", "code"},
+	}
+}
+
+// modelTypes are the types of package models that appear in the API
+var modelTypes = []any{
+	models.User{},
+	models.Profile{},
+	models.FollowUser{},
+	models.FollowPage{},
+	models.RelatedUser{},
+	models.RelatedUserPage{},
+	models.Message{},
+	models.Reply{},
+	models.Mention{},
+	models.Mentions{},
+	models.FieldError{},
+	models.CreateMessageRequest{},
+	models.UpdateMessageRequest{},
+	models.CreateReplyRequest{},
+	models.VoteRequest{},
+	models.VoteResponse{},
+	models.Tag{},
+	models.MessagePage{},
+	models.Conversation{},
+	models.ConversationParticipant{},
+	models.ConversationPage{},
+	models.DirectMessage{},
+	models.DirectMessagePage{},
+	models.CreateConversationRequest{},
+	models.SendDirectMessageRequest{},
+	models.MarkConversationReadRequest{},
+	models.SearchResult{},
+	models.SearchPage{},
+	models.Notification{},
+	models.NotificationPage{},
+	models.UnreadCounts{},
+	models.NotificationPreferences{},
+	models.Report{},
+	models.ReportPage{},
+	models.CreateReportRequest{},
+	models.ResolveReportRequest{},
+	models.SuspendUserRequest{},
+	models.BanUserRequest{},
+	models.AuditEntry{},
+	models.AuditLogPage{},
+	models.Webhook{},
+	models.CreateWebhookRequest{},
+	models.UpdateWebhookRequest{},
+	models.WebhookDelivery{},
+	models.WebhookDeliveryPage{},
+	models.SignupRequest{},
+	models.UpdateHandleRequest{},
+	models.LoginRequest{},
+	models.AuthResponse{},
+	accounts.Standing{},
+}
@@ -0,0 +1,303 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Schema is a JSON Schema, as OpenAPI 3.1 uses it. AdditionalProperties is
+// a *Schema, or false to allow none; left nil, any are allowed.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Const                any                `json:"const,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Examples             []any              `json:"examples,omitempty"`
+}
+
+// Types is a schema's type: one JSON type, or several such as a string or
+// null
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Has reports whether name is one of the types
+func (t Types) Has(name string) bool {
+	for _, typ := range t {
+		if typ == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ref points to the component schema name
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func typed(types ...string) *Schema {
+	return &Schema{Type: Types(types)}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	stringArray    = reflect.TypeOf(pq.StringArray{})
+)
+
+// schemas derives component schemas from Go types, registering each named
+// struct, slice and map type once under its name
+type schemas struct {
+	components map[string]*Schema
+	// names renames types whose Go name would be unclear in the API
+	names map[reflect.Type]string
+}
+
+// name is the component name of t
+func (s *schemas) name(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	return t.Name()
+}
+
+// of returns the schema of values of t: a reference for named types,
+// registered as components along the way
+func (s *schemas) of(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case t == rawMessageType:
+		// Any JSON value
+		return &Schema{}
+	case t == stringArray:
+		// Stored arrays are NULL when never set
+		return &Schema{Type: Types{"array", "null"}, Items: typed("string")}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(s.of(t.Elem()))
+	case reflect.String:
+		return typed("string")
+	case reflect.Bool:
+		return typed("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return typed("integer")
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}, Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: Types{"number"}, Format: "float"}
+	case reflect.Float64:
+		return typed("number")
+	}
+
+	if t.Name() == "" {
+		return s.define(t)
+	}
+	name := s.name(t)
+	if _, ok := s.components[name]; !ok {
+		// Placeholder first, so recursive types end
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.define(t)
+	}
+	return ref(name)
+}
+
+// define builds the schema of a struct, slice or map type
+func (s *schemas) define(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: Types{"array"}, Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}, AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		return s.object(t)
+	}
+	// Any JSON value
+	return &Schema{}
+}
+
+// object builds the schema of a struct from its json and binding tags.
+// Request structs require the fields bound as required; the others are
+// responses, which always carry every field not tagged omitempty. Neither
+// has fields the struct does not.
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: Types{"object"}, Properties: map[string]*Schema{}, AdditionalProperties: false}
+	request := strings.HasSuffix(t.Name(), "Request")
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.of(field.Type)
+		binding := field.Tag.Get("binding")
+		if binding != "" {
+			property = constrain(property, field.Type, binding)
+		}
+		if request && !hasRule(binding, "required") && len(property.AnyOf) == 0 &&
+			(field.Type.Kind() == reflect.Slice || field.Type.Kind() == reflect.Map) {
+			// Decoded from null, an optional list is just empty
+			property = nullable(property)
+		}
+		schema.Properties[name] = property
+
+		if request && hasRule(binding, "required") || !request && !hasOption(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// nullable lets schema also be null
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AnyOf: []*Schema{schema, typed("null")}}
+	}
+	copied := *schema
+	if !copied.Type.Has("null") {
+		copied.Type = append(append(Types{}, copied.Type...), "null")
+	}
+	return &copied
+}
+
+// constrain adds the validator rules of a binding tag to a copy of schema.
+// Under omitempty the rules hold only for values other than the zero value,
+// which is allowed too.
+func constrain(schema *Schema, t reflect.Type, binding string) *Schema {
+	copied := *schema
+	pointer := t.Kind() == reflect.Ptr
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			// Required strings and lists are not empty either
+			one := 1
+			switch t.Kind() {
+			case reflect.String:
+				copied.MinLength = &one
+			case reflect.Slice, reflect.Map:
+				copied.MinItems = &one
+			}
+		case "email":
+			copied.Format = "email"
+		case "url":
+			copied.Format = "uri"
+		case "uuid":
+			copied.Format = "uuid"
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				if n, err := strconv.Atoi(value); err == nil && t.Kind() != reflect.String {
+					copied.Enum = append(copied.Enum, n)
+				} else {
+					copied.Enum = append(copied.Enum, value)
+				}
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			switch t.Kind() {
+			case reflect.String:
+				setBound(name, &copied.MinLength, &copied.MaxLength, n)
+			case reflect.Slice, reflect.Array, reflect.Map:
+				setBound(name, &copied.MinItems, &copied.MaxItems, n)
+			default:
+				f := float64(n)
+				if name == "min" {
+					copied.Minimum = &f
+				} else {
+					copied.Maximum = &f
+				}
+			}
+		}
+	}
+
+	if pointer || !hasRule(binding, "omitempty") || reflect.DeepEqual(&copied, schema) {
+		return &copied
+	}
+	var zero *Schema
+	switch t.Kind() {
+	case reflect.String:
+		zero = &Schema{Const: ""}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		none := 0
+		zero = &Schema{Type: Types{"array", "null"}, MaxItems: &none}
+	default:
+		zero = &Schema{Const: 0}
+	}
+	return &Schema{AnyOf: []*Schema{&copied, zero}}
+}
+
+func setBound(rule string, min, max **int, n int) {
+	if rule == "min" {
+		*min = &n
+	} else {
+		*max = &n
+	}
+}
+
+// hasRule reports whether a binding tag has rule, not counting conditional
+// ones such as required_if
+func hasRule(binding, rule string) bool {
+	for _, r := range strings.Split(binding, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,444 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"why-backend/internal/models"
+)
+
+// Find returns the operation serving method on path, which is either a gin
+// route pattern such as /api/v1/messages/:id or a request path. Literal
+// segments win over parameters, as in the router.
+func (d *Document) Find(method, path string) *Operation {
+	segments := strings.Split(openAPIPath(path), "/")
+	var (
+		found *Operation
+		best  = -1
+	)
+	for pattern, item := range d.Paths {
+		op := item[strings.ToLower(method)]
+		if op == nil {
+			continue
+		}
+		if score, ok := match(strings.Split(pattern, "/"), segments); ok && score > best {
+			found, best = op, score
+		}
+	}
+	return found
+}
+
+// match reports whether segments match a pattern's, and how many literally
+func match(pattern, segments []string) (int, bool) {
+	if len(pattern) != len(segments) {
+		return 0, false
+	}
+	literal := 0
+	for i, p := range pattern {
+		switch {
+		case p == segments[i]:
+			literal++
+		case strings.HasPrefix(p, "{") && segments[i] != "":
+		default:
+			return 0, false
+		}
+	}
+	return literal, true
+}
+
+// ValidateRequest checks the query parameters and JSON body of r against op,
+// leaving the body to be read again. An empty or malformed body is left for
+// the handler to report. Errors about the body as a whole have no Field.
+func (d *Document) ValidateRequest(op *Operation, r *http.Request) []models.FieldError {
+	v := validator{doc: d}
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		if p.In == "query" && query.Has(p.Name) {
+			v.parameter(p, query.Get(p.Name))
+		}
+	}
+
+	if op.RequestBody == nil || r.Body == nil || !isJSON(r.Header.Get("Content-Type")) {
+		return v.errs
+	}
+	content, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return v.errs
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		// Such as a body over the size limit: the handler reads the error too
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), failingReader{err}))
+		return v.errs
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if value, ok := decode(data); ok {
+		v.check(content.Schema, value, "")
+	}
+	return v.errs
+}
+
+// ValidateResponse checks that a response of op is documented: its status,
+// its content type and, for JSON, its body
+func (d *Document) ValidateResponse(op *Operation, status int, header http.Header, body []byte) error {
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+	if len(response.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("status %d has no body, but got %d bytes", status, len(body))
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	content, ok := response.Content[mediaType]
+	if !ok {
+		if content, ok = response.Content["*/*"]; !ok {
+			return fmt.Errorf("content type %q is not documented for status %d", mediaType, status)
+		}
+	}
+	if !isJSON(mediaType) {
+		return nil
+	}
+
+	value, ok := decode(body)
+	if !ok {
+		return errors.New("body is not valid JSON")
+	}
+	v := validator{doc: d}
+	v.check(content.Schema, value, "")
+	if len(v.errs) == 0 {
+		return nil
+	}
+	problems := make([]string, len(v.errs))
+	for i, e := range v.errs {
+		problems[i] = strings.TrimSpace(e.Field + " " + e.Message)
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// Validate checks a JSON value, as decoded with numbers as json.Number,
+// against schema
+func (d *Document) Validate(schema *Schema, value any) []models.FieldError {
+	v := validator{doc: d}
+	v.check(schema, value, "")
+	return v.errs
+}
+
+// failingReader fails every read with err
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func decode(data []byte) (any, bool) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// validator collects what is wrong with a value, naming each field by its
+// path: media_urls[1], user.handle. Messages read like the handlers' own.
+type validator struct {
+	doc  *Document
+	errs []models.FieldError
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.errs = append(v.errs, models.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolve follows a reference to a component schema
+func (v *validator) resolve(schema *Schema) *Schema {
+	for schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, ok := v.doc.Components.Schemas[name]
+		if !ok {
+			panic("openapi: unknown schema " + schema.Ref)
+		}
+		schema = resolved
+	}
+	return schema
+}
+
+// parameter checks a query parameter, whose value is always a string
+func (v *validator) parameter(p *Parameter, raw string) {
+	var value any = raw
+	if p.Schema.Type.Has("integer") || p.Schema.Type.Has("number") {
+		value = json.Number(raw)
+	}
+	v.check(p.Schema, value, p.Name)
+}
+
+func (v *validator) check(schema *Schema, value any, path string) {
+	schema = v.resolve(schema)
+
+	if len(schema.AnyOf) > 0 {
+		v.anyOf(schema.AnyOf, value, path)
+		return
+	}
+	if schema.Const != nil && !equal(schema.Const, value) {
+		v.add(path, "must be %s", display(schema.Const))
+		return
+	}
+	if len(schema.Type) > 0 && !v.hasType(schema.Type, value) {
+		v.add(path, "must be %s", typeName(schema.Type))
+		return
+	}
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(e any) bool { return equal(e, value) }) {
+		values := make([]string, len(schema.Enum))
+		for i, e := range schema.Enum {
+			values[i] = fmt.Sprint(e)
+		}
+		v.add(path, "must be one of: %s", strings.Join(values, ", "))
+		return
+	}
+
+	switch value := value.(type) {
+	case string:
+		v.string(schema, value, path)
+	case json.Number:
+		v.number(schema, value, path)
+	case []any:
+		v.array(schema, value, path)
+	case map[string]any:
+		v.object(schema, value, path)
+	}
+}
+
+// anyOf checks value against the first alternative of its type, which says
+// best what is wrong
+func (v *validator) anyOf(alternatives []*Schema, value any, path string) {
+	var candidates []*Schema
+	for _, alternative := range alternatives {
+		alternative = v.resolve(alternative)
+		nested := validator{doc: v.doc}
+		nested.check(alternative, value, path)
+		if len(nested.errs) == 0 {
+			return
+		}
+		if len(alternative.Type) == 0 || v.hasType(alternative.Type, value) {
+			candidates = append(candidates, alternative)
+		}
+	}
+	if len(candidates) == 0 {
+		var types Types
+		for _, alternative := range alternatives {
+			types = append(types, v.resolve(alternative).Type...)
+		}
+		v.add(path, "must be %s", typeName(types))
+		return
+	}
+	v.check(candidates[0], value, path)
+}
+
+func (v *validator) hasType(types Types, value any) bool {
+	for _, t := range types {
+		switch value := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case json.Number:
+			if t == "number" {
+				if _, err := value.Float64(); err == nil {
+					return true
+				}
+			}
+			if t == "integer" {
+				if _, err := value.Int64(); err == nil {
+					return true
+				}
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case map[string]any:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var uuidPattern = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+func (v *validator) string(schema *Schema, value, path string) {
+	switch schema.Format {
+	case "email":
+		if address, err := mail.ParseAddress(value); err != nil || address.Address != value {
+			v.add(path, "must be an email address")
+			return
+		}
+	case "uri":
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" {
+			v.add(path, "must be a URL")
+			return
+		}
+	case "uuid":
+		if !uuidPattern.MatchString(value) {
+			v.add(path, "must be a UUID")
+			return
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			v.add(path, "must be an RFC 3339 time")
+			return
+		}
+	}
+
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		if *schema.MinLength == 1 {
+			v.add(path, "is required")
+		} else {
+			v.add(path, "must be at least %d characters", *schema.MinLength)
+		}
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		v.add(path, "must be at most %d characters", *schema.MaxLength)
+	}
+}
+
+func (v *validator) number(schema *Schema, value json.Number, path string) {
+	n, err := value.Float64()
+	if err != nil {
+		return
+	}
+	if schema.Minimum != nil && n < *schema.Minimum {
+		v.add(path, "must be at least %s", strconv.FormatFloat(*schema.Minimum, 'f', -1, 64))
+	}
+	if schema.Maximum != nil && n > *schema.Maximum {
+		v.add(path, "must be at most %s", strconv.FormatFloat(*schema.Maximum, 'f', -1, 64))
+	}
+}
+
+func (v *validator) array(schema *Schema, value []any, path string) {
+	if schema.MinItems != nil && len(value) < *schema.MinItems {
+		v.add(path, "must have at least %d items", *schema.MinItems)
+	}
+	if schema.MaxItems != nil && len(value) > *schema.MaxItems {
+		v.add(path, "must have at most %d items", *schema.MaxItems)
+	}
+	if schema.Items != nil {
+		for i, item := range value {
+			v.check(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *validator) object(schema *Schema, value map[string]any, path string) {
+	for _, name := range schema.Required {
+		if _, ok := value[name]; !ok {
+			v.add(join(path, name), "is required")
+		}
+	}
+
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if property, ok := schema.Properties[name]; ok {
+			v.check(property, value[name], join(path, name))
+			continue
+		}
+		switch additional := schema.AdditionalProperties.(type) {
+		case bool:
+			if !additional {
+				v.add(join(path, name), "is not a known field")
+			}
+		case *Schema:
+			v.check(additional, value[name], join(path, name))
+		}
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// equal compares a schema's enum or const value with a decoded JSON value
+func equal(want, value any) bool {
+	if n, ok := value.(json.Number); ok {
+		switch want := want.(type) {
+		case int:
+			i, err := n.Int64()
+			return err == nil && i == int64(want)
+		case float64:
+			f, err := n.Float64()
+			return err == nil && f == want
+		}
+		return false
+	}
+	return want == value
+}
+
+func display(value any) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(value)
+}
+
+// typeName names JSON types the way bindJSON does: "a string", "an integer
+// or null"
+func typeName(types Types) string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		switch t {
+		case "null":
+			names = append(names, "null")
+		case "integer", "array", "object":
+			names = append(names, "an "+t)
+		default:
+			names = append(names, "a "+t)
+		}
+	}
+	return strings.Join(names, " or ")
+}
@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+)
+
+func TestFind(t *testing.T) {
+	doc := Spec()
+
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{"GET", "/api/v1/messages/:id", "getMessage"},
+		{"GET", "/api/v1/messages/3c2b1a00", "getMessage"},
+		{"PATCH", "/api/v1/messages/3c2b1a00", "updateMessage"},
+		// Literal segments win over parameters, as in the router
+		{"GET", "/api/v1/conversations/unread-count", "conversationUnreadCount"},
+		{"GET", "/api/v1/conversations/3c2b1a00", "getConversation"},
+		{"GET", "/api/v1/nothing", ""},
+		{"DELETE", "/api/v1/messages", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			op := doc.Find(tt.method, tt.path)
+			if tt.expected == "" {
+				assert.Nil(t, op)
+				return
+			}
+			require.NotNil(t, op)
+			assert.Equal(t, tt.expected, op.OperationID)
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc := Spec()
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		expected []models.FieldError
+	}{
+		{"valid", "/api/v1/reports", `{"target_type":"message","target_id":"3c2b1a00-0000-4000-8000-000000000001","reason":"spam"}`, nil},
+		{
+			"formats", "/api/v1/reports", `{"target_type":"message","target_id":"not-a-uuid","reason":"spam","details":null}`,
+			[]models.FieldError{{Field: "details", Message: "must be a string"}, {Field: "target_id", Message: "must be a UUID"}},
+		},
+		{
+			"email and length", "/api/v1/signup", `{"email":"nobody","password":"short"}`,
+			[]models.FieldError{{Field: "email", Message: "must be an email address"}, {Field: "password", Message: "must be at least 8 characters"}},
+		},
+		{
+			"nullable", "/api/v1/replies/r1/vote", `{"value":2}`,
+			[]models.FieldError{{Field: "value", Message: "must be one of: -1, 0, 1"}},
+		},
+		{"null optional list", "/api/v1/messages", `{"content":"Why?","media_urls":null}`, nil},
+		{
+			"body not an object", "/api/v1/login", `[]`,
+			[]models.FieldError{{Field: "", Message: "must be an object"}},
+		},
+		{"empty body left to the handler", "/api/v1/login", ``, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := "POST"
+			if strings.HasSuffix(tt.path, "/vote") {
+				method = "PUT"
+			}
+			req := httptest.NewRequest(method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			op := doc.Find(method, tt.path)
+			require.NotNil(t, op)
+			assert.Equal(t, tt.expected, doc.ValidateRequest(op, req))
+		})
+	}
+}
+
+func TestValidateRequest_LeavesBody(t *testing.T) {
+	doc := Spec()
+	req := httptest.NewRequest("POST", "/api/v1/login", strings.NewReader(`{"email":"a@example.com","password":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	assert.Empty(t, doc.ValidateRequest(doc.Find("POST", "/api/v1/login"), req))
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"email":"a@example.com","password":"x"}`, string(body))
+}
+
+func TestValidateResponse(t *testing.T) {
+	doc := Spec()
+	op := doc.Find("GET", "/api/v1/users/:id")
+	json := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+
+	assert.NoError(t, doc.ValidateResponse(op, http.StatusOK, json,
+		[]byte(`{"id":"u1","handle":null,"follower_count":0,"following_count":2,"created_at":"2026-01-02T03:04:05Z"}`)))
+
+	err := doc.ValidateResponse(op, http.StatusOK, json, []byte(`{"id":"u1","handle":null,"follower_count":0,"created_at":"yesterday","email":"a@example.com"}`))
+	require.Error(t, err)
+	assert.Equal(t, "following_count is required; created_at must be an RFC 3339 time; email is not a known field", err.Error())
+
+	assert.EqualError(t, doc.ValidateResponse(op, http.StatusConflict, json, nil), "status 409 is not documented")
+	assert.EqualError(t, doc.ValidateResponse(op, http.StatusOK, http.Header{"Content-Type": {"text/plain"}}, []byte("hi")),
+		`content type "text/plain" is not documented for status 200`)
+
+	problem := http.Header{"Content-Type": {"application/problem+json"}}
+	assert.NoError(t, doc.ValidateResponse(op, http.StatusNotFound, problem,
+		[]byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"user not found","code":"not_found"}`)))
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"database/sql"
+	"net/http"
 	"net/http/pprof"
 	"time"
 
@@ -10,27 +11,50 @@ import (
 	"github.com/minio/minio-go/v7"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"why-backend/internal/accounts"
 	"why-backend/internal/api/handlers"
 	"why-backend/internal/api/middleware"
+	"why-backend/internal/api/openapi"
 	"why-backend/internal/config"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+	"why-backend/internal/realtime"
+	"why-backend/internal/search"
+	"why-backend/internal/stream"
+	"why-backend/internal/timeline"
 )
 
-func NewRouter(db *sql.DB, minio *minio.Client, cfg *config.Config) *gin.Engine {
+// allowedOrigins are the browser origins allowed to call the API
+var allowedOrigins = []string{"http://why.local:8000", "http://localhost:3000"}
+
+func NewRouter(db *sql.DB, minio *minio.Client, publisher events.Publisher, searchIndex search.Index, streamHub *stream.Hub, socketHub *realtime.Hub, contentFilter moderation.Filter, cfg *config.Config) *gin.Engine {
 	r := gin.New()
-	r.Use(gin.Recovery())
+	r.Use(gin.CustomRecovery(middleware.Recovered))
 	r.Use(otelgin.Middleware("why-backend"))    // OpenTelemetry tracing
 	r.Use(middleware.MetricsMiddleware())        // OpenTelemetry metrics
+	r.Use(middleware.Errors())                   // Errors as application/problem+json
+
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(middleware.NotFound)
+	r.NoMethod(middleware.MethodNotAllowed)
 
 	// CORS middleware to allow browser requests
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://why.local:8000", "http://localhost:3000"},
+		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Last-Event-ID"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// Request bodies are bounded before anything reads them. Requests are
+	// checked against the OpenAPI document before the handlers see them,
+	// after CORS so that browsers can read the problems.
+	r.Use(middleware.BodyLimit(int64(cfg.ContentLimits.BodyBytes)))
+	r.Use(middleware.Validate(openapi.Spec()))
+
 	// Prometheus metrics endpoint for Alloy to scrape
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
@@ -58,30 +82,127 @@ func NewRouter(db *sql.DB, minio *minio.Client, cfg *config.Config) *gin.Engine
 		}
 	}
 
+	// Every authenticated request checks the account is not suspended or
+	// banned; standings are cached briefly
+	accountCache := accounts.NewCache(db, accounts.DefaultCacheTTL)
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db, cfg)
-	messageHandler := handlers.NewMessageHandler(db)
-	mediaHandler := handlers.NewMediaHandler(minio, cfg)
+	authHandler := handlers.NewAuthHandler(db, cfg, accountCache)
+	messageHandler := handlers.NewMessageHandler(db, publisher, contentFilter, cfg.ContentLimits)
+	mediaHandler := handlers.NewMediaHandler(db, minio, cfg)
+	tagHandler := handlers.NewTagHandler(db)
+	userHandler := handlers.NewUserHandler(db)
+	notificationHandler := handlers.NewNotificationHandler(db)
+	streamHandler := handlers.NewStreamHandler(streamHub)
+	webSocketHandler := handlers.NewWebSocketHandler(db, socketHub, allowedOrigins)
+	webhookHandler := handlers.NewWebhookHandler(db)
+	searchHandler := handlers.NewSearchHandler(db, searchIndex)
+	followHandler := handlers.NewFollowHandler(db)
+	timelineHandler := handlers.NewTimelineHandler(db, timeline.NewFanOutOnRead(db))
+	blockHandler := handlers.NewBlockHandler(db)
+	conversationHandler := handlers.NewConversationHandler(db, cfg.ContentLimits)
+	reportHandler := handlers.NewReportHandler(db)
+	moderationHandler := handlers.NewModerationHandler(db, publisher, accountCache)
 
 	// API v1 routes
 	v1 := r.Group("/api/v1")
 	{
+		// The OpenAPI document, and a page to browse it
+		v1.GET("/openapi.json", func(c *gin.Context) {
+			c.Data(http.StatusOK, "application/json", openapi.JSON())
+		})
+		v1.GET("/docs", func(c *gin.Context) {
+			c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+		})
+
 		// Public routes
 		v1.POST("/signup", authHandler.Signup)
 		v1.POST("/login", authHandler.Login)
 
-		// Public read-only routes
-		v1.GET("/messages", messageHandler.ListMessages)
-		v1.GET("/messages/:id", messageHandler.GetMessage)
-		v1.GET("/messages/:id/replies", messageHandler.ListReplies)
+		// Public read-only routes; message reads take an optional token to
+		// tailor results to the viewer (visibility, blocks and mutes)
+		viewer := middleware.OptionalAuth(cfg, accountCache)
+		v1.GET("/messages", viewer, messageHandler.ListMessages)
+		v1.GET("/messages/:id", viewer, messageHandler.GetMessage)
+		v1.GET("/messages/:id/replies", viewer, messageHandler.ListReplies)
+		v1.GET("/tags", tagHandler.ListTags)
+		v1.GET("/tags/:tag/messages", tagHandler.ListTagMessages)
+		v1.GET("/search", searchHandler.Search)
+		v1.GET("/users/:id", userHandler.GetUser)
+		v1.GET("/users/:id/followers", followHandler.ListFollowers)
+		v1.GET("/users/:id/following", followHandler.ListFollowing)
+		v1.GET("/stream", streamHandler.Stream)
+
+		// WebSocket: browsers cannot set the Authorization header on it
+		v1.GET("/ws", middleware.QueryTokenAuth(), middleware.AuthMiddleware(cfg, accountCache), webSocketHandler.Serve)
+
+		// Conversation media: for <img> and <video>, which cannot set it either
+		v1.GET("/conversations/:id/media/:name", middleware.QueryTokenAuth(), middleware.AuthMiddleware(cfg, accountCache), mediaHandler.GetConversationMedia)
 
 		// Protected routes (require authentication)
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg))
+		protected.Use(middleware.AuthMiddleware(cfg, accountCache))
 		{
+			protected.POST("/refresh", authHandler.RefreshToken)
 			protected.POST("/messages", messageHandler.CreateMessage)
+			protected.PATCH("/messages/:id", messageHandler.UpdateMessage)
 			protected.POST("/messages/:id/replies", messageHandler.CreateReply)
+			protected.POST("/messages/:id/replies/:reply_id/accept", messageHandler.AcceptReply)
+			protected.DELETE("/messages/:id/replies/:reply_id/accept", messageHandler.UnacceptReply)
+			protected.PUT("/replies/:id/vote", messageHandler.VoteReply)
 			protected.POST("/media", mediaHandler.UploadMedia)
+			protected.GET("/me", userHandler.GetMe)
+			protected.PUT("/me/handle", userHandler.UpdateHandle)
+			protected.PUT("/users/:id/follow", followHandler.Follow)
+			protected.DELETE("/users/:id/follow", followHandler.Unfollow)
+			protected.GET("/timeline/home", timelineHandler.Home)
+			protected.GET("/me/blocks", blockHandler.ListBlocks)
+			protected.PUT("/me/blocks/:id", blockHandler.Block)
+			protected.DELETE("/me/blocks/:id", blockHandler.Unblock)
+			protected.GET("/me/mutes", blockHandler.ListMutes)
+			protected.PUT("/me/mutes/:id", blockHandler.Mute)
+			protected.DELETE("/me/mutes/:id", blockHandler.Unmute)
+			protected.POST("/conversations", conversationHandler.CreateConversation)
+			protected.GET("/conversations", conversationHandler.ListConversations)
+			protected.GET("/conversations/unread-count", conversationHandler.UnreadCount)
+			protected.GET("/conversations/:id", conversationHandler.GetConversation)
+			protected.POST("/conversations/:id/messages", conversationHandler.SendMessage)
+			protected.GET("/conversations/:id/messages", conversationHandler.ListMessages)
+			protected.POST("/conversations/:id/read", conversationHandler.MarkRead)
+			protected.GET("/me/notifications", notificationHandler.ListNotifications)
+			protected.GET("/me/notifications/unread-count", notificationHandler.UnreadCount)
+			protected.POST("/me/notifications/read-all", notificationHandler.MarkAllRead)
+			protected.POST("/me/notifications/:id/read", notificationHandler.MarkRead)
+			protected.GET("/me/notification-preferences", notificationHandler.GetPreferences)
+			protected.PUT("/me/notification-preferences", notificationHandler.UpdatePreferences)
+			protected.POST("/webhooks", webhookHandler.CreateWebhook)
+			protected.GET("/webhooks", webhookHandler.ListWebhooks)
+			protected.GET("/webhooks/:id", webhookHandler.GetWebhook)
+			protected.PATCH("/webhooks/:id", webhookHandler.UpdateWebhook)
+			protected.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+			protected.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+			protected.POST("/reports", reportHandler.CreateReport)
+			protected.GET("/me/reports", reportHandler.ListMyReports)
+			protected.GET("/me/reports/:id", reportHandler.GetMyReport)
+
+			// Moderation (moderators and admins)
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireRole(db, models.RoleModerator, models.RoleAdmin))
+			{
+				admin.GET("/reports", moderationHandler.ListReports)
+				admin.GET("/reports/:id", moderationHandler.GetReport)
+				admin.POST("/reports/:id/claim", moderationHandler.ClaimReport)
+				admin.DELETE("/reports/:id/claim", moderationHandler.ReleaseReport)
+				admin.POST("/reports/:id/resolve", moderationHandler.ResolveReport)
+				admin.GET("/audit-log", moderationHandler.ListAuditLog)
+				admin.PUT("/users/:id/suspension", moderationHandler.SuspendUser)
+				admin.DELETE("/users/:id/suspension", moderationHandler.LiftSuspension)
+
+				// Bans are for admins only
+				adminOnly := middleware.RequireRole(db, models.RoleAdmin)
+				admin.PUT("/users/:id/ban", adminOnly, moderationHandler.BanUser)
+				admin.DELETE("/users/:id/ban", adminOnly, moderationHandler.UnbanUser)
+			}
 		}
 	}
 
@@ -3,9 +3,12 @@ package api
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,12 +17,27 @@ import (
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"why-backend/internal/api/apierror"
 	"why-backend/internal/api/middleware"
+	"why-backend/internal/api/openapi"
 	"why-backend/internal/auth"
+	"why-backend/internal/config"
+	"why-backend/internal/events"
 	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+	"why-backend/internal/realtime"
+	"why-backend/internal/search"
+	"why-backend/internal/stream"
 	"why-backend/internal/testutil"
 )
 
+// newTestRouter builds the router with an in-process event bus, Postgres
+// search, no MinIO and no content filter
+func newTestRouter(db *sql.DB, cfg *config.Config) *gin.Engine {
+	bus := events.NewMemoryBus()
+	return NewRouter(db, nil, bus, search.NewPostgres(db), stream.NewHub(bus, stream.Options{}), realtime.NewHub(bus, realtime.Options{}), moderation.Chain{}, cfg)
+}
+
 func TestRouter_HealthCheck(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db, _ := testutil.SetupTestDB(t)
@@ -29,7 +47,7 @@ func TestRouter_HealthCheck(t *testing.T) {
 	_ = middleware.InitMetrics(context.Background())
 
 	cfg := testutil.GetTestConfig()
-	router := NewRouter(db, nil, cfg)
+	router := newTestRouter(db, cfg)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/health", nil)
@@ -51,7 +69,7 @@ func TestRouter_CORS(t *testing.T) {
 	_ = middleware.InitMetrics(context.Background())
 
 	cfg := testutil.GetTestConfig()
-	router := NewRouter(db, nil, cfg)
+	router := newTestRouter(db, cfg)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("OPTIONS", "/api/v1/messages", nil)
@@ -72,12 +90,13 @@ func TestRouter_PublicRoutes(t *testing.T) {
 	_ = middleware.InitMetrics(context.Background())
 
 	cfg := testutil.GetTestConfig()
-	router := NewRouter(db, nil, cfg)
+	router := newTestRouter(db, cfg)
 
 	tests := []struct {
 		name           string
 		method         string
 		path           string
+		header         string
 		setupMock      func()
 		expectedStatus int
 	}{
@@ -86,12 +105,32 @@ func TestRouter_PublicRoutes(t *testing.T) {
 			method: "GET",
 			path:   "/api/v1/messages",
 			setupMock: func() {
-				rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "created_at", "updated_at"})
-				mock.ExpectQuery("SELECT id, user_id, content, media_urls, created_at, updated_at FROM messages").
+				rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"})
+				mock.ExpectQuery("SELECT m.id, m.user_id, .+ FROM messages m").
 					WillReturnRows(rows)
 			},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "list messages with an invalid token",
+			method:         "GET",
+			path:           "/api/v1/messages",
+			header:         "Bearer invalid",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "reply list with a malformed authorization header",
+			method:         "GET",
+			path:           "/api/v1/messages/msg-123/replies",
+			header:         "Token abc",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "stream rejects invalid thread",
+			method:         "GET",
+			path:           "/api/v1/stream?message_id=not-a-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -102,6 +141,9 @@ func TestRouter_PublicRoutes(t *testing.T) {
 
 			w := httptest.NewRecorder()
 			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
 			router.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
@@ -117,7 +159,7 @@ func TestRouter_ProtectedRoutes_RequireAuth(t *testing.T) {
 	_ = middleware.InitMetrics(context.Background())
 
 	cfg := testutil.GetTestConfig()
-	router := NewRouter(db, nil, cfg)
+	router := newTestRouter(db, cfg)
 
 	protectedRoutes := []struct {
 		method string
@@ -126,6 +168,44 @@ func TestRouter_ProtectedRoutes_RequireAuth(t *testing.T) {
 		{"POST", "/api/v1/messages"},
 		{"POST", "/api/v1/messages/123/replies"},
 		{"POST", "/api/v1/media"},
+		{"GET", "/api/v1/me/notifications"},
+		{"PUT", "/api/v1/me/notification-preferences"},
+		{"POST", "/api/v1/webhooks"},
+		{"GET", "/api/v1/webhooks"},
+		{"DELETE", "/api/v1/webhooks/00000000-0000-0000-0000-000000000000"},
+		{"GET", "/api/v1/webhooks/00000000-0000-0000-0000-000000000000/deliveries"},
+		{"PUT", "/api/v1/users/00000000-0000-0000-0000-000000000000/follow"},
+		{"DELETE", "/api/v1/users/00000000-0000-0000-0000-000000000000/follow"},
+		{"GET", "/api/v1/timeline/home"},
+		{"GET", "/api/v1/me/blocks"},
+		{"PUT", "/api/v1/me/blocks/00000000-0000-0000-0000-000000000000"},
+		{"DELETE", "/api/v1/me/blocks/00000000-0000-0000-0000-000000000000"},
+		{"GET", "/api/v1/me/mutes"},
+		{"PUT", "/api/v1/me/mutes/00000000-0000-0000-0000-000000000000"},
+		{"DELETE", "/api/v1/me/mutes/00000000-0000-0000-0000-000000000000"},
+		{"POST", "/api/v1/conversations"},
+		{"GET", "/api/v1/conversations"},
+		{"GET", "/api/v1/conversations/unread-count"},
+		{"GET", "/api/v1/conversations/00000000-0000-0000-0000-000000000000"},
+		{"POST", "/api/v1/conversations/00000000-0000-0000-0000-000000000000/messages"},
+		{"GET", "/api/v1/conversations/00000000-0000-0000-0000-000000000000/messages"},
+		{"POST", "/api/v1/conversations/00000000-0000-0000-0000-000000000000/read"},
+		{"GET", "/api/v1/conversations/00000000-0000-0000-0000-000000000000/media/00000000-0000-0000-0000-000000000000.png"},
+		{"GET", "/api/v1/ws"},
+		{"POST", "/api/v1/reports"},
+		{"GET", "/api/v1/me/reports"},
+		{"GET", "/api/v1/me/reports/00000000-0000-0000-0000-000000000000"},
+		{"GET", "/api/v1/admin/reports"},
+		{"GET", "/api/v1/admin/reports/00000000-0000-0000-0000-000000000000"},
+		{"POST", "/api/v1/admin/reports/00000000-0000-0000-0000-000000000000/claim"},
+		{"DELETE", "/api/v1/admin/reports/00000000-0000-0000-0000-000000000000/claim"},
+		{"POST", "/api/v1/admin/reports/00000000-0000-0000-0000-000000000000/resolve"},
+		{"GET", "/api/v1/admin/audit-log"},
+		{"PUT", "/api/v1/admin/users/00000000-0000-0000-0000-000000000000/suspension"},
+		{"DELETE", "/api/v1/admin/users/00000000-0000-0000-0000-000000000000/suspension"},
+		{"PUT", "/api/v1/admin/users/00000000-0000-0000-0000-000000000000/ban"},
+		{"DELETE", "/api/v1/admin/users/00000000-0000-0000-0000-000000000000/ban"},
+		{"POST", "/api/v1/refresh"},
 	}
 
 	for _, route := range protectedRoutes {
@@ -148,7 +228,7 @@ func TestRouter_ProtectedRoutes_WithAuth(t *testing.T) {
 	_ = middleware.InitMetrics(context.Background())
 
 	cfg := testutil.GetTestConfig()
-	router := NewRouter(db, nil, cfg)
+	router := newTestRouter(db, cfg)
 
 	// Generate valid token
 	userID := "user-123"
@@ -164,12 +244,15 @@ func TestRouter_ProtectedRoutes_WithAuth(t *testing.T) {
 	body, _ := json.Marshal(createReq)
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "created_at", "updated_at"}).
-		AddRow("msg-123", userID, createReq.Content, pq.Array(createReq.MediaURLs), now, now)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "mentions", "created_at", "updated_at", "visibility"}).
+		AddRow("msg-123", userID, createReq.Content, pq.Array(createReq.MediaURLs), nil, `[]`, now, now, "public")
 
+	expectStanding(mock, userID, nil)
+	mock.ExpectBegin()
 	mock.ExpectQuery("INSERT INTO messages").
-		WithArgs(userID, createReq.Content, sqlmock.AnyArg()).
+		WithArgs(userID, createReq.Content, sqlmock.AnyArg(), sqlmock.AnyArg(), models.VisibilityPublic).
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("POST", "/api/v1/messages", bytes.NewBuffer(body))
@@ -181,6 +264,44 @@ func TestRouter_ProtectedRoutes_WithAuth(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, w.Code)
 }
 
+// expectStanding expects userID's account standing to be read, suspended
+// until suspendedUntil when it is set
+func expectStanding(mock sqlmock.Sqlmock, userID string, suspendedUntil *time.Time) {
+	mock.ExpectQuery("SELECT banned_at IS NOT NULL, .+ FROM users WHERE id = \\$1").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned", "ban_reason", "suspended_until", "suspension_reason"}).
+			AddRow(false, "", suspendedUntil, "spam"))
+}
+
+func TestRouter_SuspendedAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	_ = middleware.InitMetrics(context.Background())
+
+	cfg := testutil.GetTestConfig()
+	router := newTestRouter(db, cfg)
+
+	token, err := auth.GenerateToken("user-123", "test@example.com", cfg.JWTSecret)
+	require.NoError(t, err)
+
+	// Read once: the second request is answered from the cache
+	until := time.Now().Add(24 * time.Hour)
+	expectStanding(mock, "user-123", &until)
+
+	for _, path := range []string{"/api/v1/me", "/api/v1/timeline/home"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+		assert.Contains(t, w.Body.String(), "account suspended")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestRouter_MetricsEndpoint(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db, _ := testutil.SetupTestDB(t)
@@ -189,7 +310,7 @@ func TestRouter_MetricsEndpoint(t *testing.T) {
 	_ = middleware.InitMetrics(context.Background())
 
 	cfg := testutil.GetTestConfig()
-	router := NewRouter(db, nil, cfg)
+	router := newTestRouter(db, cfg)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/metrics", nil)
@@ -208,13 +329,22 @@ func TestRouter_NotFound(t *testing.T) {
 	_ = middleware.InitMetrics(context.Background())
 
 	cfg := testutil.GetTestConfig()
-	router := NewRouter(db, nil, cfg)
+	router := newTestRouter(db, cfg)
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/nonexistent", nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, apierror.ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"code":"not_found"`)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("DELETE", "/health", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"method_not_allowed"`)
 }
 
 // Integration test: Full signup -> login -> create message flow
@@ -226,7 +356,7 @@ func TestRouter_FullAuthFlow(t *testing.T) {
 	_ = middleware.InitMetrics(context.Background())
 
 	cfg := testutil.GetTestConfig()
-	router := NewRouter(db, nil, cfg)
+	router := newTestRouter(db, cfg)
 
 	email := "integration@test.com"
 	password := "password123"
@@ -240,11 +370,11 @@ func TestRouter_FullAuthFlow(t *testing.T) {
 	body, _ := json.Marshal(signupReq)
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "email", "created_at", "updated_at"}).
-		AddRow(userID, email, now, now)
+	rows := sqlmock.NewRows([]string{"id", "email", "handle", "role", "created_at", "updated_at"}).
+		AddRow(userID, email, nil, models.RoleUser, now, now)
 
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs(email, sqlmock.AnyArg()).
+		WithArgs(email, sqlmock.AnyArg(), "").
 		WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
@@ -267,12 +397,15 @@ func TestRouter_FullAuthFlow(t *testing.T) {
 	}
 	body, _ = json.Marshal(createReq)
 
-	msgRows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "created_at", "updated_at"}).
-		AddRow("msg-1", userID, createReq.Content, pq.Array(createReq.MediaURLs), now, now)
+	msgRows := sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "mentions", "created_at", "updated_at", "visibility"}).
+		AddRow("msg-1", userID, createReq.Content, pq.Array(createReq.MediaURLs), nil, `[]`, now, now, "public")
 
+	expectStanding(mock, userID, nil)
+	mock.ExpectBegin()
 	mock.ExpectQuery("INSERT INTO messages").
-		WithArgs(userID, createReq.Content, sqlmock.AnyArg()).
+		WithArgs(userID, createReq.Content, sqlmock.AnyArg(), sqlmock.AnyArg(), models.VisibilityPublic).
 		WillReturnRows(msgRows)
+	mock.ExpectCommit()
 
 	w = httptest.NewRecorder()
 	req = httptest.NewRequest("POST", "/api/v1/messages", bytes.NewBuffer(body))
@@ -292,3 +425,87 @@ func TestRouter_FullAuthFlow(t *testing.T) {
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
+
+func TestRouter_RequestBodyTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	_ = middleware.InitMetrics(context.Background())
+
+	cfg := testutil.GetTestConfig()
+	cfg.ContentLimits.BodyBytes = 64
+	router := newTestRouter(db, cfg)
+
+	body := `{"email":"someone@example.com","password":"` + strings.Repeat("x", 100) + `"}`
+	for _, declared := range []bool{true, false} {
+		req := httptest.NewRequest("POST", "/api/v1/signup", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if !declared {
+			// Read through the OpenAPI validation before the handler finds out
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "declared length: %v", declared)
+		assert.Contains(t, w.Body.String(), apierror.CodeRequestTooLarge)
+	}
+}
+
+func TestRouter_OpenAPICoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	_ = middleware.InitMetrics(context.Background())
+
+	router := newTestRouter(db, testutil.GetTestConfig())
+	spec := openapi.Spec()
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		path := regexp.MustCompile(`:(\w+)`).ReplaceAllString(route.Path, "{$1}")
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		item, ok := spec.Paths[path]
+		if assert.True(t, ok, "%s %s is missing from the OpenAPI document", route.Method, route.Path) {
+			assert.Contains(t, item, method, "%s %s is missing from the OpenAPI document", route.Method, route.Path)
+		}
+	}
+
+	// And the document describes no route that does not exist
+	for path, item := range spec.Paths {
+		for method := range item {
+			assert.True(t, registered[method+" "+path], "%s %s is not a route", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestRouter_OpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	_ = middleware.InitMetrics(context.Background())
+
+	router := newTestRouter(db, testutil.GetTestConfig())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/api/v1/messages/{id}")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/docs", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "/api/v1/openapi.json")
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+
+	"why-backend/internal/content"
 )
 
 // postgres://${POSTGRES_USER}:${POSTGRES_PASSWORD}@${POSTGRES_HOST}:${POSTGRES_PORT}/${POSTGRES_DB}?sslmode=${POSTGRES_SSLMODE}
@@ -13,8 +16,29 @@ type Config struct {
 	OTLPEndpoint string
 	JWTSecret    string
 	EnablePprof  bool
+	// WebhookAllowPrivateNetworks lets webhooks target private addresses
+	WebhookAllowPrivateNetworks bool
+	// JobConcurrency is how many background jobs a process runs at once;
+	// 0 leaves jobs to dedicated workers
+	JobConcurrency int
+	// SearchBackend is postgres or bleve; SearchIndexPath is where the
+	// bleve index lives
+	SearchBackend   string
+	SearchIndexPath string
+	// ContentFilterConfig is a JSON file configuring the content filter;
+	// without one the defaults apply
+	ContentFilterConfig string
+	// ContentLimits bound the length and attachments of messages, replies
+	// and direct messages, and the size of request bodies
+	ContentLimits content.Limits
 }
 
+// Search backends
+const (
+	SearchPostgres = "postgres"
+	SearchBleve    = "bleve"
+)
+
 func (c *Config) PostgresURL() string {
 	// Validate that all required fields are set
 	if c.Postgres.User == "unset" ||
@@ -64,9 +88,13 @@ func Load() (*Config, error) {
 			DB:       getEnv("POSTGRES_DB", "unset"),
 			SSLMode:  getEnv("POSTGRES_SSLMODE", "unset"),
 		},
-		OTLPEndpoint: getEnv("OTLP_ENDPOINT", "alloy.monitoring.svc.cluster.local:4317"),
-		JWTSecret:    getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		EnablePprof:  getEnv("ENABLE_PPROF", "false") == "true",
+		OTLPEndpoint:                getEnv("OTLP_ENDPOINT", "alloy.monitoring.svc.cluster.local:4317"),
+		JWTSecret:                   getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		EnablePprof:                 getEnv("ENABLE_PPROF", "false") == "true",
+		WebhookAllowPrivateNetworks: getEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "false") == "true",
+		SearchBackend:               getEnv("SEARCH_BACKEND", SearchPostgres),
+		SearchIndexPath:             getEnv("SEARCH_INDEX_PATH", "data/search.bleve"),
+		ContentFilterConfig:         getEnv("CONTENT_FILTER_CONFIG", ""),
 		MinIO: MinIOConfig{
 			Endpoint:        getEnv("MINIO_ENDPOINT", "loki-minio.monitoring.svc.cluster.local:9000"),
 			AccessKeyID:     getEnv("MINIO_ACCESS_KEY", "loki"),
@@ -76,6 +104,33 @@ func Load() (*Config, error) {
 		},
 	}
 
+	jobConcurrency, err := strconv.Atoi(getEnv("JOB_CONCURRENCY", "4"))
+	if err != nil || jobConcurrency < 0 {
+		return nil, fmt.Errorf("JOB_CONCURRENCY must be a non-negative integer")
+	}
+	cfg.JobConcurrency = jobConcurrency
+
+	for _, limit := range []struct {
+		key   string
+		value *int
+		def   int
+	}{
+		{"MAX_MESSAGE_LENGTH", &cfg.ContentLimits.MessageLength, content.DefaultLimits.MessageLength},
+		{"MAX_REPLY_LENGTH", &cfg.ContentLimits.ReplyLength, content.DefaultLimits.ReplyLength},
+		{"MAX_ATTACHMENTS", &cfg.ContentLimits.Attachments, content.DefaultLimits.Attachments},
+		{"MAX_BODY_BYTES", &cfg.ContentLimits.BodyBytes, content.DefaultLimits.BodyBytes},
+	} {
+		value, err := strconv.Atoi(getEnv(limit.key, strconv.Itoa(limit.def)))
+		if err != nil || value < 1 {
+			return nil, fmt.Errorf("%s must be a positive integer", limit.key)
+		}
+		*limit.value = value
+	}
+
+	if cfg.SearchBackend != SearchPostgres && cfg.SearchBackend != SearchBleve {
+		return nil, fmt.Errorf("SEARCH_BACKEND must be postgres or bleve")
+	}
+
 	if cfg.PostgresURL() == "" {
 		return nil, fmt.Errorf("POSTGRES_USER, POSTGRES_PASSWORD, POSTGRES_HOST, POSTGRES_PORT, POSTGRES_DB and POSTGRES_SSLMODE are required")
 	}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"why-backend/internal/content"
 )
 
 func TestLoad(t *testing.T) {
@@ -63,6 +64,9 @@ func TestLoad(t *testing.T) {
 				assert.Equal(t, "your-secret-key-change-in-production", cfg.JWTSecret)         // Default
 				assert.Equal(t, "loki-minio.monitoring.svc.cluster.local:9000", cfg.MinIO.Endpoint) // Default
 				assert.False(t, cfg.MinIO.UseSSL)                                              // Default
+				assert.Equal(t, 4, cfg.JobConcurrency)                                         // Default
+				assert.Equal(t, SearchPostgres, cfg.SearchBackend)                             // Default
+				assert.Equal(t, content.DefaultLimits, cfg.ContentLimits)                      // Default
 			},
 		},
 		{
@@ -104,6 +108,63 @@ func TestLoad(t *testing.T) {
 				assert.False(t, cfg.MinIO.UseSSL) // Only "true" sets it to true
 			},
 		},
+		{
+			name: "invalid JOB_CONCURRENCY",
+			envVars: map[string]string{
+				"POSTGRES_USER":     "user",
+				"POSTGRES_PASSWORD": "pass",
+				"POSTGRES_HOST":     "localhost",
+				"POSTGRES_PORT":     "5432",
+				"POSTGRES_DB":       "db",
+				"POSTGRES_SSLMODE":  "disable",
+				"JOB_CONCURRENCY":   "-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "content limits",
+			envVars: map[string]string{
+				"POSTGRES_USER":      "user",
+				"POSTGRES_PASSWORD":  "pass",
+				"POSTGRES_HOST":      "localhost",
+				"POSTGRES_PORT":      "5432",
+				"POSTGRES_DB":        "db",
+				"POSTGRES_SSLMODE":   "disable",
+				"MAX_MESSAGE_LENGTH": "280",
+				"MAX_ATTACHMENTS":    "1",
+				"MAX_BODY_BYTES":     "4096",
+			},
+			wantErr: false,
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, content.Limits{MessageLength: 280, ReplyLength: content.DefaultLimits.ReplyLength, Attachments: 1, BodyBytes: 4096}, cfg.ContentLimits)
+			},
+		},
+		{
+			name: "invalid MAX_REPLY_LENGTH",
+			envVars: map[string]string{
+				"POSTGRES_USER":     "user",
+				"POSTGRES_PASSWORD": "pass",
+				"POSTGRES_HOST":     "localhost",
+				"POSTGRES_PORT":     "5432",
+				"POSTGRES_DB":       "db",
+				"POSTGRES_SSLMODE":  "disable",
+				"MAX_REPLY_LENGTH":  "0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid SEARCH_BACKEND",
+			envVars: map[string]string{
+				"POSTGRES_USER":     "user",
+				"POSTGRES_PASSWORD": "pass",
+				"POSTGRES_HOST":     "localhost",
+				"POSTGRES_PORT":     "5432",
+				"POSTGRES_DB":       "db",
+				"POSTGRES_SSLMODE":  "disable",
+				"SEARCH_BACKEND":    "elastic",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,82 @@
+package content
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxTagLength is the longest hashtag (in runes) that is recognised
+const MaxTagLength = 50
+
+// ExtractHashtags returns the normalized, de-duplicated hashtags in text in
+// order of first appearance. A hashtag is '#' at the start of the text or
+// after whitespace or an opening bracket, followed by letters, digits or
+// underscores, containing at least one letter.
+func ExtractHashtags(text string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+
+	for i := 0; i < len(text); {
+		if text[i] != '#' || !isTokenBoundary(text, i) {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+			continue
+		}
+
+		end := i + 1
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !isTagRune(r) {
+				break
+			}
+			end += size
+		}
+
+		if tag := NormalizeTag(text[i+1 : end]); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+		i = end
+	}
+
+	return tags
+}
+
+// NormalizeTag lowercases a tag (with or without its leading '#') and returns
+// "" if it is not a valid hashtag
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return ""
+	}
+
+	hasLetter := false
+	for _, r := range tag {
+		if !isTagRune(r) {
+			return ""
+		}
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		}
+	}
+	if !hasLetter {
+		return ""
+	}
+
+	return tag
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isTokenBoundary reports whether the sigil at text[i] starts a new token,
+// so "foo#bar" and URL fragments are not treated as tags
+func isTokenBoundary(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsSpace(r) || r == '(' || r == '[' || r == '{'
+}
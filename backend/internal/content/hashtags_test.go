@@ -0,0 +1,82 @@
+package content
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			name:     "no tags",
+			text:     "Why is the sky blue?",
+			expected: nil,
+		},
+		{
+			name:     "single tag",
+			text:     "Why is the sky blue? #physics",
+			expected: []string{"physics"},
+		},
+		{
+			name:     "normalized and de-duplicated",
+			text:     "#Go #golang #GO",
+			expected: []string{"go", "golang"},
+		},
+		{
+			name:     "punctuation ends a tag",
+			text:     "Learning #rust, #go. (#zig)",
+			expected: []string{"rust", "go", "zig"},
+		},
+		{
+			name:     "unicode letters",
+			text:     "#café #日本語",
+			expected: []string{"café", "日本語"},
+		},
+		{
+			name:     "underscores and digits",
+			text:     "#web_3 #2024goals",
+			expected: []string{"web_3", "2024goals"},
+		},
+		{
+			name:     "numeric only is not a tag",
+			text:     "Issue #123",
+			expected: nil,
+		},
+		{
+			name:     "mid-word and URL fragments are ignored",
+			text:     "C# and https://example.com/page#section",
+			expected: nil,
+		},
+		{
+			name:     "bare sigil",
+			text:     "# heading ##",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ExtractHashtags(tt.text))
+		})
+	}
+}
+
+func TestExtractHashtags_TooLong(t *testing.T) {
+	long := strings.Repeat("a", MaxTagLength+1)
+	assert.Nil(t, ExtractHashtags("#"+long))
+	assert.Equal(t, []string{long[:MaxTagLength]}, ExtractHashtags("#"+long[:MaxTagLength]))
+}
+
+func TestNormalizeTag(t *testing.T) {
+	assert.Equal(t, "golang", NormalizeTag("#GoLang"))
+	assert.Equal(t, "golang", NormalizeTag("golang"))
+	assert.Equal(t, "", NormalizeTag("#"))
+	assert.Equal(t, "", NormalizeTag("go-lang"))
+	assert.Equal(t, "", NormalizeTag("42"))
+}
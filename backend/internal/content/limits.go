@@ -0,0 +1,47 @@
+package content
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Limits bounds what users write. Lengths are in grapheme clusters, what a
+// reader sees as one character: an emoji with skin tone counts once.
+type Limits struct {
+	MessageLength int
+	ReplyLength   int
+	// Attachments is the most media URLs a message, reply or direct message
+	// may carry
+	Attachments int
+	// BodyBytes is the largest request body read, other than uploads
+	BodyBytes int
+}
+
+// DefaultLimits apply unless configured otherwise
+var DefaultLimits = Limits{MessageLength: 5000, ReplyLength: 2000, Attachments: 4, BodyBytes: 256 << 10}
+
+// Normalize puts text in Unicode NFC, so the same text is stored, searched
+// and compared the same way however it was typed, and trims surrounding
+// whitespace
+func Normalize(text string) string {
+	return strings.TrimSpace(norm.NFC.String(text))
+}
+
+// Length is the number of grapheme clusters in text
+func Length(text string) int {
+	return uniseg.GraphemeClusterCount(text)
+}
+
+// IsBlank reports whether text has nothing to read: it is empty or only
+// whitespace and invisible formatting characters such as zero-width spaces
+func IsBlank(text string) bool {
+	for _, r := range text {
+		if !unicode.IsSpace(r) && !unicode.Is(unicode.Cf, r) && !unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package content
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	// "e" followed by a combining acute accent composes into "é"
+	assert.Equal(t, "caf\u00e9", Normalize("  cafe\u0301\n"))
+	assert.Equal(t, "line one\nline two", Normalize("line one\nline two"))
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{"ascii", "hello", 5},
+		{"combining accent", "cafe\u0301", 4},
+		{"emoji with skin tone", "\U0001F44B\U0001F3FD", 1},
+		{"family emoji", "\U0001F468\u200d\U0001F469\u200d\U0001F467", 1},
+		{"flag", "\U0001F1F3\U0001F1F1", 1},
+		{"empty", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Length(tt.text))
+		})
+	}
+}
+
+func TestIsBlank(t *testing.T) {
+	assert.True(t, IsBlank(""))
+	assert.True(t, IsBlank(" \t\n"))
+	assert.True(t, IsBlank("\u200b\u00a0\u2003"))
+	assert.False(t, IsBlank("  a  "))
+	assert.False(t, IsBlank("\U0001F44B"))
+}
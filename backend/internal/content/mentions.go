@@ -0,0 +1,77 @@
+package content
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxHandleLength is the longest user handle that can be registered or mentioned
+const MaxHandleLength = 30
+
+// MentionSpan is an @handle found in text. Start and End are offsets in
+// Unicode code points, covering the '@' and the handle.
+type MentionSpan struct {
+	Handle string
+	Start  int
+	End    int
+}
+
+// ExtractMentions returns every @handle in text in order of appearance, with
+// handles lowercased. The same handle may appear more than once. An '@' only
+// starts a mention at a token boundary, so email addresses are ignored.
+func ExtractMentions(text string) []MentionSpan {
+	var mentions []MentionSpan
+
+	runeOffset := 0
+	for i := 0; i < len(text); {
+		if text[i] != '@' || !isTokenBoundary(text, i) {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+			runeOffset++
+			continue
+		}
+
+		// Handles are ASCII, so byte and rune lengths agree from here
+		end := i + 1
+		for end < len(text) && isHandleByte(text[end]) {
+			end++
+		}
+
+		length := end - i
+		if handle := text[i+1 : end]; handle != "" && len(handle) <= MaxHandleLength {
+			mentions = append(mentions, MentionSpan{
+				Handle: strings.ToLower(handle),
+				Start:  runeOffset,
+				End:    runeOffset + length,
+			})
+		}
+
+		i = end
+		runeOffset += length
+	}
+
+	return mentions
+}
+
+// IsValidHandle reports whether handle can be registered: 1 to MaxHandleLength
+// ASCII letters, digits or underscores
+func IsValidHandle(handle string) bool {
+	if handle == "" || len(handle) > MaxHandleLength {
+		return false
+	}
+	for i := 0; i < len(handle); i++ {
+		if !isHandleByte(handle[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHandleByte(b byte) bool {
+	return b == '_' || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+// NormalizeHandle returns the case-folded form handles are compared by
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(handle, "@"))
+}
@@ -0,0 +1,78 @@
+package content
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []MentionSpan
+	}{
+		{
+			name:     "no mentions",
+			text:     "Why is the sky blue?",
+			expected: nil,
+		},
+		{
+			name: "mention at start",
+			text: "@Alice why?",
+			expected: []MentionSpan{
+				{Handle: "alice", Start: 0, End: 6},
+			},
+		},
+		{
+			name: "multiple mentions with punctuation",
+			text: "Ask @bob, or (@carol_2).",
+			expected: []MentionSpan{
+				{Handle: "bob", Start: 4, End: 8},
+				{Handle: "carol_2", Start: 14, End: 22},
+			},
+		},
+		{
+			name: "offsets count code points",
+			text: "日本 @alice",
+			expected: []MentionSpan{
+				{Handle: "alice", Start: 3, End: 9},
+			},
+		},
+		{
+			name: "repeated mention",
+			text: "@bob @bob",
+			expected: []MentionSpan{
+				{Handle: "bob", Start: 0, End: 4},
+				{Handle: "bob", Start: 5, End: 9},
+			},
+		},
+		{
+			name:     "email addresses are ignored",
+			text:     "mail bob@example.com",
+			expected: nil,
+		},
+		{
+			name:     "bare sigil",
+			text:     "meet @ noon",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ExtractMentions(tt.text))
+		})
+	}
+}
+
+func TestIsValidHandle(t *testing.T) {
+	assert.True(t, IsValidHandle("alice"))
+	assert.True(t, IsValidHandle("Bob_2"))
+	assert.False(t, IsValidHandle(""))
+	assert.False(t, IsValidHandle("al ice"))
+	assert.False(t, IsValidHandle("alice!"))
+	assert.False(t, IsValidHandle("élise"))
+	assert.False(t, IsValidHandle(strings.Repeat("a", MaxHandleLength+1)))
+}
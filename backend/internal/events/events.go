@@ -0,0 +1,113 @@
+// Package events is the internal event bus. Write paths publish domain events
+// inside their transaction; subscribers in every replica receive each event
+// once it commits.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	MessageCreated = "message.created"
+	MessageUpdated = "message.updated"
+	MessageDeleted = "message.deleted"
+	ReplyCreated   = "reply.created"
+	ReplyUpdated   = "reply.updated"
+	ReplyDeleted   = "reply.deleted"
+)
+
+// Ephemeral signal types
+const (
+	Typing   = "typing"
+	Presence = "presence"
+)
+
+// Event is a published domain event or a broadcast signal. Event IDs are
+// global across replicas and increase in publish order, though a later ID may
+// commit first; signals have ID 0. MessageID is the thread the event belongs
+// to: the message itself, or the parent message of a reply.
+type Event struct {
+	ID        int64
+	Type      string
+	MessageID string
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+// Ephemeral reports whether the event is a signal rather than a recorded event
+func (e Event) Ephemeral() bool {
+	return e.ID == 0
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Handler receives events in delivery order. It runs on the bus's delivery
+// goroutine and must not block.
+type Handler func(Event)
+
+// Publisher is the write side of a Bus, and of the outbox in front of it
+type Publisher interface {
+	// Publish records an event with db, normally the transaction making the
+	// change, so the event is delivered if and only if it commits
+	Publish(ctx context.Context, db Execer, eventType, messageID string, payload any) error
+}
+
+type Bus interface {
+	Publisher
+	// Broadcast delivers a signal to subscribers on every replica without
+	// recording it: it is never replayed and may be lost
+	Broadcast(ctx context.Context, eventType, messageID string, payload any) error
+	// Subscribe registers handler for events delivered from now on until
+	// the returned function is called
+	Subscribe(handler Handler) (unsubscribe func())
+	// Recent returns up to n of the most recently published events, oldest first
+	Recent(ctx context.Context, n int) ([]Event, error)
+}
+
+// registry holds the handlers of a Bus implementation
+type registry struct {
+	mu       sync.Mutex
+	next     int
+	handlers map[int]Handler
+}
+
+func (r *registry) subscribe(handler Handler) (unsubscribe func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.handlers == nil {
+		r.handlers = map[int]Handler{}
+	}
+	r.next++
+	id := r.next
+	r.handlers[id] = handler
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.handlers, id)
+	}
+}
+
+// deliver calls every handler. Handlers run without the lock held so they
+// may unsubscribe.
+func (r *registry) deliver(event Event) {
+	r.mu.Lock()
+	handlers := make([]Handler, 0, len(r.handlers))
+	for _, handler := range r.handlers {
+		handlers = append(handlers, handler)
+	}
+	r.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// MemoryBus is an in-process Bus for tests and single-process tools. Events
+// are delivered synchronously from Publish, regardless of whether the
+// caller's transaction later commits.
+type MemoryBus struct {
+	registry
+
+	mu     sync.Mutex
+	events []Event
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{}
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, db Execer, eventType, messageID string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	// Held across delivery so subscribers see events in ID order
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{
+		ID:        int64(len(b.events) + 1),
+		Type:      eventType,
+		MessageID: messageID,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+	b.events = append(b.events, event)
+	b.deliver(event)
+	return nil
+}
+
+func (b *MemoryBus) Broadcast(ctx context.Context, eventType, messageID string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.deliver(Event{Type: eventType, MessageID: messageID, Data: data, CreatedAt: time.Now()})
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(handler Handler) (unsubscribe func()) {
+	return b.subscribe(handler)
+}
+
+func (b *MemoryBus) Recent(ctx context.Context, n int) ([]Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := max(len(b.events)-n, 0)
+	return append([]Event(nil), b.events[start:]...), nil
+}
+
+// Published returns every event published so far, excluding signals
+func (b *MemoryBus) Published() []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Event(nil), b.events...)
+}
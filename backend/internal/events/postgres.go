@@ -0,0 +1,302 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// channel is the NOTIFY channel for recorded events. Notifications carry
+	// only the event id: NOTIFY payloads are limited to 8000 bytes, events
+	// are not.
+	channel = "events"
+	// signalChannel carries broadcast signals in full
+	signalChannel = "signals"
+	maxSignalSize = 7900
+
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+
+	// catchUpInterval bounds the delivery delay should a notification be lost
+	catchUpInterval = 30 * time.Second
+	batchSize       = 500
+
+	// A sequence gap is either a transaction that has not committed yet or
+	// one that rolled back; after gapTimeout we assume the latter
+	gapTimeout = 10 * time.Second
+	maxGaps    = 1000
+
+	pruneInterval    = time.Minute
+	defaultRetention = time.Hour
+)
+
+// ErrSignalTooLarge is returned by Broadcast for payloads that do not fit in a NOTIFY
+var ErrSignalTooLarge = errors.New("signal too large")
+
+// signal is the NOTIFY payload of a broadcast
+type signal struct {
+	Type      string          `json:"type"`
+	MessageID string          `json:"message_id"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// PostgresBus publishes events to the events table and delivers them to the
+// subscribers of every replica via LISTEN/NOTIFY
+type PostgresBus struct {
+	registry
+
+	db        *sql.DB
+	dsn       string
+	retention time.Duration
+
+	// Owned by the delivery goroutine
+	lastID int64
+	gaps   map[int64]time.Time
+}
+
+// NewPostgresBus returns a bus on db. dsn opens the dedicated LISTEN
+// connection; events older than retention (default one hour) are pruned.
+func NewPostgresBus(db *sql.DB, dsn string, retention time.Duration) *PostgresBus {
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+
+	return &PostgresBus{
+		db:        db,
+		dsn:       dsn,
+		retention: retention,
+		gaps:      map[int64]time.Time{},
+	}
+}
+
+func (b *PostgresBus) Publish(ctx context.Context, db Execer, eventType, messageID string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	// NOTIFY is transactional: listeners hear about the event when it commits
+	_, err = db.ExecContext(ctx,
+		`WITH event AS (
+			INSERT INTO events (type, message_id, payload) VALUES ($1, $2, $3) RETURNING id
+		 )
+		 SELECT pg_notify('`+channel+`', id::text) FROM event`,
+		eventType, messageID, data,
+	)
+	return err
+}
+
+func (b *PostgresBus) Broadcast(ctx context.Context, eventType, messageID string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	notification, err := json.Marshal(signal{Type: eventType, MessageID: messageID, Data: data})
+	if err != nil {
+		return err
+	}
+	if len(notification) > maxSignalSize {
+		return ErrSignalTooLarge
+	}
+
+	_, err = b.db.ExecContext(ctx, `SELECT pg_notify('`+signalChannel+`', $1)`, string(notification))
+	return err
+}
+
+func (b *PostgresBus) Subscribe(handler Handler) (unsubscribe func()) {
+	return b.subscribe(handler)
+}
+
+func (b *PostgresBus) Recent(ctx context.Context, n int) ([]Event, error) {
+	rows, err := b.db.QueryContext(ctx,
+		`SELECT id, type, message_id, payload, created_at FROM events ORDER BY id DESC LIMIT $1`,
+		n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	recent, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(recent)-1; i < j; i, j = i+1, j-1 {
+		recent[i], recent[j] = recent[j], recent[i]
+	}
+	return recent, nil
+}
+
+// Start listens for notifications and delivers events published from now on
+// until ctx is cancelled. The listener reconnects on its own; after a
+// reconnect, and periodically, the bus catches up from the events table.
+func (b *PostgresBus) Start(ctx context.Context) error {
+	listener := pq.NewListener(b.dsn, minReconnectInterval, maxReconnectInterval,
+		func(event pq.ListenerEventType, err error) {
+			switch event {
+			case pq.ListenerEventDisconnected:
+				slog.WarnContext(ctx, "Event bus listener disconnected", "error", err)
+			case pq.ListenerEventReconnected:
+				slog.InfoContext(ctx, "Event bus listener reconnected")
+			case pq.ListenerEventConnectionAttemptFailed:
+				slog.WarnContext(ctx, "Event bus listener failed to reconnect", "error", err)
+			}
+		},
+	)
+	for _, name := range []string{channel, signalChannel} {
+		if err := listener.Listen(name); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	// Anything committed before LISTEN took effect is history, served by Recent
+	err := b.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM events`).Scan(&b.lastID)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	go b.run(ctx, listener)
+	return nil
+}
+
+func (b *PostgresBus) run(ctx context.Context, listener *pq.Listener) {
+	defer listener.Close()
+
+	catchUp := time.NewTicker(catchUpInterval)
+	defer catchUp.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// Coalesce a burst of event notifications into one query
+			pending := b.handleNotification(n)
+			for drained := false; !drained; {
+				select {
+				case n := <-listener.Notify:
+					pending = b.handleNotification(n) || pending
+				default:
+					drained = true
+				}
+			}
+			if !pending {
+				continue
+			}
+		case <-catchUp.C:
+		case <-prune.C:
+			if err := b.prune(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to prune events", "error", err)
+			}
+			continue
+		}
+
+		if err := b.catchUp(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to fetch events", "error", err)
+		}
+	}
+}
+
+// handleNotification delivers a signal, or reports whether the events table
+// must be read: the notification is for a new event, or it is the nil
+// notification that follows a reconnect, after which anything may have been missed.
+func (b *PostgresBus) handleNotification(n *pq.Notification) (catchUp bool) {
+	if n == nil || n.Channel != signalChannel {
+		return true
+	}
+
+	var s signal
+	if err := json.Unmarshal([]byte(n.Extra), &s); err != nil {
+		slog.Warn("Dropping malformed signal", "error", err)
+		return false
+	}
+	b.deliver(Event{Type: s.Type, MessageID: s.MessageID, Data: s.Data, CreatedAt: time.Now()})
+	return false
+}
+
+// catchUp delivers every committed event after lastID, and any that fill
+// earlier sequence gaps
+func (b *PostgresBus) catchUp(ctx context.Context) error {
+	for {
+		pending := make([]int64, 0, len(b.gaps))
+		for id := range b.gaps {
+			pending = append(pending, id)
+		}
+
+		rows, err := b.db.QueryContext(ctx,
+			`SELECT id, type, message_id, payload, created_at FROM events
+			 WHERE id > $1 OR id = ANY($2)
+			 ORDER BY id
+			 LIMIT $3`,
+			b.lastID, pq.Array(pending), batchSize,
+		)
+		if err != nil {
+			return err
+		}
+		batch, err := scanEvents(rows)
+		if err != nil {
+			return err
+		}
+
+		b.dispatch(batch, time.Now())
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (b *PostgresBus) dispatch(batch []Event, now time.Time) {
+	for _, event := range batch {
+		if _, filled := b.gaps[event.ID]; filled {
+			delete(b.gaps, event.ID)
+		} else if event.ID <= b.lastID {
+			continue
+		} else {
+			for id := b.lastID + 1; id < event.ID && len(b.gaps) < maxGaps; id++ {
+				b.gaps[id] = now
+			}
+			b.lastID = event.ID
+		}
+
+		b.deliver(event)
+	}
+
+	for id, seen := range b.gaps {
+		if now.Sub(seen) > gapTimeout {
+			delete(b.gaps, id)
+		}
+	}
+}
+
+func (b *PostgresBus) prune(ctx context.Context) error {
+	_, err := b.db.ExecContext(ctx,
+		`DELETE FROM events WHERE created_at < NOW() - $1 * INTERVAL '1 second'`,
+		int64(b.retention.Seconds()),
+	)
+	return err
+}
+
+func scanEvents(rows *sql.Rows) ([]Event, error) {
+	defer rows.Close()
+
+	var scanned []Event
+	for rows.Next() {
+		var event Event
+		var data []byte
+		if err := rows.Scan(&event.ID, &event.Type, &event.MessageID, &data, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.Data = data
+		scanned = append(scanned, event)
+	}
+	return scanned, rows.Err()
+}
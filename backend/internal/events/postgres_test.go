@@ -0,0 +1,153 @@
+package events
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/testutil"
+)
+
+var eventColumns = []string{"id", "type", "message_id", "payload", "created_at"}
+
+func TestPostgresBus_Publish_NotifiesOnlyTheID(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO events \\(type, message_id, payload\\) .+ SELECT pg_notify\\('events', id::text\\)").
+		WithArgs(ReplyCreated, "msg-1", []byte(`{"id":"reply-1"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	bus := NewPostgresBus(db, "", 0)
+	err := bus.Publish(context.Background(), db, ReplyCreated, "msg-1", map[string]string{"id": "reply-1"})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresBus_Recent(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, type, message_id, payload, created_at FROM events ORDER BY id DESC LIMIT \\$1").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(eventColumns).
+			AddRow(9, MessageCreated, "msg-2", []byte(`{}`), now).
+			AddRow(8, MessageCreated, "msg-1", []byte(`{}`), now))
+
+	recent, err := NewPostgresBus(db, "", 0).Recent(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, recent, 2)
+	assert.Equal(t, int64(8), recent[0].ID)
+	assert.Equal(t, int64(9), recent[1].ID)
+}
+
+func TestPostgresBus_CatchUp_DeliversLateCommits(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	bus := NewPostgresBus(db, "", 0)
+	bus.lastID = 1
+
+	var delivered []int64
+	bus.Subscribe(func(event Event) { delivered = append(delivered, event.ID) })
+
+	now := time.Now()
+	// Event 2's transaction commits after event 3's
+	mock.ExpectQuery("WHERE id > \\$1 OR id = ANY\\(\\$2\\)").
+		WithArgs(int64(1), pq.Array([]int64{}), batchSize).
+		WillReturnRows(sqlmock.NewRows(eventColumns).
+			AddRow(3, MessageCreated, "msg-1", []byte(`{}`), now))
+	mock.ExpectQuery("WHERE id > \\$1 OR id = ANY\\(\\$2\\)").
+		WithArgs(int64(3), pq.Array([]int64{2}), batchSize).
+		WillReturnRows(sqlmock.NewRows(eventColumns).
+			AddRow(2, ReplyCreated, "msg-1", []byte(`{}`), now))
+
+	require.NoError(t, bus.catchUp(context.Background()))
+	require.NoError(t, bus.catchUp(context.Background()))
+
+	assert.Equal(t, []int64{3, 2}, delivered)
+	assert.Empty(t, bus.gaps)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresBus_Dispatch_GivesUpOnRolledBackIDs(t *testing.T) {
+	bus := NewPostgresBus(nil, "", 0)
+	now := time.Now()
+
+	bus.dispatch([]Event{{ID: 3}}, now)
+	assert.Len(t, bus.gaps, 2)
+
+	// Already delivered events are not delivered again
+	var delivered int
+	bus.Subscribe(func(Event) { delivered++ })
+	bus.dispatch([]Event{{ID: 3}}, now)
+	assert.Zero(t, delivered)
+
+	bus.dispatch(nil, now.Add(gapTimeout+time.Second))
+	assert.Empty(t, bus.gaps)
+}
+
+func TestMemoryBus(t *testing.T) {
+	bus := NewMemoryBus()
+
+	var received []Event
+	unsubscribe := bus.Subscribe(func(event Event) { received = append(received, event) })
+
+	ctx := context.Background()
+	require.NoError(t, bus.Publish(ctx, nil, MessageCreated, "msg-1", map[string]string{"id": "msg-1"}))
+	unsubscribe()
+	require.NoError(t, bus.Publish(ctx, nil, MessageUpdated, "msg-1", map[string]string{"id": "msg-1"}))
+
+	require.Len(t, received, 1)
+	assert.Equal(t, int64(1), received[0].ID)
+	assert.JSONEq(t, `{"id":"msg-1"}`, string(received[0].Data))
+
+	recent, err := bus.Recent(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, recent, 1)
+	assert.Equal(t, MessageUpdated, recent[0].Type)
+	assert.Len(t, bus.Published(), 2)
+}
+
+func TestPostgresBus_Broadcast(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectExec("SELECT pg_notify\\('signals', \\$1\\)").
+		WithArgs(`{"type":"typing","message_id":"msg-1","data":{"user_id":"user-1"}}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	bus := NewPostgresBus(db, "", 0)
+	ctx := context.Background()
+	require.NoError(t, bus.Broadcast(ctx, Typing, "msg-1", map[string]string{"user_id": "user-1"}))
+
+	err := bus.Broadcast(ctx, Typing, "msg-1", map[string]string{"text": strings.Repeat("x", maxSignalSize)})
+	assert.ErrorIs(t, err, ErrSignalTooLarge)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresBus_HandleNotification(t *testing.T) {
+	bus := NewPostgresBus(nil, "", 0)
+
+	var delivered []Event
+	bus.Subscribe(func(event Event) { delivered = append(delivered, event) })
+
+	catchUp := bus.handleNotification(&pq.Notification{
+		Channel: signalChannel,
+		Extra:   `{"type":"presence","message_id":"msg-1","data":{"user_id":"user-1","online":true}}`,
+	})
+	assert.False(t, catchUp)
+	require.Len(t, delivered, 1)
+	assert.True(t, delivered[0].Ephemeral())
+	assert.Equal(t, Presence, delivered[0].Type)
+	assert.JSONEq(t, `{"user_id":"user-1","online":true}`, string(delivered[0].Data))
+
+	assert.True(t, bus.handleNotification(&pq.Notification{Channel: channel, Extra: "42"}))
+	assert.True(t, bus.handleNotification(nil), "a reconnect must trigger a catch-up")
+}
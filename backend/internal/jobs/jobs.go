@@ -0,0 +1,138 @@
+// Package jobs runs background work outside the request path. Jobs are rows
+// in the jobs table, enqueued (optionally in the caller's transaction) with
+// Enqueue and run by a Worker in any process: the API server or a dedicated
+// `server --worker`. Workers claim due jobs with FOR UPDATE SKIP LOCKED, so
+// each job runs on one worker at a time; failed jobs are retried with
+// exponential backoff and dead-lettered once they run out of attempts.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Job statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+const defaultMaxAttempts = 10
+
+// ErrNotDead is returned by Retry for a job that is not dead-lettered
+var ErrNotDead = errors.New("job is not dead")
+
+// Execer is satisfied by both *sql.DB and *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Job is a claimed job
+type Job struct {
+	ID      int64
+	Type    string
+	Payload json.RawMessage
+	// Attempts includes the current one
+	Attempts    int
+	MaxAttempts int
+	CreatedAt   time.Time
+}
+
+// Handler runs a job. Returning an error retries it later, unless the error
+// is Permanent. ctx is cancelled if the worker is forced to stop; the job is
+// then released and runs again, so handlers must be idempotent.
+type Handler func(ctx context.Context, job Job) error
+
+// Handle registers a handler whose payload is decoded into T. A payload that
+// does not decode is dead-lettered at once.
+func Handle[T any](w *Worker, jobType string, handler func(ctx context.Context, payload T) error) {
+	w.Register(jobType, func(ctx context.Context, job Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return Permanent(err)
+		}
+		return handler(ctx, payload)
+	})
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a job error as not worth retrying: the job is dead-lettered
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type enqueueOptions struct {
+	runAt       time.Time
+	maxAttempts int
+}
+
+// EnqueueOption customizes an enqueued job
+type EnqueueOption func(*enqueueOptions)
+
+// RunAt schedules the job for t instead of now
+func RunAt(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+// MaxAttempts sets how many times the job runs before it is dead-lettered
+func MaxAttempts(n int) EnqueueOption {
+	return func(o *enqueueOptions) { o.maxAttempts = n }
+}
+
+// Enqueue records a job with db. Pass the transaction making a change to run
+// the job if and only if the change commits.
+func Enqueue(ctx context.Context, db Execer, jobType string, payload any, opts ...EnqueueOption) error {
+	o := enqueueOptions{maxAttempts: defaultMaxAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var runAt any
+	if !o.runAt.IsZero() {
+		runAt = o.runAt
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO jobs (type, payload, max_attempts, run_at)
+		 VALUES ($1, $2, $3, COALESCE($4, NOW()))`,
+		jobType, data, o.maxAttempts, runAt,
+	)
+	return err
+}
+
+// Retry requeues a dead-lettered job with a fresh set of attempts
+func Retry(ctx context.Context, db Execer, id int64) error {
+	result, err := db.ExecContext(ctx,
+		`UPDATE jobs SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+		 WHERE id = $1 AND status = 'dead'`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotDead
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/testutil"
+)
+
+func TestEnqueue(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO jobs \\(type, payload, max_attempts, run_at\\) VALUES \\(\\$1, \\$2, \\$3, COALESCE\\(\\$4, NOW\\(\\)\\)\\)").
+		WithArgs("email", []byte(`{"to":"a@example.com"}`), defaultMaxAttempts, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := Enqueue(context.Background(), db, "email", map[string]string{"to": "a@example.com"})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnqueue_Scheduled(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	runAt := time.Now().Add(time.Hour)
+	mock.ExpectExec("INSERT INTO jobs").
+		WithArgs("email", []byte(`{}`), 3, runAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := Enqueue(context.Background(), db, "email", struct{}{}, RunAt(runAt), MaxAttempts(3))
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetry(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE jobs SET status = 'pending', attempts = 0.+WHERE id = \\$1 AND status = 'dead'").
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE jobs SET status = 'pending'").
+		WithArgs(int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, Retry(context.Background(), db, 7))
+	assert.ErrorIs(t, Retry(context.Background(), db, 8), ErrNotDead)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandle_DecodesPayload(t *testing.T) {
+	type email struct {
+		To string `json:"to"`
+	}
+
+	w := NewWorker(nil, Options{})
+	var got email
+	Handle(w, "email", func(ctx context.Context, payload email) error {
+		got = payload
+		return nil
+	})
+
+	err := w.call(context.Background(), Job{Type: "email", Payload: []byte(`{"to":"a@example.com"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, "a@example.com", got.To)
+
+	err = w.call(context.Background(), Job{Type: "email", Payload: []byte(`[]`)})
+	assert.True(t, isPermanent(err))
+}
+
+func TestPermanent(t *testing.T) {
+	cause := errors.New("bad address")
+	err := Permanent(cause)
+
+	assert.ErrorIs(t, err, cause)
+	assert.True(t, isPermanent(err))
+	assert.True(t, isPermanent(errors.Join(errors.New("context"), err)))
+	assert.False(t, isPermanent(cause))
+}
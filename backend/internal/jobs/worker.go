@@ -0,0 +1,293 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrency  = 4
+	defaultPollInterval = time.Second
+	defaultLease        = 5 * time.Minute
+	defaultRetention    = 7 * 24 * time.Hour
+
+	baseBackoff    = 10 * time.Second
+	maxBackoff     = time.Hour
+	pruneInterval  = time.Hour
+	maxErrorLength = 1000
+)
+
+// Options tunes a Worker. Zero values select the defaults.
+type Options struct {
+	// Concurrency is how many jobs run at once
+	Concurrency int
+	// PollInterval is how often the worker looks for due jobs while idle
+	PollInterval time.Duration
+	// Lease is how long a job may run before it is presumed abandoned and
+	// claimed again; it also bounds each job's context
+	Lease time.Duration
+	// Retention is how long succeeded jobs are kept
+	Retention time.Duration
+}
+
+type Worker struct {
+	db       *sql.DB
+	opts     Options
+	handlers map[string]Handler
+
+	// stop ends claiming; cancel aborts running jobs
+	stop    context.CancelFunc
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	done    chan struct{}
+}
+
+func NewWorker(db *sql.DB, opts Options) *Worker {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaultLease
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+
+	return &Worker{db: db, opts: opts, handlers: map[string]Handler{}}
+}
+
+// Register sets the handler for a job type. Handlers must be registered
+// before Start.
+func (w *Worker) Register(jobType string, handler Handler) {
+	w.handlers[jobType] = handler
+}
+
+// Start claims and runs jobs until Shutdown
+func (w *Worker) Start(ctx context.Context) {
+	// Jobs outlive ctx until Shutdown gives up on them
+	jobsCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	claimCtx, stop := context.WithCancel(ctx)
+	w.stop, w.cancel = stop, cancel
+	w.done = make(chan struct{})
+
+	go w.run(claimCtx, jobsCtx)
+}
+
+// Shutdown stops claiming jobs and waits for running ones to finish. If ctx
+// expires first, running jobs are cancelled and released to run again.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.stop()
+	<-w.done
+
+	finished := make(chan struct{})
+	go func() {
+		w.running.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+func (w *Worker) run(claimCtx, jobsCtx context.Context) {
+	defer close(w.done)
+
+	slots := make(chan struct{}, w.opts.Concurrency)
+	poll := time.NewTicker(w.opts.PollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	for {
+		// Claim as many jobs as there are free slots
+		free := w.opts.Concurrency - len(slots)
+		if free > 0 {
+			claimed, err := w.claim(claimCtx, free)
+			if err != nil && claimCtx.Err() == nil {
+				slog.ErrorContext(claimCtx, "Failed to claim jobs", "error", err)
+			}
+			for _, job := range claimed {
+				slots <- struct{}{}
+				w.running.Add(1)
+				go func(job Job) {
+					defer w.running.Done()
+					defer func() { <-slots }()
+					w.execute(jobsCtx, job)
+				}(job)
+			}
+			// A full batch suggests more are due: claim again without waiting
+			if len(claimed) == free {
+				continue
+			}
+		}
+
+		select {
+		case <-claimCtx.Done():
+			return
+		case <-poll.C:
+		case <-prune.C:
+			if err := w.prune(claimCtx); err != nil && claimCtx.Err() == nil {
+				slog.ErrorContext(claimCtx, "Failed to prune jobs", "error", err)
+			}
+		}
+	}
+}
+
+// claim marks up to n due jobs running: pending jobs whose run_at has come,
+// and running jobs whose lease expired
+func (w *Worker) claim(ctx context.Context, n int) ([]Job, error) {
+	rows, err := w.db.QueryContext(ctx,
+		`UPDATE jobs SET status = 'running', attempts = attempts + 1,
+			locked_until = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+		 WHERE id IN (
+			SELECT id FROM jobs
+			WHERE (status = 'pending' AND run_at <= NOW())
+			   OR (status = 'running' AND locked_until < NOW())
+			ORDER BY run_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING id, type, payload, attempts, max_attempts, created_at`,
+		n, int64(w.opts.Lease.Seconds()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []Job
+	for rows.Next() {
+		var job Job
+		var payload []byte
+		if err := rows.Scan(&job.ID, &job.Type, &payload, &job.Attempts, &job.MaxAttempts, &job.CreatedAt); err != nil {
+			return nil, err
+		}
+		job.Payload = payload
+		claimed = append(claimed, job)
+	}
+	return claimed, rows.Err()
+}
+
+// execute runs a job and records the outcome
+func (w *Worker) execute(ctx context.Context, job Job) {
+	ctx, cancel := context.WithTimeout(ctx, w.opts.Lease)
+	defer cancel()
+
+	err := w.call(ctx, job)
+
+	// The outcome is recorded even when the job was cancelled
+	recordCtx := context.WithoutCancel(ctx)
+	switch {
+	case err == nil:
+		err = w.succeed(recordCtx, job)
+	case errors.Is(ctx.Err(), context.Canceled):
+		slog.WarnContext(ctx, "Job interrupted by shutdown", "job_id", job.ID, "job_type", job.Type)
+		err = w.release(recordCtx, job)
+	default:
+		slog.WarnContext(ctx, "Job failed", "error", err, "job_id", job.ID, "job_type", job.Type, "attempt", job.Attempts)
+		err = w.fail(recordCtx, job, err)
+	}
+	if err != nil {
+		slog.ErrorContext(recordCtx, "Failed to record job outcome", "error", err, "job_id", job.ID)
+	}
+}
+
+// call runs the job's handler, turning a panic into an error
+func (w *Worker) call(ctx context.Context, job Job) (err error) {
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for job type %q", job.Type))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+func (w *Worker) succeed(ctx context.Context, job Job) error {
+	_, err := w.db.ExecContext(ctx,
+		`UPDATE jobs SET status = 'succeeded', locked_until = NULL, last_error = NULL,
+			finished_at = NOW(), updated_at = NOW()
+		 WHERE id = $1`,
+		job.ID,
+	)
+	return err
+}
+
+// release returns an interrupted job to the queue without counting the attempt
+func (w *Worker) release(ctx context.Context, job Job) error {
+	_, err := w.db.ExecContext(ctx,
+		`UPDATE jobs SET status = 'pending', attempts = attempts - 1, locked_until = NULL,
+			run_at = NOW(), updated_at = NOW()
+		 WHERE id = $1`,
+		job.ID,
+	)
+	return err
+}
+
+// fail schedules a retry, or dead-letters the job once it is out of attempts
+// or the error is permanent
+func (w *Worker) fail(ctx context.Context, job Job, jobErr error) error {
+	errorText := jobErr.Error()
+	if len(errorText) > maxErrorLength {
+		errorText = errorText[:maxErrorLength]
+	}
+
+	if isPermanent(jobErr) || job.Attempts >= job.MaxAttempts {
+		slog.ErrorContext(ctx, "Job dead-lettered", "error", jobErr, "job_id", job.ID, "job_type", job.Type)
+		_, err := w.db.ExecContext(ctx,
+			`UPDATE jobs SET status = 'dead', locked_until = NULL, last_error = $2,
+				finished_at = NOW(), updated_at = NOW()
+			 WHERE id = $1`,
+			job.ID, errorText,
+		)
+		return err
+	}
+
+	retryIn := backoff(job.Attempts)
+	retryIn += rand.N(retryIn / 5) // spread retries of jobs that failed together
+	_, err := w.db.ExecContext(ctx,
+		`UPDATE jobs SET status = 'pending', locked_until = NULL, last_error = $2,
+			run_at = NOW() + $3 * INTERVAL '1 second', updated_at = NOW()
+		 WHERE id = $1`,
+		job.ID, errorText, int64(retryIn.Seconds()),
+	)
+	return err
+}
+
+func (w *Worker) prune(ctx context.Context) error {
+	_, err := w.db.ExecContext(ctx,
+		`DELETE FROM jobs WHERE status = 'succeeded' AND finished_at < NOW() - $1 * INTERVAL '1 second'`,
+		int64(w.opts.Retention.Seconds()),
+	)
+	return err
+}
+
+// backoff is the delay before retrying after the given number of attempts:
+// 10s, 20s, 40s, ... capped at an hour
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
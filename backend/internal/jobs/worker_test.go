@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/testutil"
+)
+
+var jobColumns = []string{"id", "type", "payload", "attempts", "max_attempts", "created_at"}
+
+func TestWorker_Claim(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE jobs SET status = 'running', attempts = attempts \\+ 1.+FOR UPDATE SKIP LOCKED.+RETURNING").
+		WithArgs(2, int64(300)).
+		WillReturnRows(sqlmock.NewRows(jobColumns).
+			AddRow(1, "email", []byte(`{}`), 1, 10, time.Now()).
+			AddRow(2, "email", []byte(`{}`), 3, 10, time.Now()))
+
+	claimed, err := NewWorker(db, Options{}).claim(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.Equal(t, 3, claimed[1].Attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorker_Execute(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     Job
+		handler Handler
+		expect  func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name:    "success",
+			job:     Job{ID: 1, Type: "email", Attempts: 1, MaxAttempts: 10},
+			handler: func(ctx context.Context, job Job) error { return nil },
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE jobs SET status = 'succeeded'").
+					WithArgs(int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:    "failure is retried",
+			job:     Job{ID: 1, Type: "email", Attempts: 2, MaxAttempts: 10},
+			handler: func(ctx context.Context, job Job) error { return errors.New("smtp unavailable") },
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE jobs SET status = 'pending', locked_until = NULL, last_error = \\$2, run_at = NOW\\(\\) \\+ \\$3").
+					WithArgs(int64(1), "smtp unavailable", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:    "last attempt is dead-lettered",
+			job:     Job{ID: 1, Type: "email", Attempts: 10, MaxAttempts: 10},
+			handler: func(ctx context.Context, job Job) error { return errors.New("smtp unavailable") },
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE jobs SET status = 'dead'").
+					WithArgs(int64(1), "smtp unavailable").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:    "permanent error is dead-lettered",
+			job:     Job{ID: 1, Type: "email", Attempts: 1, MaxAttempts: 10},
+			handler: func(ctx context.Context, job Job) error { return Permanent(errors.New("no such user")) },
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE jobs SET status = 'dead'").
+					WithArgs(int64(1), "no such user").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:    "panic is a failure",
+			job:     Job{ID: 1, Type: "email", Attempts: 1, MaxAttempts: 10},
+			handler: func(ctx context.Context, job Job) error { panic("boom") },
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE jobs SET status = 'pending'").
+					WithArgs(int64(1), "panic: boom", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "unknown type is dead-lettered",
+			job:  Job{ID: 1, Type: "fax", Attempts: 1, MaxAttempts: 10},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE jobs SET status = 'dead'").
+					WithArgs(int64(1), `no handler for job type "fax"`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.SetupTestDB(t)
+			defer db.Close()
+
+			w := NewWorker(db, Options{})
+			if tt.handler != nil {
+				w.Register("email", tt.handler)
+			}
+			tt.expect(mock)
+
+			w.execute(context.Background(), tt.job)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWorker_ShutdownWaitsForRunningJobs(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery("UPDATE jobs SET status = 'running'").
+		WillReturnRows(sqlmock.NewRows(jobColumns).AddRow(1, "slow", []byte(`{}`), 1, 10, time.Now()))
+	mock.ExpectExec("UPDATE jobs SET status = 'succeeded'").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	started := make(chan struct{})
+	w := NewWorker(db, Options{Concurrency: 1, PollInterval: time.Hour})
+	w.Register("slow", func(ctx context.Context, job Job) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	w.Start(context.Background())
+	<-started
+
+	require.NoError(t, w.Shutdown(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorker_ShutdownTimeoutReleasesJobs(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery("UPDATE jobs SET status = 'running'").
+		WillReturnRows(sqlmock.NewRows(jobColumns).AddRow(1, "stuck", []byte(`{}`), 1, 10, time.Now()))
+	mock.ExpectExec("UPDATE jobs SET status = 'pending', attempts = attempts - 1").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	started := make(chan struct{})
+	w := NewWorker(db, Options{Concurrency: 1, PollInterval: time.Hour})
+	w.Register("stuck", func(ctx context.Context, job Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	w.Start(context.Background())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Shutdown(ctx), context.DeadlineExceeded)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, backoff(1))
+	assert.Equal(t, 40*time.Second, backoff(3))
+	assert.Equal(t, time.Hour, backoff(30))
+}
@@ -1,26 +1,101 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/lib/pq"
 )
 
+// User roles. Moderators and admins work the report queue.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Account states. Suspended and banned users cannot sign in or use their
+// tokens; a banned user's messages and replies are hidden from everyone.
+const (
+	AccountActive    = "active"
+	AccountSuspended = "suspended"
+	AccountBanned    = "banned"
+)
+
 type User struct {
 	ID           string    `json:"id"`
 	Email        string    `json:"email"`
+	Handle       *string   `json:"handle"`
+	Role         string    `json:"role"`
 	PasswordHash string    `json:"-"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// Profile is the public view of a user
+type Profile struct {
+	ID             string    `json:"id"`
+	Handle         *string   `json:"handle"`
+	FollowerCount  int       `json:"follower_count"`
+	FollowingCount int       `json:"following_count"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// FollowUser is an entry of a follower or following list
+type FollowUser struct {
+	ID         string    `json:"id"`
+	Handle     *string   `json:"handle"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// FollowPage is a page of a follower or following list. Total is the
+// length of the whole list.
+type FollowPage struct {
+	Users      []FollowUser `json:"users"`
+	Total      int          `json:"total"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// RelatedUser is an entry of a block or mute list
+type RelatedUser struct {
+	ID        string    `json:"id"`
+	Handle    *string   `json:"handle"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RelatedUserPage struct {
+	Users      []RelatedUser `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// Message visibility levels. Replies are readable wherever their message is.
+const (
+	// VisibilityPublic messages are listed, searchable and streamed
+	VisibilityPublic = "public"
+	// VisibilityUnlisted messages are readable by anyone with the link but
+	// left out of listings, search and event streams
+	VisibilityUnlisted = "unlisted"
+	// VisibilityFollowers messages are readable by the author, their
+	// followers and users mentioned in them
+	VisibilityFollowers = "followers"
+)
+
 type Message struct {
-	ID        string         `json:"id"`
-	UserID    string         `json:"user_id"`
-	Content   string         `json:"content"`
-	MediaURLs pq.StringArray `json:"media_urls"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
+	ID              string         `json:"id"`
+	UserID          string         `json:"user_id"`
+	Content         string         `json:"content"`
+	MediaURLs       pq.StringArray `json:"media_urls"`
+	AcceptedReplyID *string        `json:"accepted_reply_id"`
+	Tags            pq.StringArray `json:"tags"`
+	Mentions        Mentions       `json:"mentions"`
+	Visibility      string         `json:"visibility"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	// PendingReview is set on a new message the content filter held for a
+	// moderator; until approved only its author sees it
+	PendingReview bool `json:"pending_review,omitempty"`
 }
 
 type Reply struct {
@@ -29,13 +104,67 @@ type Reply struct {
 	UserID    string         `json:"user_id"`
 	Content   string         `json:"content"`
 	MediaURLs pq.StringArray `json:"media_urls"`
+	Mentions  Mentions       `json:"mentions"`
+	Score     int            `json:"score"`
+	Accepted  bool           `json:"accepted"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
+	// PendingReview is set on a new reply held like a message
+	PendingReview bool `json:"pending_review,omitempty"`
+}
+
+// Mention is an @handle in content resolved to a user. Start and End are
+// offsets in Unicode code points and include the '@'.
+type Mention struct {
+	UserID string `json:"user_id"`
+	Handle string `json:"handle"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+// Mentions is stored as a JSONB array
+type Mentions []Mention
+
+func (m Mentions) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(m)
+}
+
+func (m *Mentions) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	case nil:
+		*m = Mentions{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Mentions", src)
+	}
 }
 
+// FieldError says what is wrong with one field of a request. Field is its
+// JSON name, with an index for list items: media_urls[1].
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Content is at most the configured number of characters and media_urls the
+// configured number of files, uploaded by the author
 type CreateMessageRequest struct {
 	Content   string   `json:"content" binding:"required"`
 	MediaURLs []string `json:"media_urls"`
+	// Visibility defaults to public
+	Visibility string `json:"visibility" binding:"omitempty,oneof=public unlisted followers"`
+}
+
+type UpdateMessageRequest struct {
+	Content   string   `json:"content" binding:"required"`
+	MediaURLs []string `json:"media_urls"`
 }
 
 type CreateReplyRequest struct {
@@ -43,9 +172,306 @@ type CreateReplyRequest struct {
 	MediaURLs []string `json:"media_urls"`
 }
 
+type VoteRequest struct {
+	Value *int `json:"value" binding:"required,oneof=-1 0 1"`
+}
+
+type VoteResponse struct {
+	ReplyID string `json:"reply_id"`
+	Score   int    `json:"score"`
+	Vote    int    `json:"vote"`
+}
+
+type Tag struct {
+	Name         string `json:"name"`
+	MessageCount int    `json:"message_count"`
+}
+
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// Conversation is a private conversation as seen by one participant
+type Conversation struct {
+	ID            string                    `json:"id"`
+	Participants  []ConversationParticipant `json:"participants"`
+	UnreadCount   int                       `json:"unread_count"`
+	LastMessageAt time.Time                 `json:"last_message_at"`
+	CreatedAt     time.Time                 `json:"created_at"`
+}
+
+// ConversationParticipant carries a participant's read receipt: the newest
+// message they have read, if any
+type ConversationParticipant struct {
+	UserID            string     `json:"user_id"`
+	Handle            *string    `json:"handle"`
+	LastReadMessageID *string    `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at"`
+}
+
+type ConversationPage struct {
+	Conversations []Conversation `json:"conversations"`
+	NextCursor    string         `json:"next_cursor,omitempty"`
+}
+
+type DirectMessage struct {
+	ID             string         `json:"id"`
+	ConversationID string         `json:"conversation_id"`
+	SenderID       string         `json:"sender_id"`
+	Content        string         `json:"content"`
+	MediaURLs      pq.StringArray `json:"media_urls"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+type DirectMessagePage struct {
+	Messages   []DirectMessage `json:"messages"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type CreateConversationRequest struct {
+	ParticipantIDs []string `json:"participant_ids" binding:"required,min=1"`
+}
+
+type SendDirectMessageRequest struct {
+	Content   string   `json:"content" binding:"required"`
+	MediaURLs []string `json:"media_urls"`
+}
+
+// MarkConversationReadRequest moves the read receipt to MessageID, or to the
+// newest message when it is empty
+type MarkConversationReadRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// Search result types
+const (
+	SearchResultMessage = "message"
+	SearchResultReply   = "reply"
+)
+
+// SearchResult is a message or reply matching a search. Snippet is
+// HTML-escaped content around the matches, which are wrapped in <mark>.
+type SearchResult struct {
+	Type      string    `json:"type"`
+	ID        string    `json:"id"`
+	MessageID string    `json:"message_id"`
+	UserID    string    `json:"user_id"`
+	Snippet   string    `json:"snippet"`
+	Rank      float32   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// Notification types
+const (
+	NotificationReply          = "reply"
+	NotificationMention        = "mention"
+	NotificationReaction       = "reaction"
+	NotificationAcceptedAnswer = "accepted_answer"
+	// Moderation notices have no actor and cannot be switched off
+	NotificationReportResolved    = "report_resolved"
+	NotificationModerationWarning = "moderation_warning"
+)
+
+// NotificationTypes lists every notification type a user can configure
+var NotificationTypes = []string{
+	NotificationReply,
+	NotificationMention,
+	NotificationReaction,
+	NotificationAcceptedAnswer,
+}
+
+type Notification struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	ActorID   *string    `json:"actor_id"`
+	MessageID *string    `json:"message_id"`
+	ReplyID   *string    `json:"reply_id"`
+	ReportID  *string    `json:"report_id"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"`
+	NextCursor    string         `json:"next_cursor,omitempty"`
+}
+
+type UnreadCounts struct {
+	Total  int            `json:"total"`
+	ByType map[string]int `json:"by_type"`
+}
+
+// NotificationPreferences maps a notification type to whether it is delivered
+type NotificationPreferences map[string]bool
+
+// Report targets
+const (
+	ReportTargetMessage = "message"
+	ReportTargetReply   = "reply"
+	ReportTargetUser    = "user"
+)
+
+// Report statuses: open reports wait in the queue until a moderator claims
+// them, and claimed ones until they are resolved
+const (
+	ReportOpen     = "open"
+	ReportClaimed  = "claimed"
+	ReportResolved = "resolved"
+)
+
+// Moderation actions a report is resolved with
+const (
+	ModerationDismiss = "dismiss"
+	ModerationHide    = "hide"
+	ModerationWarn    = "warn"
+	ModerationSuspend = "suspend"
+)
+
+// Report is a user's complaint about a message, reply or user. Reporters see
+// their own reports without the moderator fields. ReporterID is nil for
+// content the content filter held for review.
+type Report struct {
+	ID             string     `json:"id"`
+	ReporterID     *string    `json:"reporter_id"`
+	TargetType     string     `json:"target_type"`
+	TargetID       string     `json:"target_id"`
+	TargetUserID   string     `json:"target_user_id"`
+	Reason         string     `json:"reason"`
+	Details        *string    `json:"details"`
+	Status         string     `json:"status"`
+	ClaimedBy      *string    `json:"claimed_by,omitempty"`
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty"`
+	ResolvedBy     *string    `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	Action         *string    `json:"action"`
+	ResolutionNote *string    `json:"resolution_note,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type ReportPage struct {
+	Reports    []Report `json:"reports"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+type CreateReportRequest struct {
+	TargetType string `json:"target_type" binding:"required,oneof=message reply user"`
+	TargetID   string `json:"target_id" binding:"required,uuid"`
+	Reason     string `json:"reason" binding:"required,oneof=spam harassment hate violence sexual self_harm misinformation other"`
+	Details    string `json:"details" binding:"max=2000"`
+}
+
+// ResolveReportRequest closes a report with an action. DurationDays is how
+// long a suspension lasts and is required for it.
+type ResolveReportRequest struct {
+	Action       string `json:"action" binding:"required,oneof=dismiss hide warn suspend"`
+	Note         string `json:"note" binding:"max=2000"`
+	DurationDays int    `json:"duration_days" binding:"required_if=Action suspend,omitempty,min=1,max=365"`
+}
+
+// Audit log actions
+const (
+	AuditReportClaimed   = "report.claimed"
+	AuditReportReleased  = "report.released"
+	AuditReportResolved  = "report.resolved"
+	AuditContentHidden   = "content.hidden"
+	AuditContentApproved = "content.approved"
+	AuditUserWarned      = "user.warned"
+	AuditUserSuspended   = "user.suspended"
+	AuditUserUnsuspended = "user.unsuspended"
+	AuditUserBanned      = "user.banned"
+	AuditUserUnbanned    = "user.unbanned"
+	AuditUserRoleSet     = "user.role_set"
+)
+
+// SuspendUserRequest suspends a user for DurationDays
+type SuspendUserRequest struct {
+	Reason       string `json:"reason" binding:"required,max=500"`
+	DurationDays int    `json:"duration_days" binding:"required,min=1,max=365"`
+}
+
+// BanUserRequest bans a user until the ban is lifted
+type BanUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// AuditEntry records a moderation step. ActorID is empty for changes made
+// from the command line.
+type AuditEntry struct {
+	ID         string          `json:"id"`
+	ActorID    *string         `json:"actor_id"`
+	Action     string          `json:"action"`
+	ReportID   *string         `json:"report_id"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditLogPage struct {
+	Entries    []AuditEntry `json:"entries"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+type Webhook struct {
+	ID           string         `json:"id"`
+	UserID       string         `json:"user_id"`
+	URL          string         `json:"url"`
+	EventTypes   pq.StringArray `json:"event_types"`
+	Active       bool           `json:"active"`
+	FailureCount int            `json:"failure_count"`
+	DisabledAt   *time.Time     `json:"disabled_at"`
+	// Secret is only returned when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required,url"`
+	EventTypes []string `json:"event_types" binding:"required,min=1"`
+}
+
+// UpdateWebhookRequest changes the fields that are set. Setting active to
+// true re-enables a webhook that was disabled after repeated failures.
+type UpdateWebhookRequest struct {
+	URL        *string  `json:"url" binding:"omitempty,url"`
+	EventTypes []string `json:"event_types" binding:"omitempty,min=1"`
+	Active     *bool    `json:"active"`
+}
+
+type WebhookDelivery struct {
+	ID               string    `json:"id"`
+	EventID          int64     `json:"event_id"`
+	EventType        string    `json:"event_type"`
+	Status           string    `json:"status"`
+	Attempts         int       `json:"attempts"`
+	LastResponseCode *int      `json:"last_response_code"`
+	LastError        *string   `json:"last_error"`
+	NextAttemptAt    time.Time `json:"next_attempt_at"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
 type SignupRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
+	Handle   string `json:"handle"`
+}
+
+type UpdateHandleRequest struct {
+	Handle string `json:"handle" binding:"required"`
 }
 
 type LoginRequest struct {
@@ -0,0 +1,152 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// Token bounds: shorter and longer words carry little signal, and long texts
+// are judged on their first maxTokens distinct words
+const (
+	minTokenLength = 2
+	maxTokenLength = 32
+	maxTokens      = 200
+)
+
+// Execer runs a statement, on a database or in a transaction
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Tokenize returns the distinct lowercased words of text
+func Tokenize(text string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if n := utf8.RuneCountInString(word); n < minTokenLength || n > maxTokenLength || seen[word] {
+			continue
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+		if len(tokens) == maxTokens {
+			break
+		}
+	}
+	return tokens
+}
+
+// Train records text as spam or not. The classifier learns from moderators'
+// decisions on spam reports.
+func Train(ctx context.Context, db Execer, text string, spam bool) error {
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+	spamCount, hamCount, class := 0, 1, "ham"
+	if spam {
+		spamCount, hamCount, class = 1, 0, "spam"
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO spam_tokens (token, spam_count, ham_count)
+		 SELECT t, $2, $3 FROM unnest($1::text[]) AS t
+		 ON CONFLICT (token) DO UPDATE SET spam_count = spam_tokens.spam_count + EXCLUDED.spam_count,
+			ham_count = spam_tokens.ham_count + EXCLUDED.ham_count`,
+		pq.Array(tokens), spamCount, hamCount,
+	)
+	if err == nil {
+		_, err = db.ExecContext(ctx, `UPDATE spam_documents SET count = count + 1 WHERE class = $1`, class)
+	}
+	return err
+}
+
+// SpamClassifier is a naive Bayes classifier over the words of content,
+// trained with Train. Content scoring at least holdAbove is held, and at
+// least shadowHideAbove shadow-hidden; a zero threshold is not applied. It
+// allows everything until it has seen minDocuments of both spam and not.
+type SpamClassifier struct {
+	db              *sql.DB
+	holdAbove       float64
+	shadowHideAbove float64
+	minDocuments    int
+}
+
+func NewSpamClassifier(db *sql.DB, holdAbove, shadowHideAbove float64, minDocuments int) (*SpamClassifier, error) {
+	for _, threshold := range []float64{holdAbove, shadowHideAbove} {
+		if threshold < 0 || threshold > 1 {
+			return nil, fmt.Errorf("spam: thresholds must be between 0 and 1")
+		}
+	}
+	return &SpamClassifier{db: db, holdAbove: holdAbove, shadowHideAbove: shadowHideAbove, minDocuments: minDocuments}, nil
+}
+
+func (f *SpamClassifier) Check(ctx context.Context, content Content) (Decision, error) {
+	p, trained, err := f.Score(ctx, content.Text)
+	if err != nil {
+		return Decision{}, fmt.Errorf("spam: %w", err)
+	}
+	switch {
+	case !trained:
+		return Allowed, nil
+	case f.shadowHideAbove > 0 && p >= f.shadowHideAbove:
+		return decide("spam", ShadowHide, CategorySpam, "spam probability %.3f", p), nil
+	case f.holdAbove > 0 && p >= f.holdAbove:
+		return decide("spam", Hold, CategorySpam, "spam probability %.3f", p), nil
+	}
+	return Allowed, nil
+}
+
+// Score returns the probability that text is spam, and whether the
+// classifier has seen enough to tell
+func (f *SpamClassifier) Score(ctx context.Context, text string) (float64, bool, error) {
+	var spamDocs, hamDocs int
+	err := f.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(count) FILTER (WHERE class = 'spam'), 0), COALESCE(SUM(count) FILTER (WHERE class = 'ham'), 0)
+		 FROM spam_documents`,
+	).Scan(&spamDocs, &hamDocs)
+	if err != nil {
+		return 0, false, err
+	}
+	if spamDocs < f.minDocuments || hamDocs < f.minDocuments || spamDocs == 0 || hamDocs == 0 {
+		return 0, false, nil
+	}
+
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return 0, true, nil
+	}
+	rows, err := f.db.QueryContext(ctx,
+		`SELECT spam_count, ham_count FROM spam_tokens WHERE token = ANY($1)`,
+		pq.Array(tokens),
+	)
+	if err != nil {
+		return 0, false, err
+	}
+	defer rows.Close()
+
+	// Words never seen say nothing either way and are left out; the others
+	// are smoothed so one unseen in a class does not decide alone
+	total := float64(spamDocs + hamDocs)
+	logSpam := math.Log(float64(spamDocs) / total)
+	logHam := math.Log(float64(hamDocs) / total)
+	for rows.Next() {
+		var spamCount, hamCount int
+		if err := rows.Scan(&spamCount, &hamCount); err != nil {
+			return 0, false, err
+		}
+		logSpam += math.Log(float64(spamCount+1) / float64(spamDocs+2))
+		logHam += math.Log(float64(hamCount+1) / float64(hamDocs+2))
+	}
+	if err := rows.Err(); err != nil {
+		return 0, false, err
+	}
+	return 1 / (1 + math.Exp(logHam-logSpam)), true, nil
+}
@@ -0,0 +1,88 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/testutil"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"buy", "cheap", "pills", "at", "https", "example", "com"},
+		Tokenize("Buy CHEAP pills! cheap pills at https://example.com a"))
+	assert.Equal(t, []string{"café", "über"}, Tokenize("Café über"))
+	assert.Empty(t, Tokenize("a b c !!!"))
+}
+
+func TestTrain(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO spam_tokens \\(token, spam_count, ham_count\\) SELECT t, \\$2, \\$3 FROM unnest\\(\\$1::text\\[\\]\\) AS t ON CONFLICT \\(token\\) DO UPDATE").
+		WithArgs(pq.Array([]string{"cheap", "pills"}), 1, 0).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE spam_documents SET count = count \\+ 1 WHERE class = \\$1").
+		WithArgs("spam").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, Train(context.Background(), db, "Cheap pills", true))
+
+	// Nothing to learn from
+	require.NoError(t, Train(context.Background(), db, "!!", false))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectModel(mock sqlmock.Sqlmock, spamDocs, hamDocs int) {
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(count\\) FILTER \\(WHERE class = 'spam'\\), 0\\).+ FROM spam_documents").
+		WillReturnRows(sqlmock.NewRows([]string{"spam", "ham"}).AddRow(spamDocs, hamDocs))
+}
+
+func TestSpamClassifier(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	f, err := NewSpamClassifier(db, 0.9, 0.99, 10)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Untrained: everything goes
+	expectModel(mock, 3, 50)
+	decision, err := f.Check(ctx, Content{Text: "cheap pills"})
+	require.NoError(t, err)
+	assert.Equal(t, Allow, decision.Action)
+
+	// Words seen mostly in spam
+	expectModel(mock, 20, 20)
+	mock.ExpectQuery("SELECT spam_count, ham_count FROM spam_tokens WHERE token = ANY\\(\\$1\\)").
+		WithArgs(pq.Array([]string{"cheap", "pills"})).
+		WillReturnRows(sqlmock.NewRows([]string{"spam_count", "ham_count"}).AddRow(18, 0).AddRow(15, 1))
+	decision, err = f.Check(ctx, Content{Text: "cheap pills"})
+	require.NoError(t, err)
+	assert.Equal(t, ShadowHide, decision.Action)
+	assert.Equal(t, CategorySpam, decision.Category)
+
+	// Less sure
+	expectModel(mock, 20, 20)
+	mock.ExpectQuery("FROM spam_tokens").
+		WillReturnRows(sqlmock.NewRows([]string{"spam_count", "ham_count"}).AddRow(15, 0))
+	decision, err = f.Check(ctx, Content{Text: "cheap"})
+	require.NoError(t, err)
+	assert.Equal(t, Hold, decision.Action)
+
+	// Words seen mostly in ham
+	expectModel(mock, 20, 20)
+	mock.ExpectQuery("FROM spam_tokens").
+		WillReturnRows(sqlmock.NewRows([]string{"spam_count", "ham_count"}).AddRow(0, 15))
+	p, trained, err := f.Score(ctx, "postgres release")
+	require.NoError(t, err)
+	assert.True(t, trained)
+	assert.Less(t, p, 0.1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = NewSpamClassifier(db, 1.5, 0, 0)
+	assert.Error(t, err)
+}
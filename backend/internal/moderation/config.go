@@ -0,0 +1,152 @@
+package moderation
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config configures the filter chain; it is read from a JSON file. Lists
+// run first, in order, then the link limit, duplicate detection and the
+// spam classifier. Sections left out are not run.
+//
+//	{
+//	  "words": [{"action": "reject", "category": "hate", "words": ["..."]}],
+//	  "patterns": [{"action": "hold", "patterns": ["(?i)free\\s+crypto"]}],
+//	  "links": {"max": 5, "action": "hold"},
+//	  "duplicates": {"window": "10m", "action": "reject"},
+//	  "spam": {"hold_above": 0.9, "shadow_hide_above": 0.99, "min_documents": 20}
+//	}
+type Config struct {
+	Words      []ListConfig      `json:"words"`
+	Patterns   []ListConfig      `json:"patterns"`
+	Links      *LinksConfig      `json:"links"`
+	Duplicates *DuplicatesConfig `json:"duplicates"`
+	Spam       *SpamConfig       `json:"spam"`
+}
+
+// ListConfig is a word or pattern list. Category is the report reason holds
+// are filed under, other by default.
+type ListConfig struct {
+	Name     string   `json:"name"`
+	Action   string   `json:"action"`
+	Category string   `json:"category"`
+	Words    []string `json:"words"`
+	Patterns []string `json:"patterns"`
+}
+
+type LinksConfig struct {
+	Max    int    `json:"max"`
+	Action string `json:"action"`
+}
+
+// DuplicatesConfig takes the window as a Go duration, like "10m"
+type DuplicatesConfig struct {
+	Window string `json:"window"`
+	Action string `json:"action"`
+}
+
+type SpamConfig struct {
+	HoldAbove       float64 `json:"hold_above"`
+	ShadowHideAbove float64 `json:"shadow_hide_above"`
+	MinDocuments    int     `json:"min_documents"`
+}
+
+// DefaultConfig is used without a config file: no lists, holds for link
+// floods, rejects for reposts and the spam classifier once trained
+func DefaultConfig() Config {
+	return Config{
+		Links:      &LinksConfig{Max: 5, Action: Hold},
+		Duplicates: &DuplicatesConfig{Window: "10m", Action: Reject},
+		Spam:       &SpamConfig{HoldAbove: 0.9, ShadowHideAbove: 0.99, MinDocuments: 20},
+	}
+}
+
+// categories are the report reasons
+var categories = map[string]bool{
+	"spam": true, "harassment": true, "hate": true, "violence": true,
+	"sexual": true, "self_harm": true, "misinformation": true, "other": true,
+}
+
+// LoadConfig reads a config file, or returns DefaultConfig when path is empty
+func LoadConfig(path string) (Config, error) {
+	if path == "" {
+		return DefaultConfig(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read content filter config: %w", err)
+	}
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse content filter config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Build makes the filter chain cfg describes
+func (cfg Config) Build(db *sql.DB) (Chain, error) {
+	var chain Chain
+	for i, list := range cfg.Words {
+		f, err := list.build("words", i, NewWordFilter, list.Words)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, f)
+	}
+	for i, list := range cfg.Patterns {
+		f, err := list.build("patterns", i, NewPatternFilter, list.Patterns)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, f)
+	}
+	if cfg.Links != nil {
+		f, err := NewLinkFilter(cfg.Links.Max, cfg.Links.Action)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, f)
+	}
+	if cfg.Duplicates != nil {
+		window, err := time.ParseDuration(cfg.Duplicates.Window)
+		if err != nil {
+			return nil, fmt.Errorf("duplicates: %w", err)
+		}
+		f, err := NewDuplicateFilter(db, window, cfg.Duplicates.Action)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, f)
+	}
+	if cfg.Spam != nil {
+		f, err := NewSpamClassifier(db, cfg.Spam.HoldAbove, cfg.Spam.ShadowHideAbove, cfg.Spam.MinDocuments)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, f)
+	}
+	return chain, nil
+}
+
+// build makes the i'th list of a kind with newFilter, named after its
+// position unless it has a name
+func (list ListConfig) build(kind string, i int, newFilter func(name, action, category string, entries []string) (*ListFilter, error), entries []string) (*ListFilter, error) {
+	name := list.Name
+	if name == "" {
+		name = fmt.Sprintf("%s[%d]", kind, i)
+	}
+	category := list.Category
+	if category == "" {
+		category = CategoryOther
+	}
+	if !categories[category] {
+		return nil, fmt.Errorf("%s: invalid category %q", name, category)
+	}
+	return newFilter(name, list.Action, category, entries)
+}
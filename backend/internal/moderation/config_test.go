@@ -0,0 +1,79 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/testutil"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "filters.json")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	return path
+}
+
+func TestLoadConfig_Default(t *testing.T) {
+	cfg, err := LoadConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultConfig(), cfg)
+
+	db, _ := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	chain, err := cfg.Build(db)
+	require.NoError(t, err)
+	require.Len(t, chain, 3)
+	assert.IsType(t, &LinkFilter{}, chain[0])
+	assert.IsType(t, &DuplicateFilter{}, chain[1])
+	assert.IsType(t, &SpamClassifier{}, chain[2])
+}
+
+func TestLoadConfig_File(t *testing.T) {
+	path := writeConfig(t, `{
+		"words": [{"name": "slurs", "action": "reject", "category": "hate", "words": ["badword"]}],
+		"patterns": [{"action": "hold", "patterns": ["(?i)free\\s+crypto"]}],
+		"links": {"max": 3, "action": "shadow_hide"}
+	}`)
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Nil(t, cfg.Duplicates)
+	assert.Nil(t, cfg.Spam)
+
+	chain, err := cfg.Build(nil)
+	require.NoError(t, err)
+	require.Len(t, chain, 3)
+	assert.Equal(t, "slurs", chain[0].(*ListFilter).name)
+	// Unnamed lists are named after their place, and file holds as other
+	assert.Equal(t, "patterns[0]", chain[1].(*ListFilter).name)
+	assert.Equal(t, CategoryOther, chain[1].(*ListFilter).category)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	_, err = LoadConfig(writeConfig(t, `{"link": {"max": 3}}`))
+	assert.Error(t, err, "unknown fields are rejected")
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"bad action", Config{Words: []ListConfig{{Action: "delete", Words: []string{"x"}}}}},
+		{"bad category", Config{Words: []ListConfig{{Action: Hold, Category: "rude", Words: []string{"x"}}}}},
+		{"bad pattern", Config{Patterns: []ListConfig{{Action: Hold, Patterns: []string{"("}}}}},
+		{"bad window", Config{Duplicates: &DuplicatesConfig{Window: "soon", Action: Reject}}},
+		{"bad threshold", Config{Spam: &SpamConfig{HoldAbove: 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.cfg.Build(nil)
+			assert.Error(t, err)
+		})
+	}
+}
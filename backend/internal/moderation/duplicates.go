@@ -0,0 +1,48 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DuplicateFilter catches a user posting the same text again within a
+// window, as a message or a reply. Case and surrounding whitespace are
+// ignored, and so is the content being edited.
+type DuplicateFilter struct {
+	db     *sql.DB
+	window time.Duration
+	action string
+}
+
+func NewDuplicateFilter(db *sql.DB, window time.Duration, action string) (*DuplicateFilter, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("duplicates: window must be positive")
+	}
+	if !validAction(action) {
+		return nil, fmt.Errorf("duplicates: invalid action %q", action)
+	}
+	return &DuplicateFilter{db: db, window: window, action: action}, nil
+}
+
+func (f *DuplicateFilter) Check(ctx context.Context, content Content) (Decision, error) {
+	var duplicate bool
+	err := f.db.QueryRowContext(ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM messages WHERE user_id = $1 AND created_at > NOW() - make_interval(secs => $3)
+				AND lower(btrim(content)) = lower(btrim($2)) AND id::text <> $4
+			UNION ALL
+			SELECT 1 FROM replies WHERE user_id = $1 AND created_at > NOW() - make_interval(secs => $3)
+				AND lower(btrim(content)) = lower(btrim($2)) AND id::text <> $4
+		)`,
+		content.UserID, content.Text, f.window.Seconds(), content.ID,
+	).Scan(&duplicate)
+	if err != nil {
+		return Decision{}, fmt.Errorf("duplicates: %w", err)
+	}
+	if duplicate {
+		return decide("duplicates", f.action, CategorySpam, "same text posted within %s", f.window), nil
+	}
+	return Allowed, nil
+}
@@ -0,0 +1,95 @@
+// Package moderation screens messages and replies before they are stored.
+//
+// A Filter looks at new content and decides what happens to it: it is
+// allowed, rejected outright, held for a moderator to review, or
+// shadow-hidden, stored and shown to its author but no one else. A Chain
+// runs filters in order and keeps the strictest decision.
+package moderation
+
+import (
+	"context"
+	"fmt"
+)
+
+// Actions a filter decides on, from most to least lenient. Held content
+// waits for a moderator; shadow-hidden content never shows to others.
+const (
+	Allow      = "allow"
+	Hold       = "hold"
+	ShadowHide = "shadow_hide"
+	Reject     = "reject"
+)
+
+// severity orders actions; an unknown action counts as allow
+var severity = map[string]int{Allow: 0, Hold: 1, ShadowHide: 2, Reject: 3}
+
+// validAction reports whether action is one a filter may decide on
+func validAction(action string) bool {
+	_, ok := severity[action]
+	return ok && action != Allow
+}
+
+// Content kinds
+const (
+	KindMessage = "message"
+	KindReply   = "reply"
+)
+
+// Categories holds are filed under; they are report reasons
+const (
+	CategorySpam  = "spam"
+	CategoryOther = "other"
+)
+
+// Content is a message or reply about to be stored. ID is set when an
+// existing one is being edited.
+type Content struct {
+	ID     string
+	UserID string
+	Kind   string
+	Text   string
+}
+
+// Decision is what a filter decided about content. Filter names the filter
+// that decided and Reason says why, for the author on a reject and for
+// moderators on a hold. Category is the report reason a hold is filed under.
+type Decision struct {
+	Action   string
+	Filter   string
+	Reason   string
+	Category string
+}
+
+// Allowed is the decision to let content through
+var Allowed = Decision{Action: Allow}
+
+// Filter decides what happens to new content
+type Filter interface {
+	Check(ctx context.Context, content Content) (Decision, error)
+}
+
+// Chain runs filters in order and returns the strictest decision. It stops
+// at the first reject. An empty chain allows everything.
+type Chain []Filter
+
+func (ch Chain) Check(ctx context.Context, content Content) (Decision, error) {
+	decision := Allowed
+	for _, f := range ch {
+		d, err := f.Check(ctx, content)
+		if err != nil {
+			return Decision{}, err
+		}
+		if severity[d.Action] > severity[decision.Action] {
+			decision = d
+		}
+		if decision.Action == Reject {
+			break
+		}
+	}
+	return decision, nil
+}
+
+// decide is a decision by the named filter
+func decide(filter, action, category, format string, args ...any) Decision {
+	return Decision{Action: action, Filter: filter, Reason: fmt.Sprintf(format, args...), Category: category}
+}
@@ -0,0 +1,151 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/testutil"
+)
+
+// fixed decides the same for all content
+type fixed Decision
+
+func (f fixed) Check(ctx context.Context, content Content) (Decision, error) {
+	return Decision(f), nil
+}
+
+// failing fails every check
+type failing struct{}
+
+func (failing) Check(ctx context.Context, content Content) (Decision, error) {
+	return Decision{}, errors.New("boom")
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	content := Content{UserID: "user-1", Kind: KindMessage, Text: "hello"}
+
+	decision, err := Chain{}.Check(ctx, content)
+	require.NoError(t, err)
+	assert.Equal(t, Allow, decision.Action)
+
+	// The strictest decision wins, the first of equals
+	decision, err = Chain{
+		fixed(Allowed),
+		fixed{Action: Hold, Filter: "a"},
+		fixed{Action: ShadowHide, Filter: "b"},
+		fixed{Action: Hold, Filter: "c"},
+	}.Check(ctx, content)
+	require.NoError(t, err)
+	assert.Equal(t, ShadowHide, decision.Action)
+	assert.Equal(t, "b", decision.Filter)
+
+	// A reject stops the chain
+	decision, err = Chain{fixed{Action: Reject, Filter: "a"}, failing{}}.Check(ctx, content)
+	require.NoError(t, err)
+	assert.Equal(t, Reject, decision.Action)
+
+	_, err = Chain{fixed(Allowed), failing{}}.Check(ctx, content)
+	assert.Error(t, err)
+}
+
+func TestWordFilter(t *testing.T) {
+	f, err := NewWordFilter("slurs", Reject, "hate", []string{"Badword", " ", "c++"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"this has a badword in it", Reject},
+		{"BADWORD!", Reject},
+		{"badwords are fine", Allow},
+		{"not a rebadword", Allow},
+		{"I write c++ daily", Reject},
+		{"", Allow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			decision, err := f.Check(context.Background(), Content{Text: tt.text})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, decision.Action)
+			if tt.want != Allow {
+				assert.Equal(t, "slurs", decision.Filter)
+				assert.Equal(t, "hate", decision.Category)
+			}
+		})
+	}
+
+	_, err = NewWordFilter("slurs", "delete", "hate", nil)
+	assert.Error(t, err)
+	_, err = NewWordFilter("slurs", Allow, "hate", nil)
+	assert.Error(t, err)
+}
+
+func TestPatternFilter(t *testing.T) {
+	f, err := NewPatternFilter("crypto", Hold, CategorySpam, []string{`(?i)free\s+crypto`})
+	require.NoError(t, err)
+
+	decision, err := f.Check(context.Background(), Content{Text: "Get FREE   crypto now"})
+	require.NoError(t, err)
+	assert.Equal(t, Hold, decision.Action)
+
+	decision, err = f.Check(context.Background(), Content{Text: "crypto is not free"})
+	require.NoError(t, err)
+	assert.Equal(t, Allow, decision.Action)
+
+	_, err = NewPatternFilter("broken", Hold, CategorySpam, []string{`(`})
+	assert.Error(t, err)
+}
+
+func TestLinkFilter(t *testing.T) {
+	f, err := NewLinkFilter(2, Hold)
+	require.NoError(t, err)
+
+	decision, err := f.Check(context.Background(), Content{Text: "see https://a.example and www.b.example"})
+	require.NoError(t, err)
+	assert.Equal(t, Allow, decision.Action)
+
+	decision, err = f.Check(context.Background(), Content{Text: "http://a.example https://b.example www.c.example"})
+	require.NoError(t, err)
+	assert.Equal(t, Hold, decision.Action)
+	assert.Equal(t, CategorySpam, decision.Category)
+	assert.Equal(t, "3 links, at most 2 allowed", decision.Reason)
+
+	_, err = NewLinkFilter(-1, Hold)
+	assert.Error(t, err)
+}
+
+func TestDuplicateFilter(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	f, err := NewDuplicateFilter(db, 10*time.Minute, Reject)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT EXISTS \\( SELECT 1 FROM messages WHERE user_id = \\$1 .+ UNION ALL SELECT 1 FROM replies WHERE user_id = \\$1").
+		WithArgs("user-1", "Hello again", float64(600), "").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("user-1", "Something new", float64(600), "msg-1").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	decision, err := f.Check(context.Background(), Content{UserID: "user-1", Kind: KindReply, Text: "Hello again"})
+	require.NoError(t, err)
+	assert.Equal(t, Reject, decision.Action)
+	assert.Equal(t, "same text posted within 10m0s", decision.Reason)
+
+	// An edit is no duplicate of itself
+	decision, err = f.Check(context.Background(), Content{ID: "msg-1", UserID: "user-1", Kind: KindMessage, Text: "Something new"})
+	require.NoError(t, err)
+	assert.Equal(t, Allow, decision.Action)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = NewDuplicateFilter(db, 0, Reject)
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ListFilter matches content against a list of words or regular
+// expressions; content matching any of them gets its action
+type ListFilter struct {
+	name     string
+	action   string
+	category string
+	patterns []*regexp.Regexp
+}
+
+// NewWordFilter matches whole words, case-insensitively
+func NewWordFilter(name, action, category string, words []string) (*ListFilter, error) {
+	patterns := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		patterns = append(patterns, `(?i)(^|\W)`+regexp.QuoteMeta(w)+`($|\W)`)
+	}
+	return NewPatternFilter(name, action, category, patterns)
+}
+
+// NewPatternFilter matches regular expressions in RE2 syntax
+func NewPatternFilter(name, action, category string, patterns []string) (*ListFilter, error) {
+	if !validAction(action) {
+		return nil, fmt.Errorf("%s: invalid action %q", name, action)
+	}
+	f := &ListFilter{name: name, action: action, category: category}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	return f, nil
+}
+
+func (f *ListFilter) Check(ctx context.Context, content Content) (Decision, error) {
+	for _, re := range f.patterns {
+		if re.MatchString(content.Text) {
+			return decide(f.name, f.action, f.category, "matched %s list", f.name), nil
+		}
+	}
+	return Allowed, nil
+}
+
+// linkPattern finds links, with or without a scheme
+var linkPattern = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+`)
+
+// LinkFilter limits how many links content may carry
+type LinkFilter struct {
+	max    int
+	action string
+}
+
+func NewLinkFilter(max int, action string) (*LinkFilter, error) {
+	if max < 0 {
+		return nil, fmt.Errorf("links: max must not be negative")
+	}
+	if !validAction(action) {
+		return nil, fmt.Errorf("links: invalid action %q", action)
+	}
+	return &LinkFilter{max: max, action: action}, nil
+}
+
+func (f *LinkFilter) Check(ctx context.Context, content Content) (Decision, error) {
+	if n := len(linkPattern.FindAllStringIndex(content.Text, -1)); n > f.max {
+		return decide("links", f.action, CategorySpam, "%d links, at most %d allowed", n, f.max), nil
+	}
+	return Allowed, nil
+}
@@ -0,0 +1,40 @@
+// Package outbox makes domain events as durable as the changes they describe.
+// Write paths publish to a Writer, which records the event in the outbox
+// table inside their transaction; the Relay then publishes committed rows to
+// the event bus and marks them delivered. An event is published at least
+// once even if the process dies right after the commit.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"why-backend/internal/events"
+)
+
+// channel is the NOTIFY channel that wakes relays when an outbox row commits
+const channel = "outbox"
+
+// Writer records events in the outbox. It is an events.Publisher, so write
+// paths use it in place of the bus.
+type Writer struct{}
+
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+func (w *Writer) Publish(ctx context.Context, db events.Execer, eventType, messageID string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx,
+		`WITH row AS (
+			INSERT INTO outbox (event_type, message_id, payload) VALUES ($1, $2, $3) RETURNING id
+		 )
+		 SELECT pg_notify('`+channel+`', id::text) FROM row`,
+		eventType, messageID, data,
+	)
+	return err
+}
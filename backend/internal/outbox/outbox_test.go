@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/events"
+	"why-backend/internal/testutil"
+)
+
+var outboxColumns = []string{"id", "event_type", "message_id", "payload", "created_at"}
+
+func TestWriter_Publish(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO outbox \\(event_type, message_id, payload\\) .+ SELECT pg_notify\\('outbox', id::text\\)").
+		WithArgs(events.ReplyCreated, "msg-1", []byte(`{"id":"reply-1"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := NewWriter().Publish(context.Background(), db, events.ReplyCreated, "msg-1", map[string]string{"id": "reply-1"})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_RelayBatch(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	created := time.Now().Add(-time.Second)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock\\(\\$1\\)").
+		WithArgs(relayLockID).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectQuery("SELECT id, event_type, message_id, payload, created_at FROM outbox WHERE delivered_at IS NULL ORDER BY id LIMIT \\$1").
+		WithArgs(defaultBatchSize).
+		WillReturnRows(sqlmock.NewRows(outboxColumns).
+			AddRow(7, events.MessageCreated, "msg-1", []byte(`{"id":"msg-1"}`), created).
+			AddRow(8, events.ReplyCreated, "msg-1", []byte(`{"id":"reply-1"}`), created))
+	mock.ExpectExec("UPDATE outbox SET delivered_at = NOW\\(\\) WHERE id = ANY\\(\\$1\\)").
+		WithArgs(pq.Array([]int64{7, 8})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	bus := events.NewMemoryBus()
+	relay, err := NewRelay(db, "", bus, Options{})
+	require.NoError(t, err)
+
+	relayed, err := relay.relayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+
+	published := bus.Published()
+	require.Len(t, published, 2)
+	assert.Equal(t, events.MessageCreated, published[0].Type)
+	assert.Equal(t, events.ReplyCreated, published[1].Type)
+	assert.Equal(t, "msg-1", published[1].MessageID)
+	assert.JSONEq(t, `{"id":"reply-1"}`, string(published[1].Data))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_RelayBatch_AnotherReplicaRelaying(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock").
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectRollback()
+
+	bus := events.NewMemoryBus()
+	relay, err := NewRelay(db, "", bus, Options{})
+	require.NoError(t, err)
+
+	relayed, err := relay.relayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, relayed)
+	assert.Empty(t, bus.Published())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// failingBus fails to publish, as the Postgres bus does when its insert fails
+type failingBus struct {
+	*events.MemoryBus
+}
+
+func (b failingBus) Publish(ctx context.Context, db events.Execer, eventType, messageID string, payload any) error {
+	return errors.New("publish failed")
+}
+
+func TestRelay_RelayBatch_PublishFailureLeavesRowsPending(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock").
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectQuery("SELECT id, event_type, message_id, payload, created_at FROM outbox").
+		WillReturnRows(sqlmock.NewRows(outboxColumns).
+			AddRow(7, events.MessageCreated, "msg-1", []byte(`{}`), time.Now()))
+	mock.ExpectRollback()
+
+	relay, err := NewRelay(db, "", failingBus{events.NewMemoryBus()}, Options{})
+	require.NoError(t, err)
+
+	_, err = relay.relayBatch(context.Background())
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_Backlog(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\), .+ FROM outbox WHERE delivered_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "age"}).AddRow(3, 12.5))
+
+	relay, err := NewRelay(db, "", events.NewMemoryBus(), Options{})
+	require.NoError(t, err)
+
+	count, age, err := relay.backlog(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, 12.5, age)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,279 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"why-backend/internal/events"
+)
+
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+	defaultRetention    = 24 * time.Hour
+
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+	pruneInterval        = time.Minute
+
+	// relayLockID is the advisory lock held by the replica relaying a batch.
+	// One relay at a time keeps events in commit order.
+	relayLockID = 0x6f7574626f78 // "outbox"
+)
+
+// Options tunes a Relay. Zero values select the defaults.
+type Options struct {
+	// BatchSize is how many rows are published per transaction
+	BatchSize int
+	// PollInterval bounds the delay should a notification be lost
+	PollInterval time.Duration
+	// Retention is how long delivered rows are kept
+	Retention time.Duration
+}
+
+// Relay publishes committed outbox rows to the bus
+type Relay struct {
+	db   *sql.DB
+	dsn  string
+	bus  events.Bus
+	opts Options
+
+	lag metric.Float64Histogram
+}
+
+// NewRelay returns a relay from db to bus. dsn opens the dedicated LISTEN
+// connection.
+func NewRelay(db *sql.DB, dsn string, bus events.Bus, opts Options) (*Relay, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+
+	r := &Relay{db: db, dsn: dsn, bus: bus, opts: opts}
+	if err := r.initMetrics(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// initMetrics registers the lag histogram and the gauges of the backlog,
+// which are read from the table when metrics are collected
+func (r *Relay) initMetrics() error {
+	meter := otel.Meter("why-backend")
+
+	var err error
+	r.lag, err = meter.Float64Histogram(
+		"outbox_relay_lag_milliseconds",
+		metric.WithDescription("Time from an outbox row being written to it being published"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return err
+	}
+
+	pending, err := meter.Int64ObservableGauge(
+		"outbox_pending_events",
+		metric.WithDescription("Outbox rows not yet published"),
+	)
+	if err != nil {
+		return err
+	}
+	oldest, err := meter.Float64ObservableGauge(
+		"outbox_oldest_pending_age_seconds",
+		metric.WithDescription("Age of the oldest outbox row not yet published"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		count, age, err := r.backlog(ctx)
+		if err != nil {
+			return err
+		}
+		o.ObserveInt64(pending, count)
+		o.ObserveFloat64(oldest, age)
+		return nil
+	}, pending, oldest)
+	return err
+}
+
+// backlog returns the number of pending rows and the age in seconds of the oldest
+func (r *Relay) backlog(ctx context.Context) (int64, float64, error) {
+	var count int64
+	var age float64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at)), 0)
+		 FROM outbox WHERE delivered_at IS NULL`,
+	).Scan(&count, &age)
+	return count, age, err
+}
+
+// Start relays rows until ctx is cancelled: whenever one commits, and every
+// PollInterval in case a notification was missed
+func (r *Relay) Start(ctx context.Context) error {
+	listener := pq.NewListener(r.dsn, minReconnectInterval, maxReconnectInterval,
+		func(event pq.ListenerEventType, err error) {
+			switch event {
+			case pq.ListenerEventDisconnected:
+				slog.WarnContext(ctx, "Outbox listener disconnected", "error", err)
+			case pq.ListenerEventConnectionAttemptFailed:
+				slog.WarnContext(ctx, "Outbox listener failed to reconnect", "error", err)
+			}
+		},
+	)
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go r.run(ctx, listener)
+	return nil
+}
+
+func (r *Relay) run(ctx context.Context, listener *pq.Listener) {
+	defer listener.Close()
+
+	poll := time.NewTicker(r.opts.PollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-listener.Notify:
+			// Coalesce a burst of notifications into one pass
+			for drained := false; !drained; {
+				select {
+				case <-listener.Notify:
+				default:
+					drained = true
+				}
+			}
+		case <-poll.C:
+		case <-prune.C:
+			if err := r.prune(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to prune outbox", "error", err)
+			}
+			continue
+		}
+
+		if err := r.drain(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to relay outbox", "error", err)
+		}
+	}
+}
+
+// drain relays batches until the outbox is empty or another replica holds
+// the relay lock
+func (r *Relay) drain(ctx context.Context) error {
+	for {
+		relayed, err := r.relayBatch(ctx)
+		if err != nil || relayed < r.opts.BatchSize {
+			return err
+		}
+	}
+}
+
+// relayBatch publishes the oldest pending rows and marks them delivered in
+// one transaction. The bus publishes with the same transaction, so with the
+// Postgres bus a row is delivered exactly when its event is recorded; with
+// a bus outside the database a failed commit means the batch is published
+// again.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, relayLockID).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, event_type, message_id, payload, created_at FROM outbox
+		 WHERE delivered_at IS NULL
+		 ORDER BY id
+		 LIMIT $1`,
+		r.opts.BatchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	type row struct {
+		id        int64
+		eventType string
+		messageID string
+		payload   []byte
+		createdAt time.Time
+	}
+	var batch []row
+	for rows.Next() {
+		var rw row
+		if err := rows.Scan(&rw.id, &rw.eventType, &rw.messageID, &rw.payload, &rw.createdAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, rw)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, len(batch))
+	for i, rw := range batch {
+		if err := r.bus.Publish(ctx, tx, rw.eventType, rw.messageID, json.RawMessage(rw.payload)); err != nil {
+			return 0, err
+		}
+		ids[i] = rw.id
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE outbox SET delivered_at = NOW() WHERE id = ANY($1)`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, rw := range batch {
+		r.lag.Record(ctx, float64(now.Sub(rw.createdAt).Milliseconds()))
+	}
+	return len(batch), nil
+}
+
+func (r *Relay) prune(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM outbox WHERE delivered_at < NOW() - $1 * INTERVAL '1 second'`,
+		int64(r.opts.Retention.Seconds()),
+	)
+	return err
+}
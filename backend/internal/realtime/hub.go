@@ -0,0 +1,474 @@
+// Package realtime keeps the state behind WebSocket connections: which threads
+// each connection follows, who is typing and who is present in a thread.
+// Thread events and typing/presence signals travel over the event bus, so
+// connections on different replicas see each other.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"why-backend/internal/events"
+)
+
+const (
+	defaultSendBuffer  = 64
+	defaultPresenceTTL = time.Minute
+
+	// typingInterval throttles typing signals per connection and thread
+	typingInterval = 2 * time.Second
+	// MaxSubscriptions caps the threads one connection may follow
+	MaxSubscriptions = 50
+)
+
+// Server frame types, besides the event types of the events package
+const (
+	FrameSubscribed   = "subscribed"
+	FrameUnsubscribed = "unsubscribed"
+	FrameTyping       = events.Typing
+	FramePresence     = events.Presence
+	FrameError        = "error"
+)
+
+var (
+	ErrTooManySubscriptions = errors.New("too many subscriptions")
+	ErrNotSubscribed        = errors.New("not subscribed to thread")
+)
+
+// Frame is a message sent to a client
+type Frame struct {
+	Type      string          `json:"type"`
+	ID        int64           `json:"id,omitempty"`
+	MessageID string          `json:"message_id,omitempty"`
+	UserID    string          `json:"user_id,omitempty"`
+	UserIDs   []string        `json:"user_ids,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// typingSignal and presenceSignal are the payloads of the bus signals
+type typingSignal struct {
+	UserID string `json:"user_id"`
+}
+
+type presenceSignal struct {
+	UserID string `json:"user_id"`
+	Online bool   `json:"online"`
+}
+
+// Options tunes a Hub. Zero values select the defaults.
+type Options struct {
+	// SendBuffer is how many frames may queue for a client before it is
+	// dropped as a slow consumer
+	SendBuffer int
+	// PresenceTTL is how long a user stays present without a refresh from
+	// their replica, which matters only if that replica goes away
+	PresenceTTL time.Duration
+}
+
+// Hub routes bus events and signals to the clients following each thread
+type Hub struct {
+	bus  events.Bus
+	opts Options
+
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	threads map[string]map[*Client]struct{}
+	// presence holds the users present in each thread on any replica, by the
+	// time of their last presence signal
+	presence map[string]map[string]time.Time
+	closed   bool
+}
+
+// Client is one connection. Its frames are read from Send until Done is
+// closed, after which Slow tells whether the hub dropped it for falling behind.
+type Client struct {
+	UserID string
+
+	hub  *Hub
+	send chan Frame
+	done chan struct{}
+
+	// Guarded by hub.mu
+	threads map[string]time.Time // thread → last typing signal
+	slow    bool
+	closed  bool
+}
+
+func NewHub(bus events.Bus, opts Options) *Hub {
+	if opts.SendBuffer <= 0 {
+		opts.SendBuffer = defaultSendBuffer
+	}
+	if opts.PresenceTTL <= 0 {
+		opts.PresenceTTL = defaultPresenceTTL
+	}
+
+	return &Hub{
+		bus:      bus,
+		opts:     opts,
+		clients:  map[*Client]struct{}{},
+		threads:  map[string]map[*Client]struct{}{},
+		presence: map[string]map[string]time.Time{},
+	}
+}
+
+// Start follows the bus and keeps presence fresh until ctx is cancelled, at
+// which point every client is closed
+func (h *Hub) Start(ctx context.Context) {
+	unsubscribe := h.bus.Subscribe(h.dispatch)
+
+	go func() {
+		defer unsubscribe()
+
+		refresh := time.NewTicker(h.opts.PresenceTTL / 3)
+		defer refresh.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				h.close()
+				return
+			case now := <-refresh.C:
+				h.refreshPresence(ctx, now)
+			}
+		}
+	}()
+}
+
+// Connect registers a client for userID
+func (h *Hub) Connect(userID string) *Client {
+	c := &Client{
+		UserID:  userID,
+		hub:     h,
+		send:    make(chan Frame, h.opts.SendBuffer),
+		done:    make(chan struct{}),
+		threads: map[string]time.Time{},
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		c.closed = true
+		close(c.done)
+		return c
+	}
+	h.clients[c] = struct{}{}
+	return c
+}
+
+func (c *Client) Send() <-chan Frame {
+	return c.send
+}
+
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Slow reports whether the client was dropped for not keeping up
+func (c *Client) Slow() bool {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+
+	return c.slow
+}
+
+// Reply queues a frame for the client
+func (c *Client) Reply(frame Frame) {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+
+	c.hub.enqueue(c, frame)
+}
+
+// Subscribe follows a thread: the client receives its events, typing
+// signals and presence, and becomes present in it
+func (c *Client) Subscribe(ctx context.Context, threadID string) error {
+	h := c.hub
+
+	h.mu.Lock()
+	if c.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	if _, ok := c.threads[threadID]; ok {
+		h.enqueue(c, Frame{Type: FrameSubscribed, MessageID: threadID})
+		h.enqueue(c, h.presenceFrame(threadID))
+		h.mu.Unlock()
+		return nil
+	}
+	if len(c.threads) >= MaxSubscriptions {
+		h.mu.Unlock()
+		return ErrTooManySubscriptions
+	}
+
+	arrived := !h.presentLocally(threadID, c.UserID)
+	c.threads[threadID] = time.Time{}
+	if h.threads[threadID] == nil {
+		h.threads[threadID] = map[*Client]struct{}{}
+	}
+	h.threads[threadID][c] = struct{}{}
+
+	h.enqueue(c, Frame{Type: FrameSubscribed, MessageID: threadID})
+	if arrived {
+		h.sendPresence(threadID)
+	} else {
+		h.enqueue(c, h.presenceFrame(threadID))
+	}
+	h.mu.Unlock()
+
+	if arrived {
+		return h.bus.Broadcast(ctx, events.Presence, threadID, presenceSignal{UserID: c.UserID, Online: true})
+	}
+	return nil
+}
+
+// Unsubscribe stops following a thread
+func (c *Client) Unsubscribe(ctx context.Context, threadID string) error {
+	h := c.hub
+
+	h.mu.Lock()
+	if _, ok := c.threads[threadID]; !ok {
+		h.mu.Unlock()
+		return ErrNotSubscribed
+	}
+	left := h.leave(c, threadID)
+	h.enqueue(c, Frame{Type: FrameUnsubscribed, MessageID: threadID})
+	h.mu.Unlock()
+
+	if left {
+		return h.bus.Broadcast(ctx, events.Presence, threadID, presenceSignal{UserID: c.UserID, Online: false})
+	}
+	return nil
+}
+
+// Typing tells the other followers of a thread that the client's user is
+// typing. Repeated calls within typingInterval are ignored.
+func (c *Client) Typing(ctx context.Context, threadID string) error {
+	h := c.hub
+
+	h.mu.Lock()
+	last, ok := c.threads[threadID]
+	if !ok {
+		h.mu.Unlock()
+		return ErrNotSubscribed
+	}
+	now := time.Now()
+	if now.Sub(last) < typingInterval {
+		h.mu.Unlock()
+		return nil
+	}
+	c.threads[threadID] = now
+	h.mu.Unlock()
+
+	return h.bus.Broadcast(ctx, events.Typing, threadID, typingSignal{UserID: c.UserID})
+}
+
+// Close unregisters the client and ends its presence
+func (c *Client) Close(ctx context.Context) {
+	h := c.hub
+
+	h.mu.Lock()
+	var left []string
+	for threadID := range c.threads {
+		if h.leave(c, threadID) {
+			left = append(left, threadID)
+		}
+	}
+	delete(h.clients, c)
+	h.stop(c)
+	h.mu.Unlock()
+
+	for _, threadID := range left {
+		err := h.bus.Broadcast(ctx, events.Presence, threadID, presenceSignal{UserID: c.UserID, Online: false})
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to broadcast presence", "error", err, "message_id", threadID)
+		}
+	}
+}
+
+// dispatch is the bus handler
+func (h *Hub) dispatch(event events.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	followers := h.threads[event.MessageID]
+
+	switch {
+	case !event.Ephemeral():
+		if len(followers) == 0 {
+			return
+		}
+		frame := Frame{Type: event.Type, ID: event.ID, MessageID: event.MessageID, Data: event.Data}
+		for c := range followers {
+			h.enqueue(c, frame)
+		}
+
+	case event.Type == events.Typing:
+		var signal typingSignal
+		if err := json.Unmarshal(event.Data, &signal); err != nil {
+			return
+		}
+		frame := Frame{Type: FrameTyping, MessageID: event.MessageID, UserID: signal.UserID}
+		for c := range followers {
+			if c.UserID != signal.UserID {
+				h.enqueue(c, frame)
+			}
+		}
+
+	case event.Type == events.Presence:
+		var signal presenceSignal
+		if err := json.Unmarshal(event.Data, &signal); err != nil {
+			return
+		}
+		before := h.presentUsers(event.MessageID)
+		if signal.Online {
+			if h.presence[event.MessageID] == nil {
+				h.presence[event.MessageID] = map[string]time.Time{}
+			}
+			h.presence[event.MessageID][signal.UserID] = time.Now()
+		} else {
+			delete(h.presence[event.MessageID], signal.UserID)
+		}
+		if !slices.Equal(before, h.presentUsers(event.MessageID)) {
+			h.sendPresence(event.MessageID)
+		}
+	}
+}
+
+// refreshPresence re-announces this replica's users and forgets users whose
+// replica has stopped announcing them
+func (h *Hub) refreshPresence(ctx context.Context, now time.Time) {
+	type announcement struct{ threadID, userID string }
+
+	h.mu.Lock()
+	var announce []announcement
+	for threadID, followers := range h.threads {
+		users := map[string]bool{}
+		for c := range followers {
+			if !users[c.UserID] {
+				users[c.UserID] = true
+				announce = append(announce, announcement{threadID, c.UserID})
+			}
+		}
+	}
+	for threadID, users := range h.presence {
+		expired := false
+		for userID, seen := range users {
+			if now.Sub(seen) > h.opts.PresenceTTL {
+				delete(users, userID)
+				expired = true
+			}
+		}
+		if len(users) == 0 {
+			delete(h.presence, threadID)
+		}
+		if expired {
+			h.sendPresence(threadID)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, a := range announce {
+		err := h.bus.Broadcast(ctx, events.Presence, a.threadID, presenceSignal{UserID: a.userID, Online: true})
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "Failed to refresh presence", "error", err, "message_id", a.threadID)
+		}
+	}
+}
+
+func (h *Hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for c := range h.clients {
+		h.stop(c)
+	}
+}
+
+// The methods below must be called with h.mu held.
+
+// enqueue queues a frame without blocking; a client whose queue is full is
+// dropped so that it cannot stall delivery to everyone else
+func (h *Hub) enqueue(c *Client, frame Frame) {
+	if c.closed {
+		return
+	}
+	select {
+	case c.send <- frame:
+	default:
+		c.slow = true
+		h.stop(c)
+	}
+}
+
+// stop closes Done. The client stays registered until Close.
+func (h *Hub) stop(c *Client) {
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+}
+
+// leave removes c from a thread and reports whether its user has no other
+// connection on this replica following the thread
+func (h *Hub) leave(c *Client, threadID string) bool {
+	delete(c.threads, threadID)
+	delete(h.threads[threadID], c)
+	if len(h.threads[threadID]) == 0 {
+		delete(h.threads, threadID)
+	}
+
+	if h.presentLocally(threadID, c.UserID) {
+		return false
+	}
+	// Another replica still following for this user re-announces them
+	delete(h.presence[threadID], c.UserID)
+	h.sendPresence(threadID)
+	return true
+}
+
+func (h *Hub) presentLocally(threadID, userID string) bool {
+	for c := range h.threads[threadID] {
+		if c.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// presentUsers lists the users present in a thread, here or on another replica
+func (h *Hub) presentUsers(threadID string) []string {
+	users := map[string]bool{}
+	for userID := range h.presence[threadID] {
+		users[userID] = true
+	}
+	for c := range h.threads[threadID] {
+		users[c.UserID] = true
+	}
+
+	list := make([]string, 0, len(users))
+	for userID := range users {
+		list = append(list, userID)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (h *Hub) presenceFrame(threadID string) Frame {
+	return Frame{Type: FramePresence, MessageID: threadID, UserIDs: h.presentUsers(threadID)}
+}
+
+func (h *Hub) sendPresence(threadID string) {
+	frame := h.presenceFrame(threadID)
+	for c := range h.threads[threadID] {
+		h.enqueue(c, frame)
+	}
+}
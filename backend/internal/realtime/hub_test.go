@@ -0,0 +1,159 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/events"
+)
+
+const thread = "5f0c6a1e-7d2b-4a8e-9c3f-00000000000a"
+
+func startHub(t *testing.T, bus events.Bus, opts Options) *Hub {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	hub := NewHub(bus, opts)
+	hub.Start(ctx)
+	return hub
+}
+
+// drain returns the frames queued for c
+func drain(c *Client) []Frame {
+	var frames []Frame
+	for len(c.send) > 0 {
+		frames = append(frames, <-c.send)
+	}
+	return frames
+}
+
+func frameTypes(frames []Frame) []string {
+	types := make([]string, len(frames))
+	for i, frame := range frames {
+		types[i] = frame.Type
+	}
+	return types
+}
+
+func TestHub_Subscribe_ReceivesThreadEvents(t *testing.T) {
+	bus := events.NewMemoryBus()
+	hub := startHub(t, bus, Options{})
+	ctx := context.Background()
+
+	alice := hub.Connect("alice")
+	require.NoError(t, alice.Subscribe(ctx, thread))
+
+	frames := drain(alice)
+	assert.Equal(t, []string{FrameSubscribed, FramePresence}, frameTypes(frames))
+	assert.Equal(t, []string{"alice"}, frames[1].UserIDs)
+
+	require.NoError(t, bus.Publish(ctx, nil, events.ReplyCreated, thread, map[string]string{"id": "reply-1"}))
+	require.NoError(t, bus.Publish(ctx, nil, events.ReplyCreated, "other-thread", map[string]string{"id": "reply-2"}))
+
+	frames = drain(alice)
+	require.Len(t, frames, 1)
+	assert.Equal(t, events.ReplyCreated, frames[0].Type)
+	assert.Equal(t, int64(1), frames[0].ID)
+	assert.JSONEq(t, `{"id":"reply-1"}`, string(frames[0].Data))
+
+	require.NoError(t, alice.Unsubscribe(ctx, thread))
+	require.NoError(t, bus.Publish(ctx, nil, events.ReplyCreated, thread, map[string]string{"id": "reply-3"}))
+	assert.Equal(t, []string{FrameUnsubscribed}, frameTypes(drain(alice)))
+	assert.ErrorIs(t, alice.Unsubscribe(ctx, thread), ErrNotSubscribed)
+}
+
+func TestHub_Typing(t *testing.T) {
+	hub := startHub(t, events.NewMemoryBus(), Options{})
+	ctx := context.Background()
+
+	alice := hub.Connect("alice")
+	bob := hub.Connect("bob")
+	assert.ErrorIs(t, alice.Typing(ctx, thread), ErrNotSubscribed)
+
+	require.NoError(t, alice.Subscribe(ctx, thread))
+	require.NoError(t, bob.Subscribe(ctx, thread))
+	drain(alice)
+	drain(bob)
+
+	require.NoError(t, alice.Typing(ctx, thread))
+	// Throttled
+	require.NoError(t, alice.Typing(ctx, thread))
+
+	assert.Empty(t, drain(alice), "typists are not told about themselves")
+	frames := drain(bob)
+	require.Len(t, frames, 1)
+	assert.Equal(t, FrameTyping, frames[0].Type)
+	assert.Equal(t, "alice", frames[0].UserID)
+}
+
+func TestHub_Presence(t *testing.T) {
+	bus := events.NewMemoryBus()
+	hub := startHub(t, bus, Options{PresenceTTL: time.Minute})
+	ctx := context.Background()
+
+	alice := hub.Connect("alice")
+	require.NoError(t, alice.Subscribe(ctx, thread))
+	drain(alice)
+
+	// Bob arrives on another replica
+	require.NoError(t, bus.Broadcast(ctx, events.Presence, thread, presenceSignal{UserID: "bob", Online: true}))
+	frames := drain(alice)
+	require.Len(t, frames, 1)
+	assert.Equal(t, []string{"alice", "bob"}, frames[0].UserIDs)
+
+	// A second connection of alice changes nothing for the others
+	aliceTab := hub.Connect("alice")
+	require.NoError(t, aliceTab.Subscribe(ctx, thread))
+	assert.Empty(t, drain(alice))
+	aliceTab.Close(ctx)
+	assert.Empty(t, drain(alice))
+
+	// Bob's replica went away without saying goodbye
+	hub.refreshPresence(ctx, time.Now().Add(2*time.Minute))
+	frames = drain(alice)
+	require.Len(t, frames, 1)
+	assert.Equal(t, []string{"alice"}, frames[0].UserIDs)
+}
+
+func TestHub_DropsSlowConsumer(t *testing.T) {
+	bus := events.NewMemoryBus()
+	hub := startHub(t, bus, Options{SendBuffer: 3})
+	ctx := context.Background()
+
+	slow := hub.Connect("alice")
+	require.NoError(t, slow.Subscribe(ctx, thread))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, bus.Publish(ctx, nil, events.ReplyCreated, thread, map[string]int{"n": i}))
+	}
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("slow consumer should be dropped")
+	}
+	assert.True(t, slow.Slow())
+
+	slow.Close(ctx)
+	assert.Empty(t, hub.clients)
+	assert.Empty(t, hub.threads)
+}
+
+func TestHub_CloseOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	hub := NewHub(events.NewMemoryBus(), Options{})
+	hub.Start(ctx)
+
+	client := hub.Connect("alice")
+	cancel()
+
+	select {
+	case <-client.Done():
+	case <-time.After(time.Second):
+		t.Fatal("client should be closed on shutdown")
+	}
+	assert.False(t, client.Slow())
+}
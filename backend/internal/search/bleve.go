@@ -0,0 +1,295 @@
+package search
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	bleveHTML "github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"why-backend/internal/models"
+)
+
+// snippetLength is how many characters of content stand in for a snippet
+// when no match could be highlighted
+const snippetLength = 200
+
+// Bleve is an embedded on-disk index. Each replica keeps its own, fed by
+// the event bus, and a directory can only be open in one process at a time.
+type Bleve struct {
+	index bleve.Index
+}
+
+// OpenBleve opens the index at path, creating it if it does not exist
+func OpenBleve(path string) (*Bleve, error) {
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(path, bleveMapping())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open search index %s: %w", path, err)
+	}
+	return &Bleve{index: index}, nil
+}
+
+// bleveMapping indexes content as English text for matching and highlighting,
+// and the rest as exact values for filtering
+func bleveMapping() *mapping.IndexMappingImpl {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = en.AnalyzerName
+	text.Store = true
+	text.IncludeTermVectors = true
+
+	exact := bleve.NewKeywordFieldMapping()
+	exact.Analyzer = keyword.Name
+
+	unstored := bleve.NewKeywordFieldMapping()
+	unstored.Analyzer = keyword.Name
+	unstored.Store = false
+
+	date := bleve.NewDateTimeFieldMapping()
+
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt("content", text)
+	doc.AddFieldMappingsAt("type", exact)
+	doc.AddFieldMappingsAt("message_id", exact)
+	doc.AddFieldMappingsAt("user_id", exact)
+	doc.AddFieldMappingsAt("tags", unstored)
+	doc.AddFieldMappingsAt("created_at", date)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.DefaultAnalyzer = en.AnalyzerName
+	return m
+}
+
+// docID keeps message and reply IDs apart
+func docID(docType, id string) string {
+	return docType + ":" + id
+}
+
+func (b *Bleve) Index(ctx context.Context, docs ...Document) error {
+	batch := b.index.NewBatch()
+	for _, d := range docs {
+		tags := d.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		err := batch.Index(docID(d.Type, d.ID), map[string]any{
+			"type":       d.Type,
+			"message_id": d.MessageID,
+			"user_id":    d.UserID,
+			"content":    d.Content,
+			"tags":       tags,
+			"created_at": d.CreatedAt.UTC(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return b.index.Batch(batch)
+}
+
+func (b *Bleve) Delete(ctx context.Context, docType, id string) error {
+	return b.index.Delete(docID(docType, id))
+}
+
+func (b *Bleve) Close() error {
+	return b.index.Close()
+}
+
+// encodeBleveCursor records the sort values of the last hit of a page,
+// ready to be passed back as SearchAfter
+func encodeBleveCursor(score float64, id string) string {
+	raw := strconv.FormatFloat(score, 'g', -1, 64) + "," + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeBleveCursor(s string) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	score, id, ok := strings.Cut(string(raw), ",")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := strconv.ParseFloat(score, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return []string{score, id}, nil
+}
+
+func (b *Bleve) Search(ctx context.Context, q Query) (models.SearchPage, error) {
+	result := models.SearchPage{Results: []models.SearchResult{}}
+
+	terms, err := parseTerms(q.Text)
+	if err != nil {
+		return result, err
+	}
+
+	var after []string
+	if q.Cursor != "" {
+		after, err = decodeBleveCursor(q.Cursor)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	conjuncts := []query.Query{termsQuery(terms)}
+	exact := func(field, value string) {
+		tq := bleve.NewTermQuery(value)
+		tq.SetField(field)
+		conjuncts = append(conjuncts, tq)
+	}
+	if q.Type != "" {
+		exact("type", q.Type)
+	}
+	if q.AuthorID != "" {
+		exact("user_id", q.AuthorID)
+	}
+	if q.Tag != "" {
+		exact("tags", q.Tag)
+	}
+	if !q.From.IsZero() || !q.Before.IsZero() {
+		inclusive, exclusive := true, false
+		dq := bleve.NewDateRangeInclusiveQuery(q.From.UTC(), q.Before.UTC(), &inclusive, &exclusive)
+		dq.SetField("created_at")
+		conjuncts = append(conjuncts, dq)
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(conjuncts...), q.Limit+1, 0, false)
+	req.SortBy([]string{"-_score", "-_id"})
+	if after != nil {
+		req.SetSearchAfter(after)
+	}
+	req.Fields = []string{"type", "message_id", "user_id", "content", "created_at"}
+	req.Highlight = bleve.NewHighlightWithStyle(bleveHTML.Name)
+	req.Highlight.AddField("content")
+
+	res, err := b.index.SearchInContext(ctx, req)
+	if err != nil {
+		return result, err
+	}
+
+	for _, hit := range res.Hits {
+		r := models.SearchResult{
+			Type:      stringField(hit.Fields, "type"),
+			ID:        strings.TrimPrefix(hit.ID, stringField(hit.Fields, "type")+":"),
+			MessageID: stringField(hit.Fields, "message_id"),
+			UserID:    stringField(hit.Fields, "user_id"),
+			Snippet:   strings.Join(hit.Fragments["content"], " … "),
+			Rank:      float32(hit.Score),
+		}
+		if r.Snippet == "" {
+			r.Snippet = html.EscapeString(truncate(stringField(hit.Fields, "content"), snippetLength))
+		}
+		if created, err := time.Parse(time.RFC3339Nano, stringField(hit.Fields, "created_at")); err == nil {
+			r.CreatedAt = created
+		}
+		result.Results = append(result.Results, r)
+	}
+
+	if len(res.Hits) > q.Limit {
+		result.Results = result.Results[:q.Limit]
+		last := res.Hits[q.Limit-1]
+		result.NextCursor = encodeBleveCursor(last.Score, last.ID)
+	}
+	return result, nil
+}
+
+// termsQuery mirrors the tsquery ParseQuery builds: AND binds tighter than
+// OR, so terms form AND groups separated by ORs
+func termsQuery(terms []term) query.Query {
+	var groups []query.Query
+	var must, mustNot []query.Query
+
+	flush := func() {
+		group := bleve.NewBooleanQuery()
+		if len(must) > 0 {
+			group.AddMust(must...)
+		} else {
+			group.AddMust(bleve.NewMatchAllQuery())
+		}
+		if len(mustNot) > 0 {
+			group.AddMustNot(mustNot...)
+		}
+		groups = append(groups, group)
+		must, mustNot = nil, nil
+	}
+
+	for i, t := range terms {
+		if i > 0 && t.or {
+			flush()
+		}
+		if t.exclude {
+			mustNot = append(mustNot, termQuery(t))
+		} else {
+			must = append(must, termQuery(t))
+		}
+	}
+	flush()
+
+	if len(groups) == 1 {
+		return groups[0]
+	}
+	return bleve.NewDisjunctionQuery(groups...)
+}
+
+// termQuery matches one word or phrase of the content. A prefix applies to
+// the last word, which is matched against the stemmed terms of the index.
+func termQuery(t term) query.Query {
+	words := t.words
+	var last query.Query
+	if t.prefix {
+		pq := bleve.NewPrefixQuery(words[len(words)-1])
+		pq.SetField("content")
+		last = pq
+		words = words[:len(words)-1]
+	}
+
+	var q query.Query
+	switch len(words) {
+	case 0:
+	case 1:
+		mq := bleve.NewMatchQuery(words[0])
+		mq.SetField("content")
+		q = mq
+	default:
+		pq := bleve.NewMatchPhraseQuery(strings.Join(words, " "))
+		pq.SetField("content")
+		q = pq
+	}
+
+	switch {
+	case q == nil:
+		return last
+	case last == nil:
+		return q
+	default:
+		return bleve.NewConjunctionQuery(q, last)
+	}
+}
+
+func stringField(fields map[string]any, name string) string {
+	s, _ := fields[name].(string)
+	return s
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
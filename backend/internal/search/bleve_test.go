@@ -0,0 +1,150 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/models"
+)
+
+var day = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+var testDocuments = []Document{
+	{Type: models.SearchResultMessage, ID: "m1", MessageID: "m1", UserID: "alice",
+		Content: "Why does the connection pool leak under load?", Tags: []string{"databases"}, CreatedAt: day},
+	{Type: models.SearchResultReply, ID: "r1", MessageID: "m1", UserID: "bob",
+		Content: "The pool leaks when <script> transactions are never committed", Tags: []string{"databases"}, CreatedAt: day.Add(time.Hour)},
+	{Type: models.SearchResultMessage, ID: "m2", MessageID: "m2", UserID: "bob",
+		Content: "How do I set up replication on staging?", Tags: []string{"ops"}, CreatedAt: day.AddDate(0, 0, 1)},
+	{Type: models.SearchResultMessage, ID: "m3", MessageID: "m3", UserID: "alice",
+		Content: "Replicas lag behind the primary during a dry run", CreatedAt: day.AddDate(0, 0, 2)},
+}
+
+func openTestBleve(t *testing.T) *Bleve {
+	t.Helper()
+	index, err := OpenBleve(filepath.Join(t.TempDir(), "search.bleve"))
+	require.NoError(t, err)
+	t.Cleanup(func() { index.Close() })
+	require.NoError(t, index.Index(context.Background(), testDocuments...))
+	return index
+}
+
+func resultIDs(page models.SearchPage) []string {
+	ids := []string{}
+	for _, r := range page.Results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestBleve_Search(t *testing.T) {
+	index := openTestBleve(t)
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"stemmed word", Query{Text: "leaking"}, []string{"m1", "r1"}},
+		{"all words", Query{Text: "pool committed"}, []string{"r1"}},
+		{"phrase", Query{Text: `"connection pool"`}, []string{"m1"}},
+		{"prefix", Query{Text: "replic*"}, []string{"m2", "m3"}},
+		{"exclude", Query{Text: "replic* -staging"}, []string{"m3"}},
+		{"exclude phrase", Query{Text: `replic* -"dry run"`}, []string{"m2"}},
+		{"or", Query{Text: "staging OR primary"}, []string{"m2", "m3"}},
+		{"type", Query{Text: "pool", Type: models.SearchResultReply}, []string{"r1"}},
+		{"author", Query{Text: "pool OR replication", AuthorID: "bob"}, []string{"r1", "m2"}},
+		{"tag", Query{Text: "pool", Tag: "databases"}, []string{"m1", "r1"}},
+		{"from", Query{Text: "replic*", From: day.AddDate(0, 0, 2)}, []string{"m3"}},
+		{"before", Query{Text: "replic*", Before: day.AddDate(0, 0, 2)}, []string{"m2"}},
+		{"no match", Query{Text: "mysql"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Limit = 10
+			page, err := index.Search(context.Background(), tt.query)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, resultIDs(page))
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+}
+
+func TestBleve_Search_Results(t *testing.T) {
+	index := openTestBleve(t)
+
+	page, err := index.Search(context.Background(), Query{Text: "transactions", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Results, 1)
+
+	r := page.Results[0]
+	assert.Equal(t, models.SearchResultReply, r.Type)
+	assert.Equal(t, "r1", r.ID)
+	assert.Equal(t, "m1", r.MessageID)
+	assert.Equal(t, "bob", r.UserID)
+	assert.True(t, r.CreatedAt.Equal(day.Add(time.Hour)))
+	assert.Greater(t, r.Rank, float32(0))
+	assert.Contains(t, r.Snippet, "&lt;script&gt; <mark>transactions</mark>")
+}
+
+func TestBleve_Search_Pages(t *testing.T) {
+	index := openTestBleve(t)
+
+	var seen []string
+	q := Query{Text: "pool OR replic*", Limit: 1}
+	for i := 0; i < 5; i++ {
+		page, err := index.Search(context.Background(), q)
+		require.NoError(t, err)
+		seen = append(seen, resultIDs(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	assert.ElementsMatch(t, []string{"m1", "r1", "m2", "m3"}, seen)
+
+	_, err := index.Search(context.Background(), Query{Text: "pool", Limit: 1, Cursor: "bogus"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestBleve_IndexAndDelete(t *testing.T) {
+	index := openTestBleve(t)
+	ctx := context.Background()
+
+	updated := testDocuments[2]
+	updated.Content = "How do I set up logical decoding?"
+	require.NoError(t, index.Index(ctx, updated))
+
+	page, err := index.Search(ctx, Query{Text: "replication", Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Results)
+
+	require.NoError(t, index.Delete(ctx, models.SearchResultReply, "r1"))
+	require.NoError(t, index.Delete(ctx, models.SearchResultReply, "missing"))
+
+	page, err = index.Search(ctx, Query{Text: "pool", Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"m1"}, resultIDs(page))
+}
+
+func TestOpenBleve_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.bleve")
+
+	index, err := OpenBleve(path)
+	require.NoError(t, err)
+	require.NoError(t, index.Index(context.Background(), testDocuments[0]))
+	require.NoError(t, index.Close())
+
+	index, err = OpenBleve(path)
+	require.NoError(t, err)
+	defer index.Close()
+
+	page, err := index.Search(context.Background(), Query{Text: "pool", Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"m1"}, resultIDs(page))
+}
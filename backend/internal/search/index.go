@@ -0,0 +1,59 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"why-backend/internal/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Document is a message or reply as the index sees it. Tags are the
+// message's tags, or a reply's parent message's.
+type Document struct {
+	Type      string
+	ID        string
+	MessageID string
+	UserID    string
+	Content   string
+	Tags      []string
+	CreatedAt time.Time
+}
+
+// Query is a search request. Text uses the ParseQuery syntax; the other
+// fields are optional filters. Cursor is the NextCursor of a previous page
+// from the same index.
+type Query struct {
+	Text     string
+	Type     string
+	AuthorID string
+	Tag      string
+	// From is inclusive and Before exclusive
+	From   time.Time
+	Before time.Time
+	Limit  int
+	Cursor string
+}
+
+// Index stores documents and answers queries, best matches first with
+// highlighted snippets. Snippets are HTML: matches are wrapped in <mark> and
+// everything else is escaped.
+type Index interface {
+	// Index adds or replaces documents
+	Index(ctx context.Context, docs ...Document) error
+	// Delete removes a document; deleting a missing one is not an error
+	Delete(ctx context.Context, docType, id string) error
+	Search(ctx context.Context, q Query) (models.SearchPage, error)
+	Close() error
+}
+
+// BadQuery reports whether err from Search is the caller's fault: the query
+// text or cursor is unusable
+func BadQuery(err error) bool {
+	return errors.Is(err, ErrEmptyQuery) ||
+		errors.Is(err, ErrTooManyTerms) ||
+		errors.Is(err, ErrOnlyExclusion) ||
+		errors.Is(err, ErrInvalidCursor)
+}
@@ -0,0 +1,108 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+)
+
+// indexerQueueSize bounds the events waiting to be indexed
+const indexerQueueSize = 1024
+
+// Indexer keeps an Index current from message and reply write events. It
+// indexes rows as they are in the database when the event is handled, so a
+// late or repeated event cannot regress a document. Events dropped while the
+// queue is full leave the index stale until the next write or a reindex.
+type Indexer struct {
+	db    *sql.DB
+	bus   events.Bus
+	index Index
+	queue chan events.Event
+}
+
+func NewIndexer(db *sql.DB, bus events.Bus, index Index) *Indexer {
+	return &Indexer{
+		db:    db,
+		bus:   bus,
+		index: index,
+		queue: make(chan events.Event, indexerQueueSize),
+	}
+}
+
+// Start subscribes to the bus and indexes events until ctx is cancelled
+func (x *Indexer) Start(ctx context.Context) {
+	unsubscribe := x.bus.Subscribe(func(e events.Event) {
+		switch e.Type {
+		case events.MessageCreated, events.MessageUpdated, events.MessageDeleted,
+			events.ReplyCreated, events.ReplyUpdated, events.ReplyDeleted:
+		default:
+			return
+		}
+		select {
+		case x.queue <- e:
+		default:
+			slog.Warn("Search indexer queue full, dropping event", "event_id", e.ID, "type", e.Type)
+		}
+	})
+
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-x.queue:
+				if err := x.handle(ctx, e); err != nil {
+					slog.ErrorContext(ctx, "Failed to index event", "event_id", e.ID, "type", e.Type, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+func (x *Indexer) handle(ctx context.Context, e events.Event) error {
+	var payload struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(e.Data, &payload); err != nil {
+		return err
+	}
+
+	switch e.Type {
+	case events.MessageDeleted:
+		return x.index.Delete(ctx, models.SearchResultMessage, payload.ID)
+	case events.ReplyDeleted:
+		return x.index.Delete(ctx, models.SearchResultReply, payload.ID)
+	case events.MessageCreated:
+		return x.refresh(ctx, models.SearchResultMessage, payload.ID, `AND m.id = $1`)
+	case events.MessageUpdated:
+		if err := x.refresh(ctx, models.SearchResultMessage, payload.ID, `AND m.id = $1`); err != nil {
+			return err
+		}
+		// Replies carry the thread's tags, which the update may have changed
+		docs, err := queryDocuments(ctx, x.db, models.SearchResultReply, `AND r.message_id = $1`, payload.ID)
+		if err != nil || len(docs) == 0 {
+			return err
+		}
+		return x.index.Index(ctx, docs...)
+	default:
+		return x.refresh(ctx, models.SearchResultReply, payload.ID, `AND r.id = $1`)
+	}
+}
+
+// refresh re-indexes one document from the database, or deletes it if the
+// row is gone
+func (x *Indexer) refresh(ctx context.Context, docType, id, where string) error {
+	docs, err := queryDocuments(ctx, x.db, docType, where, id)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return x.index.Delete(ctx, docType, id)
+	}
+	return x.index.Index(ctx, docs...)
+}
@@ -0,0 +1,182 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"why-backend/internal/models"
+)
+
+// headlineOptions configures the highlighted snippets
+const headlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, ` +
+	`MaxFragments=2, FragmentDelimiter=" … "`
+
+// escapeHTML wraps a text column so snippets built from it are safe to render
+// as HTML once the matches are marked
+func escapeHTML(column string) string {
+	return `replace(replace(replace(` + column + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`
+}
+
+// Postgres searches the generated search_vector columns of messages and
+// replies. The database keeps them current as rows are written, so Index and
+// Delete have nothing to do.
+type Postgres struct {
+	db *sql.DB
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Index(ctx context.Context, docs ...Document) error {
+	return nil
+}
+
+func (p *Postgres) Delete(ctx context.Context, docType, id string) error {
+	return nil
+}
+
+func (p *Postgres) Close() error {
+	return nil
+}
+
+// pgCursor is a keyset position in ranked results
+type pgCursor struct {
+	Rank float32
+	ID   string
+}
+
+func encodePgCursor(rank float32, id string) string {
+	raw := strconv.FormatFloat(float64(rank), 'g', -1, 32) + "," + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePgCursor(s string) (*pgCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	rank, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	r, err := strconv.ParseFloat(rank, 32)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &pgCursor{Rank: float32(r), ID: id}, nil
+}
+
+func (p *Postgres) Search(ctx context.Context, q Query) (models.SearchPage, error) {
+	result := models.SearchPage{Results: []models.SearchResult{}}
+
+	tsquery, err := ParseQuery(q.Text)
+	if err != nil {
+		return result, err
+	}
+
+	var cursor *pgCursor
+	if q.Cursor != "" {
+		cursor, err = decodePgCursor(q.Cursor)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// Filters apply to both halves of the union: {t} stands for the table and
+	// {thread} for the column holding the thread's message id
+	args := []any{tsquery, q.Limit + 1}
+	var filters []string
+	addFilter := func(condition string, value any) {
+		args = append(args, value)
+		filters = append(filters, strings.ReplaceAll(condition, "$?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if q.AuthorID != "" {
+		addFilter(`{t}.user_id = $?`, q.AuthorID)
+	}
+	if q.Tag != "" {
+		addFilter(`EXISTS (SELECT 1 FROM message_tags mt WHERE mt.message_id = {thread} AND mt.tag = $?)`, q.Tag)
+	}
+	if !q.From.IsZero() {
+		addFilter(`{t}.created_at >= $?`, q.From)
+	}
+	if !q.Before.IsZero() {
+		addFilter(`{t}.created_at < $?`, q.Before)
+	}
+
+	branch := func(kind, alias, table, messageID, public string) string {
+		where := alias + `.search_vector @@ q.query AND ` + public
+		for _, f := range filters {
+			where += ` AND ` + strings.NewReplacer("{t}", alias, "{thread}", messageID).Replace(f)
+		}
+		return `SELECT '` + kind + `' AS kind, ` + alias + `.id, ` + messageID + ` AS message_id, ` +
+			alias + `.user_id, ` + alias + `.content, ts_rank_cd(` + alias + `.search_vector, q.query) AS rank, ` +
+			alias + `.created_at
+			 FROM ` + table + ` ` + alias + `, q
+			 WHERE ` + where
+	}
+
+	// Only public threads are searchable, and hidden or filtered content is not
+	var branches []string
+	if q.Type != models.SearchResultReply {
+		branches = append(branches, branch(models.SearchResultMessage, "m", "messages", "m.id",
+			`m.visibility = '`+models.VisibilityPublic+`' AND m.hidden_at IS NULL AND m.filter_action IS NULL`))
+	}
+	if q.Type != models.SearchResultMessage {
+		branches = append(branches, branch(models.SearchResultReply, "r", "replies", "r.message_id",
+			`r.hidden_at IS NULL AND r.filter_action IS NULL AND EXISTS (SELECT 1 FROM messages pm
+			 WHERE pm.id = r.message_id AND pm.visibility = '`+models.VisibilityPublic+`' AND pm.hidden_at IS NULL
+			 AND pm.filter_action IS NULL)`))
+	}
+
+	hits := `SELECT * FROM (` + strings.Join(branches, ` UNION ALL `) + `) u`
+	if cursor != nil {
+		args = append(args, cursor.Rank, cursor.ID)
+		hits += ` WHERE (u.rank, u.id) < ($` + strconv.Itoa(len(args)-1) + `::real, $` + strconv.Itoa(len(args)) + `::uuid)`
+	}
+	hits += ` ORDER BY u.rank DESC, u.id DESC LIMIT $2`
+
+	// Snippets are only built for the page
+	query := `WITH q AS (SELECT to_tsquery('english', $1) AS query),
+		 hits AS (` + hits + `)
+		 SELECT h.kind, h.id, h.message_id, h.user_id,
+			ts_headline('english', ` + escapeHTML("h.content") + `, q.query, '` + headlineOptions + `'),
+			h.rank, h.created_at
+		 FROM hits h, q
+		 ORDER BY h.rank DESC, h.id DESC`
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.SearchResult
+		if err := rows.Scan(&r.Type, &r.ID, &r.MessageID, &r.UserID, &r.Snippet, &r.Rank, &r.CreatedAt); err != nil {
+			return result, fmt.Errorf("scan search result: %w", err)
+		}
+		result.Results = append(result.Results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	if len(result.Results) > q.Limit {
+		result.Results = result.Results[:q.Limit]
+		last := result.Results[q.Limit-1]
+		result.NextCursor = encodePgCursor(last.Rank, last.ID)
+	}
+	return result, nil
+}
@@ -0,0 +1,59 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/testutil"
+)
+
+var searchColumns = []string{"kind", "id", "message_id", "user_id", "ts_headline", "rank", "created_at"}
+
+func TestPostgres_Search_Pages(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	index := NewPostgres(db)
+	now := time.Now()
+
+	mock.ExpectQuery("ORDER BY u.rank DESC, u.id DESC LIMIT \\$2").
+		WithArgs("postgres", 2).
+		WillReturnRows(sqlmock.NewRows(searchColumns).
+			AddRow("message", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "user-1", "<mark>postgres</mark>", 0.5, now).
+			AddRow("reply", "5f0c6a1e-7d2b-4a8e-9c3f-000000000002", "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", "user-2", "<mark>postgres</mark>", 0.25, now))
+
+	page, err := index.Search(context.Background(), Query{Text: "postgres", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Results, 1)
+	require.NotEmpty(t, page.NextCursor)
+
+	mock.ExpectQuery("FROM replies r, q WHERE r.search_vector @@ q.query "+
+		"AND r.hidden_at IS NULL AND r.filter_action IS NULL AND EXISTS \\(SELECT 1 FROM messages pm WHERE pm.id = r.message_id AND pm.visibility = 'public' AND pm.hidden_at IS NULL AND pm.filter_action IS NULL\\)\\) u "+
+		"WHERE \\(u.rank, u.id\\) < \\(\\$3::real, \\$4::uuid\\)").
+		WithArgs("postgres", 2, float32(0.5), "5f0c6a1e-7d2b-4a8e-9c3f-000000000003").
+		WillReturnRows(sqlmock.NewRows(searchColumns))
+
+	page, err = index.Search(context.Background(), Query{Text: "postgres", Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Empty(t, page.Results)
+	assert.Empty(t, page.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgres_Search_BadQuery(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	index := NewPostgres(db)
+
+	_, err := index.Search(context.Background(), Query{Text: "-draft", Limit: 10})
+	assert.True(t, BadQuery(err))
+
+	_, err = index.Search(context.Background(), Query{Text: "postgres", Limit: 10, Cursor: "bogus"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
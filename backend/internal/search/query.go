@@ -0,0 +1,154 @@
+// Package search indexes messages and replies and answers user search
+// queries, with Postgres full-text search or an embedded Bleve index.
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// maxTerms bounds the size of a query
+const maxTerms = 16
+
+var (
+	ErrEmptyQuery    = errors.New("query has no search terms")
+	ErrTooManyTerms  = errors.New("query has too many terms")
+	ErrOnlyExclusion = errors.New("query must include a term that is not excluded")
+)
+
+// term is a word or phrase of a query
+type term struct {
+	words   []string
+	prefix  bool
+	exclude bool
+	// or joins the term to the previous one with OR instead of AND
+	or bool
+}
+
+// ParseQuery converts a user query into to_tsquery syntax. Words must all
+// match; "quoted phrases" match adjacent words; a trailing * matches a prefix
+// (data*); a leading - excludes a term (-draft); OR between terms matches
+// either. Punctuation within a word splits it into a phrase, as the
+// full-text parser does when indexing. The result contains only letters,
+// digits and tsquery operators, so it is safe to pass to to_tsquery.
+func ParseQuery(q string) (string, error) {
+	terms, err := parseTerms(q)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, t := range terms {
+		if i > 0 {
+			if t.or {
+				b.WriteString(" | ")
+			} else {
+				b.WriteString(" & ")
+			}
+		}
+		if t.exclude {
+			b.WriteString("!")
+		}
+		if len(t.words) > 1 {
+			b.WriteString("(")
+		}
+		for j, word := range t.words {
+			if j > 0 {
+				b.WriteString(" <-> ")
+			}
+			b.WriteString(word)
+			if t.prefix && j == len(t.words)-1 {
+				b.WriteString(":*")
+			}
+		}
+		if len(t.words) > 1 {
+			b.WriteString(")")
+		}
+	}
+	return b.String(), nil
+}
+
+func parseTerms(q string) ([]term, error) {
+	var terms []term
+	or := false
+	positive := false
+
+	for _, token := range tokenize(q) {
+		if token == "OR" {
+			// OR between two terms; leading, trailing or doubled ORs are ignored
+			or = len(terms) > 0
+			continue
+		}
+
+		var t term
+		quoted := strings.HasPrefix(token, `"`)
+		if !quoted && len(token) > 1 && strings.HasPrefix(token, "-") {
+			t.exclude = true
+			token = token[1:]
+			quoted = strings.HasPrefix(token, `"`)
+		}
+		if strings.HasSuffix(token, "*") {
+			t.prefix = true
+			token = strings.TrimRight(token, "*")
+		}
+		if quoted {
+			token = strings.Trim(token, `"`)
+		}
+
+		t.words = words(token)
+		if len(t.words) == 0 {
+			continue
+		}
+		t.or = or
+		or = false
+
+		if len(terms) == maxTerms {
+			return nil, ErrTooManyTerms
+		}
+		terms = append(terms, t)
+		positive = positive || !t.exclude
+	}
+
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if !positive {
+		return nil, ErrOnlyExclusion
+	}
+	return terms, nil
+}
+
+// tokenize splits q on whitespace outside double quotes. An unterminated
+// quote runs to the end of the query.
+func tokenize(q string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuote := false
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuote:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// words returns the lowercased runs of letters and digits in s
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
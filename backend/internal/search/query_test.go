@@ -0,0 +1,46 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+		err   error
+	}{
+		{"single word", "postgres", "postgres", nil},
+		{"all words", "postgres index", "postgres & index", nil},
+		{"case is folded", "Postgres", "postgres", nil},
+		{"phrase", `"connection pool" leak`, "(connection <-> pool) & leak", nil},
+		{"prefix", "replic*", "replic:*", nil},
+		{"prefix phrase", `"read repl"*`, "(read <-> repl:*)", nil},
+		{"exclude", "deploy -staging", "deploy & !staging", nil},
+		{"exclude phrase", `deploy -"dry run"`, "deploy & !(dry <-> run)", nil},
+		{"or", "mysql OR postgres", "mysql | postgres", nil},
+		{"lowercase or is a word", "this or that", "this & or & that", nil},
+		{"dangling or", "OR postgres OR", "postgres", nil},
+		{"punctuation splits words", "e-mail", "(e <-> mail)", nil},
+		{"operators are not passed through", "a&b | !c <-> (d):*", "(a <-> b) & c & d:*", nil},
+		{"quote injection", `x' | 'y`, "x & y", nil},
+		{"unicode", "café naïve", "café & naïve", nil},
+		{"unterminated quote", `"open ended`, "(open <-> ended)", nil},
+		{"empty", "   ", "", ErrEmptyQuery},
+		{"only punctuation", `!!! "" *`, "", ErrEmptyQuery},
+		{"only exclusions", "-draft -staging", "", ErrOnlyExclusion},
+		{"too many terms", strings.Repeat("word ", maxTerms+1), "", ErrTooManyTerms},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuery(tt.query)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/lib/pq"
+	"why-backend/internal/models"
+)
+
+// DefaultBatchSize is how many rows Reindex loads and indexes at a time
+const DefaultBatchSize = 500
+
+// Only public threads are searchable, and hidden content and content the
+// content filter held or shadow-hid are not
+var (
+	messageDocuments = `SELECT m.id, m.id, m.user_id, m.content,
+			ARRAY(SELECT tag FROM message_tags WHERE message_id = m.id ORDER BY tag), m.created_at
+		 FROM messages m
+		 WHERE m.visibility = '` + models.VisibilityPublic + `' AND m.hidden_at IS NULL AND m.filter_action IS NULL`
+	replyDocuments = `SELECT r.id, r.message_id, r.user_id, r.content,
+			ARRAY(SELECT tag FROM message_tags WHERE message_id = r.message_id ORDER BY tag), r.created_at
+		 FROM replies r
+		 JOIN messages m ON m.id = r.message_id
+		 WHERE m.visibility = '` + models.VisibilityPublic + `' AND m.hidden_at IS NULL AND m.filter_action IS NULL
+			AND r.hidden_at IS NULL AND r.filter_action IS NULL`
+)
+
+// queryDocuments loads documents of docType with one of the queries above
+// followed by more conditions and clauses, starting with AND
+func queryDocuments(ctx context.Context, db *sql.DB, docType, where string, args ...any) ([]Document, error) {
+	base := messageDocuments
+	if docType == models.SearchResultReply {
+		base = replyDocuments
+	}
+
+	rows, err := db.QueryContext(ctx, base+" "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []Document
+	for rows.Next() {
+		d := Document{Type: docType}
+		var tags pq.StringArray
+		if err := rows.Scan(&d.ID, &d.MessageID, &d.UserID, &d.Content, &tags, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan %s document: %w", docType, err)
+		}
+		d.Tags = tags
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// Reindex indexes every message and reply in the database, batchSize rows
+// at a time, and returns how many documents it indexed
+func Reindex(ctx context.Context, db *sql.DB, index Index, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	total := 0
+	for _, t := range []struct{ docType, alias string }{
+		{models.SearchResultMessage, "m"},
+		{models.SearchResultReply, "r"},
+	} {
+		after := "00000000-0000-0000-0000-000000000000"
+		for {
+			docs, err := queryDocuments(ctx, db, t.docType,
+				`AND `+t.alias+`.id > $1 ORDER BY `+t.alias+`.id LIMIT $2`, after, batchSize)
+			if err != nil {
+				return total, fmt.Errorf("load %s documents: %w", t.docType, err)
+			}
+			if len(docs) == 0 {
+				break
+			}
+			if err := index.Index(ctx, docs...); err != nil {
+				return total, fmt.Errorf("index %s documents: %w", t.docType, err)
+			}
+			total += len(docs)
+			after = docs[len(docs)-1].ID
+			if len(docs) < batchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// RebuildBleve builds a fresh Bleve index from the database next to path and
+// then swaps it in, so documents of deleted rows do not survive. Nothing may
+// have the index at path open meanwhile.
+func RebuildBleve(ctx context.Context, db *sql.DB, path string, batchSize int) (int, error) {
+	next := path + ".new"
+	if err := os.RemoveAll(next); err != nil {
+		return 0, err
+	}
+
+	index, err := OpenBleve(next)
+	if err != nil {
+		return 0, err
+	}
+	total, err := Reindex(ctx, db, index, batchSize)
+	if cerr := index.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.RemoveAll(next)
+		return total, err
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return total, err
+	}
+	return total, os.Rename(next, path)
+}
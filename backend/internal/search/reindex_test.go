@@ -0,0 +1,117 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/testutil"
+)
+
+var documentColumns = []string{"id", "message_id", "user_id", "content", "tags", "created_at"}
+
+const firstID = "00000000-0000-0000-0000-000000000000"
+
+func TestRebuildBleve(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("FROM messages m WHERE m.visibility = 'public' AND m.hidden_at IS NULL AND m.filter_action IS NULL AND m.id > \\$1 ORDER BY m.id LIMIT \\$2").
+		WithArgs(firstID, 2).
+		WillReturnRows(sqlmock.NewRows(documentColumns).
+			AddRow("m1", "m1", "alice", "connection pool leak", "{databases}", now).
+			AddRow("m2", "m2", "bob", "replication on staging", "{}", now))
+	mock.ExpectQuery("FROM messages m WHERE m.visibility = 'public' AND m.hidden_at IS NULL AND m.filter_action IS NULL AND m.id > \\$1").
+		WithArgs("m2", 2).
+		WillReturnRows(sqlmock.NewRows(documentColumns).
+			AddRow("m3", "m3", "alice", "replicas lag", "{}", now))
+	mock.ExpectQuery("FROM replies r JOIN messages m ON m.id = r.message_id WHERE m.visibility = 'public' AND m.hidden_at IS NULL AND m.filter_action IS NULL AND r.hidden_at IS NULL AND r.filter_action IS NULL AND r.id > \\$1 ORDER BY r.id LIMIT \\$2").
+		WithArgs(firstID, 2).
+		WillReturnRows(sqlmock.NewRows(documentColumns).
+			AddRow("r1", "m1", "bob", "the pool leaks", "{databases}", now))
+
+	// A stale index is replaced, not added to
+	path := filepath.Join(t.TempDir(), "search.bleve")
+	stale, err := OpenBleve(path)
+	require.NoError(t, err)
+	require.NoError(t, stale.Index(context.Background(), Document{Type: models.SearchResultMessage, ID: "gone", Content: "pool"}))
+	require.NoError(t, stale.Close())
+
+	total, err := RebuildBleve(context.Background(), db, path, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = os.Stat(path + ".new")
+	assert.True(t, os.IsNotExist(err))
+
+	index, err := OpenBleve(path)
+	require.NoError(t, err)
+	defer index.Close()
+
+	page, err := index.Search(context.Background(), Query{Text: "pool", Tag: "databases", Limit: 10})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"m1", "r1"}, resultIDs(page))
+
+	page, err = index.Search(context.Background(), Query{Text: "pool", Limit: 10})
+	require.NoError(t, err)
+	assert.NotContains(t, resultIDs(page), "gone")
+}
+
+func TestIndexer(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	index, err := OpenBleve(filepath.Join(t.TempDir(), "search.bleve"))
+	require.NoError(t, err)
+	defer index.Close()
+
+	bus := events.NewMemoryBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	NewIndexer(db, bus, index).Start(ctx)
+
+	now := time.Now()
+	mock.ExpectQuery("FROM messages m WHERE m.visibility = 'public' AND m.hidden_at IS NULL AND m.filter_action IS NULL AND m.id = \\$1").
+		WithArgs("m1").
+		WillReturnRows(sqlmock.NewRows(documentColumns).
+			AddRow("m1", "m1", "alice", "connection pool leak", "{}", now))
+	mock.ExpectQuery("FROM replies r JOIN messages m ON m.id = r.message_id WHERE m.visibility = 'public' AND m.hidden_at IS NULL AND m.filter_action IS NULL AND r.hidden_at IS NULL AND r.filter_action IS NULL AND r.id = \\$1").
+		WithArgs("r1").
+		WillReturnRows(sqlmock.NewRows(documentColumns).
+			AddRow("r1", "m1", "bob", "the pool leaks", "{}", now))
+	// Tags changed: the thread's replies are re-indexed with them
+	mock.ExpectQuery("FROM messages m WHERE m.visibility = 'public' AND m.hidden_at IS NULL AND m.filter_action IS NULL AND m.id = \\$1").
+		WithArgs("m1").
+		WillReturnRows(sqlmock.NewRows(documentColumns).
+			AddRow("m1", "m1", "alice", "connection pool leak", "{databases}", now))
+	mock.ExpectQuery("FROM replies r JOIN messages m ON m.id = r.message_id WHERE m.visibility = 'public' AND m.hidden_at IS NULL AND m.filter_action IS NULL AND r.hidden_at IS NULL AND r.filter_action IS NULL AND r.message_id = \\$1").
+		WithArgs("m1").
+		WillReturnRows(sqlmock.NewRows(documentColumns).
+			AddRow("r1", "m1", "bob", "the pool leaks", "{databases}", now))
+
+	require.NoError(t, bus.Publish(ctx, db, events.MessageCreated, "m1", models.Message{ID: "m1"}))
+	require.NoError(t, bus.Publish(ctx, db, events.ReplyCreated, "m1", models.Reply{ID: "r1", MessageID: "m1"}))
+	require.NoError(t, bus.Publish(ctx, db, events.MessageUpdated, "m1", models.Message{ID: "m1"}))
+
+	assert.Eventually(t, func() bool {
+		page, err := index.Search(ctx, Query{Text: "pool", Tag: "databases", Limit: 10})
+		return err == nil && len(page.Results) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, bus.Publish(ctx, db, events.ReplyDeleted, "m1", models.Reply{ID: "r1", MessageID: "m1"}))
+
+	assert.Eventually(t, func() bool {
+		page, err := index.Search(ctx, Query{Text: "pool", Limit: 10})
+		return err == nil && len(page.Results) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -75,6 +76,36 @@ func UploadFile(ctx context.Context, client *minio.Client, bucketName, objectNam
 	return url, nil
 }
 
+// ErrNotFound is returned by OpenFile when the object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// OpenFile opens an object for reading. The caller closes it.
+func OpenFile(ctx context.Context, client *minio.Client, bucketName, objectName string) (*minio.Object, minio.ObjectInfo, error) {
+	ctx, span := tracer.Start(ctx, "OpenFile")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("object.name", objectName))
+
+	object, err := client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		span.RecordError(err)
+		return nil, minio.ObjectInfo{}, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	// GetObject is lazy; Stat finds out whether the object exists
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, minio.ObjectInfo{}, ErrNotFound
+		}
+		span.RecordError(err)
+		return nil, minio.ObjectInfo{}, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return object, info, nil
+}
+
 // GetContentType returns the MIME type based on file extension
 func GetContentType(filename string) string {
 	ext := filepath.Ext(filename)
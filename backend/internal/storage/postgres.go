@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	_ "github.com/lib/pq"
 	"go.opentelemetry.io/otel"
@@ -45,20 +47,37 @@ func runMigrations(ctx context.Context, db *sql.DB) error {
 	ctx, span := tracer.Start(ctx, "runMigrations")
 	defer span.End()
 
-	// Read migration file
-	migrationPath := filepath.Join("migrations", "001_create_schema.sql")
-	migrationSQL, err := os.ReadFile(migrationPath)
+	// Read migration files; every migration is idempotent, so they are all
+	// applied in filename order on each startup
+	entries, err := os.ReadDir("migrations")
 	if err != nil {
 		span.RecordError(err)
-		return fmt.Errorf("failed to read migration file: %w", err)
+		return fmt.Errorf("failed to read migration files: %w", err)
 	}
 
-	// Execute migration
-	if _, err := db.ExecContext(ctx, string(migrationSQL)); err != nil {
-		span.RecordError(err)
-		return fmt.Errorf("failed to execute migration: %w", err)
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+
+	for _, name := range files {
+		migrationPath := filepath.Join("migrations", name)
+		migrationSQL, err := os.ReadFile(migrationPath)
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("failed to read migration file: %w", err)
+		}
+
+		// Execute migration
+		if _, err := db.ExecContext(ctx, string(migrationSQL)); err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("failed to execute migration %s: %w", name, err)
+		}
 	}
 
-	span.SetAttributes(attribute.String("migration.file", migrationPath))
+	span.SetAttributes(attribute.Int("migration.count", len(files)))
 	return nil
 }
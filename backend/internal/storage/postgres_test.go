@@ -104,3 +104,36 @@ func TestRunMigrations_ExecutionError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to execute migration")
 }
+
+func TestRunMigrations_AppliesFilesInOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	tmpDir := t.TempDir()
+	migrationDir := tmpDir + "/migrations"
+	err = os.MkdirAll(migrationDir, 0755)
+	require.NoError(t, err)
+
+	// Written out of order; must be applied by filename
+	require.NoError(t, os.WriteFile(migrationDir+"/002_second.sql", []byte("CREATE TABLE second (id INT);"), 0644))
+	require.NoError(t, os.WriteFile(migrationDir+"/001_first.sql", []byte("CREATE TABLE first (id INT);"), 0644))
+	require.NoError(t, os.WriteFile(migrationDir+"/README.md", []byte("not a migration"), 0644))
+
+	oldDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer os.Chdir(oldDir)
+
+	err = os.Chdir(tmpDir)
+	require.NoError(t, err)
+
+	mock.ExpectExec("CREATE TABLE first").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE second").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	err = runMigrations(ctx, db)
+	require.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
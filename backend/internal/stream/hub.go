@@ -0,0 +1,219 @@
+// Package stream delivers live message and reply events to connected
+// clients. A Hub in every replica subscribes to the event bus, keeps a
+// bounded replay buffer and fans events out to its subscribers. Event IDs
+// come from the bus and are global, so a client can resume on whichever
+// replica it reconnects to.
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"why-backend/internal/events"
+)
+
+const (
+	defaultBufferSize       = 1000
+	defaultSubscriberBuffer = 64
+)
+
+// Options tunes a Hub. Zero values select the defaults.
+type Options struct {
+	// BufferSize is how many recent events are kept for Last-Event-ID replay
+	BufferSize int
+	// SubscriberBuffer is how many undelivered events a subscriber may have
+	// before it is disconnected
+	SubscriberBuffer int
+}
+
+// Hub fans events from the bus out to subscribers
+type Hub struct {
+	bus  events.Bus
+	opts Options
+
+	mu     sync.Mutex
+	buffer []events.Event // arrival order
+	seen   map[int64]struct{}
+	// truncated is set once events have been dropped from the buffer
+	truncated bool
+	subs      map[*Subscription]struct{}
+	closed    bool
+}
+
+// Subscription receives the events of one thread, or of every thread when
+// MessageID is empty. C is closed when the hub stops or when the subscriber
+// falls too far behind; the client is expected to reconnect with the ID of
+// the last event it received.
+type Subscription struct {
+	MessageID string
+	C         <-chan events.Event
+
+	ch  chan events.Event
+	hub *Hub
+}
+
+func NewHub(bus events.Bus, opts Options) *Hub {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	if opts.SubscriberBuffer <= 0 {
+		opts.SubscriberBuffer = defaultSubscriberBuffer
+	}
+
+	return &Hub{
+		bus:  bus,
+		opts: opts,
+		seen: map[int64]struct{}{},
+		subs: map[*Subscription]struct{}{},
+	}
+}
+
+// Start fills the replay buffer with the most recent events and follows the
+// bus until ctx is cancelled, at which point every subscription is closed.
+func (h *Hub) Start(ctx context.Context) error {
+	// Subscribe before loading so nothing falls in between; dispatch drops
+	// the overlap
+	unsubscribe := h.bus.Subscribe(h.dispatch)
+
+	recent, err := h.bus.Recent(ctx, h.opts.BufferSize)
+	if err != nil {
+		unsubscribe()
+		return err
+	}
+	h.load(recent)
+
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+		h.close()
+	}()
+	return nil
+}
+
+// Subscribe registers a subscriber for messageID ("" for all threads) and
+// returns the buffered events newer than lastEventID (0 for none). complete
+// is false when events after lastEventID have already left the buffer, in
+// which case the client must refetch rather than rely on the replay.
+func (h *Hub) Subscribe(messageID string, lastEventID int64) (sub *Subscription, replay []events.Event, complete bool) {
+	ch := make(chan events.Event, h.opts.SubscriberBuffer)
+	sub = &Subscription{MessageID: messageID, C: ch, ch: ch, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(ch)
+		return sub, nil, true
+	}
+	h.subs[sub] = struct{}{}
+
+	if lastEventID <= 0 {
+		return sub, nil, true
+	}
+
+	complete = true
+	if h.truncated && len(h.buffer) > 0 && lastEventID < h.oldestID()-1 {
+		complete = false
+	}
+	for _, event := range h.buffer {
+		if event.ID > lastEventID && sub.matches(event) {
+			replay = append(replay, event)
+		}
+	}
+	return sub, replay, complete
+}
+
+// Close unregisters the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
+
+func (s *Subscription) matches(event events.Event) bool {
+	return s.MessageID == "" || s.MessageID == event.MessageID
+}
+
+// load seeds the buffer so clients can resume across a restart of this replica
+func (h *Hub) load(recent []events.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, event := range recent {
+		if _, dup := h.seen[event.ID]; !dup {
+			h.append(event)
+		}
+	}
+	if len(recent) == h.opts.BufferSize {
+		h.truncated = true
+	}
+}
+
+// dispatch is the bus handler. Signals are not part of the stream.
+func (h *Hub) dispatch(event events.Event) {
+	if event.Ephemeral() {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, dup := h.seen[event.ID]; dup {
+		return
+	}
+	h.append(event)
+
+	for sub := range h.subs {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// Too slow: disconnect rather than block everyone else
+			h.remove(sub)
+		}
+	}
+}
+
+// append must be called with h.mu held
+func (h *Hub) append(event events.Event) {
+	h.buffer = append(h.buffer, event)
+	h.seen[event.ID] = struct{}{}
+
+	if overflow := len(h.buffer) - h.opts.BufferSize; overflow > 0 {
+		for _, evicted := range h.buffer[:overflow] {
+			delete(h.seen, evicted.ID)
+		}
+		h.buffer = append(h.buffer[:0:0], h.buffer[overflow:]...)
+		h.truncated = true
+	}
+}
+
+func (h *Hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		h.remove(sub)
+	}
+}
+
+// remove must be called with h.mu held
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// oldestID must be called with h.mu held
+func (h *Hub) oldestID() int64 {
+	oldest := h.buffer[0].ID
+	for _, event := range h.buffer[1:] {
+		oldest = min(oldest, event.ID)
+	}
+	return oldest
+}
@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/events"
+)
+
+func publish(t *testing.T, bus *events.MemoryBus, messageID string) {
+	require.NoError(t, bus.Publish(context.Background(), nil, events.MessageCreated, messageID, map[string]string{}))
+}
+
+func startHub(t *testing.T, bus events.Bus, opts Options) (*Hub, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	hub := NewHub(bus, opts)
+	require.NoError(t, hub.Start(ctx))
+	return hub, cancel
+}
+
+func TestHub_StartLoadsRecentAndFollowsBus(t *testing.T) {
+	bus := events.NewMemoryBus()
+	publish(t, bus, "a")
+	publish(t, bus, "b")
+
+	hub, cancel := startHub(t, bus, Options{})
+
+	sub, replay, complete := hub.Subscribe("a", 0)
+	assert.True(t, complete)
+	assert.Empty(t, replay)
+
+	publish(t, bus, "b")
+	publish(t, bus, "a")
+
+	event := <-sub.C
+	assert.Equal(t, int64(4), event.ID)
+	assert.Equal(t, events.MessageCreated, event.Type)
+
+	cancel()
+	for range sub.C {
+	}
+}
+
+func TestHub_Subscribe_Replay(t *testing.T) {
+	bus := events.NewMemoryBus()
+	for _, messageID := range []string{"a", "b", "a", "a"} {
+		publish(t, bus, messageID)
+	}
+	hub, _ := startHub(t, bus, Options{BufferSize: 3})
+
+	// Event 1 was evicted, but the client saw it
+	_, replay, complete := hub.Subscribe("a", 1)
+	assert.True(t, complete)
+	require.Len(t, replay, 2)
+	assert.Equal(t, int64(3), replay[0].ID)
+	assert.Equal(t, int64(4), replay[1].ID)
+
+	publish(t, bus, "b")
+
+	// Events 2 and 3 were evicted and never seen
+	_, replay, complete = hub.Subscribe("", 1)
+	assert.False(t, complete)
+	assert.Len(t, replay, 3)
+}
+
+func TestHub_Dispatch_IgnoresDuplicates(t *testing.T) {
+	hub := NewHub(events.NewMemoryBus(), Options{})
+	sub, _, _ := hub.Subscribe("", 0)
+
+	event := events.Event{ID: 7, Type: events.ReplyCreated, MessageID: "a"}
+	hub.load([]events.Event{event})
+	hub.dispatch(event)
+
+	assert.Len(t, hub.buffer, 1)
+	assert.Empty(t, sub.C)
+}
+
+func TestHub_Dispatch_DropsSlowSubscriber(t *testing.T) {
+	bus := events.NewMemoryBus()
+	hub, _ := startHub(t, bus, Options{SubscriberBuffer: 1})
+	slow, _, _ := hub.Subscribe("", 0)
+	other, _, _ := hub.Subscribe("b", 0)
+
+	publish(t, bus, "a")
+	publish(t, bus, "a")
+
+	event, ok := <-slow.C
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), event.ID)
+	_, ok = <-slow.C
+	assert.False(t, ok, "slow subscriber should be disconnected")
+
+	// Subscribers of other threads are unaffected
+	assert.Len(t, hub.subs, 1)
+	other.Close()
+	assert.Empty(t, hub.subs)
+}
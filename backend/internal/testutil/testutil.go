@@ -1,6 +1,7 @@
 package testutil
 
 import (
+	"bytes"
 	"database/sql"
 	"testing"
 
@@ -8,7 +9,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/api/openapi"
 	"why-backend/internal/config"
+	"why-backend/internal/content"
 )
 
 // SetupTestDB creates a mock database for testing
@@ -39,6 +43,7 @@ func GetTestConfig() *config.Config {
 			BucketName:      "test-bucket",
 			UseSSL:          false,
 		},
+		ContentLimits: content.DefaultLimits,
 	}
 }
 
@@ -47,3 +52,44 @@ func SetupTestRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	return gin.New()
 }
+
+// Serve runs handler on c as the router would, behind the error middleware:
+// a failed request is answered with its problem. The test fails when the
+// response does not match the OpenAPI document. Request paths may leave out
+// the /api/v1 prefix; those of no documented route are not checked.
+func Serve(t testing.TB, c *gin.Context, handler gin.HandlerFunc) {
+	t.Helper()
+	w := &recorder{ResponseWriter: c.Writer}
+	c.Writer = w
+	handler(c)
+	apierror.Respond(c)
+	c.Writer = w.ResponseWriter
+
+	doc := openapi.Spec()
+	op := doc.Find(c.Request.Method, c.Request.URL.Path)
+	if op == nil {
+		op = doc.Find(c.Request.Method, "/api/v1"+c.Request.URL.Path)
+	}
+	if op == nil {
+		return
+	}
+	if err := doc.ValidateResponse(op, w.Status(), w.Header(), w.body.Bytes()); err != nil {
+		t.Errorf("%s %s: response does not match the OpenAPI document: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+}
+
+// recorder keeps a copy of the response body
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,76 @@
+// Package timeline builds home timelines: the messages of the users someone
+// follows, newest first.
+//
+// Timelines are merged when read. Each page costs one short index scan per
+// followee, which stays fast for follow lists in the thousands. Past that, a
+// Reader over per-user timeline rows written when a message is created
+// (fan-out on write, e.g. from a job per message.created event) can replace
+// FanOutOnRead without changing callers.
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Entry is a message's position on a timeline
+type Entry struct {
+	MessageID string
+	CreatedAt time.Time
+}
+
+// Reader returns up to limit entries of userID's home timeline, newest
+// first, starting after before when it is set
+type Reader interface {
+	Home(ctx context.Context, userID string, before *Entry, limit int) ([]Entry, error)
+}
+
+// FanOutOnRead merges the newest messages of each followee at read time
+type FanOutOnRead struct {
+	db *sql.DB
+}
+
+func NewFanOutOnRead(db *sql.DB) *FanOutOnRead {
+	return &FanOutOnRead{db: db}
+}
+
+func (r *FanOutOnRead) Home(ctx context.Context, userID string, before *Entry, limit int) ([]Entry, error) {
+	// No followee can contribute more than a page, so each lateral scan stops
+	// after limit rows of idx_messages_user_id_created_at
+	args := []any{userID, limit}
+	position := ``
+	if before != nil {
+		position = ` AND (m.created_at, m.id) < ($3, $4)`
+		args = append(args, before.CreatedAt, before.MessageID)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT m.id, m.created_at
+		 FROM follows f
+		 CROSS JOIN LATERAL (
+			SELECT m.id, m.created_at FROM messages m
+			WHERE m.user_id = f.followee_id`+position+`
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT $2
+		 ) m
+		 WHERE f.follower_id = $1
+		 ORDER BY m.created_at DESC, m.id DESC
+		 LIMIT $2`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.MessageID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,50 @@
+package timeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/testutil"
+)
+
+func TestFanOutOnRead_Home(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	reader := NewFanOutOnRead(db)
+	now := time.Now()
+
+	mock.ExpectQuery("FROM follows f CROSS JOIN LATERAL \\( SELECT m.id, m.created_at FROM messages m "+
+		"WHERE m.user_id = f.followee_id ORDER BY m.created_at DESC, m.id DESC LIMIT \\$2 \\) m "+
+		"WHERE f.follower_id = \\$1 ORDER BY m.created_at DESC, m.id DESC LIMIT \\$2").
+		WithArgs("user-1", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).
+			AddRow("msg-2", now).
+			AddRow("msg-1", now.Add(-time.Minute)))
+
+	entries, err := reader.Home(context.Background(), "user-1", nil, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{{"msg-2", now}, {"msg-1", now.Add(-time.Minute)}}, entries)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFanOutOnRead_Home_Before(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	reader := NewFanOutOnRead(db)
+	before := Entry{MessageID: "msg-2", CreatedAt: time.Now()}
+
+	mock.ExpectQuery("WHERE m.user_id = f.followee_id AND \\(m.created_at, m.id\\) < \\(\\$3, \\$4\\) ORDER BY").
+		WithArgs("user-1", 3, before.CreatedAt, "msg-2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+
+	entries, err := reader.Home(context.Background(), "user-1", &before, 3)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,470 @@
+// Package webhooks delivers events to the HTTP endpoints users register.
+//
+// Dispatchers read the events table after a cursor kept in the database and
+// record a delivery row per matching webhook for each event, moving the
+// cursor in the same transaction, so an event is recorded once whichever
+// replicas are running. They then claim due deliveries with FOR UPDATE SKIP
+// LOCKED, POST them and record the outcome. Failed deliveries are retried
+// with exponential backoff; a webhook that keeps failing is disabled.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"why-backend/internal/events"
+)
+
+// EventTypes lists the event types a webhook can subscribe to
+var EventTypes = []string{
+	events.MessageCreated,
+	events.MessageUpdated,
+	events.ReplyCreated,
+}
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 50
+	defaultConcurrency  = 8
+	defaultTimeout      = 10 * time.Second
+	defaultMaxAttempts  = 8
+	defaultDisableAfter = 20
+
+	// A claimed delivery is not claimed again before its attempt has had time
+	// to finish, even if this replica dies mid-attempt
+	claimLease = time.Minute
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+
+	maxErrorLength  = 500
+	maxResponseRead = 4096
+)
+
+var errPrivateAddress = errors.New("webhook URL resolves to a private address")
+
+// Options tunes a Dispatcher. Zero values select the defaults.
+type Options struct {
+	PollInterval time.Duration
+	// BatchSize is how many events are recorded, and due deliveries claimed,
+	// at a time
+	BatchSize int
+	// Concurrency is how many deliveries are attempted in parallel
+	Concurrency int
+	// Timeout bounds each attempt
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it fails
+	MaxAttempts int
+	// DisableAfter is how many consecutive failed attempts disable a webhook
+	DisableAfter int
+	// AllowPrivateNetworks permits endpoints on loopback and private
+	// addresses, which are otherwise refused to keep webhooks from probing
+	// the internal network
+	AllowPrivateNetworks bool
+}
+
+type Dispatcher struct {
+	db     *sql.DB
+	bus    events.Bus
+	opts   Options
+	client *http.Client
+	wake   chan struct{}
+}
+
+// delivery is a claimed delivery with what is needed to attempt it
+type delivery struct {
+	ID         string
+	WebhookID  string
+	EventID    int64
+	EventType  string
+	Payload    json.RawMessage
+	Attempts   int
+	OccurredAt time.Time
+	URL        string
+	Secret     string
+}
+
+func NewDispatcher(db *sql.DB, bus events.Bus, opts Options) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.DisableAfter <= 0 {
+		opts.DisableAfter = defaultDisableAfter
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivateNetworks {
+		// Checked on the resolved address, so DNS cannot smuggle one in
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	return &Dispatcher{
+		db:   db,
+		bus:  bus,
+		opts: opts,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			// A redirect is a failed delivery, not an invitation to post elsewhere
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Start records deliveries for new events and attempts due deliveries until
+// ctx is cancelled
+func (d *Dispatcher) Start(ctx context.Context) {
+	// Bus events only wake the recorder early: it reads the events table, so
+	// a missed wake-up delays deliveries by at most PollInterval
+	unsubscribe := d.bus.Subscribe(func(event events.Event) {
+		if event.Ephemeral() {
+			return
+		}
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	})
+
+	go func() {
+		defer unsubscribe()
+		ticker := time.NewTicker(d.opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-d.wake:
+			case <-ticker.C:
+			}
+			if err := d.recordDeliveries(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to record webhook deliveries", "error", err)
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(d.opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := d.deliverDue(ctx); err != nil && ctx.Err() == nil {
+					slog.ErrorContext(ctx, "Failed to deliver webhooks", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// recordDeliveries records deliveries for the events after the cursor until
+// it has caught up or another replica holds the cursor
+func (d *Dispatcher) recordDeliveries(ctx context.Context) error {
+	for {
+		recorded, err := d.recordBatch(ctx)
+		if err != nil || recorded < d.opts.BatchSize {
+			return err
+		}
+	}
+}
+
+// recordBatch records a pending delivery of each of the next events for every
+// active webhook subscribed to its type, and moves the cursor past them, in
+// one transaction. It returns how many events it read. The outbox relay
+// publishes one batch at a time, so event IDs commit in order and none is
+// passed over; events pruned from the log before they are read are lost.
+func (d *Dispatcher) recordBatch(ctx context.Context) (int, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var cursor int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT last_event_id FROM webhook_cursor FOR UPDATE SKIP LOCKED`,
+	).Scan(&cursor)
+	if err == sql.ErrNoRows {
+		// Another replica is recording
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var read int
+	var last int64
+	err = tx.QueryRowContext(ctx,
+		`WITH batch AS (
+			SELECT id, type, payload, created_at FROM events
+			WHERE id > $1
+			ORDER BY id
+			LIMIT $2
+		 ), recorded AS (
+			INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, created_at)
+			SELECT w.id, e.id, e.type, e.payload, e.created_at
+			FROM batch e JOIN webhooks w ON e.type = ANY(w.event_types)
+			WHERE w.active
+			ON CONFLICT (webhook_id, event_id) DO NOTHING
+		 )
+		 SELECT COUNT(*), COALESCE(MAX(id), $1) FROM batch`,
+		cursor, d.opts.BatchSize,
+	).Scan(&read, &last)
+	if err != nil || read == 0 {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE webhook_cursor SET last_event_id = $1, updated_at = NOW()`,
+		last,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return read, tx.Commit()
+}
+
+// deliverDue claims a batch of due deliveries and attempts them
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	rows, err := d.db.QueryContext(ctx,
+		`UPDATE webhook_deliveries d SET next_attempt_at = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+		 FROM webhooks w
+		 WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT dd.id FROM webhook_deliveries dd
+			JOIN webhooks ww ON ww.id = dd.webhook_id
+			WHERE dd.status = 'pending' AND dd.next_attempt_at <= NOW() AND ww.active
+			ORDER BY dd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF dd SKIP LOCKED
+		 )
+		 RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, d.created_at, w.url, w.secret`,
+		d.opts.BatchSize, int64(claimLease.Seconds()),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var due []delivery
+	for rows.Next() {
+		var dl delivery
+		var payload []byte
+		if err := rows.Scan(&dl.ID, &dl.WebhookID, &dl.EventID, &dl.EventType, &payload, &dl.Attempts,
+			&dl.OccurredAt, &dl.URL, &dl.Secret); err != nil {
+			return err
+		}
+		dl.Payload = payload
+		due = append(due, dl)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, d.opts.Concurrency)
+	for _, dl := range due {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(dl delivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			code, elapsed, attemptErr := d.attempt(ctx, dl)
+			// The outcome is recorded even if shutdown interrupted the attempt
+			if err := d.record(context.WithoutCancel(ctx), dl, code, attemptErr, elapsed); err != nil {
+				slog.ErrorContext(ctx, "Failed to record webhook attempt", "error", err, "delivery_id", dl.ID)
+			}
+		}(dl)
+	}
+	wg.Wait()
+	return nil
+}
+
+// attempt POSTs a delivery and returns the response code (0 without a
+// response) and an error unless the endpoint answered 2xx
+func (d *Dispatcher) attempt(ctx context.Context, dl delivery) (int, time.Duration, error) {
+	body, err := json.Marshal(struct {
+		ID         int64           `json:"id"`
+		Type       string          `json:"type"`
+		OccurredAt time.Time       `json:"occurred_at"`
+		Data       json.RawMessage `json:"data"`
+	}{dl.EventID, dl.EventType, dl.OccurredAt, dl.Payload})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "why-webhooks/1.0")
+	req.Header.Set(HeaderEvent, dl.EventType)
+	req.Header.Set(HeaderDelivery, dl.ID)
+	req.Header.Set(HeaderTimestamp, fmt.Sprint(timestamp))
+	req.Header.Set(HeaderSignature, Sign(dl.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		return 0, elapsed, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseRead))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, elapsed, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, elapsed, nil
+}
+
+// record logs an attempt and schedules the delivery's retry, or finishes it.
+// Every failed attempt counts toward disabling the webhook; a success resets
+// the count.
+func (d *Dispatcher) record(ctx context.Context, dl delivery, code int, attemptErr error, elapsed time.Duration) error {
+	responseCode := sql.NullInt64{Int64: int64(code), Valid: code != 0}
+	var errorText sql.NullString
+	if attemptErr != nil {
+		errorText = sql.NullString{String: truncate(attemptErr.Error(), maxErrorLength), Valid: true}
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO webhook_delivery_attempts (delivery_id, response_code, error, duration_ms)
+		 VALUES ($1, $2, $3, $4)`,
+		dl.ID, responseCode, errorText, elapsed.Milliseconds(),
+	)
+	if err != nil {
+		return err
+	}
+
+	if attemptErr == nil {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE webhook_deliveries
+			 SET status = 'succeeded', attempts = attempts + 1, last_response_code = $2, last_error = NULL, updated_at = NOW()
+			 WHERE id = $1`,
+			dl.ID, responseCode,
+		)
+		if err == nil {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE webhooks SET failure_count = 0 WHERE id = $1 AND failure_count <> 0`,
+				dl.WebhookID,
+			)
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	status := StatusPending
+	if dl.Attempts+1 >= d.opts.MaxAttempts {
+		status = StatusFailed
+	}
+	retryIn := backoff(dl.Attempts + 1)
+	retryIn += rand.N(retryIn / 10) // spread retries of deliveries that failed together
+	_, err = tx.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+		 SET status = $2, attempts = attempts + 1, next_attempt_at = NOW() + $3 * INTERVAL '1 second',
+		     last_response_code = $4, last_error = $5, updated_at = NOW()
+		 WHERE id = $1`,
+		dl.ID, status, int64(retryIn.Seconds()), responseCode, errorText,
+	)
+	if err != nil {
+		return err
+	}
+
+	var active bool
+	err = tx.QueryRowContext(ctx,
+		`UPDATE webhooks
+		 SET failure_count = failure_count + 1,
+		     active = active AND failure_count + 1 < $2,
+		     disabled_at = CASE WHEN active AND failure_count + 1 >= $2 THEN NOW() ELSE disabled_at END,
+		     updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING active`,
+		dl.WebhookID, d.opts.DisableAfter,
+	).Scan(&active)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if !active {
+		slog.WarnContext(ctx, "Webhook disabled after repeated failures", "webhook_id", dl.WebhookID)
+	}
+	return nil
+}
+
+// backoff is the delay before retrying after the given number of attempts:
+// 30s, 1m, 2m, ... capped at 6h
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
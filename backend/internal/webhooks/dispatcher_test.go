@@ -0,0 +1,241 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/events"
+	"why-backend/internal/testutil"
+)
+
+var claimColumns = []string{"id", "webhook_id", "event_id", "event_type", "payload", "attempts", "created_at", "url", "secret"}
+
+func expectClaim(mock sqlmock.Sqlmock, url string, attempts int) {
+	mock.ExpectQuery("UPDATE webhook_deliveries d SET next_attempt_at .+ FOR UPDATE OF dd SKIP LOCKED").
+		WithArgs(defaultBatchSize, int64(60)).
+		WillReturnRows(sqlmock.NewRows(claimColumns).
+			AddRow("delivery-1", "webhook-1", 42, events.ReplyCreated, []byte(`{"id":"reply-1"}`), attempts,
+				time.Unix(1700000000, 0), url, "whsec_test"))
+}
+
+func expectRecordBatch(mock sqlmock.Sqlmock, cursor int64, batchSize, read int, last int64) {
+	mock.ExpectQuery("WITH batch AS \\( SELECT id, type, payload, created_at FROM events WHERE id > \\$1 ORDER BY id LIMIT \\$2 \\), "+
+		"recorded AS \\( INSERT INTO webhook_deliveries .+ WHERE w.active ON CONFLICT \\(webhook_id, event_id\\) DO NOTHING \\)").
+		WithArgs(cursor, batchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(read, last))
+}
+
+func TestDispatcher_RecordDeliveries(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	// A full batch is followed by another, until the cursor catches up
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT last_event_id FROM webhook_cursor FOR UPDATE SKIP LOCKED").
+		WillReturnRows(sqlmock.NewRows([]string{"last_event_id"}).AddRow(40))
+	expectRecordBatch(mock, 40, 2, 2, 42)
+	mock.ExpectExec("UPDATE webhook_cursor SET last_event_id = \\$1").
+		WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT last_event_id FROM webhook_cursor").
+		WillReturnRows(sqlmock.NewRows([]string{"last_event_id"}).AddRow(42))
+	expectRecordBatch(mock, 42, 2, 1, 45)
+	mock.ExpectExec("UPDATE webhook_cursor SET last_event_id = \\$1").
+		WithArgs(int64(45)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	d := NewDispatcher(db, events.NewMemoryBus(), Options{BatchSize: 2})
+	require.NoError(t, d.recordDeliveries(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcher_RecordDeliveries_CaughtUp(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT last_event_id FROM webhook_cursor").
+		WillReturnRows(sqlmock.NewRows([]string{"last_event_id"}).AddRow(42))
+	expectRecordBatch(mock, 42, defaultBatchSize, 0, 42)
+	mock.ExpectRollback()
+
+	d := NewDispatcher(db, events.NewMemoryBus(), Options{})
+	require.NoError(t, d.recordDeliveries(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcher_RecordDeliveries_CursorHeldElsewhere(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	// Another replica holds the cursor; its events are left to it
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT last_event_id FROM webhook_cursor").
+		WillReturnRows(sqlmock.NewRows([]string{"last_event_id"}))
+	mock.ExpectRollback()
+
+	d := NewDispatcher(db, events.NewMemoryBus(), Options{})
+	require.NoError(t, d.recordDeliveries(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcher_StartRecordsPublishedEvents(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	// However many events are published at once, the recorder wakes and
+	// reads them all from the events table
+	bus := events.NewMemoryBus()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT last_event_id FROM webhook_cursor").
+		WillReturnRows(sqlmock.NewRows([]string{"last_event_id"}).AddRow(0))
+	expectRecordBatch(mock, 0, defaultBatchSize, 3, 3)
+	mock.ExpectExec("UPDATE webhook_cursor SET last_event_id = \\$1").
+		WithArgs(int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := NewDispatcher(db, bus, Options{PollInterval: time.Hour})
+	d.Start(ctx)
+
+	for range 3 {
+		require.NoError(t, bus.Publish(ctx, nil, events.ReplyCreated, "msg-1", map[string]string{}))
+	}
+
+	assert.Eventually(t, func() bool {
+		return mock.ExpectationsWereMet() == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDispatcher_DeliversSignedRequest(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	var verified atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := Verify("whsec_test", r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Minute, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, events.ReplyCreated, r.Header.Get(HeaderEvent))
+		assert.Equal(t, "delivery-1", r.Header.Get(HeaderDelivery))
+
+		var payload struct {
+			ID   int64           `json:"id"`
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, int64(42), payload.ID)
+		assert.JSONEq(t, `{"id":"reply-1"}`, string(payload.Data))
+
+		verified.Store(true)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	expectClaim(mock, server.URL, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO webhook_delivery_attempts").
+		WithArgs("delivery-1", int64(http.StatusNoContent), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = 'succeeded'").
+		WithArgs("delivery-1", int64(http.StatusNoContent)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE webhooks SET failure_count = 0").
+		WithArgs("webhook-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	d := NewDispatcher(db, events.NewMemoryBus(), Options{AllowPrivateNetworks: true})
+	require.NoError(t, d.deliverDue(context.Background()))
+	assert.True(t, verified.Load())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcher_FailureSchedulesRetry(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	expectClaim(mock, server.URL, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO webhook_delivery_attempts").
+		WithArgs("delivery-1", int64(http.StatusServiceUnavailable), "endpoint responded 503 Service Unavailable", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = \\$2").
+		WithArgs("delivery-1", StatusPending, sqlmock.AnyArg(), int64(http.StatusServiceUnavailable), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE webhooks SET failure_count = failure_count \\+ 1.+RETURNING active").
+		WithArgs("webhook-1", defaultDisableAfter).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(true))
+	mock.ExpectCommit()
+
+	d := NewDispatcher(db, events.NewMemoryBus(), Options{AllowPrivateNetworks: true})
+	require.NoError(t, d.deliverDue(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcher_LastAttemptFailsDelivery(t *testing.T) {
+	db, mock := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://example.com", http.StatusFound)
+	}))
+	defer server.Close()
+
+	expectClaim(mock, server.URL, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO webhook_delivery_attempts").
+		WithArgs("delivery-1", int64(http.StatusFound), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = \\$2").
+		WithArgs("delivery-1", StatusFailed, sqlmock.AnyArg(), int64(http.StatusFound), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE webhooks SET failure_count = failure_count \\+ 1").
+		WithArgs("webhook-1", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(false))
+	mock.ExpectCommit()
+
+	d := NewDispatcher(db, events.NewMemoryBus(), Options{AllowPrivateNetworks: true, MaxAttempts: 3, DisableAfter: 2})
+	require.NoError(t, d.deliverDue(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcher_RefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("private endpoint was called")
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(nil, events.NewMemoryBus(), Options{})
+	code, _, err := d.attempt(context.Background(), delivery{ID: "delivery-1", URL: server.URL, Payload: []byte(`{}`)})
+	assert.Zero(t, code)
+	assert.ErrorIs(t, err, errPrivateAddress)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, backoff(1))
+	assert.Equal(t, time.Minute, backoff(2))
+	assert.Equal(t, 4*time.Minute, backoff(4))
+	assert.Equal(t, 6*time.Hour, backoff(20))
+}
@@ -0,0 +1,55 @@
+package webhooks
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	require.NoError(t, err)
+	b, err := NewSecret()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(a, "whsec_"))
+	assert.Len(t, a, len("whsec_")+64)
+	assert.NotEqual(t, a, b)
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("secret", now.Unix(), body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+		want      error
+	}{
+		{"valid", "secret", timestamp, signature, body, now, nil},
+		{"within tolerance", "secret", timestamp, signature, body, now.Add(4 * time.Minute), nil},
+		{"wrong secret", "other", timestamp, signature, body, now, ErrInvalidSignature},
+		{"tampered body", "secret", timestamp, signature, []byte(`{"id":2}`), now, ErrInvalidSignature},
+		{"timestamp not signed", "secret", strconv.FormatInt(now.Unix()+1, 10), signature, body, now, ErrInvalidSignature},
+		{"missing prefix", "secret", timestamp, strings.TrimPrefix(signature, "sha256="), body, now, ErrInvalidSignature},
+		{"malformed timestamp", "secret", "yesterday", signature, body, now, ErrInvalidSignature},
+		{"replayed", "secret", timestamp, signature, body, now.Add(10 * time.Minute), ErrExpiredTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, 5*time.Minute, tt.now)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
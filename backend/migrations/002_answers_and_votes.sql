@@ -0,0 +1,20 @@
+-- Accepted answers: the message author can mark one reply as accepted
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS accepted_reply_id UUID REFERENCES replies(id) ON DELETE SET NULL;
+
+-- Denormalized vote total, kept in sync by the vote endpoint
+ALTER TABLE replies ADD COLUMN IF NOT EXISTS score INTEGER NOT NULL DEFAULT 0;
+
+-- Create reply votes table
+CREATE TABLE IF NOT EXISTS reply_votes (
+    reply_id UUID NOT NULL REFERENCES replies(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (reply_id, user_id)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_messages_unanswered ON messages(created_at DESC) WHERE accepted_reply_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_replies_message_id_score ON replies(message_id, score DESC);
+CREATE INDEX IF NOT EXISTS idx_reply_votes_user_id ON reply_votes(user_id);
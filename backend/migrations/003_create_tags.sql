@@ -0,0 +1,17 @@
+-- Create tags table (names are stored normalized: lowercase, without '#')
+CREATE TABLE IF NOT EXISTS tags (
+    name TEXT PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create message tags join table
+CREATE TABLE IF NOT EXISTS message_tags (
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL REFERENCES tags(name) ON DELETE CASCADE,
+    PRIMARY KEY (message_id, tag)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_message_tags_tag ON message_tags(tag);
+CREATE INDEX IF NOT EXISTS idx_tags_name_prefix ON tags(name text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_messages_created_at_id ON messages(created_at DESC, id DESC);
@@ -0,0 +1,36 @@
+-- User handles, used for @mentions (optional, unique case-insensitively)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS handle TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_handle ON users(lower(handle));
+
+-- Resolved @mention entities, stored with the content they were parsed from
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS mentions JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE replies ADD COLUMN IF NOT EXISTS mentions JSONB NOT NULL DEFAULT '[]';
+
+-- Create user blocks table (blocker_id no longer receives anything from blocked_id)
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+-- Create notifications table
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+    reply_id UUID REFERENCES replies(id) ON DELETE CASCADE,
+    read_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks(blocked_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_created_at ON notifications(user_id, created_at DESC);
+-- Editing a message must not notify the same user twice for one mention
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_message_mention ON notifications(user_id, message_id)
+    WHERE type = 'mention' AND reply_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_reply_mention ON notifications(user_id, reply_id)
+    WHERE type = 'mention' AND reply_id IS NOT NULL;
@@ -0,0 +1,15 @@
+-- Create notification preferences table (a missing row means the type is enabled)
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, type)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_unread ON notifications(user_id) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_created_at_id ON notifications(user_id, created_at DESC, id DESC);
+-- Toggling a vote must not notify the reply author repeatedly
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_reaction ON notifications(user_id, actor_id, reply_id)
+    WHERE type = 'reaction';
@@ -0,0 +1,20 @@
+-- Create stream events table: the shared log every replica tails to feed
+-- GET /api/v1/stream. The id doubles as the SSE event id, so a client can
+-- resume on any replica. Rows are pruned after a retention window.
+--
+-- 007 renames the table to events; once it has, there is nothing to create.
+DO $$
+BEGIN
+    IF to_regclass('events') IS NULL THEN
+        CREATE TABLE IF NOT EXISTS stream_events (
+            id BIGSERIAL PRIMARY KEY,
+            type TEXT NOT NULL,
+            message_id UUID NOT NULL,
+            payload JSONB NOT NULL,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+
+        -- Create indexes
+        CREATE INDEX IF NOT EXISTS idx_stream_events_created_at ON stream_events(created_at);
+    END IF;
+END $$;
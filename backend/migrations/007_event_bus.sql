@@ -0,0 +1,23 @@
+-- The stream's event log becomes the event bus log: every domain event is a
+-- row here, and NOTIFY on the "events" channel carries only the row id.
+-- Migrations run on every start, so the rename happens only once; an empty
+-- stream_events that 006 created again before it was guarded is dropped.
+DO $$
+BEGIN
+    IF to_regclass('events') IS NULL THEN
+        ALTER TABLE IF EXISTS stream_events RENAME TO events;
+        ALTER INDEX IF EXISTS idx_stream_events_created_at RENAME TO idx_events_created_at;
+    ELSE
+        DROP TABLE IF EXISTS stream_events;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS events (
+    id BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    message_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at);
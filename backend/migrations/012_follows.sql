@@ -0,0 +1,18 @@
+-- Follow graph: follower_id sees followee_id's messages on their home timeline
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+-- Denormalized counts, kept in step with follows by the follow endpoints
+ALTER TABLE users ADD COLUMN IF NOT EXISTS follower_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS following_count INTEGER NOT NULL DEFAULT 0;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_follows_follower_id_created_at ON follows(follower_id, created_at DESC, followee_id DESC);
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id_created_at ON follows(followee_id, created_at DESC, follower_id DESC);
+-- Each followee's newest messages, for merging home timelines on read
+CREATE INDEX IF NOT EXISTS idx_messages_user_id_created_at ON messages(user_id, created_at DESC, id DESC);
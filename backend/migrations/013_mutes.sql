@@ -0,0 +1,16 @@
+-- Create user mutes table (muter_id stops seeing muted_id's content)
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_user_mutes_muter_id_created_at ON user_mutes(muter_id, created_at DESC, muted_id DESC);
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocker_id_created_at ON user_blocks(blocker_id, created_at DESC, blocked_id DESC);
+
+-- Block lists are ordered by when the block was made
+UPDATE user_blocks SET created_at = NOW() WHERE created_at IS NULL;
+ALTER TABLE user_blocks ALTER COLUMN created_at SET NOT NULL;
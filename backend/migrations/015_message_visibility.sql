@@ -0,0 +1,5 @@
+-- Who can read a message and its replies: public (everyone, listed), unlisted
+-- (everyone with the link, not listed) or followers (the author's followers
+-- and mentioned users)
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'unlisted', 'followers'));
@@ -0,0 +1,3 @@
+-- A ban has no expiry; it lasts until an admin lifts it
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS ban_reason TEXT;
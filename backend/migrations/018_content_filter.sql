@@ -0,0 +1,22 @@
+-- Content the filter chain held for review or shadow-hid shows only to its
+-- author; approving a hold clears it
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS filter_action TEXT CHECK (filter_action IN ('hold', 'shadow_hide'));
+ALTER TABLE replies ADD COLUMN IF NOT EXISTS filter_action TEXT CHECK (filter_action IN ('hold', 'shadow_hide'));
+
+-- Holds are filed as reports with no reporter
+ALTER TABLE reports ALTER COLUMN reporter_id DROP NOT NULL;
+
+-- The spam classifier's training: how many spam and ham documents each token
+-- appeared in, and how many of each there were
+CREATE TABLE IF NOT EXISTS spam_tokens (
+    token TEXT PRIMARY KEY,
+    spam_count INTEGER NOT NULL DEFAULT 0,
+    ham_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS spam_documents (
+    class TEXT PRIMARY KEY CHECK (class IN ('spam', 'ham')),
+    count INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO spam_documents (class) VALUES ('spam'), ('ham') ON CONFLICT DO NOTHING;
@@ -0,0 +1,31 @@
+-- Files uploaded through the API; messages, replies and direct messages may
+-- only attach these. Conversation media may only be attached in its
+-- conversation.
+--
+-- Migrations run on every start: the table is created, and attachments from
+-- before uploads were recorded backfilled as their authors', only once.
+DO $$
+BEGIN
+    IF to_regclass('media_uploads') IS NULL THEN
+        CREATE TABLE media_uploads (
+            url TEXT PRIMARY KEY,
+            user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            conversation_id UUID REFERENCES conversations(id) ON DELETE CASCADE,
+            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+        );
+
+        INSERT INTO media_uploads (url, user_id)
+        SELECT DISTINCT ON (url) url, user_id FROM (
+            SELECT unnest(media_urls) AS url, user_id FROM messages
+            UNION ALL
+            SELECT unnest(media_urls), user_id FROM replies
+        ) attached
+        ON CONFLICT DO NOTHING;
+
+        INSERT INTO media_uploads (url, user_id, conversation_id)
+        SELECT DISTINCT ON (url) url, sender_id, conversation_id FROM (
+            SELECT unnest(media_urls) AS url, sender_id, conversation_id FROM direct_messages
+        ) attached
+        ON CONFLICT DO NOTHING;
+    END IF;
+END $$;
@@ -0,0 +1,15 @@
+-- Create webhook cursor table: the last event the dispatchers have recorded
+-- deliveries for. One row; a dispatcher locks it while it records the events
+-- after it, so deliveries are recorded once, from the database rather than
+-- from what a replica happens to hear.
+CREATE TABLE IF NOT EXISTS webhook_cursor (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_event_id BIGINT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Start after the events already logged; migrations run on every start, so
+-- an existing cursor is kept
+INSERT INTO webhook_cursor (last_event_id)
+SELECT COALESCE(MAX(id), 0) FROM events
+ON CONFLICT (id) DO NOTHING;
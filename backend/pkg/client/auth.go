@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Signup creates an account and signs in with it
+func (c *Client) Signup(ctx context.Context, req SignupRequest) (*AuthResponse, error) {
+	r, err := jsonRequest(http.MethodPost, "/signup", req)
+	if err != nil {
+		return nil, err
+	}
+	var auth AuthResponse
+	if err := c.do(ctx, r, &auth); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setToken(auth.Token)
+	c.email, c.password = req.Email, req.Password
+	return &auth, nil
+}
+
+// Login signs in. The client keeps the password to sign in again should the
+// token be rejected later.
+func (c *Client) Login(ctx context.Context, email, password string) (*AuthResponse, error) {
+	auth, err := c.login(ctx, email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setToken(auth.Token)
+	c.email, c.password = email, password
+	return auth, nil
+}
+
+func (c *Client) login(ctx context.Context, email, password string) (*AuthResponse, error) {
+	r, err := jsonRequest(http.MethodPost, "/login", map[string]string{"email": email, "password": password})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, r, "")
+	if err != nil {
+		return nil, err
+	}
+	var auth AuthResponse
+	if err := decodeAuth(resp, &auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// Refresh exchanges the token for a fresh one. Requests do so on their own
+// when the token is about to expire.
+func (c *Client) Refresh(ctx context.Context) (*AuthResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: "/refresh"}, c.token)
+	if err != nil {
+		return nil, err
+	}
+	var auth AuthResponse
+	if err := decodeAuth(resp, &auth); err != nil {
+		return nil, err
+	}
+	c.setToken(auth.Token)
+	return &auth, nil
+}
+
+// decodeAuth reads the response of a sign in or refresh
+func decodeAuth(resp *http.Response, auth *AuthResponse) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return decodeError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(auth); err != nil {
+		return fmt.Errorf("client: decode token: %w", err)
+	}
+	return nil
+}
+
+// Me returns the signed in user
+func (c *Client) Me(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.call(ctx, http.MethodGet, "/me", nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,386 @@
+// Package client is a Go client for the why API.
+//
+// A Client signs in with Signup or Login, or uses a token given with
+// WithToken, and keeps its token fresh: it is refreshed shortly before it
+// expires, and a client that signed in with a password signs in again when
+// the token is rejected. Requests are retried with exponential backoff when
+// the API is rate limiting or failing (429 and 5xx), and failed requests
+// return an *Error carrying the API's problem.
+//
+//	c, err := client.New("https://why.example.com")
+//	if _, err := c.Login(ctx, email, password); err != nil { ... }
+//	message, err := c.CreateMessage(ctx, client.CreateMessageRequest{Content: "Why is the sky blue?"})
+//	for it := c.TagMessages(ctx, "go", nil); it.Next(); { message := it.Value() ... }
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetries is how many times a failed request is retried
+	DefaultRetries = 3
+	// DefaultBackoff is the wait before the first retry; it doubles with
+	// each retry after that, up to maxBackoff
+	DefaultBackoff = 200 * time.Millisecond
+	// DefaultRefreshBefore is how long before its expiry the token is refreshed
+	DefaultRefreshBefore = time.Hour
+
+	maxBackoff = 10 * time.Second
+)
+
+// Client calls the API. It is safe for concurrent use.
+type Client struct {
+	baseURL       string
+	http          *http.Client
+	retries       int
+	backoff       time.Duration
+	refreshBefore time.Duration
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	// email and password sign in again when the token is rejected
+	email, password string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithToken authenticates requests with a token obtained elsewhere
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.setToken(token)
+	}
+}
+
+// WithRetries retries failed requests up to retries times, waiting backoff
+// before the first retry and twice as long before each one after. Zero
+// retries turns retrying off.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithRefreshBefore refreshes the token when it expires within d
+func WithRefreshBefore(d time.Duration) Option {
+	return func(c *Client) {
+		c.refreshBefore = d
+	}
+}
+
+// New returns a client of the API served at baseURL, such as
+// https://why.example.com
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("client: invalid base URL %q", baseURL)
+	}
+	c := &Client{
+		baseURL:       strings.TrimSuffix(u.String(), "/"),
+		http:          http.DefaultClient,
+		retries:       DefaultRetries,
+		backoff:       DefaultBackoff,
+		refreshBefore: DefaultRefreshBefore,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Token is the token requests are authenticated with, if any
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *Client) setToken(token string) {
+	c.token = token
+	c.expires = expiry(token)
+}
+
+// expiry reads a JWT's exp claim; the signature is the server's business
+func expiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// request is an API call. Path is relative to /api/v1.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	// auth sends the token, refreshing it first when it is about to expire
+	auth bool
+}
+
+// jsonRequest encodes in as the body of a request
+func jsonRequest(method, path string, in any) (request, error) {
+	r := request{method: method, path: path}
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return r, fmt.Errorf("client: encode request: %w", err)
+		}
+		r.body, r.contentType = body, "application/json"
+	}
+	return r, nil
+}
+
+// call sends a request with a JSON body and decodes the JSON response into out
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	r, err := jsonRequest(method, path, in)
+	if err != nil {
+		return err
+	}
+	r.query, r.auth = query, true
+	return c.do(ctx, r, out)
+}
+
+// do sends r and decodes the response into out, if not nil
+func (c *Client) do(ctx context.Context, r request, out any) error {
+	var token string
+	if r.auth {
+		var err error
+		if token, err = c.freshToken(ctx); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.send(ctx, r, token)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && token != "" {
+		// The token may have been revoked or have expired unnoticed
+		if retry, err := c.signInAgain(ctx, token); err != nil {
+			resp.Body.Close()
+			return err
+		} else if retry != "" {
+			resp.Body.Close()
+			if resp, err = c.send(ctx, r, retry); err != nil {
+				return err
+			}
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return decodeError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode %s %s response: %w", r.method, r.path, err)
+	}
+	return nil
+}
+
+// freshToken returns the token, refreshed first when it is about to expire
+func (c *Client) freshToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" || c.expires.IsZero() || time.Until(c.expires) > c.refreshBefore {
+		return c.token, nil
+	}
+
+	var auth AuthResponse
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: "/refresh"}, c.token)
+	if err == nil {
+		err = decodeAuth(resp, &auth)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		// Go on with the token: it may be good for a while yet, and if it is
+		// rejected the client signs in again
+		return c.token, nil
+	}
+	c.setToken(auth.Token)
+	return c.token, nil
+}
+
+// signInAgain signs in with the password after token was rejected, and
+// returns the new token, or "" when there is no password to sign in with
+func (c *Client) signInAgain(ctx context.Context, token string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != token {
+		// Another request signed in meanwhile
+		return c.token, nil
+	}
+	if c.password == "" {
+		return "", nil
+	}
+	auth, err := c.login(ctx, c.email, c.password)
+	if err != nil {
+		return "", err
+	}
+	c.setToken(auth.Token)
+	return c.token, nil
+}
+
+// send sends r, retrying while the API answers 429 or 5xx. A request that
+// may change something is not retried when the server may have acted on it:
+// after an error of its own (500) or of the connection.
+func (c *Client) send(ctx context.Context, r request, token string) (*http.Response, error) {
+	u := c.baseURL + "/api/v1" + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	idempotent := r.method == http.MethodGet || r.method == http.MethodPut || r.method == http.MethodDelete
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, r.method, u, bytes.NewReader(r.body))
+		if err != nil {
+			return nil, fmt.Errorf("client: %w", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		if r.contentType != "" {
+			req.Header.Set("Content-Type", r.contentType)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := c.http.Do(req)
+		var retry bool
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			retry = idempotent
+		} else {
+			switch resp.StatusCode {
+			case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				retry = true
+			case http.StatusInternalServerError:
+				retry = idempotent
+			}
+		}
+		if !retry || attempt >= c.retries {
+			if err != nil {
+				return nil, fmt.Errorf("client: %s %s: %w", r.method, r.path, err)
+			}
+			return resp, nil
+		}
+
+		wait := c.wait(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// wait is how long to wait before retry attempt+1: what the server asked
+// for with Retry-After, or exponential backoff with jitter
+func (c *Client) wait(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxBackoff)
+		}
+	}
+	backoff := min(c.backoff<<attempt, maxBackoff)
+	if backoff <= 0 {
+		return 0
+	}
+	// Between half and all of it, so that clients spread out
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// Error is a problem the API answered a request with
+type Error struct {
+	Status int
+	// Code is stable, unlike Detail; see the Code constants
+	Code    string
+	Title   string
+	Detail  string
+	TraceID string
+	// Fields says what is wrong with each field, for CodeValidationFailed
+	Fields []FieldError
+	// Account says why and until when, for CodeAccountSuspended and
+	// CodeAccountBanned
+	Account *AccountStanding
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("why API: %d %s", e.Status, e.Code)
+	}
+	return fmt.Sprintf("why API: %d %s: %s", e.Status, e.Code, e.Detail)
+}
+
+// decodeError reads the problem of a failed response. Responses that are not
+// problems, such as a proxy's, become an Error with just the status.
+func decodeError(resp *http.Response) error {
+	e := &Error{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var problem struct {
+		Title   string           `json:"title"`
+		Detail  string           `json:"detail"`
+		Code    string           `json:"code"`
+		TraceID string           `json:"trace_id"`
+		Errors  []FieldError     `json:"errors"`
+		Account *AccountStanding `json:"account"`
+	}
+	if json.Unmarshal(body, &problem) == nil && problem.Code != "" {
+		e.Code, e.Detail, e.TraceID, e.Fields, e.Account = problem.Code, problem.Detail, problem.TraceID, problem.Errors, problem.Account
+		if problem.Title != "" {
+			e.Title = problem.Title
+		}
+	}
+	return e
+}
+
+// HasCode reports whether err is an *Error with code
+func HasCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
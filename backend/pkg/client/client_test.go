@@ -0,0 +1,317 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"why-backend/internal/api"
+	"why-backend/internal/api/middleware"
+	"why-backend/internal/auth"
+	"why-backend/internal/events"
+	"why-backend/internal/models"
+	"why-backend/internal/moderation"
+	"why-backend/internal/realtime"
+	"why-backend/internal/search"
+	"why-backend/internal/stream"
+	"why-backend/internal/testutil"
+)
+
+const (
+	testUserID = "user-123"
+	testEmail  = "bot@example.com"
+)
+
+// newTestServer serves the API's router over a mock database
+func newTestServer(t *testing.T) (*httptest.Server, sqlmock.Sqlmock) {
+	gin.SetMode(gin.TestMode)
+	db, mock := testutil.SetupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	_ = middleware.InitMetrics(context.Background())
+
+	bus := events.NewMemoryBus()
+	router := api.NewRouter(db, nil, bus, search.NewPostgres(db), stream.NewHub(bus, stream.Options{}), realtime.NewHub(bus, realtime.Options{}), moderation.Chain{}, testutil.GetTestConfig())
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, mock
+}
+
+// newTestClient returns a client of server that retries without waiting
+func newTestClient(t *testing.T, server *httptest.Server, opts ...Option) *Client {
+	c, err := New(server.URL, append([]Option{WithRetries(DefaultRetries, time.Millisecond)}, opts...)...)
+	require.NoError(t, err)
+	return c
+}
+
+// testToken is a token the test router accepts for testUserID
+func testToken(t *testing.T) string {
+	token, err := auth.GenerateToken(testUserID, testEmail, testutil.GetTestConfig().JWTSecret)
+	require.NoError(t, err)
+	return token
+}
+
+// expectStanding expects testUserID's account standing to be read
+func expectStanding(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT banned_at IS NOT NULL, .+ FROM users WHERE id = \\$1").
+		WithArgs(testUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned", "ban_reason", "suspended_until", "suspension_reason"}).
+			AddRow(false, "", nil, ""))
+}
+
+// expectLogin expects testUserID to sign in with password
+func expectLogin(t *testing.T, mock sqlmock.Sqlmock, password string) {
+	hash, err := auth.HashPassword(password)
+	require.NoError(t, err)
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, email, handle, role, password_hash, created_at, updated_at FROM users WHERE email = \\$1").
+		WithArgs(testEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "handle", "role", "password_hash", "created_at", "updated_at"}).
+			AddRow(testUserID, testEmail, nil, models.RoleUser, hash, now, now))
+	expectStanding(mock)
+}
+
+// expectMe expects the signed in user to be read for /me
+func expectMe(mock sqlmock.Sqlmock) {
+	now := time.Now()
+	mock.ExpectQuery("FROM users WHERE id = \\$1").
+		WithArgs(testUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "handle", "role", "created_at", "updated_at"}).
+			AddRow(testUserID, testEmail, "bot", models.RoleUser, now, now))
+}
+
+func TestNew_InvalidBaseURL(t *testing.T) {
+	_, err := New("why.example.com")
+	assert.Error(t, err)
+}
+
+func TestClient_SignupAndCreateMessage(t *testing.T) {
+	server, mock := newTestServer(t)
+	c := newTestClient(t, server)
+
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs(testEmail, sqlmock.AnyArg(), "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "handle", "role", "created_at", "updated_at"}).
+			AddRow(testUserID, testEmail, nil, models.RoleUser, now, now))
+
+	signedUp, err := c.Signup(context.Background(), SignupRequest{Email: testEmail, Password: "password123"})
+	require.NoError(t, err)
+	assert.Equal(t, testUserID, signedUp.User.ID)
+	assert.Equal(t, signedUp.Token, c.Token())
+
+	expectStanding(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO messages").
+		WithArgs(testUserID, "Why is the sky blue?", sqlmock.AnyArg(), sqlmock.AnyArg(), models.VisibilityPublic).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "mentions", "created_at", "updated_at", "visibility"}).
+			AddRow("5f0c6a1e-7d2b-4a8e-9c3f-000000000001", testUserID, "Why is the sky blue?", pq.StringArray{}, nil, `[]`, now, now, "public"))
+	mock.ExpectCommit()
+
+	message, err := c.CreateMessage(context.Background(), CreateMessageRequest{Content: "Why is the sky blue?"})
+	require.NoError(t, err)
+	assert.Equal(t, "5f0c6a1e-7d2b-4a8e-9c3f-000000000001", message.ID)
+	assert.Equal(t, VisibilityPublic, message.Visibility)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_Login_InvalidCredentials(t *testing.T) {
+	server, mock := newTestServer(t)
+	c := newTestClient(t, server)
+
+	mock.ExpectQuery("SELECT id, email, handle, role, password_hash, created_at, updated_at FROM users WHERE email = \\$1").
+		WithArgs(testEmail).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := c.Login(context.Background(), testEmail, "wrong")
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+	assert.True(t, HasCode(err, CodeInvalidCredentials))
+	assert.Empty(t, c.Token())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_RefreshesExpiringToken(t *testing.T) {
+	server, mock := newTestServer(t)
+	// Test tokens last a day, so this one is always about to expire
+	c := newTestClient(t, server, WithToken(testToken(t)), WithRefreshBefore(25*time.Hour))
+
+	// POST /refresh reads the user and their standing afresh, then GET /me
+	// is served with the new token
+	expectStanding(mock)
+	expectMe(mock)
+	expectStanding(mock)
+	expectMe(mock)
+
+	user, err := c.Me(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, testUserID, user.ID)
+	assert.NotEmpty(t, c.Token())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_SignsInAgainWhenTokenRejected(t *testing.T) {
+	server, mock := newTestServer(t)
+	c := newTestClient(t, server)
+
+	expectLogin(t, mock, "password123")
+	_, err := c.Login(context.Background(), testEmail, "password123")
+	require.NoError(t, err)
+
+	// As if the token had been revoked
+	c.mu.Lock()
+	c.setToken("revoked")
+	c.mu.Unlock()
+
+	expectLogin(t, mock, "password123")
+	expectMe(mock)
+
+	user, err := c.Me(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, testUserID, user.ID)
+	assert.NotEqual(t, "revoked", c.Token())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_TokenRejectedWithoutPassword(t *testing.T) {
+	server, _ := newTestServer(t)
+	c := newTestClient(t, server, WithToken("revoked"))
+
+	_, err := c.Me(context.Background())
+
+	assert.True(t, HasCode(err, CodeInvalidToken), "%v", err)
+}
+
+func TestClient_GetMessage_NotFound(t *testing.T) {
+	server, mock := newTestServer(t)
+	c := newTestClient(t, server)
+
+	mock.ExpectQuery("SELECT m.id, m.user_id, .+ FROM messages m WHERE m.id").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := c.GetMessage(context.Background(), "5f0c6a1e-7d2b-4a8e-9c3f-000000000001")
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+	assert.Equal(t, CodeNotFound, apiErr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_CreateMessage_ValidationFailed(t *testing.T) {
+	server, mock := newTestServer(t)
+	c := newTestClient(t, server, WithToken(testToken(t)))
+
+	// Rejected against the OpenAPI document before the account is read
+	_, err := c.CreateMessage(context.Background(), CreateMessageRequest{})
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, CodeValidationFailed, apiErr.Code)
+	require.Len(t, apiErr.Fields, 1)
+	assert.Equal(t, "content", apiErr.Fields[0].Field)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// stubServer answers with the given handlers in turn, counting the requests
+func stubServer(t *testing.T, handlers ...http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(calls.Add(1)) - 1
+		handlers[min(i, len(handlers)-1)](w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func status(code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}
+}
+
+func user(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"id":"user-123","email":"bot@example.com"}`))
+}
+
+func TestClient_RetriesUnavailable(t *testing.T) {
+	server, calls := stubServer(t, status(http.StatusServiceUnavailable), status(http.StatusBadGateway), user)
+	c := newTestClient(t, server, WithToken("token"))
+
+	me, err := c.Me(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, testUserID, me.ID)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_HonorsRetryAfter(t *testing.T) {
+	limited := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	server, calls := stubServer(t, limited, user)
+	// Retry-After wins over the backoff, which would time the test out
+	c, err := New(server.URL, WithToken("token"), WithRetries(1, time.Hour))
+	require.NoError(t, err)
+
+	_, err = c.Me(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestClient_GivesUpAfterRetries(t *testing.T) {
+	server, calls := stubServer(t, status(http.StatusServiceUnavailable))
+	c := newTestClient(t, server, WithToken("token"), WithRetries(2, time.Millisecond))
+
+	_, err := c.Me(context.Background())
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.Status)
+	assert.Empty(t, apiErr.Code)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_DoesNotRetryFailedPost(t *testing.T) {
+	failed := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"type":"about:blank","title":"Internal Server Error","status":500,"code":"internal_error","detail":"failed to create message"}`))
+	}
+	server, calls := stubServer(t, failed)
+	c := newTestClient(t, server, WithToken("token"))
+
+	_, err := c.CreateMessage(context.Background(), CreateMessageRequest{Content: "Why?"})
+
+	assert.True(t, HasCode(err, CodeInternal), "%v", err)
+	assert.EqualError(t, err, "why API: 500 internal_error: failed to create message")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_ContextCanceledWhileWaiting(t *testing.T) {
+	server, _ := stubServer(t, status(http.StatusServiceUnavailable))
+	c, err := New(server.URL, WithToken("token"), WithRetries(3, time.Hour))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.Me(ctx)
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+}
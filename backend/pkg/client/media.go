@@ -0,0 +1,48 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// UploadOptions controls UploadMedia
+type UploadOptions struct {
+	// ConversationID stores the file privately, for that conversation's
+	// direct messages
+	ConversationID string
+}
+
+// UploadMedia uploads an image or video and returns its URL, which messages,
+// replies and direct messages may then attach. The server takes the file's
+// type from the extension of name. The file is read into memory, so that the
+// upload can be retried.
+func (c *Client) UploadMedia(ctx context.Context, name string, file io.Reader, opts *UploadOptions) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err == nil {
+		_, err = io.Copy(part, file)
+	}
+	if err == nil && opts != nil && opts.ConversationID != "" {
+		err = form.WriteField("conversation_id", opts.ConversationID)
+	}
+	if err == nil {
+		err = form.Close()
+	}
+	if err != nil {
+		return "", fmt.Errorf("client: read %s: %w", name, err)
+	}
+
+	var uploaded struct {
+		URL string `json:"url"`
+	}
+	r := request{method: http.MethodPost, path: "/media", body: body.Bytes(), contentType: form.FormDataContentType(), auth: true}
+	if err := c.do(ctx, r, &uploaded); err != nil {
+		return "", err
+	}
+	return uploaded.URL, nil
+}
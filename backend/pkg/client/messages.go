@@ -0,0 +1,267 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListMessagesOptions filters ListMessages
+type ListMessagesOptions struct {
+	// Unanswered leaves out messages with an accepted reply
+	Unanswered bool
+}
+
+// ListMessages returns the 50 newest messages the signed in user, or anyone
+// when signed out, may read
+func (c *Client) ListMessages(ctx context.Context, opts *ListMessagesOptions) ([]Message, error) {
+	query := url.Values{}
+	if opts != nil && opts.Unanswered {
+		query.Set("filter", "unanswered")
+	}
+	var messages []Message
+	if err := c.call(ctx, http.MethodGet, "/messages", query, nil, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetMessage returns a message
+func (c *Client) GetMessage(ctx context.Context, id string) (*Message, error) {
+	var message Message
+	if err := c.call(ctx, http.MethodGet, "/messages/"+url.PathEscape(id), nil, nil, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// CreateMessage posts a message. A message the content filter holds for a
+// moderator comes back with PendingReview set.
+func (c *Client) CreateMessage(ctx context.Context, req CreateMessageRequest) (*Message, error) {
+	var message Message
+	if err := c.call(ctx, http.MethodPost, "/messages", nil, req, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// UpdateMessage edits a message of the signed in user. Edits are filtered
+// like new messages: a held edit comes back with PendingReview set.
+func (c *Client) UpdateMessage(ctx context.Context, id string, req UpdateMessageRequest) (*Message, error) {
+	var message Message
+	if err := c.call(ctx, http.MethodPatch, "/messages/"+url.PathEscape(id), nil, req, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// Reply orders
+const (
+	SortOldest = "created_at"
+	// SortScore puts the accepted answer first, then the best scored replies
+	SortScore = "score"
+)
+
+// ListRepliesOptions orders ListReplies
+type ListRepliesOptions struct {
+	// Sort is SortOldest, the default, or SortScore
+	Sort string
+}
+
+// ListReplies returns a message's replies
+func (c *Client) ListReplies(ctx context.Context, messageID string, opts *ListRepliesOptions) ([]Reply, error) {
+	query := url.Values{}
+	if opts != nil && opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	var replies []Reply
+	if err := c.call(ctx, http.MethodGet, "/messages/"+url.PathEscape(messageID)+"/replies", query, nil, &replies); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+// CreateReply replies to a message. A reply the content filter holds comes
+// back with PendingReview set.
+func (c *Client) CreateReply(ctx context.Context, messageID string, req CreateReplyRequest) (*Reply, error) {
+	var reply Reply
+	if err := c.call(ctx, http.MethodPost, "/messages/"+url.PathEscape(messageID)+"/replies", nil, req, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// AcceptReply marks a reply as the answer to the signed in user's message
+func (c *Client) AcceptReply(ctx context.Context, messageID, replyID string) (*Message, error) {
+	return c.accept(ctx, http.MethodPost, messageID, replyID)
+}
+
+// UnacceptReply withdraws the accepted answer
+func (c *Client) UnacceptReply(ctx context.Context, messageID, replyID string) (*Message, error) {
+	return c.accept(ctx, http.MethodDelete, messageID, replyID)
+}
+
+func (c *Client) accept(ctx context.Context, method, messageID, replyID string) (*Message, error) {
+	var message Message
+	path := "/messages/" + url.PathEscape(messageID) + "/replies/" + url.PathEscape(replyID) + "/accept"
+	if err := c.call(ctx, method, path, nil, nil, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// VoteReply votes a reply up (1) or down (-1), or takes the vote back (0)
+func (c *Client) VoteReply(ctx context.Context, replyID string, value int) (*VoteResponse, error) {
+	var vote VoteResponse
+	body := VoteRequest{Value: &value}
+	if err := c.call(ctx, http.MethodPut, "/replies/"+url.PathEscape(replyID)+"/vote", nil, body, &vote); err != nil {
+		return nil, err
+	}
+	return &vote, nil
+}
+
+// PageOptions controls the paging of an iterator
+type PageOptions struct {
+	// Limit is the page size; the server's default when zero
+	Limit int
+	// Cursor starts after a page fetched earlier
+	Cursor string
+}
+
+func (o *PageOptions) query() url.Values {
+	query := url.Values{}
+	if o == nil {
+		return query
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		query.Set("cursor", o.Cursor)
+	}
+	return query
+}
+
+// TagMessages iterates over the public messages with a tag, newest first,
+// fetching pages as it goes
+func (c *Client) TagMessages(ctx context.Context, tag string, opts *PageOptions) *Iterator[Message] {
+	return paginate(ctx, c, "/tags/"+url.PathEscape(tag)+"/messages", opts.query(), func(page *MessagePage) ([]Message, string) {
+		return page.Messages, page.NextCursor
+	})
+}
+
+// HomeTimeline iterates over the messages of the users the signed in user
+// follows, newest first
+func (c *Client) HomeTimeline(ctx context.Context, opts *PageOptions) *Iterator[Message] {
+	return paginate(ctx, c, "/timeline/home", opts.query(), func(page *MessagePage) ([]Message, string) {
+		return page.Messages, page.NextCursor
+	})
+}
+
+// SearchOptions narrows a search. From and To are dates or RFC 3339 times.
+type SearchOptions struct {
+	Query string
+	// Type is "message" or "reply"; both when empty
+	Type   string
+	Tag    string
+	Author string
+	From   string
+	To     string
+	PageOptions
+}
+
+// Search iterates over the messages and replies matching a search, best
+// first
+func (c *Client) Search(ctx context.Context, opts SearchOptions) *Iterator[SearchResult] {
+	query := opts.PageOptions.query()
+	for name, value := range map[string]string{
+		"q": opts.Query, "type": opts.Type, "tag": opts.Tag, "author": opts.Author, "from": opts.From, "to": opts.To,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	return paginate(ctx, c, "/search", query, func(page *SearchPage) ([]SearchResult, string) {
+		return page.Results, page.NextCursor
+	})
+}
+
+// Iterator walks the items of a paged list, fetching the next page when it
+// runs out:
+//
+//	it := c.TagMessages(ctx, "go", nil)
+//	for it.Next() {
+//		message := it.Value()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type Iterator[T any] struct {
+	fetch  func(cursor string) ([]T, string, error)
+	items  []T
+	cursor string
+	value  T
+	done   bool
+	err    error
+}
+
+// Next moves to the next item, and reports whether there is one. It returns
+// false at the end of the list or when a page could not be fetched.
+func (it *Iterator[T]) Next() bool {
+	for len(it.items) == 0 {
+		if it.done {
+			return false
+		}
+		items, next, err := it.fetch(it.cursor)
+		if err != nil {
+			it.err, it.done = err, true
+			return false
+		}
+		it.items, it.cursor, it.done = items, next, next == ""
+	}
+	it.value, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+// Value is the current item
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+// Err is the error that ended the iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Cursor starts a later iteration, in PageOptions, after the page fetched
+// last; it is empty at the end of the list
+func (it *Iterator[T]) Cursor() string {
+	return it.cursor
+}
+
+// paginate iterates over the items of the pages at path, following their
+// cursors
+func paginate[P, T any](ctx context.Context, c *Client, path string, query url.Values, items func(*P) ([]T, string)) *Iterator[T] {
+	return &Iterator[T]{
+		cursor: query.Get("cursor"),
+		fetch: func(cursor string) ([]T, string, error) {
+			page := cloneQuery(query)
+			if cursor != "" {
+				page.Set("cursor", cursor)
+			}
+			var p P
+			if err := c.call(ctx, http.MethodGet, path, page, nil, &p); err != nil {
+				return nil, "", err
+			}
+			list, next := items(&p)
+			return list, next, nil
+		},
+	}
+}
+
+func cloneQuery(query url.Values) url.Values {
+	clone := make(url.Values, len(query))
+	for k, v := range query {
+		clone[k] = v
+	}
+	return clone
+}
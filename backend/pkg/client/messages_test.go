@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var messageColumns = []string{"id", "user_id", "content", "media_urls", "accepted_reply_id", "tags", "mentions", "created_at", "updated_at", "visibility"}
+
+func TestClient_ListReplies_ByScore(t *testing.T) {
+	server, mock := newTestServer(t)
+	c := newTestClient(t, server)
+
+	messageID := "5f0c6a1e-7d2b-4a8e-9c3f-000000000001"
+	now := time.Now()
+	mock.ExpectQuery("FROM replies r .+ WHERE r.message_id = \\$1 .+ ORDER BY accepted DESC, r.score DESC").
+		WithArgs(messageID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "user_id", "content", "media_urls", "mentions", "score", "accepted", "created_at", "updated_at"}).
+			AddRow("reply-2", messageID, "user-2", "Rayleigh scattering", pq.StringArray{}, `[]`, 3, true, now, now).
+			AddRow("reply-1", messageID, "user-1", "It reflects the sea", pq.StringArray{}, `[]`, -1, false, now, now))
+
+	replies, err := c.ListReplies(context.Background(), messageID, &ListRepliesOptions{Sort: SortScore})
+
+	require.NoError(t, err)
+	require.Len(t, replies, 2)
+	assert.Equal(t, "reply-2", replies[0].ID)
+	assert.True(t, replies[0].Accepted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_TagMessages_FollowsCursors(t *testing.T) {
+	server, mock := newTestServer(t)
+	c := newTestClient(t, server)
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := func(rows *sqlmock.Rows, id string, age time.Duration) *sqlmock.Rows {
+		return rows.AddRow(id, "user-1", "Why #go", pq.StringArray{}, nil, pq.StringArray{"go"}, `[]`, now.Add(-age), now, "public")
+	}
+
+	// limit+1 rows are read to tell whether there is a next page
+	first := sqlmock.NewRows(messageColumns)
+	row(first, "5f0c6a1e-7d2b-4a8e-9c3f-000000000003", 0)
+	row(first, "5f0c6a1e-7d2b-4a8e-9c3f-000000000002", time.Minute)
+	row(first, "5f0c6a1e-7d2b-4a8e-9c3f-000000000001", 2*time.Minute)
+	mock.ExpectQuery("FROM messages m JOIN message_tags t ON t.message_id = m.id WHERE t.tag = \\$1 .+ ORDER BY").
+		WithArgs("go", 3).
+		WillReturnRows(first)
+
+	second := sqlmock.NewRows(messageColumns)
+	row(second, "5f0c6a1e-7d2b-4a8e-9c3f-000000000001", 2*time.Minute)
+	mock.ExpectQuery("WHERE t.tag = \\$1 .+ AND \\(m.created_at, m.id\\) < \\(\\$3, \\$4\\)").
+		WithArgs("go", 3, now.Add(-time.Minute), "5f0c6a1e-7d2b-4a8e-9c3f-000000000002").
+		WillReturnRows(second)
+
+	var ids []string
+	it := c.TagMessages(context.Background(), "go", &PageOptions{Limit: 2})
+	for it.Next() {
+		ids = append(ids, it.Value().ID)
+	}
+
+	require.NoError(t, it.Err())
+	assert.Equal(t, []string{
+		"5f0c6a1e-7d2b-4a8e-9c3f-000000000003",
+		"5f0c6a1e-7d2b-4a8e-9c3f-000000000002",
+		"5f0c6a1e-7d2b-4a8e-9c3f-000000000001",
+	}, ids)
+	assert.Empty(t, it.Cursor())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_TagMessages_StopsOnError(t *testing.T) {
+	server, mock := newTestServer(t)
+	c := newTestClient(t, server, WithRetries(0, 0))
+
+	mock.ExpectQuery("FROM messages m JOIN message_tags t").
+		WillReturnError(errors.New("connection reset"))
+
+	it := c.TagMessages(context.Background(), "go", nil)
+
+	assert.False(t, it.Next())
+	assert.True(t, HasCode(it.Err(), CodeInternal), "%v", it.Err())
+	assert.False(t, it.Next())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_Search_SendsFilters(t *testing.T) {
+	var query string
+	server, _ := stubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"results":[{"type":"message","id":"m1","message_id":"m1","user_id":"u1","snippet":"why","created_at":"2026-01-02T03:04:05Z"}]}`))
+	})
+	c := newTestClient(t, server)
+
+	it := c.Search(context.Background(), SearchOptions{Query: "sky", Tag: "go", PageOptions: PageOptions{Limit: 10}})
+
+	require.True(t, it.Next())
+	assert.Equal(t, "m1", it.Value().ID)
+	assert.False(t, it.Next())
+	require.NoError(t, it.Err())
+	assert.Equal(t, "limit=10&q=sky&tag=go", query)
+}
+
+func TestClient_UploadMedia(t *testing.T) {
+	var name, data, conversationID string
+	server, _ := stubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err == nil {
+			content, _ := io.ReadAll(file)
+			name, data = header.Filename, string(content)
+		}
+		conversationID = r.FormValue("conversation_id")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"url":"/api/v1/conversations/c1/media/abc.png"}`))
+	})
+	c := newTestClient(t, server, WithToken("token"))
+
+	url, err := c.UploadMedia(context.Background(), "photo.png", strings.NewReader("png"), &UploadOptions{ConversationID: "c1"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/conversations/c1/media/abc.png", url)
+	assert.Equal(t, "photo.png", name)
+	assert.Equal(t, "png", data)
+	assert.Equal(t, "c1", conversationID)
+}
+
+func TestClient_UploadMedia_ConversationNotParticipant(t *testing.T) {
+	server, mock := newTestServer(t)
+	c := newTestClient(t, server, WithToken(testToken(t)))
+
+	conversationID := "5f0c6a1e-7d2b-4a8e-9c3f-0000000000c1"
+	expectStanding(mock)
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM conversation_participants WHERE conversation_id = \\$1 AND user_id = \\$2\\)").
+		WithArgs(conversationID, testUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err := c.UploadMedia(context.Background(), "photo.png", strings.NewReader("png"), &UploadOptions{ConversationID: conversationID})
+
+	assert.True(t, HasCode(err, CodeNotFound), "%v", err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"why-backend/internal/accounts"
+	"why-backend/internal/api/apierror"
+	"why-backend/internal/models"
+)
+
+// The API's types are the server's own, so that the client cannot drift
+// from it
+type (
+	User                 = models.User
+	AuthResponse         = models.AuthResponse
+	SignupRequest        = models.SignupRequest
+	AccountStanding      = accounts.Standing
+	Message              = models.Message
+	MessagePage          = models.MessagePage
+	CreateMessageRequest = models.CreateMessageRequest
+	UpdateMessageRequest = models.UpdateMessageRequest
+	Reply                = models.Reply
+	CreateReplyRequest   = models.CreateReplyRequest
+	VoteRequest          = models.VoteRequest
+	VoteResponse         = models.VoteResponse
+	Mention              = models.Mention
+	Mentions             = models.Mentions
+	SearchResult         = models.SearchResult
+	SearchPage           = models.SearchPage
+	FieldError           = models.FieldError
+)
+
+// Message visibility levels
+const (
+	VisibilityPublic    = models.VisibilityPublic
+	VisibilityUnlisted  = models.VisibilityUnlisted
+	VisibilityFollowers = models.VisibilityFollowers
+)
+
+// Error codes
+const (
+	CodeInvalidRequest     = apierror.CodeInvalidRequest
+	CodeValidationFailed   = apierror.CodeValidationFailed
+	CodeUnauthorized       = apierror.CodeUnauthorized
+	CodeInvalidCredentials = apierror.CodeInvalidCredentials
+	CodeInvalidToken       = apierror.CodeInvalidToken
+	CodeForbidden          = apierror.CodeForbidden
+	CodeAccountSuspended   = apierror.CodeAccountSuspended
+	CodeAccountBanned      = apierror.CodeAccountBanned
+	CodeNotFound           = apierror.CodeNotFound
+	CodeMethodNotAllowed   = apierror.CodeMethodNotAllowed
+	CodeConflict           = apierror.CodeConflict
+	CodeEmailTaken         = apierror.CodeEmailTaken
+	CodeHandleTaken        = apierror.CodeHandleTaken
+	CodeRequestTooLarge    = apierror.CodeRequestTooLarge
+	CodeContentRejected    = apierror.CodeContentRejected
+	CodeInternal           = apierror.CodeInternal
+)
@@ -49,7 +49,7 @@ export async function createMessage(content: string, mediaUrls: string[] = []):
 
   if (!response.ok) {
     const error = await response.json()
-    throw new Error(error.error || 'Failed to create message')
+    throw new Error(error.detail || 'Failed to create message')
   }
 
   return response.json()
@@ -76,7 +76,7 @@ export async function createReply(messageId: string, content: string, mediaUrls:
 
   if (!response.ok) {
     const error = await response.json()
-    throw new Error(error.error || 'Failed to create reply')
+    throw new Error(error.detail || 'Failed to create reply')
   }
 
   return response.json()
@@ -35,7 +35,7 @@ export async function signup(email: string, password: string): Promise<AuthRespo
 
   if (!response.ok) {
     const error = await response.json()
-    throw new Error(error.error || 'Signup failed')
+    throw new Error(error.detail || 'Signup failed')
   }
 
   const data = await response.json()
@@ -52,7 +52,7 @@ export async function login(email: string, password: string): Promise<AuthRespon
 
   if (!response.ok) {
     const error = await response.json()
-    throw new Error(error.error || 'Login failed')
+    throw new Error(error.detail || 'Login failed')
   }
 
   const data = await response.json()